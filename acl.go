@@ -0,0 +1,81 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// =============================================================================
+// Access Control Lists
+// =============================================================================
+
+// Extended ACL entries as getfacl and nfs4_getfacl print them. Entries for
+// the owner, group and others mirror the permission bits, which
+// 'permissions' manages, so they are not accepted here.
+var (
+	posixACLEntry = regexp.MustCompile(`^(user|group):[^:,\s]+:[r-][w-][x-]$`)
+	nfs4ACLEntry  = regexp.MustCompile(`^[ADUL]:[a-zA-Z]*:[^:,\s]+:[a-zA-Z]+$`)
+)
+
+// checkACLEntries rejects ACL entries the server would reject or that would
+// read back differently and show as drift forever.
+func checkACLEntries(entries []string) error {
+	for _, entry := range entries {
+		switch {
+		case posixACLEntry.MatchString(entry):
+		case nfs4ACLEntry.MatchString(entry):
+			if principal := strings.Split(entry, ":")[2]; slices.Contains(asyncsftp.NFS4ModePrincipals, principal) {
+				return fmt.Errorf("invalid 'acl' entry %q: %s follows 'permissions'", entry, principal)
+			}
+		default:
+			return fmt.Errorf("invalid 'acl' entry %q: must be a named user or group entry as getfacl prints it (e.g. user:deploy:rw-) or as nfs4_getfacl does (e.g. A::deploy@example.com:rxtncy)", entry)
+		}
+	}
+	return nil
+}
+
+// aclType returns the target's aclType, or the default when the config does
+// not parse.
+func aclType(targetConfig json.RawMessage) string {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || cfg.ACLType == "" {
+		return asyncsftp.ACLTypePOSIX
+	}
+	return cfg.ACLType
+}
+
+// sameACL compares ACLs the way the server applies them: POSIX entries are
+// a set, while NFSv4 entries are evaluated in order.
+func sameACL(a, b []string) bool {
+	if len(a) > 0 && posixACLEntry.MatchString(a[0]) {
+		a, b = slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b))
+	}
+	return slices.Equal(a, b)
+}
+
+// readACL fills in the file's extended ACL entries when the target allows
+// exec. Servers without the ACL tools are treated as having none.
+func readACL(log plugin.Logger, client *asyncsftp.Client, targetConfig json.RawMessage, props *FileProperties) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || !cfg.AllowExec || props.FileType != string(asyncsftp.FileTypeRegular) {
+		return
+	}
+	entries, err := client.GetACL(props.Path, aclType(targetConfig))
+	if err != nil {
+		log.Debug("could not read acl", "path", props.Path, "error", err)
+		return
+	}
+	if len(entries) > 0 {
+		props.ACL = entries
+	}
+}
@@ -0,0 +1,80 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Append Mode
+// =============================================================================
+
+// checkAppend rejects properties that cannot be combined with append mode.
+// Appended content is a fragment of someone else's file, so it is neither
+// parsed as a whole document nor staged and validated.
+func checkAppend(props *FileProperties) error {
+	switch {
+	case props.Content == "" && len(props.ContentParts) == 0:
+		return fmt.Errorf("'writeMode' append requires 'content' or 'contentParts'")
+	case props.ContentFormat != "" && props.ContentFormat != contentFormatText:
+		return fmt.Errorf("'writeMode' append only supports text content")
+	case props.RemoteValidateCommand != "":
+		return fmt.Errorf("'remoteValidateCommand' cannot be combined with 'writeMode' append")
+	case props.VerifyUpload:
+		return fmt.Errorf("'verifyUpload' cannot be combined with 'writeMode' append")
+	case props.ProtectNewer:
+		return fmt.Errorf("'protectNewer' cannot be combined with 'writeMode' append")
+	case props.ChecksumFile != "":
+		return fmt.Errorf("'checksumFile' cannot be combined with 'writeMode' append")
+	}
+	return nil
+}
+
+// checkAppendTarget requires a deployment manifest for append mode. Reads
+// and deletes only see a path; the manifest is what tells them the file is
+// shared and only the declared fragment belongs to formae.
+func checkAppendTarget(targetConfig json.RawMessage, props *FileProperties) error {
+	if props.WriteMode != writeModeAppend {
+		return nil
+	}
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return err
+	}
+	if cfg.ManifestPath == "" {
+		return fmt.Errorf("'writeMode' append requires 'manifestPath' on the target")
+	}
+	return nil
+}
+
+// reportAppended reports the declared fragment as the file's content while
+// the file still contains it. Once it is gone the whole file is reported, so
+// the agent sees drift and appends it again.
+func reportAppended(props *FileProperties, declared string) {
+	props.WriteMode = writeModeAppend
+	if strings.Contains(props.Content, declared) {
+		props.Content = declared
+	}
+}
+
+// appendManaged reports whether the manifest records the file at path as
+// append-managed, i.e. shared with other writers. An unreadable manifest is
+// an error rather than "no", so a shared file is never deleted by mistake.
+func appendManaged(client *asyncsftp.Client, targetConfig json.RawMessage, path string) (bool, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || cfg.ManifestPath == "" {
+		return false, err
+	}
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		return false, fmt.Errorf("read deployment manifest: %w", err)
+	}
+	return m.Files[path].WriteMode == writeModeAppend, nil
+}
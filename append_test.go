@@ -0,0 +1,67 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAppendMode verifies a fragment is appended once to a shared file, read
+// back as declared while present, and left in place on delete.
+func TestAppendMode(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+	require.NoError(t, client.WriteFile("/upload/drop.csv", []byte("id,name\n1,other"), 0644))
+
+	props := json.RawMessage(`{"path":"/upload/drop.csv","content":"2,formae\n","writeMode":"append"}`)
+	apply := func() {
+		created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: props, TargetConfig: target})
+		require.NoError(t, err)
+		require.Equal(t, resource.OperationStatusInProgress, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+		require.Eventually(t, func() bool {
+			status, err := p.Status(ctx, &resource.StatusRequest{RequestID: created.ProgressResult.RequestID, TargetConfig: target})
+			require.NoError(t, err)
+			require.NotEqual(t, resource.OperationStatusFailure, status.ProgressResult.OperationStatus, status.ProgressResult.StatusMessage)
+			return status.ProgressResult.OperationStatus == resource.OperationStatusSuccess
+		}, time.Second, time.Millisecond)
+	}
+	apply()
+	apply()
+
+	info, err := client.ReadFile("/upload/drop.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "id,name\n1,other\n2,formae\n", info.Content)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/drop.csv", TargetConfig: target})
+	require.NoError(t, err)
+	var got FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &got))
+	assert.Equal(t, "2,formae\n", got.Content)
+	assert.Equal(t, writeModeAppend, got.WriteMode)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: fileType, NativeID: "/upload/drop.csv", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	_, err = client.Stat("/upload/drop.csv")
+	assert.NoError(t, err)
+
+	noManifest := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: props, TargetConfig: noManifest})
+	require.NoError(t, err)
+	assert.Contains(t, created.ProgressResult.StatusMessage, "manifestPath")
+	_, err = parseFileProperties(json.RawMessage(`{"path":"/a","content":"{}","writeMode":"append","contentFormat":"json"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Authentication Lockout
+// =============================================================================
+
+// errAuthLockedOut marks a connection refused locally because the target
+// rejected too many consecutive logins.
+var errAuthLockedOut = errors.New("authentication locked out")
+
+// Defaults for the target's authFailureLimit and authLockoutPeriod.
+const (
+	defaultAuthFailureLimit  = 3
+	defaultAuthLockoutPeriod = 5 * time.Minute
+)
+
+// authLockout counts consecutive authentication failures and, past the
+// limit, refuses to reconnect until the lockout period has passed, so
+// repeated syncs with bad credentials do not trip fail2ban-style bans.
+type authLockout struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// check returns an error while connections are locked out.
+func (l *authLockout) check(now time.Time) error {
+	if now.Before(l.lockedUntil) {
+		return fmt.Errorf("%w: %d consecutive authentication failures; not retrying until %s",
+			errAuthLockedOut, l.failures, l.lockedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// record updates the count with the outcome of a connection attempt and
+// reports whether it started a lockout. A limit of 0 disables lockouts.
+func (l *authLockout) record(err error, now time.Time, limit int, period time.Duration) bool {
+	if !errors.Is(err, asyncsftp.ErrAuthFailed) {
+		if err == nil {
+			l.failures = 0
+		}
+		return false
+	}
+	l.failures++
+	if limit <= 0 || l.failures < limit {
+		return false
+	}
+	l.lockedUntil = now.Add(period)
+	return true
+}
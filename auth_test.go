@@ -0,0 +1,42 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuthLockout verifies connections stop after the failure limit, resume
+// after the lockout period, and that other errors neither count nor reset.
+func TestAuthLockout(t *testing.T) {
+	var l authLockout
+	now := time.Now()
+	authErr := asyncsftp.ErrAuthFailed
+
+	assert.False(t, l.record(authErr, now, 3, time.Minute))
+	assert.False(t, l.record(asyncsftp.ErrUnreachable, now, 3, time.Minute))
+	assert.False(t, l.record(authErr, now, 3, time.Minute))
+	assert.NoError(t, l.check(now))
+
+	assert.True(t, l.record(authErr, now, 3, time.Minute))
+	assert.ErrorIs(t, l.check(now.Add(30*time.Second)), errAuthLockedOut)
+	assert.NoError(t, l.check(now.Add(time.Minute)))
+
+	assert.False(t, l.record(nil, now, 3, time.Minute))
+	assert.Equal(t, 0, l.failures)
+
+	var disabled authLockout
+	for range 10 {
+		assert.False(t, disabled.record(errors.Join(authErr), now, 0, time.Minute))
+	}
+	assert.NoError(t, disabled.check(now))
+}
@@ -0,0 +1,308 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"golang.org/x/crypto/ssh"
+)
+
+// =============================================================================
+// Authorized Key
+// =============================================================================
+
+// authorizedKeyType manages a single public key line in an authorized_keys
+// file. Other lines - keys added by hand or by other teams - are left
+// exactly as they are.
+const authorizedKeyType = "SFTP::SSH::AuthorizedKey"
+
+// authorizedKeyEditAttempts is how many times an edit is retried when the
+// file changes between reading and writing it.
+const authorizedKeyEditAttempts = 3
+
+// authorizedKeysMode is applied to authorized_keys files the plugin creates;
+// sshd's StrictModes rejects group- or world-writable ones.
+const authorizedKeysMode = 0600
+
+// authorizedKeyHandler manages SFTP::SSH::AuthorizedKey resources.
+type authorizedKeyHandler struct {
+	plugin *Plugin
+}
+
+// AuthorizedKeyProperties are the properties of an AuthorizedKey resource.
+type AuthorizedKeyProperties struct {
+	// Path is the authorized_keys file, e.g. /home/deploy/.ssh/authorized_keys.
+	Path string `json:"path"`
+	// Key is the full line: optional options, key type, base64 key and
+	// optional comment.
+	Key string `json:"key"`
+}
+
+// parseAuthorizedKeyProperties extracts AuthorizedKey properties from a JSON
+// request and returns them with the key's SHA256 fingerprint.
+func parseAuthorizedKeyProperties(data json.RawMessage) (*AuthorizedKeyProperties, string, error) {
+	var props AuthorizedKeyProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, "", fmt.Errorf("invalid authorized key properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, "", fmt.Errorf("authorized key properties missing 'path'")
+	}
+	props.Key = strings.TrimSpace(props.Key)
+	if strings.ContainsAny(props.Key, "\r\n") {
+		return nil, "", fmt.Errorf("'key' must be a single authorized_keys line")
+	}
+	fingerprint, ok := keyFingerprint(props.Key)
+	if !ok {
+		return nil, "", fmt.Errorf("invalid 'key': not an authorized_keys line")
+	}
+	return &props, fingerprint, nil
+}
+
+// keyFingerprint returns the SHA256 fingerprint of an authorized_keys line,
+// or false for comments, blank lines and lines that do not parse.
+func keyFingerprint(line string) (string, bool) {
+	pub, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil || len(bytes.TrimSpace(rest)) > 0 {
+		return "", false
+	}
+	return ssh.FingerprintSHA256(pub), true
+}
+
+// authorizedKeyNativeID identifies a key within a file: the path and the
+// key's fingerprint, e.g. /home/deploy/.ssh/authorized_keys#SHA256:...
+func authorizedKeyNativeID(path, fingerprint string) string {
+	return path + "#" + fingerprint
+}
+
+// splitAuthorizedKeyNativeID is the inverse of authorizedKeyNativeID.
+func splitAuthorizedKeyNativeID(nativeID string) (path, fingerprint string, err error) {
+	path, fingerprint, ok := strings.Cut(nativeID, "#")
+	if !ok || path == "" || fingerprint == "" {
+		return "", "", fmt.Errorf("%w: %q is not an authorized key ID", errInvalidPath, nativeID)
+	}
+	return path, fingerprint, nil
+}
+
+// findKey returns the line holding the key with fingerprint, if any.
+func findKey(content, fingerprint string) (string, bool) {
+	for line := range strings.SplitSeq(content, "\n") {
+		if fp, ok := keyFingerprint(line); ok && fp == fingerprint {
+			return strings.TrimSpace(line), true
+		}
+	}
+	return "", false
+}
+
+// setKey returns content with the key with fingerprint replaced by line, or
+// removed when line is empty. A key not yet present is appended.
+func setKey(content, fingerprint, line string) string {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	out := make([]string, 0, len(lines)+1)
+	placed := false
+	for _, l := range lines {
+		if fp, ok := keyFingerprint(l); ok && fp == fingerprint {
+			if line != "" && !placed {
+				out = append(out, line)
+				placed = true
+			}
+			continue
+		}
+		out = append(out, l)
+	}
+	if line != "" && !placed {
+		out = append(out, line)
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// editAuthorizedKeys rewrites the file at path with edit. Each attempt reads
+// the file, and re-reads it just before writing: if someone else changed it
+// in between, the edit is redone on their version rather than clobbering
+// it. The file is written to a temporary sibling and renamed into place.
+func editAuthorizedKeys(client *asyncsftp.Client, path string, edit func(content string) string) error {
+	for range authorizedKeyEditAttempts {
+		before, mode, err := readAuthorizedKeys(client, path)
+		if err != nil {
+			return err
+		}
+		after := edit(before)
+		if after == before {
+			return nil
+		}
+
+		current, _, err := readAuthorizedKeys(client, path)
+		if err != nil {
+			return err
+		}
+		if current != before {
+			continue
+		}
+		return client.WriteFile(path, []byte(after), mode)
+	}
+	return fmt.Errorf("%w: %s kept changing while it was being edited", errConflict, path)
+}
+
+// readAuthorizedKeys returns the file's content and permissions. A missing
+// file reads as empty, with the permissions sshd requires.
+func readAuthorizedKeys(client *asyncsftp.Client, path string) (string, os.FileMode, error) {
+	info, err := client.ReadFile(path)
+	if errors.Is(err, asyncsftp.ErrNotFound) {
+		return "", authorizedKeysMode, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if info.Type != asyncsftp.FileTypeRegular {
+		return "", 0, fmt.Errorf("%w: %s is a %s, not a regular file", errInvalidPath, path, info.Type)
+	}
+	var mode os.FileMode = authorizedKeysMode
+	if info.Permissions != "" {
+		_, _ = fmt.Sscanf(info.Permissions, "%o", &mode)
+	}
+	return info.Content, mode, nil
+}
+
+// putKey adds or replaces the key line declared in properties.
+func (h *authorizedKeyHandler) putKey(ctx context.Context, log plugin.Logger, op resource.Operation, targetConfig, properties json.RawMessage) *resource.ProgressResult {
+	props, fingerprint, err := parseAuthorizedKeyProperties(properties)
+	if err == nil {
+		err = checkPath(targetConfig, props.Path)
+	}
+	if err != nil {
+		return failureResult(op, "", resource.OperationErrorCodeInvalidRequest, err)
+	}
+
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err == nil {
+		props.Path, err = resolvePath(client, targetConfig, props.Path)
+	}
+	if err != nil {
+		return failureResult(op, "", errorCode(err), err)
+	}
+	nativeID := authorizedKeyNativeID(props.Path, fingerprint)
+
+	err = editAuthorizedKeys(client, props.Path, func(content string) string {
+		return setKey(content, fingerprint, props.Key)
+	})
+	if err != nil {
+		return failureResult(op, nativeID, errorCode(err), err)
+	}
+	log.Info("authorized key in place", "path", props.Path, "fingerprint", fingerprint)
+
+	propsJSON, _ := json.Marshal(props)
+	return &resource.ProgressResult{
+		Operation:          op,
+		OperationStatus:    resource.OperationStatusSuccess,
+		NativeID:           nativeID,
+		ResourceProperties: propsJSON,
+	}
+}
+
+// Create adds the key to the file, creating the file if needed. A key that
+// is already present has its line replaced with the declared one.
+func (h *authorizedKeyHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+	return &resource.CreateResult{ProgressResult: h.putKey(ctx, log, resource.OperationCreate, req.TargetConfig, req.Properties)}, nil
+}
+
+// Read reports the key's current line in the file.
+func (h *authorizedKeyHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	path, fingerprint, err := splitAuthorizedKeyNativeID(req.NativeID)
+	if err == nil {
+		err = checkPath(req.TargetConfig, path)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	info, err := client.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+	line, ok := findKey(info.Content, fingerprint)
+	if !ok {
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: resource.OperationErrorCodeNotFound}, nil
+	}
+
+	propsJSON, _ := json.Marshal(AuthorizedKeyProperties{Path: path, Key: line})
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update replaces the key's line, e.g. to change its options or comment.
+func (h *authorizedKeyHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+	result := h.putKey(ctx, log, resource.OperationUpdate, req.TargetConfig, req.DesiredProperties)
+	if result.NativeID == "" {
+		result.NativeID = req.NativeID
+	}
+	return &resource.UpdateResult{ProgressResult: result}, nil
+}
+
+// Delete removes the key's line, leaving the rest of the file alone.
+func (h *authorizedKeyHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	path, fingerprint, err := splitAuthorizedKeyNativeID(req.NativeID)
+	if err == nil {
+		err = checkPath(req.TargetConfig, path)
+	}
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	err = editAuthorizedKeys(client, path, func(content string) string {
+		return setKey(content, fingerprint, "")
+	})
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+	log.Info("authorized key removed", "path", path, "fingerprint", fingerprint)
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
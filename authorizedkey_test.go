@@ -0,0 +1,95 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testKey returns a fresh ed25519 authorized_keys line with comment.
+func testKey(t *testing.T, comment string) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " " + comment
+}
+
+// TestSetKey verifies key lines are appended, replaced in place and removed
+// while comments and other keys are preserved.
+func TestSetKey(t *testing.T) {
+	alice, bob := testKey(t, "alice"), testKey(t, "bob")
+	fp, ok := keyFingerprint(bob)
+	require.True(t, ok)
+
+	content := "# team keys\n" + alice + "\n"
+	added := setKey(content, fp, bob)
+	assert.Equal(t, "# team keys\n"+alice+"\n"+bob+"\n", added)
+
+	restricted := `no-pty,from="10.0.0.0/8" ` + bob
+	assert.Equal(t, "# team keys\n"+alice+"\n"+restricted+"\n", setKey(added, fp, restricted))
+	assert.Equal(t, content, setKey(added, fp, ""))
+	assert.Equal(t, bob+"\n", setKey("", fp, bob))
+
+	_, ok = keyFingerprint("# not a key")
+	assert.False(t, ok)
+}
+
+// TestAuthorizedKey verifies the resource adds its key next to existing
+// ones, reads it back by fingerprint and removes only its own line.
+func TestAuthorizedKey(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/home/deploy/.ssh", "")
+
+	const path = "/home/deploy/.ssh/authorized_keys"
+	existing, ci := testKey(t, "ops"), testKey(t, "ci@example")
+	require.NoError(t, client.WriteFile(path, []byte(existing+"\n"), 0600))
+
+	propsJSON, _ := json.Marshal(AuthorizedKeyProperties{Path: path, Key: ci})
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: authorizedKeyType,
+		Properties:   propsJSON,
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	nativeID := created.ProgressResult.NativeID
+	assert.True(t, strings.HasPrefix(nativeID, path+"#SHA256:"), nativeID)
+
+	info, err := client.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, existing+"\n"+ci+"\n", info.Content)
+	assert.Equal(t, "0600", info.Permissions)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: authorizedKeyType, NativeID: nativeID, TargetConfig: target})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(propsJSON), read.Properties)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: authorizedKeyType, NativeID: nativeID, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus, deleted.ProgressResult.StatusMessage)
+
+	info, err = client.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, existing+"\n", info.Content)
+
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: authorizedKeyType, NativeID: nativeID, TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeNotFound, read.ErrorCode)
+}
@@ -0,0 +1,87 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// =============================================================================
+// Managed Banner
+// =============================================================================
+
+// bannerNone opts a file out of the target's banner.
+const bannerNone = "none"
+
+// defaultBannerComment is used when a file does not choose a comment syntax.
+const defaultBannerComment = "#"
+
+// bannerComments maps each supported comment syntax to the text placed
+// before and after each banner line.
+var bannerComments = map[string][2]string{
+	"#":    {"# ", ""},
+	"//":   {"// ", ""},
+	"--":   {"-- ", ""},
+	";":    {"; ", ""},
+	"<!--": {"<!-- ", " -->"},
+	"/*":   {"/* ", " */"},
+}
+
+// renderBanner comments out every line of text in the given syntax.
+func renderBanner(text, comment string) string {
+	delims := bannerComments[comment]
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(delims[0] + line + delims[1] + "\n")
+	}
+	return b.String()
+}
+
+// bannerComment returns the comment syntax for a file's banner, or "" when the
+// file gets none. JSON has no comments, so json content never gets one, and
+// appended fragments would repeat it inside someone else's file.
+func (props *FileProperties) bannerComment(cfg *TargetConfig) string {
+	if cfg.Banner == "" || props.BannerComment == bannerNone || props.ContentFormat == contentFormatJSON || props.WriteMode == writeModeAppend {
+		return ""
+	}
+	if props.BannerComment == "" {
+		return defaultBannerComment
+	}
+	return props.BannerComment
+}
+
+// uploadContent returns the content to write: the declared content, preceded
+// by the target's banner when one applies.
+func uploadContent(targetConfig json.RawMessage, props *FileProperties) string {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return props.Content
+	}
+	comment := props.bannerComment(cfg)
+	if comment == "" {
+		return props.Content
+	}
+	return renderBanner(cfg.Banner, comment) + props.Content
+}
+
+// stripBanner removes the target's banner from content read back from the
+// server, in whichever syntax it was written, and reports that syntax so the
+// banner is not seen as drift.
+func stripBanner(targetConfig json.RawMessage, props *FileProperties) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || cfg.Banner == "" {
+		return
+	}
+	for comment := range bannerComments {
+		if rest, ok := strings.CutPrefix(props.Content, renderBanner(cfg.Banner, comment)); ok {
+			props.Content = rest
+			if comment != defaultBannerComment {
+				props.BannerComment = comment
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBannerRoundTrip verifies the banner is prepended on upload in the
+// chosen syntax and stripped again on read.
+func TestBannerRoundTrip(t *testing.T) {
+	target := json.RawMessage(`{"url":"sftp://localhost","banner":"Managed by formae\nDo not edit"}`)
+
+	for comment := range bannerComments {
+		t.Run(comment, func(t *testing.T) {
+			props := &FileProperties{Content: "key=value\n", BannerComment: comment}
+			uploaded := uploadContent(target, props)
+			assert.NotEqual(t, props.Content, uploaded)
+
+			read := &FileProperties{Content: uploaded}
+			stripBanner(target, read)
+			assert.Equal(t, "key=value\n", read.Content)
+			if comment != defaultBannerComment {
+				assert.Equal(t, comment, read.BannerComment)
+			}
+		})
+	}
+
+	assert.Equal(t, "# Managed by formae\n# Do not edit\nx", uploadContent(target, &FileProperties{Content: "x"}))
+	assert.Equal(t, "x", uploadContent(target, &FileProperties{Content: "x", BannerComment: bannerNone}))
+	assert.Equal(t, "{}", uploadContent(target, &FileProperties{Content: "{}", ContentFormat: contentFormatJSON}))
+}
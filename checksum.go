@@ -0,0 +1,78 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Checksums
+// =============================================================================
+
+// verifyHashes are the algorithms verifyUpload may compare by.
+var verifyHashes = map[string]bool{"": true, "sha256": true, "sha512": true, "xxhash": true}
+
+// checksumFileAlgorithms are the algorithms checksum files may use; B2B
+// partners still ask for md5.
+var checksumFileAlgorithms = map[string]bool{"sha256": true, "sha512": true, "md5": true}
+
+// checkChecksumFile validates the checksum file properties.
+func checkChecksumFile(props *FileProperties) error {
+	if props.ChecksumFile != "" && !checksumFileAlgorithms[props.ChecksumFile] {
+		return fmt.Errorf("invalid 'checksumFile' %q: must be sha256, sha512 or md5", props.ChecksumFile)
+	}
+	switch asyncsftp.SidecarFormat(props.ChecksumFileFormat) {
+	case "", asyncsftp.SidecarGNU, asyncsftp.SidecarBSD, asyncsftp.SidecarHash:
+	default:
+		return fmt.Errorf("invalid 'checksumFileFormat' %q: must be gnu, bsd or hash", props.ChecksumFileFormat)
+	}
+	if props.ChecksumFileFormat != "" && props.ChecksumFile == "" {
+		return fmt.Errorf("'checksumFileFormat' requires 'checksumFile'")
+	}
+	return nil
+}
+
+// sidecar returns the checksum file to write with each upload, if any.
+func (props *FileProperties) sidecar() *asyncsftp.Sidecar {
+	if props.ChecksumFile == "" {
+		return nil
+	}
+	return &asyncsftp.Sidecar{
+		Algorithm: asyncsftp.HashAlgorithm(props.ChecksumFile),
+		Format:    asyncsftp.SidecarFormat(props.ChecksumFileFormat),
+	}
+}
+
+// checksumFileLayout describes the checksum file, e.g. "sha256 (gnu)", or
+// "none", for comparing and reporting changes.
+func (props *FileProperties) checksumFileLayout() string {
+	if props.ChecksumFile == "" {
+		return "none"
+	}
+	format := props.ChecksumFileFormat
+	if format == "" {
+		format = string(asyncsftp.SidecarGNU)
+	}
+	return fmt.Sprintf("%s (%s)", props.ChecksumFile, format)
+}
+
+// updateChecksumFile brings the checksum file of the file at p from prior
+// to desired: a checksum file under another name is removed, and the
+// desired one written unless the file was just rewritten with it.
+func updateChecksumFile(client *asyncsftp.Client, p string, prior, desired *FileProperties, rewritten bool) error {
+	want := desired.sidecar()
+	if prior != nil && prior.ChecksumFile != "" && (want == nil || prior.ChecksumFile != desired.ChecksumFile) {
+		if err := client.Remove(prior.sidecar().Path(p)); err != nil {
+			return fmt.Errorf("remove checksum file: %w", err)
+		}
+	}
+	if want == nil || rewritten {
+		return nil
+	}
+	return client.WriteSidecar(p, *want)
+}
@@ -0,0 +1,297 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// =============================================================================
+// Content Assembly
+// =============================================================================
+
+// errContentSource marks a contentParts source that could not be fetched.
+var errContentSource = errors.New("content source unavailable")
+
+// contentFetchTimeout bounds each URL fetch for contentParts.
+const contentFetchTimeout = 30 * time.Second
+
+// ContentPart is one source of a file's content. Exactly one of Inline, File
+// (a path on the agent host) or URL (http or https) is set.
+type ContentPart struct {
+	Inline *string `json:"inline,omitempty"`
+	File   string  `json:"file,omitempty"`
+	URL    string  `json:"url,omitempty"`
+}
+
+// validate checks that exactly one source is set.
+func (part *ContentPart) validate() error {
+	sources := 0
+	if part.Inline != nil {
+		sources++
+	}
+	if part.File != "" {
+		sources++
+	}
+	if part.URL != "" {
+		u, err := url.Parse(part.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid content part url %q: must be http:// or https://", part.URL)
+		}
+		sources++
+	}
+	if sources != 1 {
+		return fmt.Errorf("each content part must set exactly one of 'inline', 'file' or 'url'")
+	}
+	return nil
+}
+
+// fetch returns the part's content.
+func (part *ContentPart) fetch(ctx context.Context) (string, error) {
+	switch {
+	case part.Inline != nil:
+		return *part.Inline, nil
+	case part.File != "":
+		data, err := os.ReadFile(part.File)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errContentSource, err)
+		}
+		return string(data), nil
+	default:
+		return fetchURL(ctx, part.URL)
+	}
+}
+
+func fetchURL(ctx context.Context, rawURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, contentFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errContentSource, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errContentSource, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: GET %s: %s", errContentSource, rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: GET %s: %w", errContentSource, rawURL, err)
+	}
+	return string(data), nil
+}
+
+// checkInlineSize rejects a file declaring more inline content, in content
+// or inline contentParts, than maxInlineContentSize allows. Content that
+// large travels in every request between the agent and the plugin, so it
+// belongs in a file or url content part, which the plugin reads itself.
+func (p *Plugin) checkInlineSize(props *FileProperties) error {
+	size := len(props.Content)
+	for _, part := range props.ContentParts {
+		if part.Inline != nil {
+			size += len(*part.Inline)
+		}
+	}
+	if limit := p.settings.maxInlineContentSize(); size > limit {
+		return fmt.Errorf("%w: %d bytes of inline content is more than maxInlineContentSize (%d); declare it as a file or url in contentParts instead", errInvalidContent, size, limit)
+	}
+	return nil
+}
+
+// assembleContent concatenates the file's contentParts, in order, into
+// Content. Files without contentParts are left as they are.
+func assembleContent(ctx context.Context, props *FileProperties) error {
+	if len(props.ContentParts) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for i := range props.ContentParts {
+		part, err := props.ContentParts[i].fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("content part %d: %w", i, err)
+		}
+		b.WriteString(part)
+	}
+	props.Content = b.String()
+	return nil
+}
+
+// =============================================================================
+// Content Formats
+// =============================================================================
+
+// Formats accepted by contentFormat. Structured formats are compared
+// semantically, so key order and whitespace differences are not drift. XML
+// is only checked for well-formedness and compared byte-wise like text.
+const (
+	contentFormatText = "text"
+	contentFormatJSON = "json"
+	contentFormatYAML = "yaml"
+	contentFormatXML  = "xml"
+)
+
+// errInvalidContent marks content rejected by its format's syntax check or
+// by the file's validateCommand.
+var errInvalidContent = errors.New("invalid content")
+
+// parseStructured decodes content in a structured format.
+func parseStructured(format, content string) (any, error) {
+	var v any
+	var err error
+	switch format {
+	case contentFormatJSON:
+		err = json.Unmarshal([]byte(content), &v)
+	case contentFormatYAML:
+		err = yaml.Unmarshal([]byte(content), &v)
+	default:
+		return nil, fmt.Errorf("unsupported content format %q", format)
+	}
+	return v, err
+}
+
+// checkContentFormat verifies the content parses as its declared format.
+func checkContentFormat(props *FileProperties) error {
+	var err error
+	switch props.ContentFormat {
+	case "", contentFormatText:
+		return nil
+	case contentFormatXML:
+		err = checkXML(props.Content)
+	default:
+		_, err = parseStructured(props.ContentFormat, props.Content)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: content is not valid %s: %w", errInvalidContent, props.ContentFormat, err)
+	}
+	return nil
+}
+
+// checkXML verifies content is a well-formed XML document.
+func checkXML(content string) error {
+	dec := xml.NewDecoder(strings.NewReader(content))
+	root := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			root = true
+		}
+	}
+	if !root {
+		return errors.New("no root element")
+	}
+	return nil
+}
+
+// contentEqual compares two contents as format: byte-wise for text, and by
+// parsed value for json and yaml. Content that fails to parse is compared
+// byte-wise.
+func contentEqual(format, a, b string) bool {
+	if a == b {
+		return true
+	}
+	switch format {
+	case contentFormatJSON, contentFormatYAML:
+	default:
+		return false
+	}
+	va, errA := parseStructured(format, a)
+	vb, errB := parseStructured(format, b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// =============================================================================
+// Content Validation
+// =============================================================================
+
+// validateTimeout bounds a validateCommand run.
+const validateTimeout = time.Minute
+
+// maxValidateOutput caps how much of a failed validateCommand's output is
+// reported.
+const maxValidateOutput = 2048
+
+// lintContent rejects content that fails its format's syntax check or the
+// file's validateCommand before anything is uploaded. The command sees the
+// content as it will be written, banner included.
+func (p *Plugin) lintContent(ctx context.Context, targetConfig json.RawMessage, props *FileProperties) error {
+	if err := checkContentFormat(props); err != nil {
+		return err
+	}
+	if props.ValidateCommand == "" {
+		return nil
+	}
+	if !p.settings.AllowValidateCommand {
+		return fmt.Errorf("%w: 'validateCommand' runs on the agent host and is disabled; set allowValidateCommand in the plugin settings to enable it", errInvalidContent)
+	}
+	return runValidateCommand(ctx, props.ValidateCommand, props.Path, uploadContent(targetConfig, props))
+}
+
+// runValidateCommand runs command with sh on the agent host. The content is
+// on its standard input, and in a temporary file named like the target file
+// whose path is in $FORMAE_FILE, for tools that only read files or go by
+// extension. A non-zero exit rejects the content with the command's output.
+func runValidateCommand(ctx context.Context, command, target, content string) error {
+	dir, err := os.MkdirTemp("", "formae-validate-")
+	if err != nil {
+		return fmt.Errorf("validateCommand: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, path.Base(target))
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		return fmt.Errorf("validateCommand: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Env = append(os.Environ(), "FORMAE_FILE="+file)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", validateTimeout)
+	}
+	msg := strings.TrimSpace(string(out))
+	if len(msg) > maxValidateOutput {
+		msg = msg[:maxValidateOutput] + "..."
+	}
+	if msg == "" {
+		return fmt.Errorf("%w: validateCommand rejected the content: %w", errInvalidContent, err)
+	}
+	return fmt.Errorf("%w: validateCommand rejected the content: %w: %s", errInvalidContent, err, msg)
+}
@@ -0,0 +1,97 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAssembleContent verifies that inline, file and URL parts are
+// concatenated in order.
+func TestAssembleContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/host.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("host=web1\n"))
+	}))
+	defer server.Close()
+
+	snippet := filepath.Join(t.TempDir(), "common.conf")
+	require.NoError(t, os.WriteFile(snippet, []byte("shared=true\n"), 0644))
+
+	header := "# managed by formae\n"
+	props := &FileProperties{ContentParts: []ContentPart{
+		{Inline: &header},
+		{File: snippet},
+		{URL: server.URL + "/host.conf"},
+	}}
+	require.NoError(t, assembleContent(context.Background(), props))
+	assert.Equal(t, "# managed by formae\nshared=true\nhost=web1\n", props.Content)
+
+	props = &FileProperties{ContentParts: []ContentPart{{URL: server.URL + "/missing"}}}
+	assert.ErrorIs(t, assembleContent(context.Background(), props), errContentSource)
+}
+
+// TestParseContentParts verifies each part names exactly one source.
+func TestParseContentParts(t *testing.T) {
+	_, err := parseFileProperties([]byte(`{"path":"/a","contentParts":[{"inline":"x","file":"/tmp/y"}]}`))
+	assert.Error(t, err)
+
+	_, err = parseFileProperties([]byte(`{"path":"/a","content":"x","contentParts":[{"inline":"y"}]}`))
+	assert.Error(t, err)
+
+	_, err = parseFileProperties([]byte(`{"path":"/a","contentParts":[{"url":"ftp://example.com/x"}]}`))
+	assert.Error(t, err)
+
+	props, err := parseFileProperties([]byte(`{"path":"/a","contentParts":[{"inline":""},{"url":"https://example.com/x"}]}`))
+	require.NoError(t, err)
+	assert.Len(t, props.ContentParts, 2)
+}
+
+// TestContentEqual verifies structured formats ignore key order and
+// whitespace while text stays byte-wise.
+func TestContentEqual(t *testing.T) {
+	assert.True(t, contentEqual(contentFormatJSON, `{"a":1,"b":[1,2]}`, "{\n  \"b\": [1, 2],\n  \"a\": 1\n}\n"))
+	assert.False(t, contentEqual(contentFormatJSON, `{"a":1}`, `{"a":2}`))
+	assert.True(t, contentEqual(contentFormatYAML, "a: 1\nb: x\n", "b: x   # comment\na: 1"))
+	assert.False(t, contentEqual(contentFormatYAML, "a: [1, 2]", "a: [2, 1]"))
+	assert.False(t, contentEqual(contentFormatText, "a: 1", "a:  1"))
+	assert.False(t, contentEqual(contentFormatJSON, "{", "{ "))
+}
+
+// TestLintContent verifies broken structured content and content failing
+// validateCommand are rejected, and that the command must be allowed.
+func TestLintContent(t *testing.T) {
+	p := &Plugin{}
+	ctx := context.Background()
+
+	err := p.lintContent(ctx, nil, &FileProperties{Content: "<a><b></a>", ContentFormat: contentFormatXML})
+	assert.ErrorIs(t, err, errInvalidContent)
+	assert.NoError(t, p.lintContent(ctx, nil, &FileProperties{Content: `<?xml version="1.0"?><a><b/></a>`, ContentFormat: contentFormatXML}))
+	assert.Error(t, p.lintContent(ctx, nil, &FileProperties{Content: "plain", ContentFormat: contentFormatXML}))
+
+	check := &FileProperties{Path: "/upload/app.conf", Content: "ok\n", ValidateCommand: `grep -qx ok "$FORMAE_FILE" && grep -qx ok`}
+	assert.ErrorContains(t, p.lintContent(ctx, nil, check), "allowValidateCommand")
+
+	p.settings.AllowValidateCommand = true
+	assert.NoError(t, p.lintContent(ctx, nil, check))
+	err = p.lintContent(ctx, nil, &FileProperties{Path: "/upload/app.conf", Content: "broken", ValidateCommand: "echo bad line 1 >&2; exit 1"})
+	assert.ErrorIs(t, err, errInvalidContent)
+	assert.ErrorContains(t, err, "bad line 1")
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, errorCode(err))
+}
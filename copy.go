@@ -0,0 +1,328 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Copy
+// =============================================================================
+
+// copyType is a file copied from another target, e.g. a release promoted
+// from a staging server to production. The content streams through the
+// plugin, never through the operator's machine. A copy reproduces the
+// source as it was when applied: later changes at the source are not
+// followed, so promotions pin a versioned sourcePath and change it to
+// promote again.
+const copyType = "SFTP::Files::Copy"
+
+// copyHandler manages SFTP::Files::Copy resources.
+type copyHandler struct {
+	plugin *Plugin
+}
+
+// CopyProperties are the properties of a Copy resource.
+type CopyProperties struct {
+	// Path is the destination on this target (the native ID).
+	Path string `json:"path"`
+	// SourceTarget is the target configuration of the server copied from,
+	// in the same form as a target's. Its credentials come from
+	// SFTP_SOURCE_USERNAME and SFTP_SOURCE_PASSWORD when set.
+	SourceTarget json.RawMessage `json:"sourceTarget,omitempty"`
+	// SourcePath is the regular file copied.
+	SourcePath string `json:"sourcePath,omitempty"`
+	// Permissions is the octal mode of the copy. Defaults to "0644".
+	Permissions string `json:"permissions"`
+
+	// Read-only: the copy on this target.
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// CopySource records where a copied file came from.
+type CopySource struct {
+	Target json.RawMessage `json:"target"`
+	Path   string          `json:"path"`
+}
+
+// parseCopyProperties extracts Copy properties from a JSON request.
+func parseCopyProperties(data json.RawMessage) (*CopyProperties, error) {
+	var props CopyProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid copy properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, fmt.Errorf("copy properties missing 'path'")
+	}
+	if len(props.SourceTarget) == 0 || props.SourcePath == "" {
+		return nil, fmt.Errorf("copy properties need 'sourceTarget' and 'sourcePath'")
+	}
+	if props.Permissions == "" {
+		props.Permissions = "0644"
+	}
+	if _, err := parseMode("permissions", props.Permissions, "0644"); err != nil {
+		return nil, err
+	}
+	props.SHA256, props.Size = "", 0
+	return &props, nil
+}
+
+// checkCopy validates both ends of a copy and returns the destination's
+// target configuration. The manifest records the source, which Read cannot
+// learn from the destination path.
+func checkCopy(targetConfig json.RawMessage, props *CopyProperties) (*TargetConfig, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ManifestPath == "" {
+		return nil, fmt.Errorf("%s requires 'manifestPath' on the target", copyType)
+	}
+	if err := validatePath(props.Path, cfg.Root); err != nil {
+		return nil, err
+	}
+	source, err := parseTargetConfig(props.SourceTarget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'sourceTarget': %w", err)
+	}
+	if err := validatePath(props.SourcePath, source.Root); err != nil {
+		return nil, fmt.Errorf("invalid 'sourcePath': %w", err)
+	}
+	return cfg, nil
+}
+
+// sourceClient returns the client for a copy's source target. Source
+// clients are kept and reused like the destination's, under keys of their
+// own: a copy only reads from its source, so they are never swept. Their
+// rejected logins count towards the same lockout as the destination's.
+func (p *Plugin) sourceClient(ctx context.Context, log plugin.Logger, sourceTarget json.RawMessage) (*asyncsftp.Client, error) {
+	cfg, err := parseTargetConfig(sourceTarget)
+	if err != nil {
+		return nil, err
+	}
+	// Sources share the destination's credentials unless they have their own
+	prefix := sourceCredentialsEnvPrefix
+	if os.Getenv(prefix+"_USERNAME") == "" {
+		prefix = credentialsEnvPrefix
+	}
+	clientCfg, err := p.clientConfig(cfg, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("source target: %w", err)
+	}
+	client, err := p.sharedClient(ctx, log, "source|"+clientKey(clientCfg, cfg), clientCfg, cfg, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to source target: %w", err)
+	}
+	return client, nil
+}
+
+// copyFile copies the source file to the destination, records it in the
+// manifest and returns the resulting properties. The content streams from
+// the source into a staged file on the destination, hashed on the way, so
+// only a chunk of it is in memory at a time, and the write waits its turn
+// in the destination's worker queue like any other upload.
+func (h *copyHandler) copyFile(ctx context.Context, log plugin.Logger, targetConfig json.RawMessage, cfg *TargetConfig, label string, props *CopyProperties) (*CopyProperties, error) {
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Admit(); err != nil {
+		log.Warn("copy rejected: queue is full", "path", props.Path, "error", err)
+		countThrottled(ctx, "queue_full")
+		return nil, err
+	}
+	source, err := h.plugin.sourceClient(ctx, log, props.SourceTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	info, r, err := source.OpenFile(props.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from source target: %w", props.SourcePath, err)
+	}
+	if info.Type != asyncsftp.FileTypeRegular {
+		return nil, fmt.Errorf("%w: source %s is a %s, not a regular file", errInvalidPath, props.SourcePath, info.Type)
+	}
+	defer func() { _ = r.Close() }()
+	if err := client.CheckSize(info.Size); err != nil {
+		return nil, err
+	}
+
+	mode, _ := parseMode("permissions", props.Permissions, "0644")
+	sum := sha256.New()
+	op, err := client.Wait(ctx, client.StartUploadFrom(props.Path, io.TeeReader(r, sum), info.Size, mode))
+	if err != nil {
+		return nil, err
+	}
+	if op.State == asyncsftp.StateFailure {
+		return nil, op.Err
+	}
+	log.Info("copied file from source target", "path", props.Path, "source", source.Endpoint(), "sourcePath", props.SourcePath, "size", info.Size)
+
+	copied := &asyncsftp.FileInfo{Path: props.Path, Size: info.Size}
+	entry := ManifestEntry{Label: label, ResourceType: copyType, SHA256: hex.EncodeToString(sum.Sum(nil)),
+		CopiedFrom: &CopySource{Target: props.SourceTarget, Path: props.SourcePath}}
+	if err := recordManaged(client, cfg.ManifestPath, copied, entry); err != nil {
+		return nil, fmt.Errorf("failed to record copy in deployment manifest: %w", err)
+	}
+
+	current := *props
+	current.SHA256 = entry.SHA256
+	current.Size = info.Size
+	return &current, nil
+}
+
+// Create copies the file from the source target.
+func (h *copyHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+
+	props, err := parseCopyProperties(req.Properties)
+	var cfg *TargetConfig
+	if err == nil {
+		cfg, err = checkCopy(req.TargetConfig, props)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, err := h.copyFile(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationCreate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           props.Path,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Read reports the copy on this target, and its source while the copy is
+// still what was copied. Once it has been changed or replaced the source is
+// left out, so the next sync copies it again.
+func (h *copyHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err == nil {
+		err = validatePath(req.NativeID, cfg.Root)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	info, err := client.ReadFile(req.NativeID)
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	props := CopyProperties{
+		Path:        req.NativeID,
+		Permissions: info.Permissions,
+		SHA256:      contentHash(info.Content),
+		Size:        info.Size,
+	}
+	if cfg.ManifestPath != "" {
+		m, err := readManifest(client, cfg.ManifestPath)
+		if err != nil {
+			log.Debug("could not read deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+		} else if entry, ok := m.Files[req.NativeID]; ok && entry.CopiedFrom != nil && entry.SHA256 == props.SHA256 {
+			props.SourceTarget = entry.CopiedFrom.Target
+			props.SourcePath = entry.CopiedFrom.Path
+		}
+	}
+
+	propsJSON, _ := json.Marshal(props)
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update copies the file again, e.g. from a new sourcePath.
+func (h *copyHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	props, err := parseCopyProperties(req.DesiredProperties)
+	var cfg *TargetConfig
+	if err == nil {
+		cfg, err = checkCopy(req.TargetConfig, props)
+	}
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, err := h.copyFile(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.UpdateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationUpdate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           req.NativeID,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Delete removes the copy. The source file stays.
+func (h *copyHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	if err := client.Remove(req.NativeID); err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+	withManifest(log, req.TargetConfig, func(manifestPath string) error {
+		return forgetManaged(client, manifestPath, req.NativeID)
+	})
+	log.Info("copy removed", "path", req.NativeID)
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
@@ -0,0 +1,92 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCopy verifies a file is copied from another target, Read reports its
+// source until the copy is changed, delete leaves the source alone, the
+// source's client is reused, and a source larger than the destination's
+// maxFileSize is refused.
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	staging, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name() + "-staging", LocalDir: "/releases"})
+	require.NoError(t, err)
+	defer func() { _ = staging.Close() }()
+	require.NoError(t, staging.WriteFile("/releases/app-1.2.tgz", []byte("release 1.2"), 0644))
+
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+
+	props := json.RawMessage(`{"path":"/upload/app.tgz","sourceTarget":{"url":"memory://` + t.Name() + `-staging/releases"},"sourcePath":"/releases/app-1.2.tgz"}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: copyType, Properties: props, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	info, err := client.ReadFile("/upload/app.tgz")
+	require.NoError(t, err)
+	assert.Equal(t, "release 1.2", info.Content)
+	assert.Len(t, p.clients, 2, "the source's client is kept alongside the destination's")
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: copyType, NativeID: "/upload/app.tgz", TargetConfig: target})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(created.ProgressResult.ResourceProperties), read.Properties)
+
+	require.NoError(t, client.WriteFile("/upload/app.tgz", []byte("hotfix"), 0644))
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: copyType, NativeID: "/upload/app.tgz", TargetConfig: target})
+	require.NoError(t, err)
+	var got CopyProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &got))
+	assert.Empty(t, got.SourcePath)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: copyType, NativeID: "/upload/app.tgz", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	_, err = staging.Stat("/releases/app-1.2.tgz")
+	assert.NoError(t, err)
+
+	missing := json.RawMessage(`{"path":"/upload/app.tgz","sourceTarget":{"url":"memory://` + t.Name() + `-staging/releases"},"sourcePath":"/releases/app-9.9.tgz"}`)
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: copyType, Properties: missing, TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus)
+	assert.Len(t, p.clients, 2, "later copies reuse the source's client")
+
+	small := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","manifestPath":"/upload/.formae.json","maxFileSize":4}`)
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: copyType, Properties: props, TargetConfig: small})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+	_, err = client.Stat("/upload/app.tgz")
+	assert.ErrorIs(t, err, asyncsftp.ErrNotFound)
+	_, err = parseCopyProperties(json.RawMessage(`{"path":"/upload/app.tgz"}`))
+	assert.Error(t, err)
+}
+
+// TestCopyPermissions verifies a Copy accepts the same permissions as a
+// File, setuid, setgid and sticky bits included, and rejects the same ones.
+func TestCopyPermissions(t *testing.T) {
+	for _, perm := range []string{"0644", "4755", "2775", "1777", "7777", "10000", "0999", "rwx"} {
+		_, fileErr := parseFileProperties(json.RawMessage(`{"path":"/upload/a","content":"a","permissions":"` + perm + `"}`))
+		_, copyErr := parseCopyProperties(json.RawMessage(`{"path":"/upload/a","sourceTarget":{"url":"memory://s/upload"},"sourcePath":"/upload/a","permissions":"` + perm + `"}`))
+		if fileErr != nil {
+			assert.EqualError(t, copyErr, fileErr.Error(), perm)
+		} else {
+			assert.NoError(t, copyErr, perm)
+		}
+	}
+	_, err := parseCopyProperties(json.RawMessage(`{"path":"/upload/a","sourceTarget":{"url":"memory://s/upload"},"sourcePath":"/upload/a","permissions":"10000"}`))
+	assert.EqualError(t, err, `invalid 'permissions' "10000": must be an octal mode such as 0644`)
+}
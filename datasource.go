@@ -0,0 +1,148 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Data Sources
+// =============================================================================
+
+// dataSourceHandler serves read-only resources that report something about
+// the target without managing it. Create and Update query the target and
+// return the answer as the resource's properties, Read queries it again by
+// native ID (the path), and Delete leaves the target untouched.
+type dataSourceHandler struct {
+	plugin *Plugin
+	// query returns the data source's properties for path.
+	query func(client *asyncsftp.Client, cfg *TargetConfig, path string) (any, error)
+}
+
+// dataSource registers a data source answering with query.
+func dataSource(query func(client *asyncsftp.Client, cfg *TargetConfig, path string) (any, error)) func(*Plugin) resourceHandler {
+	return func(p *Plugin) resourceHandler { return &dataSourceHandler{plugin: p, query: query} }
+}
+
+// dataSourceProperties are the inputs every data source takes.
+type dataSourceProperties struct {
+	Path string `json:"path"`
+}
+
+// parseDataSourceProperties extracts the queried path from a JSON request.
+func parseDataSourceProperties(data json.RawMessage) (string, error) {
+	var props dataSourceProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return "", fmt.Errorf("invalid data source properties: %w", err)
+	}
+	if props.Path == "" {
+		return "", fmt.Errorf("data source properties missing 'path'")
+	}
+	return props.Path, nil
+}
+
+// run queries the data source at path and builds the operation's result.
+func (h *dataSourceHandler) run(ctx context.Context, log plugin.Logger, op resource.Operation, targetConfig, properties json.RawMessage) *resource.ProgressResult {
+	var path string
+	cfg, err := parseTargetConfig(targetConfig)
+	if err == nil {
+		path, err = parseDataSourceProperties(properties)
+	}
+	if err == nil {
+		err = validatePath(path, cfg.Root)
+	}
+	if err != nil {
+		return failureResult(op, "", resource.OperationErrorCodeInvalidRequest, err)
+	}
+
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err == nil {
+		path, err = resolvePath(client, targetConfig, path)
+	}
+	if err != nil {
+		return failureResult(op, "", errorCode(err), err)
+	}
+
+	props, err := h.query(client, cfg, path)
+	if err != nil {
+		return failureResult(op, "", errorCode(err), err)
+	}
+	propsJSON, _ := json.Marshal(h.plugin.redact(props))
+	return &resource.ProgressResult{
+		Operation:          op,
+		OperationStatus:    resource.OperationStatusSuccess,
+		NativeID:           path,
+		ResourceProperties: propsJSON,
+	}
+}
+
+// Create queries the target.
+func (h *dataSourceHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+	return &resource.CreateResult{ProgressResult: h.run(ctx, log, resource.OperationCreate, req.TargetConfig, req.Properties)}, nil
+}
+
+// Read queries the target again for the stored path.
+func (h *dataSourceHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err == nil {
+		err = validatePath(req.NativeID, cfg.Root)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	props, err := h.query(client, cfg, req.NativeID)
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(h.plugin.redact(props))
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update queries the target for the desired path.
+func (h *dataSourceHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+	result := h.run(ctx, log, resource.OperationUpdate, req.TargetConfig, req.DesiredProperties)
+	if result.NativeID == "" {
+		result.NativeID = req.NativeID
+	}
+	return &resource.UpdateResult{ProgressResult: result}, nil
+}
+
+// Delete forgets the data source; nothing on the target changes.
+func (h *dataSourceHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
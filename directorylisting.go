@@ -0,0 +1,110 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Directory Listing
+// =============================================================================
+
+// directoryListingType is a read-only data source listing the files in a
+// remote directory, so stacks can reference values such as the latest file
+// dropped into /incoming.
+const directoryListingType = "SFTP::Files::DirectoryListing"
+
+// DirectoryListingProperties are the properties of a DirectoryListing data
+// source.
+type DirectoryListingProperties struct {
+	// Path is the directory listed (the native ID).
+	Path string `json:"path"`
+	// Files are the directory's files, sorted by name. Subdirectories are
+	// not listed or descended into.
+	Files []DirectoryEntry `json:"files"`
+	// Latest is the path of the most recently modified file, empty when the
+	// directory has none.
+	Latest string `json:"latest,omitempty"`
+}
+
+// DirectoryEntry is one file in a DirectoryListing.
+type DirectoryEntry struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modifiedAt,omitempty"`
+	// Symlink is set for a symlink listed as itself, which is what the
+	// target does unless it sets followSymlinks.
+	Symlink bool `json:"symlink,omitempty"`
+}
+
+// queryDirectoryListing lists the files in dir. With followSymlinks,
+// symlinks are listed as the files they point at, and links to
+// directories or to nothing are left out.
+func queryDirectoryListing(client *asyncsftp.Client, cfg *TargetConfig, dir string) (any, error) {
+	infos, err := client.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FollowSymlinks {
+		if infos, err = followLinks(client, cfg, infos); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(infos, func(a, b *asyncsftp.FileInfo) int { return strings.Compare(a.Path, b.Path) })
+
+	props := DirectoryListingProperties{Path: dir, Files: []DirectoryEntry{}}
+	var latest *asyncsftp.FileInfo
+	for _, info := range infos {
+		entry := DirectoryEntry{Name: path.Base(info.Path), Path: info.Path, Size: info.Size, Symlink: info.Type == asyncsftp.FileTypeSymlink}
+		// Open probes cannot see the modification time
+		if !info.ModifiedAt.IsZero() {
+			entry.ModifiedAt = formatTimestamp(info.ModifiedAt, cfg.TimestampFormat)
+			if latest == nil || info.ModifiedAt.After(latest.ModifiedAt) {
+				latest = info
+			}
+		}
+		props.Files = append(props.Files, entry)
+	}
+	if latest != nil {
+		props.Latest = latest.Path
+	}
+	return props, nil
+}
+
+// followLinks replaces the symlinks among infos with what they point at,
+// keeping the link's path.
+func followLinks(client *asyncsftp.Client, cfg *TargetConfig, infos []*asyncsftp.FileInfo) ([]*asyncsftp.FileInfo, error) {
+	followed := infos[:0]
+	for _, info := range infos {
+		if info.Type == asyncsftp.FileTypeSymlink {
+			link := info.Path
+			target, err := readPath(client, cfg, link)
+			if err == nil {
+				info, err = client.Stat(target)
+			}
+			if errors.Is(err, asyncsftp.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if info.Type == asyncsftp.FileTypeDirectory {
+				continue
+			}
+			linked := *info
+			linked.Path = link
+			info = &linked
+		}
+		followed = append(followed, info)
+	}
+	return followed, nil
+}
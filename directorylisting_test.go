@@ -0,0 +1,65 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDirectoryListing verifies the DirectoryListing data source lists files
+// by name, skips subdirectories and picks the most recently modified file.
+func TestDirectoryListing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	incoming := filepath.Join(dir, "incoming")
+	require.NoError(t, os.MkdirAll(filepath.Join(incoming, "archive"), 0755))
+	for name, age := range map[string]time.Duration{"a.csv": time.Hour, "b.csv": time.Minute, "c.csv": 2 * time.Hour} {
+		p := filepath.Join(incoming, name)
+		require.NoError(t, os.WriteFile(p, []byte(name), 0644))
+		mtime := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC).Add(-age)
+		require.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: directoryListingType,
+		Properties:   json.RawMessage(`{"path":"/incoming"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+
+	var listing DirectoryListingProperties
+	require.NoError(t, json.Unmarshal(created.ProgressResult.ResourceProperties, &listing))
+	assert.Equal(t, "/incoming/b.csv", listing.Latest)
+	require.Len(t, listing.Files, 3)
+	assert.Equal(t, DirectoryEntry{
+		Name:       "a.csv",
+		Path:       "/incoming/a.csv",
+		Size:       5,
+		ModifiedAt: "2025-01-02T11:00:00Z",
+	}, listing.Files[0])
+
+	read, err := p.Read(ctx, &resource.ReadRequest{
+		ResourceType: directoryListingType,
+		NativeID:     "/incoming/archive",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path":"/incoming/archive","files":[]}`, read.Properties)
+}
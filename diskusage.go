@@ -0,0 +1,53 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Disk Usage
+// =============================================================================
+
+// diskUsageType is a read-only data source reporting the capacity of the
+// filesystem holding a path, so stacks can gate deployments on free space.
+const diskUsageType = "SFTP::Files::DiskUsage"
+
+// DiskUsageProperties are the properties of a DiskUsage data source.
+type DiskUsageProperties struct {
+	// Path is any existing path on the filesystem (the native ID).
+	Path string `json:"path"`
+
+	TotalBytes     uint64 `json:"totalBytes"`
+	UsedBytes      uint64 `json:"usedBytes"`
+	FreeBytes      uint64 `json:"freeBytes"`
+	AvailableBytes uint64 `json:"availableBytes"` // free to the SFTP account
+	TotalFiles     uint64 `json:"totalFiles"`
+	FreeFiles      uint64 `json:"freeFiles"`
+}
+
+// queryDiskUsage reports statvfs information for path.
+func queryDiskUsage(client *asyncsftp.Client, cfg *TargetConfig, path string) (any, error) {
+	usage, err := client.DiskUsage(path)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			err = fmt.Errorf("%w: this target cannot report disk usage (SFTP servers need the statvfs@openssh.com extension)", errInvalidTargetConfig)
+		}
+		return nil, err
+	}
+	return DiskUsageProperties{
+		Path:           path,
+		TotalBytes:     usage.TotalBytes,
+		UsedBytes:      usage.TotalBytes - usage.FreeBytes,
+		FreeBytes:      usage.FreeBytes,
+		AvailableBytes: usage.AvailableBytes,
+		TotalFiles:     usage.TotalFiles,
+		FreeFiles:      usage.FreeFiles,
+	}, nil
+}
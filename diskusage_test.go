@@ -0,0 +1,64 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDiskUsage verifies the DiskUsage data source reports capacity on
+// create and read, and that deleting it leaves the target alone.
+func TestDiskUsage(t *testing.T) {
+	ctx := context.Background()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	p := &Plugin{}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: diskUsageType,
+		Properties:   json.RawMessage(`{"path":"/upload"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Equal(t, "/upload", created.ProgressResult.NativeID)
+
+	var usage DiskUsageProperties
+	require.NoError(t, json.Unmarshal(created.ProgressResult.ResourceProperties, &usage))
+	assert.Equal(t, "/upload", usage.Path)
+	assert.NotZero(t, usage.TotalBytes)
+	assert.Equal(t, usage.TotalBytes, usage.UsedBytes+usage.FreeBytes)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{
+		ResourceType: diskUsageType,
+		NativeID:     "/upload",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(created.ProgressResult.ResourceProperties), read.Properties)
+
+	missing, err := p.Read(ctx, &resource.ReadRequest{
+		ResourceType: diskUsageType,
+		NativeID:     "/missing",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeNotFound, missing.ErrorCode)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{
+		ResourceType: diskUsageType,
+		NativeID:     "/upload",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+}
@@ -0,0 +1,330 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Download
+// =============================================================================
+
+// downloadType pulls a remote file the other way round, e.g. a response a
+// partner drops on the server, and keeps a copy current at a path on the
+// agent host. Without a local path the content is only reported, for other
+// resources to reference. The remote file is never written or removed.
+const downloadType = "SFTP::Files::Download"
+
+// downloadPermissions is the mode of local copies: what partners drop is
+// for the agent, not for other users of its host.
+const downloadPermissions = 0600
+
+// downloadHandler manages SFTP::Files::Download resources.
+type downloadHandler struct {
+	plugin *Plugin
+}
+
+// DownloadProperties are the properties of a Download resource.
+type DownloadProperties struct {
+	// Path is the remote file to pull (the native ID).
+	Path string `json:"path"`
+	// LocalPath is where the copy is kept on the agent host, under the
+	// downloadDir plugin setting. It is recorded in the deployment
+	// manifest, and Read reports it only while the copy matches the remote
+	// file, so a stale or missing copy is drift.
+	LocalPath string `json:"localPath,omitempty"`
+
+	// Read-only: the remote file as last pulled. Content is reported only
+	// without a local path, and not at all under omitContent.
+	Content    string `json:"content,omitempty"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modifiedAt,omitempty"`
+}
+
+// parseDownloadProperties extracts Download properties from a JSON request.
+func parseDownloadProperties(data json.RawMessage) (*DownloadProperties, error) {
+	var props DownloadProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid download properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, fmt.Errorf("download properties missing 'path'")
+	}
+	return &DownloadProperties{Path: props.Path, LocalPath: props.LocalPath}, nil
+}
+
+// checkLocalPath requires localPath to be absolute and inside dir, the
+// downloadDir setting, and the target to have a deployment manifest to
+// record it in. Without the setting nothing may be written locally: the
+// path comes from the stack, not from the operator.
+func checkLocalPath(cfg *TargetConfig, localPath, dir string) error {
+	if localPath == "" {
+		return nil
+	}
+	if cfg.ManifestPath == "" {
+		return fmt.Errorf("'localPath' requires 'manifestPath' on the target")
+	}
+	if dir == "" {
+		return fmt.Errorf("'localPath' writes to the agent host and is disabled; set downloadDir in the plugin settings to enable it")
+	}
+	if !filepath.IsAbs(localPath) {
+		return fmt.Errorf("%w: 'localPath' %q must be absolute", errInvalidPath, localPath)
+	}
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(localPath))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: 'localPath' %q is outside downloadDir %q", errInvalidPath, localPath, dir)
+	}
+	return nil
+}
+
+// pull downloads the remote file, stores it at the local path if there is
+// one and records that in the manifest, and returns the resulting
+// properties.
+func (h *downloadHandler) pull(client *asyncsftp.Client, cfg *TargetConfig, label string, props *DownloadProperties) (*DownloadProperties, error) {
+	target, err := readPath(client, cfg, props.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.ReadFile(target)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != asyncsftp.FileTypeRegular {
+		return nil, fmt.Errorf("%w: %s is a %s, not a regular file", errInvalidPath, props.Path, info.Type)
+	}
+	if props.LocalPath != "" {
+		if err := writeLocal(props.LocalPath, []byte(info.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ManifestPath != "" {
+		err = updateManifest(client, cfg.ManifestPath, func(m *Manifest) {
+			if props.LocalPath == "" {
+				delete(m.Downloads, props.Path)
+				return
+			}
+			m.Downloads[props.Path] = DownloadEntry{
+				LocalPath: props.LocalPath,
+				SHA256:    contentHash(info.Content),
+				Label:     label,
+				UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to record download in deployment manifest: %w", err)
+		}
+	}
+	return h.describe(cfg, props.Path, props.LocalPath, info), nil
+}
+
+// describe returns the properties reporting info, pulled for localPath.
+func (h *downloadHandler) describe(cfg *TargetConfig, path, localPath string, info *asyncsftp.FileInfo) *DownloadProperties {
+	props := &DownloadProperties{
+		Path:      path,
+		LocalPath: localPath,
+		SHA256:    contentHash(info.Content),
+		Size:      info.Size,
+	}
+	if localPath == "" && !h.plugin.settings.OmitContent {
+		props.Content = info.Content
+	}
+	// Open probes cannot see the modification time
+	if !info.ModifiedAt.IsZero() {
+		props.ModifiedAt = formatTimestamp(info.ModifiedAt, cfg.TimestampFormat)
+	}
+	return props
+}
+
+// writeLocal replaces the file at p with content, through a temporary file
+// in the same directory so readers never see a partial copy.
+func writeLocal(p string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create local directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write local copy: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(downloadPermissions)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write local copy: %w", err)
+	}
+	return nil
+}
+
+// localCurrent reports whether the local copy at p holds content.
+func localCurrent(p, content string) bool {
+	data, err := os.ReadFile(p)
+	return err == nil && string(data) == content
+}
+
+// run validates the request, pulls the file and builds the operation's
+// result.
+func (h *downloadHandler) run(ctx context.Context, log plugin.Logger, op resource.Operation, nativeID, label string, targetConfig, properties json.RawMessage) *resource.ProgressResult {
+	var cfg *TargetConfig
+	props, err := parseDownloadProperties(properties)
+	if err == nil {
+		cfg, err = parseTargetConfig(targetConfig)
+	}
+	if err == nil {
+		err = validatePath(props.Path, cfg.Root)
+	}
+	if err == nil {
+		err = checkLocalPath(cfg, props.LocalPath, h.plugin.settings.DownloadDir)
+	}
+	if err != nil {
+		return failureResult(op, nativeID, resource.OperationErrorCodeInvalidRequest, err)
+	}
+
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err != nil {
+		return failureResult(op, nativeID, errorCode(err), err)
+	}
+	current, err := h.pull(client, cfg, label, props)
+	if err != nil {
+		return failureResult(op, nativeID, errorCode(err), err)
+	}
+	if props.LocalPath != "" {
+		log.Info("downloaded file", "path", props.Path, "localPath", props.LocalPath, "size", current.Size)
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.ProgressResult{
+		Operation:          op,
+		OperationStatus:    resource.OperationStatusSuccess,
+		NativeID:           props.Path,
+		ResourceProperties: propsJSON,
+	}
+}
+
+// Create pulls the file.
+func (h *downloadHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+	return &resource.CreateResult{ProgressResult: h.run(ctx, log, resource.OperationCreate, "", req.Label, req.TargetConfig, req.Properties)}, nil
+}
+
+// Read reports the remote file, and the local path while the copy there
+// is current.
+func (h *downloadHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err == nil {
+		err = validatePath(req.NativeID, cfg.Root)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	var info *asyncsftp.FileInfo
+	target, err := readPath(client, cfg, req.NativeID)
+	if err == nil {
+		info, err = client.ReadFile(target)
+	}
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	var localPath string
+	if entry, ok := downloadEntry(log, client, cfg, req.NativeID); ok && localCurrent(entry.LocalPath, info.Content) {
+		localPath = entry.LocalPath
+	}
+
+	propsJSON, _ := json.Marshal(h.describe(cfg, req.NativeID, localPath, info))
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update pulls the file again, e.g. after the partner replaced it.
+func (h *downloadHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+	return &resource.UpdateResult{ProgressResult: h.run(ctx, log, resource.OperationUpdate, req.NativeID, req.Label, req.TargetConfig, req.DesiredProperties)}, nil
+}
+
+// Delete removes the local copy. The remote file stays.
+func (h *downloadHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	if entry, ok := downloadEntry(log, client, cfg, req.NativeID); ok {
+		if err := os.Remove(entry.LocalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("failed to remove local copy: %w", err)
+			return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+		}
+		err := updateManifest(client, cfg.ManifestPath, func(m *Manifest) {
+			delete(m.Downloads, req.NativeID)
+		})
+		if err != nil {
+			return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+		}
+		log.Info("local copy removed", "path", req.NativeID, "localPath", entry.LocalPath)
+	}
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
+
+// downloadEntry returns the manifest's record of where the remote file at
+// path is kept locally, if any.
+func downloadEntry(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, path string) (DownloadEntry, bool) {
+	if cfg.ManifestPath == "" {
+		return DownloadEntry{}, false
+	}
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		log.Debug("could not read deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+		return DownloadEntry{}, false
+	}
+	entry, ok := m.Downloads[path]
+	return entry, ok
+}
@@ -0,0 +1,80 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDownload verifies a remote file is mirrored to a local path, a
+// replaced remote file shows as drift until updated, and delete removes
+// only the local copy.
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	local := filepath.Join(dir, "partner", "response.xml")
+	p := &Plugin{settings: Settings{DownloadDir: dir}}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+	require.NoError(t, client.WriteFile("/upload/response.xml", []byte("<ok/>"), 0644))
+
+	props := json.RawMessage(`{"path":"/upload/response.xml","localPath":"` + local + `"}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: downloadType, Properties: props, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	data, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, "<ok/>", string(data))
+	stat, err := os.Stat(local)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(downloadPermissions), stat.Mode().Perm())
+
+	read := func() DownloadProperties {
+		result, err := p.Read(ctx, &resource.ReadRequest{ResourceType: downloadType, NativeID: "/upload/response.xml", TargetConfig: target})
+		require.NoError(t, err)
+		require.Empty(t, result.ErrorCode)
+		var got DownloadProperties
+		require.NoError(t, json.Unmarshal([]byte(result.Properties), &got))
+		return got
+	}
+	got := read()
+	assert.Equal(t, local, got.LocalPath)
+	assert.Empty(t, got.Content)
+	assert.Equal(t, contentHash("<ok/>"), got.SHA256)
+
+	require.NoError(t, client.WriteFile("/upload/response.xml", []byte("<retry/>"), 0644))
+	assert.Empty(t, read().LocalPath)
+	updated, err := p.Update(ctx, &resource.UpdateRequest{ResourceType: downloadType, NativeID: "/upload/response.xml", DesiredProperties: props, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	assert.Equal(t, local, read().LocalPath)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: downloadType, NativeID: "/upload/response.xml", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	assert.NoFileExists(t, local)
+	_, err = client.Stat("/upload/response.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "<retry/>", read().Content)
+
+	outside := json.RawMessage(`{"path":"/upload/response.xml","localPath":"/etc/response.xml"}`)
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: downloadType, Properties: outside, TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+	disabled := &Plugin{}
+	created, err = disabled.Create(ctx, &resource.CreateRequest{ResourceType: downloadType, Properties: props, TargetConfig: target})
+	require.NoError(t, err)
+	assert.Contains(t, created.ProgressResult.StatusMessage, "downloadDir")
+}
@@ -0,0 +1,158 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Dry Run
+// =============================================================================
+
+// formae plans without calling plugins, so a plan cannot say what an apply
+// would cost on the wire. A target with dryRun set answers every change
+// instead: nothing on the server is written or removed, and each change
+// fails with what it would have done and a running summary of the apply so
+// far, for checking an apply against a metered or slow partner connection.
+
+// errDryRun marks a change not made because the target is in dry run.
+var errDryRun = errors.New("dry run")
+
+// dryRunWindow is how long a dry run's summary waits for the next change
+// before the next one starts a new summary.
+const dryRunWindow = 10 * time.Minute
+
+// dryRunLargest is how many of the largest writes a summary names.
+const dryRunLargest = 3
+
+// dryRunState is the summary of the changes a dry run has answered.
+type dryRunState struct {
+	mu      sync.Mutex
+	writes  int
+	bytes   int64
+	deletes int
+	largest []dryRunWrite // largest first
+	last    time.Time
+}
+
+// dryRunWrite is a write a dry run counted.
+type dryRunWrite struct {
+	path string
+	size int64
+}
+
+// dryRun answers a change to nativeID on a dry-run target: a write of
+// properties, or a delete when properties is nil.
+func (p *Plugin) dryRun(ctx context.Context, op resource.Operation, resourceType, nativeID string, prior, properties, targetConfig json.RawMessage) *resource.ProgressResult {
+	var action string
+	var size int64
+	switch {
+	case op == resource.OperationDelete:
+		action = "delete " + nativeID
+	case resourceType == fileType:
+		path, n, changed, err := p.uploadSize(ctx, prior, properties, targetConfig)
+		nativeID = cmp.Or(nativeID, path)
+		switch {
+		case err != nil:
+			// Left out of the summary, which would otherwise count a size
+			// the apply could not send
+			action = fmt.Sprintf("upload %s, of unknown size: %v", cmp.Or(nativeID, "a file"), err)
+		case changed:
+			size = n
+			action = fmt.Sprintf("upload %s to %s", formatBytes(size), nativeID)
+		default:
+			action = "change " + nativeID + " without uploading it"
+		}
+	default:
+		action = fmt.Sprintf("%s %s %s", strings.ToLower(string(op)), resourceType, nativeID)
+	}
+
+	summary := p.dryRunState.count(op, nativeID, size, time.Now())
+	plugin.LoggerFromContext(ctx).Info("dry run", "operation", op, "nativeID", nativeID, "bytes", size, "summary", summary)
+	err := fmt.Errorf("%w: would %s; %s", errDryRun, action, summary)
+	return failureResult(op, nativeID, errorCode(err), err)
+}
+
+// uploadSize returns the path a File's properties declare and the size of
+// the content an upload would send, and whether its content differs from
+// prior, so updates that only change metadata send nothing. It fails when
+// the properties are invalid or the content cannot be assembled or
+// expanded, as the apply would.
+func (p *Plugin) uploadSize(ctx context.Context, prior, properties, targetConfig json.RawMessage) (string, int64, bool, error) {
+	props, err := parseFileProperties(properties)
+	if err != nil {
+		return "", 0, false, err
+	}
+	if err := assembleContent(ctx, props); err != nil {
+		return props.Path, 0, false, err
+	}
+	if err := p.expandContent(props); err != nil {
+		return props.Path, 0, false, err
+	}
+	if prior != nil {
+		if before, err := parseFileProperties(prior); err == nil && before.Content == props.Content {
+			return props.Path, 0, false, nil
+		}
+	}
+	return props.Path, int64(len(uploadContent(targetConfig, props))), true, nil
+}
+
+// count adds a change to the summary, starting a new one when the last
+// change is more than dryRunWindow ago, and returns the summary.
+func (s *dryRunState) count(op resource.Operation, path string, size int64, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.last) > dryRunWindow {
+		s.writes, s.bytes, s.deletes, s.largest = 0, 0, 0, nil
+	}
+	s.last = now
+	switch {
+	case op == resource.OperationDelete:
+		s.deletes++
+	case size > 0:
+		s.writes++
+		s.bytes += size
+		s.largest = append(s.largest, dryRunWrite{path: path, size: size})
+		slices.SortStableFunc(s.largest, func(a, b dryRunWrite) int { return cmp.Compare(b.size, a.size) })
+		s.largest = s.largest[:min(len(s.largest), dryRunLargest)]
+	}
+
+	summary := fmt.Sprintf("apply so far: %d uploads totalling %s, %d deletes", s.writes, formatBytes(s.bytes), s.deletes)
+	if len(s.largest) > 0 {
+		names := make([]string, len(s.largest))
+		for i, w := range s.largest {
+			names[i] = fmt.Sprintf("%s (%s)", w.path, formatBytes(w.size))
+		}
+		summary += "; largest: " + strings.Join(names, ", ")
+	}
+	return summary
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
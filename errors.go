@@ -0,0 +1,82 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"github.com/pkg/sftp"
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// errInvalidTargetConfig marks target configuration the user has to fix.
+var errInvalidTargetConfig = errors.New("invalid target config")
+
+// errMissingCredentials indicates the credential environment variables are unset.
+var errMissingCredentials = errors.New("SFTP_USERNAME and SFTP_PASSWORD must be set")
+
+// errMissingAgent indicates a target using sshAgent has no agent or username.
+var errMissingAgent = errors.New("sshAgent requires SFTP_USERNAME and SSH_AUTH_SOCK to be set")
+
+// errAlreadyExists indicates a file is in the way, e.g. at a move's
+// destination.
+var errAlreadyExists = errors.New("file already exists")
+
+// errConflict indicates the server-side state changed underneath an edit.
+var errConflict = errors.New("conflicting concurrent change")
+
+// errorCode maps an error to the formae error code that tells the agent (and
+// the user reading a sync report) what actually went wrong: bad credentials,
+// a refused path, or a server that could not be reached.
+func errorCode(err error) resource.OperationErrorCode {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return resource.OperationErrorCodeNotSet
+	case errors.Is(err, asyncsftp.ErrNotFound):
+		return resource.OperationErrorCodeNotFound
+	case errors.Is(err, errInvalidTargetConfig), errors.Is(err, errInvalidPath), errors.Is(err, errContentSource),
+		errors.Is(err, errInvalidContent), errors.Is(err, asyncsftp.ErrInvalidConfig), errors.Is(err, asyncsftp.ErrTooLarge),
+		errors.Is(err, asyncsftp.ErrSymlinkLoop), errors.Is(err, errDryRun):
+		return resource.OperationErrorCodeInvalidRequest
+	case errors.Is(err, errMissingCredentials), errors.Is(err, errMissingAgent),
+		errors.Is(err, asyncsftp.ErrAuthFailed), errors.Is(err, errAuthLockedOut):
+		return resource.OperationErrorCodeInvalidCredentials
+	case errors.Is(err, errConflict), errors.Is(err, errPaused):
+		return resource.OperationErrorCodeResourceConflict
+	case errors.Is(err, errAlreadyExists):
+		return resource.OperationErrorCodeAlreadyExists
+	case errors.Is(err, asyncsftp.ErrQueueFull), errors.Is(err, errQuietHours):
+		return resource.OperationErrorCodeThrottling
+	case errors.Is(err, asyncsftp.ErrNoSpace):
+		return resource.OperationErrorCodeServiceLimitExceeded
+	case errors.Is(err, asyncsftp.ErrReadOnly), errors.Is(err, asyncsftp.ErrInterrupted):
+		// Usually maintenance, or a restart mid-upload; report a transient
+		// server-side failure so the agent retries rather than giving up
+		// on the resource
+		return resource.OperationErrorCodeServiceInternalError
+	case errors.Is(err, os.ErrPermission):
+		return resource.OperationErrorCodeAccessDenied
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return resource.OperationErrorCodeServiceTimeout
+	case errors.Is(err, asyncsftp.ErrUnreachable),
+		errors.Is(err, sftp.ErrSSHFxConnectionLost),
+		errors.Is(err, sftp.ErrSSHFxNoConnection):
+		return resource.OperationErrorCodeNetworkFailure
+	default:
+		return resource.OperationErrorCodeInternalFailure
+	}
+}
+
+// failureCode is the error code for a failed asyncsftp operation.
+func failureCode(op *asyncsftp.Operation) resource.OperationErrorCode {
+	if op.Err == nil {
+		return resource.OperationErrorCodeInternalFailure
+	}
+	return errorCode(op.Err)
+}
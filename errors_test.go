@@ -0,0 +1,52 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestErrorCode verifies that bad credentials, refused paths and unreachable
+// servers are reported with distinct error codes.
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want resource.OperationErrorCode
+	}{
+		{"not found", asyncsftp.ErrNotFound, resource.OperationErrorCodeNotFound},
+		{"bad target", fmt.Errorf("%w: missing 'url'", errInvalidTargetConfig), resource.OperationErrorCodeInvalidRequest},
+		{"bad client config", fmt.Errorf("%w: unsupported probe \"x\"", asyncsftp.ErrInvalidConfig), resource.OperationErrorCodeInvalidRequest},
+		{"too large", fmt.Errorf("%w: 2048 bytes is more than the 1024 bytes the server accepts", asyncsftp.ErrTooLarge), resource.OperationErrorCodeInvalidRequest},
+		{"content source", fmt.Errorf("content part 0: %w: GET x: 404 Not Found", errContentSource), resource.OperationErrorCodeInvalidRequest},
+		{"no credentials", errMissingCredentials, resource.OperationErrorCodeInvalidCredentials},
+		{"no ssh agent", errMissingAgent, resource.OperationErrorCodeInvalidCredentials},
+		{"auth locked out", errAuthLockedOut, resource.OperationErrorCodeInvalidCredentials},
+		{"conflict", fmt.Errorf("%w: file kept changing", errConflict), resource.OperationErrorCodeResourceConflict},
+		{"paused", fmt.Errorf("%w by %q", errPaused, "/etc/*"), resource.OperationErrorCodeResourceConflict},
+		{"already exists", fmt.Errorf("%w: /upload/b.txt", errAlreadyExists), resource.OperationErrorCodeAlreadyExists},
+		{"queue full", fmt.Errorf("%w: 64 operations waiting", asyncsftp.ErrQueueFull), resource.OperationErrorCodeThrottling},
+		{"bad password", fmt.Errorf("ssh dial failed: %w", asyncsftp.ErrAuthFailed), resource.OperationErrorCodeInvalidCredentials},
+		{"no space", fmt.Errorf("%w: write failed: sftp: \"Quota exceeded for group finance\" (SSH_FX_FAILURE)", asyncsftp.ErrNoSpace), resource.OperationErrorCodeServiceLimitExceeded},
+		{"read-only", fmt.Errorf("%w: create failed: read-only file system", asyncsftp.ErrReadOnly), resource.OperationErrorCodeServiceInternalError},
+		{"permission", &os.PathError{Op: "stat", Path: "/etc/shadow", Err: os.ErrPermission}, resource.OperationErrorCodeAccessDenied},
+		{"unreachable", fmt.Errorf("ssh dial failed: %w", asyncsftp.ErrUnreachable), resource.OperationErrorCodeNetworkFailure},
+		{"unknown", fmt.Errorf("boom"), resource.OperationErrorCodeInternalFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorCode(tt.err))
+		})
+	}
+}
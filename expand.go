@@ -0,0 +1,111 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// =============================================================================
+// Environment Expansion
+// =============================================================================
+
+// envReference matches ${NAME} and its escaped form $${NAME}.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// envAllowPattern matches an entry of the expandEnvAllow setting: a
+// variable name, or a prefix followed by "*".
+var envAllowPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\*?$`)
+
+// expandEnv replaces each ${NAME} in content with the plugin's environment
+// variable NAME, which allowed must name. $${NAME} stands for a literal
+// ${NAME}, and a lone $ is left alone, so shell scripts need no escaping
+// beyond their ${...} references.
+func expandEnv(content string, allowed []string) (string, error) {
+	var errs []error
+	expanded := envReference.ReplaceAllStringFunc(content, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[2 : len(ref)-1]
+		if !envAllowed(name, allowed) {
+			errs = append(errs, fmt.Errorf("${%s} is not allowed by the plugin's expandEnvAllow setting", name))
+			return ref
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("${%s} is not set in the plugin's environment", name))
+			return ref
+		}
+		return value
+	})
+	if len(errs) > 0 {
+		return "", fmt.Errorf("%w: %w", errInvalidContent, errors.Join(errs...))
+	}
+	return expanded, nil
+}
+
+// envAllowed reports whether allowed names the variable name, exactly or
+// by a prefix ending in "*". The plugin's credentials are never allowed.
+func envAllowed(name string, allowed []string) bool {
+	if strings.HasPrefix(name, credentialsEnvPrefix+"_") || name == "SSH_AUTH_SOCK" {
+		return false
+	}
+	for _, pattern := range allowed {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// expandContent expands an expandEnv file's content, keeping the content
+// as declared for reporting it back.
+func (p *Plugin) expandContent(props *FileProperties) error {
+	if !props.ExpandEnv {
+		return nil
+	}
+	expanded, err := expandEnv(props.Content, p.settings.ExpandEnvAllow)
+	if err != nil {
+		return err
+	}
+	props.template, props.Content = props.Content, expanded
+	return nil
+}
+
+// checkExpandTarget checks that the target records expandEnv files'
+// declared content, without which every read would report drift.
+func checkExpandTarget(targetConfig json.RawMessage, props *FileProperties) error {
+	if !props.ExpandEnv {
+		return nil
+	}
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return err
+	}
+	if cfg.ManifestPath == "" {
+		return fmt.Errorf("'expandEnv' requires 'manifestPath' on the target")
+	}
+	return nil
+}
+
+// reportExpanded replaces read content with its declared template while
+// the template still expands to it. Once a variable changes, the rendered
+// content is reported, which shows up as drift and renders the file again.
+func reportExpanded(props *FileProperties, template string, allowed []string) {
+	props.ExpandEnv = true
+	if expanded, err := expandEnv(template, allowed); err == nil && expanded == props.Content {
+		props.Content = template
+	}
+}
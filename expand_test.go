@@ -0,0 +1,83 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpandEnv verifies only allowed, set variables are substituted, that
+// $${NAME} escapes a reference and that credentials are never exposed.
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("API_ENDPOINT", "https://api.internal")
+	t.Setenv("DEPLOY_REGION", "eu-west-1")
+	t.Setenv("SFTP_PASSWORD", "hunter2")
+	allowed := []string{"API_ENDPOINT", "DEPLOY_*", "SFTP_*"}
+
+	got, err := expandEnv("url=${API_ENDPOINT} region=${DEPLOY_REGION} literal=$${API_ENDPOINT} shell=$HOME", allowed)
+	require.NoError(t, err)
+	assert.Equal(t, "url=https://api.internal region=eu-west-1 literal=${API_ENDPOINT} shell=$HOME", got)
+
+	_, err = expandEnv("${HOME}", allowed)
+	assert.ErrorIs(t, err, errInvalidContent)
+	assert.ErrorContains(t, err, "${HOME} is not allowed")
+
+	_, err = expandEnv("${DEPLOY_MISSING}", allowed)
+	assert.ErrorContains(t, err, "${DEPLOY_MISSING} is not set")
+
+	_, err = expandEnv("${SFTP_PASSWORD}", allowed)
+	assert.ErrorContains(t, err, "${SFTP_PASSWORD} is not allowed")
+}
+
+// TestExpandEnvRoundTrip verifies an expandEnv file is uploaded expanded
+// and read back as declared until the variable changes.
+func TestExpandEnvRoundTrip(t *testing.T) {
+	t.Setenv("API_ENDPOINT", "https://api.internal")
+	ctx := context.Background()
+	p := &Plugin{settings: Settings{ExpandEnvAllow: []string{"API_ENDPOINT"}, SyncCreateMaxSize: 1024}}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.manifest.json"`)
+	declared := `{"path":"/upload/app.conf","content":"endpoint=${API_ENDPOINT}\n","expandEnv":true}`
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(declared), TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Contains(t, string(created.ProgressResult.ResourceProperties), `"content":"endpoint=${API_ENDPOINT}\n"`)
+
+	info, err := client.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint=https://api.internal\n", info.Content)
+
+	read := func() FileProperties {
+		result, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/app.conf", TargetConfig: target})
+		require.NoError(t, err)
+		var props FileProperties
+		require.NoError(t, json.Unmarshal([]byte(result.Properties), &props))
+		return props
+	}
+	props := read()
+	assert.Equal(t, "endpoint=${API_ENDPOINT}\n", props.Content)
+	assert.True(t, props.ExpandEnv)
+
+	t.Setenv("API_ENDPOINT", "https://api2.internal")
+	assert.Equal(t, "endpoint=https://api.internal\n", read().Content)
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{ResourceType: fileType, NativeID: "/upload/app.conf", DesiredProperties: json.RawMessage(declared), TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	assert.Equal(t, "endpoint=${API_ENDPOINT}\n", read().Content)
+
+	failed, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(declared), TargetConfig: json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)})
+	require.NoError(t, err)
+	assert.Contains(t, failed.ProgressResult.StatusMessage, "'expandEnv' requires 'manifestPath'")
+}
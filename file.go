@@ -0,0 +1,988 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// =============================================================================
+// File Resource
+// =============================================================================
+
+// fileType is the resource type of a managed file.
+const fileType = "SFTP::Files::File"
+
+// syncCreateWait bounds how long Create waits for a small upload before
+// answering InProgress after all, so a busy queue does not hold up the agent.
+const syncCreateWait = time.Second
+
+// fileHandler manages SFTP::Files::File resources: files whose content,
+// permissions and attributes formae owns.
+type fileHandler struct {
+	plugin *Plugin
+}
+
+// Create provisions a new resource.
+// Returns InProgress with a RequestID - poll Status() for completion.
+func (h *fileHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	// Get observability from context
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+	metrics := plugin.MetricsFromContext(ctx)
+
+	log.Info("creating file resource")
+
+	// Parse file properties from request
+	props, err := parseFileProperties(req.Properties)
+	if err == nil {
+		err = h.plugin.checkInlineSize(props)
+	}
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+	err = assembleContent(ctx, props)
+	if err == nil {
+		err = h.plugin.expandContent(props)
+	}
+	if err == nil {
+		err = h.plugin.lintContent(ctx, req.TargetConfig, props)
+	}
+	if err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// Get SFTP client
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	if err := errors.Join(checkExecAllowed(req.TargetConfig, props), checkAppendTarget(req.TargetConfig, props), checkCompressionTarget(req.TargetConfig, props), checkExpandTarget(req.TargetConfig, props)); err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// The file is uploaded to, and identified by, its canonical path
+	if props.Path, err = resolvePath(client, req.TargetConfig, props.Path); err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// Content the server cannot store fails now rather than once queued.
+	// Compressed content is measured as stored, by the upload itself.
+	content := uploadContent(req.TargetConfig, props)
+	if props.Compression == "" {
+		if err := client.CheckSize(int64(len(content))); err != nil {
+			return &resource.CreateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationCreate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+				},
+			}, nil
+		}
+	}
+
+	// Another stack's file is not replaced unless adopted. Appended files
+	// are shared with other writers by design.
+	if cfg, err := parseTargetConfig(req.TargetConfig); err == nil && cfg.ManifestPath != "" && props.WriteMode != writeModeAppend {
+		previous, err := claimPath(client, cfg.ManifestPath, props.Path, req.Label, req.ResourceType, props.Adopt)
+		if err != nil {
+			return &resource.CreateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationCreate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        props.Path,
+				},
+			}, nil
+		}
+		if previous != "" {
+			log.Warn("adopting file from another owner", "path", props.Path, "previousOwner", previous)
+		}
+	}
+
+	// A saturated queue would leave the upload waiting for minutes; report
+	// Throttling so the agent's scheduler backs off and retries instead.
+	if err := client.Admit(); err != nil {
+		log.Warn("upload rejected: queue is full", "path", props.Path, "error", err)
+		countThrottled(ctx, "queue_full")
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// Start async upload - returns immediately with operation ID.
+	// The label travels with the operation so Status can record it in the
+	// deployment manifest.
+	opts := props.uploadOptions(req.TargetConfig)
+	opts.Metadata = operationMetadata(req.Label, req.ResourceType)
+	if props.ContentFormat != "" {
+		opts.Metadata[metaContentFormat] = props.ContentFormat
+	}
+	if props.WriteMode == writeModeAppend {
+		opts.Metadata[metaWriteMode] = writeModeAppend
+	}
+	if props.Compression != "" {
+		opts.Metadata[metaCompression] = props.Compression
+	}
+	if props.ChecksumFile != "" {
+		opts.Metadata[metaChecksumFile] = props.ChecksumFile
+		opts.Metadata[metaChecksumFmt] = props.ChecksumFileFormat
+	}
+	if props.ExpandEnv {
+		opts.Metadata[metaExpandEnv] = props.template
+	}
+	requestID := client.StartUploadWithOptions(props.Path, content, opts)
+
+	// Record metric for uploads started
+	metrics.Counter("sftp.uploads_started", 1,
+		attribute.String("path", props.Path))
+
+	log.Debug("upload started", "requestID", requestID, "path", props.Path)
+
+	if len(content) <= h.plugin.settings.SyncCreateMaxSize {
+		if result := h.createdNow(ctx, client, req, requestID); result != nil {
+			return &resource.CreateResult{ProgressResult: result}, nil
+		}
+	}
+
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationCreate,
+			OperationStatus: resource.OperationStatusInProgress,
+			RequestID:       requestID,
+			NativeID:        props.Path, // File path is the native identifier
+		},
+	}, nil
+}
+
+// createdNow waits briefly for a small upload and, once it has finished,
+// returns the result Status would have, with its properties. It returns nil
+// when the upload is still running, leaving the agent to poll as usual.
+func (h *fileHandler) createdNow(ctx context.Context, client *asyncsftp.Client, req *resource.CreateRequest, requestID string) *resource.ProgressResult {
+	waitCtx, cancel := context.WithTimeout(ctx, syncCreateWait)
+	defer cancel()
+	if _, err := client.Wait(waitCtx, requestID); err != nil {
+		return nil
+	}
+	status, err := h.plugin.status(ctx, &resource.StatusRequest{RequestID: requestID, ResourceType: req.ResourceType, TargetConfig: req.TargetConfig})
+	if err != nil || status.ProgressResult.OperationStatus == resource.OperationStatusInProgress {
+		return nil
+	}
+	result := status.ProgressResult
+	result.Operation = resource.OperationCreate
+	return result
+}
+
+// Read retrieves the current state of a resource.
+// Returns NotFound error code (not an error) if the file doesn't exist.
+// ReadResult has no message field, so failures are logged with their cause
+// and mapped to distinct error codes (credentials vs. access vs. network).
+func (h *fileHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    errorCode(err),
+		}, nil
+	}
+
+	// Get SFTP client
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    errorCode(err),
+		}, nil
+	}
+
+	nativeID, err := resolvePath(client, req.TargetConfig, req.NativeID)
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    errorCode(err),
+		}, nil
+	}
+
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    errorCode(err),
+		}, nil
+	}
+
+	// Read file from SFTP server; stat and sample modes leave the content
+	// on the server
+	var fileInfo *asyncsftp.FileInfo
+	var sample, compression string
+	target, err := readPath(client, cfg, nativeID)
+	switch {
+	case err != nil:
+	case cfg.readsContent():
+		compression = managedCompression(log, client, cfg, nativeID)
+		fileInfo, err = client.ReadFileThrough(target, compressionStages(compression)...)
+	default:
+		fileInfo, err = client.Stat(target)
+	}
+	if err == nil && cfg.ReadMode == readModeSample && fileInfo.Type == asyncsftp.FileTypeRegular {
+		sample, err = readSample(client, fileInfo)
+	}
+	if err != nil {
+		// NotFound is not an error - return result with ErrorCode
+		if errors.Is(err, asyncsftp.ErrNotFound) {
+			return &resource.ReadResult{
+				ResourceType: req.ResourceType,
+				ErrorCode:    resource.OperationErrorCodeNotFound,
+			}, nil
+		}
+		log.Error("read failed", "error", err)
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    errorCode(err),
+		}, nil
+	}
+
+	// Convert to JSON properties
+	props := filePropertiesFromInfo(fileInfo, cfg.TimestampFormat)
+	props.Path = nativeID
+	props.Compression = compression
+	withLocation(log, client, req.TargetConfig, &props)
+	readXattrs(log, client, req.TargetConfig, &props)
+	readACL(log, client, req.TargetConfig, &props)
+	readSELinuxContext(log, client, req.TargetConfig, &props)
+	if cfg.readsContent() {
+		stripBanner(req.TargetConfig, &props)
+		readDeclared(log, client, cfg, &props, h.plugin.settings.ExpandEnvAllow)
+	}
+	var propsJSON []byte
+	if cfg.readsContent() {
+		propsJSON, _ = json.Marshal(h.plugin.redact(props))
+	} else {
+		props.SHA256 = remoteChecksum(log, client, cfg, fileInfo)
+		props.SampleSHA256 = sample
+		propsJSON, _ = json.Marshal(statProperties{FileProperties: props})
+	}
+
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update modifies an existing resource.
+// Updates are synchronous - we update content and/or permissions directly.
+func (h *fileHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	// Get SFTP client
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// Parse desired properties
+	desiredProps, err := parseFileProperties(req.DesiredProperties)
+	if err == nil {
+		err = h.plugin.checkInlineSize(desiredProps)
+	}
+	if err == nil {
+		err = checkPath(req.TargetConfig, req.NativeID)
+	}
+	if err == nil {
+		err = assembleContent(ctx, desiredProps)
+	}
+	if err == nil {
+		err = h.plugin.expandContent(desiredProps)
+	}
+	if err == nil {
+		err = h.plugin.lintContent(ctx, req.TargetConfig, desiredProps)
+	}
+	if err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	nativeID, err := resolvePath(client, req.TargetConfig, req.NativeID)
+	if err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	if err := errors.Join(checkExecAllowed(req.TargetConfig, desiredProps), checkAppendTarget(req.TargetConfig, desiredProps), checkCompressionTarget(req.TargetConfig, desiredProps), checkExpandTarget(req.TargetConfig, desiredProps)); err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	// Refuse to write through a symlink or onto a directory: the SFTP
+	// open would follow the link and overwrite whatever it points at.
+	current, err := client.Stat(nativeID)
+	if err != nil && !errors.Is(err, asyncsftp.ErrNotFound) {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+	if err == nil && current.Type != asyncsftp.FileTypeRegular {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   fmt.Sprintf("%s is a %s, not a regular file", req.NativeID, current.Type),
+			},
+		}, nil
+	}
+
+	// A changed path moves the file once the update has been validated;
+	// the rest of the update then applies to it at its new path, which
+	// failures from here on report, so the agent follows the move.
+	var moved string
+	id := req.NativeID
+	if desiredProps.Path != req.NativeID {
+		to := ""
+		err := checkPath(req.TargetConfig, desiredProps.Path)
+		if err == nil {
+			to, err = resolvePath(client, req.TargetConfig, desiredProps.Path)
+		}
+		if err == nil && to != nativeID {
+			err = moveFile(ctx, client, nativeID, to)
+			if err == nil {
+				log.Info("file moved", "from", nativeID, "to", to)
+				withManifest(log, req.TargetConfig, func(manifestPath string) error {
+					return forgetManaged(client, manifestPath, nativeID)
+				})
+				moved = fmt.Sprintf("moved from %s; ", nativeID)
+				current, _ = client.Stat(to)
+				id = to
+			}
+		}
+		if err != nil {
+			return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+		}
+		nativeID = to
+	}
+
+	// Parse prior properties to detect changes
+	priorProps, _ := parseFileProperties(req.PriorProperties)
+	decision := decideUpdate(priorProps, desiredProps)
+	// Compare against what is actually on the server instead, so an Update
+	// whose desired state is already in place writes nothing. Stat and
+	// sample read modes leave content on the server, so they rely on the
+	// prior state.
+	if cfg, err := parseTargetConfig(req.TargetConfig); err == nil && cfg.readsContent() && current != nil {
+		compression := managedCompression(log, client, cfg, nativeID)
+		if actual, err := client.ReadFileThrough(nativeID, compressionStages(compression)...); err == nil {
+			actualProps := actualProperties(actual, priorProps)
+			actualProps.Compression = compression
+			if desiredProps.WriteMode == writeModeAppend {
+				reportAppended(actualProps, desiredProps.Content)
+			}
+			decision = decideUpdate(actualProps, bannered(req.TargetConfig, desiredProps))
+			if len(decision.reasons) == 0 {
+				log.Info("update skipped: server already matches desired state")
+				return &resource.UpdateResult{ProgressResult: h.updateResult(log, client, req, nativeID, actual, desiredProps, moved+"no changes: server already matches desired state")}, nil
+			}
+		}
+	}
+	log.Info("update planned", "decision", decision.String())
+
+	// Shared directories: never overwrite a change made on the server.
+	// Appends leave other writers' changes alone anyway.
+	cfg, err := parseTargetConfig(req.TargetConfig)
+	if err == nil && (desiredProps.ProtectNewer || cfg.ProtectNewer) && decision.rewrite && current != nil && desiredProps.WriteMode != writeModeAppend {
+		if err := checkNewer(log, client, cfg, nativeID, priorProps, current); err != nil {
+			log.Warn("update refused", "error", err)
+			return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, id, errorCode(err), err)}, nil
+		}
+	}
+
+	if decision.rewrite {
+		if err := client.Admit(); err != nil {
+			log.Warn("rewrite rejected: queue is full", "error", err)
+			countThrottled(ctx, "queue_full")
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+
+		// Use sync upload for update (blocking)
+		opts := desiredProps.uploadOptions(req.TargetConfig)
+		opts.Metadata = operationMetadata(req.Label, req.ResourceType)
+		opID := client.StartUploadWithOptions(nativeID, uploadContent(req.TargetConfig, desiredProps), opts)
+		log.Debug("rewrite started", "requestID", opID)
+
+		// Wait for completion
+		op, err := client.Wait(ctx, opID)
+		if err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+		warnIfSlow(log, req.TargetConfig, client, op)
+		if op.State == asyncsftp.StateFailure {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       failureCode(op),
+					StatusMessage:   op.Error,
+					NativeID:        id,
+				},
+			}, nil
+		}
+	} else if decision.chmod {
+		if err := client.SetPermissions(nativeID, desiredProps.fileMode()); err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+	}
+
+	// Rewrites already applied the desired xattrs; drop the ones no longer
+	// declared, and apply changes when the content was left alone.
+	var priorXattrs map[string]string
+	if priorProps != nil {
+		priorXattrs = priorProps.Xattrs
+	}
+	if !maps.Equal(priorXattrs, desiredProps.Xattrs) {
+		var remove []string
+		for name := range priorXattrs {
+			if _, ok := desiredProps.Xattrs[name]; !ok {
+				remove = append(remove, name)
+			}
+		}
+		if err := client.SetXattrs(nativeID, desiredProps.Xattrs, remove); err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+	}
+
+	// Likewise drop an ACL no longer declared; SetACL replaces all extended
+	// entries, so it also applies changes when the content was left alone.
+	var priorACL []string
+	if priorProps != nil {
+		priorACL = priorProps.ACL
+	}
+	if !sameACL(priorACL, desiredProps.ACL) && (!decision.rewrite || len(desiredProps.ACL) == 0) {
+		if err := client.SetACL(nativeID, aclType(req.TargetConfig), desiredProps.ACL); err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+	}
+
+	// A rewrite already set the context
+	var priorContext string
+	if priorProps != nil {
+		priorContext = priorProps.SELinuxContext
+	}
+	if desiredProps.SELinuxContext != "" && priorContext != desiredProps.SELinuxContext && !decision.rewrite {
+		if err := client.SetSELinuxContext(nativeID, desiredProps.SELinuxContext); err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+	}
+
+	if decision.sidecar {
+		if err := updateChecksumFile(client, nativeID, priorProps, desiredProps, decision.rewrite); err != nil {
+			return &resource.UpdateResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationUpdate,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+					NativeID:        id,
+				},
+			}, nil
+		}
+	}
+
+	// Read back the updated file to return current state
+	fileInfo, err := client.ReadFileThrough(nativeID, compressionStages(desiredProps.Compression)...)
+	if err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        id,
+			},
+		}, nil
+	}
+
+	withManifest(log, req.TargetConfig, func(manifestPath string) error {
+		return recordManaged(client, manifestPath, fileInfo, ManifestEntry{
+			Label:         req.Label,
+			ResourceType:  req.ResourceType,
+			ContentFormat: desiredProps.ContentFormat,
+			WriteMode:     desiredProps.WriteMode,
+			Compression:   desiredProps.Compression,
+			ExpandEnv:     desiredProps.ExpandEnv,
+			Declared:      cmp.Or(desiredProps.template, desiredProps.Content),
+		})
+	})
+
+	return &resource.UpdateResult{ProgressResult: h.updateResult(log, client, req, nativeID, fileInfo, desiredProps, moved+decision.String())}, nil
+}
+
+// updateResult reports the state of the file at nativeID after an Update,
+// with message explaining what was done.
+func (h *fileHandler) updateResult(log plugin.Logger, client *asyncsftp.Client, req *resource.UpdateRequest, nativeID string, info *asyncsftp.FileInfo, desired *FileProperties, message string) *resource.ProgressResult {
+	props := filePropertiesFromInfo(info, timestampFormat(req.TargetConfig))
+	stripBanner(req.TargetConfig, &props)
+	switch {
+	case desired.WriteMode == writeModeAppend:
+		reportAppended(&props, desired.Content)
+	case desired.ExpandEnv:
+		reportExpanded(&props, desired.template, h.plugin.settings.ExpandEnvAllow)
+	default:
+		reportDeclared(&props, desired.ContentFormat, desired.Content)
+	}
+	props.Compression = desired.Compression
+	props.ChecksumFile, props.ChecksumFileFormat = desired.ChecksumFile, desired.ChecksumFileFormat
+	withSample(req.TargetConfig, &props, info)
+	withLocation(log, client, req.TargetConfig, &props)
+	readXattrs(log, client, req.TargetConfig, &props)
+	readACL(log, client, req.TargetConfig, &props)
+	readSELinuxContext(log, client, req.TargetConfig, &props)
+	propsJSON, _ := json.Marshal(h.plugin.redact(props))
+
+	return &resource.ProgressResult{
+		Operation:          resource.OperationUpdate,
+		OperationStatus:    resource.OperationStatusSuccess,
+		StatusMessage:      withWarnings(message, client),
+		NativeID:           nativeID,
+		ResourceProperties: propsJSON,
+	}
+}
+
+// moveFile moves the file at from to to and waits for the move. A file
+// already at to is refused, unless from is gone because an earlier attempt
+// moved it.
+func moveFile(ctx context.Context, client *asyncsftp.Client, from, to string) error {
+	if _, err := client.Stat(to); err == nil {
+		if _, err := client.Stat(from); errors.Is(err, asyncsftp.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%w: cannot move %s to %s", errAlreadyExists, from, to)
+	}
+	op, err := client.Wait(ctx, client.StartMove(from, to))
+	if err != nil {
+		return err
+	}
+	if op.State == asyncsftp.StateFailure {
+		if op.Err != nil {
+			return op.Err
+		}
+		return errors.New(op.Error)
+	}
+	return nil
+}
+
+// actualProperties are the comparable properties of the file on the
+// server. Xattrs, ACLs and the SELinux context come from the prior state,
+// since reading them needs exec, as do permissions when the probe cannot
+// see them.
+func actualProperties(info *asyncsftp.FileInfo, prior *FileProperties) *FileProperties {
+	props := &FileProperties{Content: info.Content, Permissions: info.Permissions}
+	if prior != nil {
+		props.Xattrs = prior.Xattrs
+		props.ACL = prior.ACL
+		props.SELinuxContext = prior.SELinuxContext
+		props.ChecksumFile, props.ChecksumFileFormat = prior.ChecksumFile, prior.ChecksumFileFormat
+		if props.Permissions == "" {
+			props.Permissions = prior.Permissions
+		}
+	}
+	return props
+}
+
+// bannered returns desired with its content as uploaded, banner included.
+func bannered(targetConfig json.RawMessage, desired *FileProperties) *FileProperties {
+	want := *desired
+	want.Content = uploadContent(targetConfig, desired)
+	return &want
+}
+
+// updateDecision records what Update does to a file and the differences
+// that triggered it, so users can see why formae keeps pushing a file.
+type updateDecision struct {
+	rewrite bool     // upload the content again
+	chmod   bool     // only change permissions
+	sidecar bool     // the checksum file changed
+	reasons []string // the triggering differences
+}
+
+// decideUpdate compares the prior and desired properties. Structured
+// content that only differs in formatting is not rewritten.
+func decideUpdate(prior, desired *FileProperties) updateDecision {
+	var d updateDecision
+	if prior == nil {
+		d.rewrite = true
+		d.reasons = append(d.reasons, "no prior state to compare")
+		return d
+	}
+	switch {
+	case prior.Content == "" && prior.SHA256 != "" && desired.Content != "":
+		// State stored without content (omitContent) still has its hash
+		if prior.SHA256 != contentHash(desired.Content) {
+			d.rewrite = true
+			d.reasons = append(d.reasons, fmt.Sprintf("content sha256 %.12s -> %.12s", prior.SHA256, contentHash(desired.Content)))
+		}
+	case !contentEqual(desired.ContentFormat, prior.Content, desired.Content):
+		d.rewrite = true
+		d.reasons = append(d.reasons, contentDifference(prior.Content, desired.Content))
+	}
+	if prior.Compression != desired.Compression {
+		d.rewrite = true
+		d.reasons = append(d.reasons, fmt.Sprintf("compression %s -> %s", cmp.Or(prior.Compression, "none"), cmp.Or(desired.Compression, "none")))
+	}
+	if prior.Permissions != desired.Permissions {
+		d.chmod = !d.rewrite
+		d.reasons = append(d.reasons, fmt.Sprintf("permissions %s -> %s", prior.Permissions, desired.Permissions))
+	}
+	if !maps.Equal(prior.Xattrs, desired.Xattrs) {
+		var changed []string
+		for name := range prior.Xattrs {
+			if v, ok := desired.Xattrs[name]; !ok || v != prior.Xattrs[name] {
+				changed = append(changed, name)
+			}
+		}
+		for name := range desired.Xattrs {
+			if _, ok := prior.Xattrs[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		slices.Sort(changed)
+		d.reasons = append(d.reasons, "xattrs "+strings.Join(changed, ", ")+" changed")
+	}
+	if !sameACL(prior.ACL, desired.ACL) {
+		d.reasons = append(d.reasons, "acl changed")
+	}
+	if desired.SELinuxContext != "" && prior.SELinuxContext != desired.SELinuxContext {
+		d.reasons = append(d.reasons, fmt.Sprintf("selinuxContext %s -> %s", cmp.Or(prior.SELinuxContext, "unset"), desired.SELinuxContext))
+	}
+	if prior.checksumFileLayout() != desired.checksumFileLayout() {
+		d.sidecar = true
+		d.reasons = append(d.reasons, fmt.Sprintf("checksumFile %s -> %s", prior.checksumFileLayout(), desired.checksumFileLayout()))
+	}
+	return d
+}
+
+// contentDifference describes how desired content differs from prior.
+func contentDifference(prior, desired string) string {
+	priorLines, desiredLines := strings.Split(prior, "\n"), strings.Split(desired, "\n")
+	line := 1
+	for line <= len(priorLines) && line <= len(desiredLines) && priorLines[line-1] == desiredLines[line-1] {
+		line++
+	}
+	return fmt.Sprintf("content differs from line %d (%d -> %d bytes, sha256 %.12s -> %.12s)",
+		line, len(prior), len(desired), contentHash(prior), contentHash(desired))
+}
+
+// String summarizes the decision, e.g. "rewrote file: content differs ...".
+func (d updateDecision) String() string {
+	action := "no changes"
+	switch {
+	case d.rewrite:
+		action = "rewrote file"
+	case d.chmod:
+		action = "changed permissions only"
+	case len(d.reasons) > 0:
+		action = "updated attributes only"
+	}
+	if len(d.reasons) == 0 {
+		return action
+	}
+	return action + ": " + strings.Join(d.reasons, "; ")
+}
+
+// Delete removes a resource.
+// Returns Failure with NotFound error code if file doesn't exist (agent treats this as success).
+func (h *fileHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+
+	// Get SFTP client
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	nativeID, err := resolvePath(client, req.TargetConfig, req.NativeID)
+	if err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+
+	// A shared file keeps everything, including what formae appended: the
+	// other writers' lines are interleaved and may already be consumed
+	shared, err := appendManaged(client, req.TargetConfig, nativeID)
+	if err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+	if shared {
+		log.Info("releasing appended content; shared file left in place", "path", nativeID)
+		withManifest(log, req.TargetConfig, func(manifestPath string) error {
+			return forgetManaged(client, manifestPath, nativeID)
+		})
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusSuccess,
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+
+	// Check if file exists first
+	_, err = client.Stat(nativeID)
+	if err != nil {
+		if errors.Is(err, asyncsftp.ErrNotFound) {
+			// File doesn't exist - return Failure with NotFound
+			// The agent treats NotFound on Delete as success
+			return &resource.DeleteResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationDelete,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       resource.OperationErrorCodeNotFound,
+					NativeID:        req.NativeID,
+				},
+			}, nil
+		}
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+
+	if err := client.Admit(); err != nil {
+		log.Warn("delete rejected: queue is full", "error", err)
+		countThrottled(ctx, "queue_full")
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+
+	// Checksum files go first, so nobody picks up a file about to vanish
+	if removed, err := client.RemoveSidecars(nativeID); err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	} else if len(removed) > 0 {
+		log.Info("removed checksum files", "paths", removed)
+	}
+
+	// Start delete operation
+	opID := client.StartDelete(nativeID)
+	log.Debug("delete started", "requestID", opID)
+
+	// Wait for completion (delete is fast, we wait synchronously)
+	op, err := client.Wait(ctx, opID)
+	if err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       errorCode(err),
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+	warnIfSlow(log, req.TargetConfig, client, op)
+	if op.State == asyncsftp.StateFailure {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       failureCode(op),
+				StatusMessage:   op.Error,
+			},
+		}, nil
+	}
+	withManifest(log, req.TargetConfig, func(manifestPath string) error {
+		return forgetManaged(client, manifestPath, nativeID)
+	})
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
@@ -0,0 +1,607 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecideUpdate verifies Update explains whether it rewrites, only
+// changes permissions, or leaves the file alone, and why.
+func TestDecideUpdate(t *testing.T) {
+	prior := &FileProperties{Content: "a\nb\nc\n", Permissions: "0644"}
+
+	d := decideUpdate(nil, prior)
+	assert.True(t, d.rewrite)
+	assert.Equal(t, "rewrote file: no prior state to compare", d.String())
+
+	d = decideUpdate(prior, &FileProperties{Content: "a\nB\nc\n", Permissions: "0600"})
+	assert.True(t, d.rewrite)
+	assert.False(t, d.chmod)
+	assert.Regexp(t, `^rewrote file: content differs from line 2 \(6 -> 6 bytes, sha256 \w{12} -> \w{12}\); permissions 0644 -> 0600$`, d.String())
+
+	d = decideUpdate(prior, &FileProperties{Content: prior.Content, Permissions: "0600"})
+	assert.Equal(t, updateDecision{chmod: true, reasons: []string{"permissions 0644 -> 0600"}}, d)
+	assert.Equal(t, "changed permissions only: permissions 0644 -> 0600", d.String())
+
+	d = decideUpdate(prior, &FileProperties{Content: prior.Content, Permissions: "0644", Xattrs: map[string]string{"user.build": "7"}})
+	assert.Equal(t, "updated attributes only: xattrs user.build changed", d.String())
+
+	withACL := &FileProperties{Content: prior.Content, Permissions: "0644", ACL: []string{"user:deploy:rw-", "group:web:r--"}}
+	assert.Equal(t, "updated attributes only: acl changed", decideUpdate(prior, withACL).String())
+	assert.Equal(t, "no changes", decideUpdate(withACL, &FileProperties{Content: prior.Content, Permissions: "0644", ACL: []string{"group:web:r--", "user:deploy:rw-"}}).String())
+	assert.Equal(t, "updated attributes only: acl changed", decideUpdate(
+		&FileProperties{Content: prior.Content, Permissions: "0644", ACL: []string{"D::guest@example.com:w", "A::guest@example.com:rw"}},
+		&FileProperties{Content: prior.Content, Permissions: "0644", ACL: []string{"A::guest@example.com:rw", "D::guest@example.com:w"}}).String())
+
+	labeled := &FileProperties{Content: prior.Content, Permissions: "0644", SELinuxContext: "system_u:object_r:httpd_sys_content_t:s0"}
+	assert.Equal(t, "updated attributes only: selinuxContext unset -> system_u:object_r:httpd_sys_content_t:s0", decideUpdate(prior, labeled).String())
+	assert.Equal(t, "no changes", decideUpdate(labeled, prior).String(), "an undeclared context is left to the server")
+
+	structured := &FileProperties{Content: `{"a": 1, "b": 2}`, ContentFormat: contentFormatJSON, Permissions: "0644"}
+	d = decideUpdate(structured, &FileProperties{Content: `{"b":2,"a":1}`, ContentFormat: contentFormatJSON, Permissions: "0644"})
+	assert.Equal(t, "no changes", d.String())
+
+	hashed := &FileProperties{SHA256: contentHash(prior.Content), Permissions: "0644"}
+	assert.Equal(t, "no changes", decideUpdate(hashed, prior).String())
+	assert.Regexp(t, `^rewrote file: content sha256 \w{12} -> \w{12}$`, decideUpdate(hashed, &FileProperties{Content: "x", Permissions: "0644"}).String())
+}
+
+// TestOmitContent verifies that with omitContent set, File and FileContent
+// properties carry the content's hash but never the content itself.
+func TestOmitContent(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{settings: Settings{OmitContent: true}}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/a.txt", []byte("secret"), 0600))
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/a.txt", TargetConfig: target})
+	require.NoError(t, err)
+	assert.NotContains(t, read.Properties, "secret")
+	assert.NotContains(t, read.Properties, `"content"`)
+	assert.Contains(t, read.Properties, `"sha256":"`+contentHash("secret")+`"`)
+	assert.Contains(t, read.Properties, `"size":6`)
+
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: fileContentType, NativeID: "/upload/a.txt", TargetConfig: target})
+	require.NoError(t, err)
+	assert.NotContains(t, read.Properties, "secret")
+	assert.Contains(t, read.Properties, contentHash("secret"))
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{
+		ResourceType:      fileType,
+		NativeID:          "/upload/a.txt",
+		DesiredProperties: json.RawMessage(`{"path":"/upload/a.txt","content":"secret","permissions":"0640"}`),
+		TargetConfig:      target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	assert.NotContains(t, string(updated.ProgressResult.ResourceProperties), "secret")
+}
+
+// TestUpdateSkipsNoop verifies an Update without prior state writes nothing
+// when the server already holds the desired content and permissions.
+func TestUpdateSkipsNoop(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/a.txt", []byte("hello"), 0600))
+
+	update := func(desired string) *resource.ProgressResult {
+		result, err := p.Update(ctx, &resource.UpdateRequest{
+			ResourceType:      fileType,
+			NativeID:          "/upload/a.txt",
+			DesiredProperties: json.RawMessage(desired),
+			TargetConfig:      target,
+		})
+		require.NoError(t, err)
+		require.Equal(t, resource.OperationStatusSuccess, result.ProgressResult.OperationStatus, result.ProgressResult.StatusMessage)
+		return result.ProgressResult
+	}
+
+	result := update(`{"path":"/upload/a.txt","content":"hello","permissions":"0600"}`)
+	assert.Equal(t, "no changes: server already matches desired state", result.StatusMessage)
+	assert.Zero(t, client.Stats().Operations)
+
+	result = update(`{"path":"/upload/a.txt","content":"hello","permissions":"0640"}`)
+	assert.Equal(t, "changed permissions only: permissions 0600 -> 0640", result.StatusMessage)
+	assert.Zero(t, client.Stats().Operations)
+}
+
+// TestReadFileTypes verifies Read reports a regular file with its content,
+// a symlink with its target and a directory without content, and that
+// followSymlinks reads the file a symlink points at instead.
+func TestReadFileTypes(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() { asyncsftp.ResetMemory(t.Name()) })
+	c, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name(), LocalDir: "/upload/dir"})
+	require.NoError(t, err)
+	require.NoError(t, c.WriteFile("/upload/app.conf", []byte("debug = true\n"), 0o640))
+	require.NoError(t, c.Close())
+	require.NoError(t, asyncsftp.MemorySymlink(t.Name(), "app.conf", "/upload/current"))
+	p := &Plugin{}
+
+	read := func(target json.RawMessage, nativeID string) FileProperties {
+		result, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: nativeID, TargetConfig: target})
+		require.NoError(t, err)
+		require.Empty(t, result.ErrorCode, nativeID)
+		var props FileProperties
+		require.NoError(t, json.Unmarshal([]byte(result.Properties), &props))
+		return props
+	}
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+
+	file := read(target, "/upload/app.conf")
+	assert.Equal(t, "regular", file.FileType)
+	assert.Equal(t, "debug = true\n", file.Content)
+	assert.Equal(t, "0640", file.Permissions)
+	assert.Empty(t, file.LinkTarget)
+
+	link := read(target, "/upload/current")
+	assert.Equal(t, "symlink", link.FileType)
+	assert.Equal(t, "app.conf", link.LinkTarget)
+	assert.Empty(t, link.Content)
+
+	dir := read(target, "/upload/dir")
+	assert.Equal(t, "directory", dir.FileType)
+	assert.Empty(t, dir.Content)
+	assert.Empty(t, dir.LinkTarget)
+
+	followed := read(json.RawMessage(`{"url":"memory://`+t.Name()+`/upload","followSymlinks":true}`), "/upload/current")
+	assert.Equal(t, "regular", followed.FileType)
+	assert.Equal(t, "debug = true\n", followed.Content)
+}
+
+// TestUpdateNonRegular verifies Update refuses to write through a symlink
+// or onto a directory, leaving the file the symlink points at untouched.
+func TestUpdateNonRegular(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() { asyncsftp.ResetMemory(t.Name()) })
+	c, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name(), LocalDir: "/upload/dir"})
+	require.NoError(t, err)
+	require.NoError(t, c.WriteFile("/upload/app.conf", []byte("v1"), 0o644))
+	require.NoError(t, asyncsftp.MemorySymlink(t.Name(), "app.conf", "/upload/current"))
+	defer func() { _ = c.Close() }()
+	p := &Plugin{}
+	defer func() { _ = p.Close(plugin.LoggerFromContext(ctx)) }()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+
+	for nativeID, kind := range map[string]string{"/upload/current": "symlink", "/upload/dir": "directory"} {
+		updated, err := p.Update(ctx, &resource.UpdateRequest{NativeID: nativeID, ResourceType: fileType, TargetConfig: target,
+			PriorProperties:   json.RawMessage(`{"path":"` + nativeID + `","content":"v1"}`),
+			DesiredProperties: json.RawMessage(`{"path":"` + nativeID + `","content":"v2"}`)})
+		require.NoError(t, err)
+		assert.Equal(t, resource.OperationStatusFailure, updated.ProgressResult.OperationStatus, nativeID)
+		assert.Equal(t, resource.OperationErrorCodeInvalidRequest, updated.ProgressResult.ErrorCode, nativeID)
+		assert.Equal(t, nativeID+" is a "+kind+", not a regular file", updated.ProgressResult.StatusMessage)
+	}
+
+	info, err := c.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", info.Content)
+}
+
+// TestReadStatOnly verifies a stat-mode Read reports size, modification
+// time, permissions and a hash computed on the server without downloading
+// any content, where a full Read downloads the file.
+func TestReadStatOnly(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"readMode":"stat","allowExec":true`)
+	content := strings.Repeat("artifact", 1024)
+	require.NoError(t, client.WriteFile("/upload/app.bin", []byte(content), 0o640))
+	info, err := client.Stat("/upload/app.bin")
+	require.NoError(t, err)
+
+	before := client.Stats().BytesDownloaded
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/app.bin", TargetConfig: target})
+	require.NoError(t, err)
+	require.Empty(t, read.ErrorCode)
+	assert.Equal(t, before, client.Stats().BytesDownloaded, "stat Read downloaded content")
+
+	var props FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &props))
+	assert.Equal(t, int64(len(content)), props.Size)
+	assert.Equal(t, formatTimestamp(info.ModifiedAt, ""), props.ModifiedAt)
+	assert.Equal(t, "0640", props.Permissions)
+	assert.Equal(t, contentHash(content), props.SHA256)
+	assert.NotContains(t, read.Properties, `"content"`)
+
+	full := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	_, err = p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/app.bin", TargetConfig: full})
+	require.NoError(t, err)
+	assert.Equal(t, before+int64(len(content)), client.Stats().BytesDownloaded)
+}
+
+// TestFormatTimestamp verifies modification times are reported in UTC in
+// each supported format.
+func TestFormatTimestamp(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	mtime := time.Date(2025, 1, 2, 13, 4, 5, 600, berlin)
+
+	assert.Equal(t, "2025-01-02T12:04:05Z", formatTimestamp(mtime, ""))
+	assert.Equal(t, "2025-01-02T12:04:05Z", formatTimestamp(mtime, timestampRFC3339))
+	assert.Equal(t, "2025-01-02T12:04:05.0000006Z", formatTimestamp(mtime, timestampRFC3339Nano))
+	assert.Equal(t, "1735819445", formatTimestamp(mtime, timestampEpoch))
+}
+
+// TestUpdateMovesFile verifies an Update to a new path moves the file there,
+// refuses to move it onto another file, validates the update before moving,
+// and reports the new path when the update fails after the move.
+func TestUpdateMovesFile(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/a.txt", []byte("hello"), 0600))
+	require.NoError(t, client.WriteFile("/upload/c.txt", []byte("other"), 0600))
+
+	update := func(from, desired string) *resource.ProgressResult {
+		result, err := p.Update(ctx, &resource.UpdateRequest{
+			ResourceType:      fileType,
+			NativeID:          from,
+			DesiredProperties: json.RawMessage(desired),
+			TargetConfig:      target,
+		})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	result := update("/upload/a.txt", `{"path":"/upload/b.txt","content":"hello","permissions":"0600"}`)
+	require.Equal(t, resource.OperationStatusSuccess, result.OperationStatus, result.StatusMessage)
+	assert.Equal(t, "/upload/b.txt", result.NativeID)
+	assert.Equal(t, "moved from /upload/a.txt; no changes: server already matches desired state", result.StatusMessage)
+	_, err := client.Stat("/upload/a.txt")
+	assert.Error(t, err)
+
+	result = update("/upload/b.txt", `{"path":"/upload/c.txt","content":"hello","permissions":"0600"}`)
+	assert.Equal(t, resource.OperationStatusFailure, result.OperationStatus)
+	assert.Equal(t, resource.OperationErrorCodeAlreadyExists, result.ErrorCode)
+	info, err := client.ReadFile("/upload/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "other", info.Content)
+
+	// An update that is refused leaves the file where it was
+	result = update("/upload/b.txt", `{"path":"/upload/d.txt","content":"hello","writeMode":"append"}`)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, result.ErrorCode)
+	_, err = client.Stat("/upload/b.txt")
+	assert.NoError(t, err)
+	_, err = client.Stat("/upload/d.txt")
+	assert.ErrorIs(t, err, asyncsftp.ErrNotFound)
+
+	// One failing after the move reports where the file now is
+	target = json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","maxFileSize":3}`)
+	result = update("/upload/b.txt", `{"path":"/upload/e.txt","content":"too long","permissions":"0600"}`)
+	assert.Equal(t, resource.OperationStatusFailure, result.OperationStatus)
+	assert.Equal(t, "/upload/e.txt", result.NativeID)
+}
+
+// TestCreateSmallFileSynchronously verifies that Create answers with the
+// created file's properties when it is within syncCreateMaxSize, and
+// leaves larger files to Status.
+func TestCreateSmallFileSynchronously(t *testing.T) {
+	ctx := context.Background()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 5}}
+
+	create := func(path, content string) *resource.ProgressResult {
+		result, err := p.Create(ctx, &resource.CreateRequest{
+			ResourceType: fileType,
+			Properties:   json.RawMessage(`{"path":"` + path + `","content":"` + content + `","permissions":"0600"}`),
+			TargetConfig: target,
+		})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	result := create("/upload/small.txt", "hello")
+	require.Equal(t, resource.OperationStatusSuccess, result.OperationStatus, result.StatusMessage)
+	assert.Equal(t, resource.OperationCreate, result.Operation)
+	assert.Equal(t, "/upload/small.txt", result.NativeID)
+	var props FileProperties
+	require.NoError(t, json.Unmarshal(result.ResourceProperties, &props))
+	assert.Equal(t, "hello", props.Content)
+	assert.Equal(t, "0600", props.Permissions)
+
+	result = create("/upload/large.txt", "hello world")
+	assert.Equal(t, resource.OperationStatusInProgress, result.OperationStatus)
+	assert.NotEmpty(t, result.RequestID)
+}
+
+// TestCreateRefusesOversizedContent verifies that content beyond the
+// target's maxFileSize fails Create before anything is uploaded.
+func TestCreateRefusesOversizedContent(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"maxFileSize":5`)
+
+	result, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/upload/big.txt","content":"hello world"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, result.ProgressResult.OperationStatus)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, result.ProgressResult.ErrorCode)
+	assert.Contains(t, result.ProgressResult.StatusMessage, "11 bytes is more than the 5 bytes")
+
+	_, err = client.Stat("/upload/big.txt")
+	assert.ErrorIs(t, err, asyncsftp.ErrNotFound)
+}
+
+// TestInlineContentLimit verifies that inline content beyond
+// maxInlineContentSize is rejected, counting inline content parts, while
+// the same content from a file part is accepted.
+func TestInlineContentLimit(t *testing.T) {
+	ctx := context.Background()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	p := &Plugin{settings: Settings{MaxInlineContentSize: 4}}
+	source := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, os.WriteFile(source, []byte("hello"), 0600))
+
+	create := func(props string) *resource.ProgressResult {
+		result, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(props), TargetConfig: target})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	for _, props := range []string{
+		`{"path":"/upload/a.txt","content":"hello"}`,
+		`{"path":"/upload/a.txt","contentParts":[{"inline":"he"},{"inline":"llo"}]}`,
+	} {
+		result := create(props)
+		assert.Equal(t, resource.OperationErrorCodeInvalidRequest, result.ErrorCode, props)
+		assert.Contains(t, result.StatusMessage, "5 bytes of inline content is more than maxInlineContentSize (4)")
+	}
+
+	result := create(`{"path":"/upload/a.txt","contentParts":[{"file":"` + source + `"}]}`)
+	assert.NotEqual(t, resource.OperationStatusFailure, result.OperationStatus, result.StatusMessage)
+}
+
+// TestCreateConflictingOwner verifies a Create of a path another resource
+// manages fails with a conflict naming the owner, unless it adopts the file,
+// and that of two concurrent creates only one goes ahead.
+func TestCreateConflictingOwner(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 64}}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+	create := func(label, path, extra string) *resource.ProgressResult {
+		result, err := p.Create(ctx, &resource.CreateRequest{
+			ResourceType: fileType,
+			Label:        label,
+			Properties:   json.RawMessage(`{"path":"` + path + `","content":"` + label + `"` + extra + `}`),
+			TargetConfig: target,
+		})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	result := create("stack-a", "/upload/app.conf", "")
+	require.Equal(t, resource.OperationStatusSuccess, result.OperationStatus, result.StatusMessage)
+	result = create("stack-a", "/upload/app.conf", "")
+	assert.Equal(t, resource.OperationStatusSuccess, result.OperationStatus, "the owner may create again")
+
+	result = create("stack-b", "/upload/app.conf", "")
+	assert.Equal(t, resource.OperationErrorCodeResourceConflict, result.ErrorCode)
+	assert.Contains(t, result.StatusMessage, `"stack-a" (SFTP::Files::File)`)
+
+	result = create("stack-b", "/upload/app.conf", `,"adopt":true`)
+	require.Equal(t, resource.OperationStatusSuccess, result.OperationStatus, result.StatusMessage)
+	m, err := readManifest(client, "/upload/.formae.json")
+	require.NoError(t, err)
+	assert.Equal(t, "stack-b", m.Files["/upload/app.conf"].Label)
+
+	var wg sync.WaitGroup
+	results := make([]*resource.ProgressResult, 2)
+	for i, label := range []string{"stack-c", "stack-d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = create(label, "/upload/new.conf", "")
+		}()
+	}
+	wg.Wait()
+	conflicts := 0
+	for _, result := range results {
+		if result.ErrorCode == resource.OperationErrorCodeResourceConflict {
+			conflicts++
+		}
+	}
+	assert.Equal(t, 1, conflicts)
+
+	_, err = claimPath(client, "/upload/.formae.json", "/upload/failed.conf", "stack-e", fileType, false)
+	require.NoError(t, err)
+	require.NoError(t, releaseClaim(client, "/upload/.formae.json", "/upload/failed.conf", "stack-e"))
+	_, err = claimPath(client, "/upload/.formae.json", "/upload/failed.conf", "stack-f", fileType, false)
+	assert.NoError(t, err, "a failed create's claim is released")
+}
+
+// TestMissingDirectoryNamesSession verifies an upload into a missing
+// directory reports who and where the session is logged in.
+func TestMissingDirectoryNamesSession(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 64}}
+	result, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/upload/missing/app.conf","content":"x"}`),
+		TargetConfig: json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, result.ProgressResult.OperationStatus)
+	assert.Contains(t, result.ProgressResult.StatusMessage, "session: user unknown, home /")
+}
+
+// TestFileOutputs verifies a File reports the same location and hash
+// outputs after Create as on a later Read, with the canonical path
+// resolving symlinked parent directories.
+func TestFileOutputs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "releases", "7"), 0755))
+	require.NoError(t, os.Symlink("releases/7", filepath.Join(dir, "current")))
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 1024}}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/current/app.tar.gz","content":"artifact","permissions":"0644"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	var outputs FileProperties
+	require.NoError(t, json.Unmarshal(created.ProgressResult.ResourceProperties, &outputs))
+	assert.Equal(t, "/releases/7/app.tar.gz", outputs.CanonicalPath)
+	assert.Equal(t, "file://"+filepath.Join(dir, "releases/7/app.tar.gz"), outputs.URL)
+	assert.Equal(t, contentHash("artifact"), outputs.SHA256)
+	assert.Equal(t, int64(len("artifact")), outputs.Size)
+	assert.NotEmpty(t, outputs.ModifiedAt)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/current/app.tar.gz", TargetConfig: target})
+	require.NoError(t, err)
+	var props FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &props))
+	for name, pair := range map[string][2]any{
+		"canonicalPath": {outputs.CanonicalPath, props.CanonicalPath},
+		"url":           {outputs.URL, props.URL},
+		"sha256":        {outputs.SHA256, props.SHA256},
+		"size":          {outputs.Size, props.Size},
+		"modifiedAt":    {outputs.ModifiedAt, props.ModifiedAt},
+	} {
+		assert.Equal(t, pair[0], pair[1], name)
+	}
+
+	assert.Equal(t, "sftp://files.example.com:2222/upload/a.txt", fileURL("sftp://deploy@files.example.com:2222", "/upload/a.txt"))
+	assert.Equal(t, "memory://store/upload/a.txt", fileURL("memory://store/upload", "/upload/a.txt"))
+}
+
+// TestVerifyHash verifies a File's verifyHash takes precedence over the
+// target's, and that unknown algorithms are rejected up front.
+func TestVerifyHash(t *testing.T) {
+	target := json.RawMessage(`{"url":"memory://h/upload","verifyHash":"xxhash"}`)
+	props := FileProperties{Path: "/upload/a.txt", VerifyUpload: true}
+	assert.Equal(t, asyncsftp.HashXXHash, props.uploadOptions(target).VerifyHash)
+	props.VerifyHash = "sha512"
+	assert.Equal(t, asyncsftp.HashSHA512, props.uploadOptions(target).VerifyHash)
+
+	_, err := parseTargetConfig(json.RawMessage(`{"url":"memory://h/upload","verifyHash":"md5"}`))
+	assert.ErrorIs(t, err, errInvalidTargetConfig)
+	_, err = parseFileProperties(json.RawMessage(`{"path":"/upload/a.txt","content":"x","verifyHash":"md5"}`))
+	assert.ErrorContains(t, err, "invalid 'verifyHash'")
+}
+
+// TestChecksumFile verifies a checksum file is written with the upload,
+// rewritten alone when only its layout changes, and removed before the
+// file on delete.
+func TestChecksumFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 1024}}
+	sha := sha256.Sum256([]byte("artifact"))
+	md := md5.Sum([]byte("artifact"))
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/app.tar.gz","content":"artifact","permissions":"0644","checksumFile":"sha256"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	sidecar, err := os.ReadFile(filepath.Join(dir, "app.tar.gz.sha256"))
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sha[:])+"  app.tar.gz\n", string(sidecar))
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{
+		ResourceType:      fileType,
+		NativeID:          "/app.tar.gz",
+		PriorProperties:   created.ProgressResult.ResourceProperties,
+		DesiredProperties: json.RawMessage(`{"path":"/app.tar.gz","content":"artifact","permissions":"0644","checksumFile":"md5","checksumFileFormat":"bsd"}`),
+		TargetConfig:      target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	assert.Contains(t, updated.ProgressResult.StatusMessage, "updated attributes only: checksumFile sha256 (gnu) -> md5 (bsd)")
+	assert.NoFileExists(t, filepath.Join(dir, "app.tar.gz.sha256"))
+	sidecar, err = os.ReadFile(filepath.Join(dir, "app.tar.gz.md5"))
+	require.NoError(t, err)
+	assert.Equal(t, "MD5 (app.tar.gz) = "+hex.EncodeToString(md[:])+"\n", string(sidecar))
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: fileType, NativeID: "/app.tar.gz", TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus, deleted.ProgressResult.StatusMessage)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = parseFileProperties(json.RawMessage(`{"path":"/a.txt","content":"x","checksumFileFormat":"bsd"}`))
+	assert.ErrorContains(t, err, "'checksumFileFormat' requires 'checksumFile'")
+}
+
+// TestProtectNewer verifies an update refuses to overwrite a file changed
+// on the server since formae's last write, recorded in the manifest or the
+// prior state, and goes ahead without the option.
+func TestProtectNewer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 1024}}
+
+	for name, target := range map[string]json.RawMessage{
+		"manifest":    json.RawMessage(`{"url":"file://` + dir + `","manifestPath":"/.formae.json"}`),
+		"prior state": json.RawMessage(`{"url":"file://` + dir + `"}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := "/" + strings.ReplaceAll(name, " ", "-") + ".csv"
+			created, err := p.Create(ctx, &resource.CreateRequest{
+				ResourceType: fileType,
+				Label:        name,
+				Properties:   json.RawMessage(`{"path":"` + file + `","content":"v1","permissions":"0644"}`),
+				TargetConfig: target,
+			})
+			require.NoError(t, err)
+			require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte("edited by hand"), 0644))
+
+			update := func(protect string) *resource.ProgressResult {
+				updated, err := p.Update(ctx, &resource.UpdateRequest{
+					ResourceType:      fileType,
+					Label:             name,
+					NativeID:          file,
+					PriorProperties:   created.ProgressResult.ResourceProperties,
+					DesiredProperties: json.RawMessage(`{"path":"` + file + `","content":"v2","permissions":"0644"` + protect + `}`),
+					TargetConfig:      target,
+				})
+				require.NoError(t, err)
+				return updated.ProgressResult
+			}
+			refused := update(`,"protectNewer":true`)
+			assert.Equal(t, resource.OperationErrorCodeResourceConflict, refused.ErrorCode)
+			assert.Contains(t, refused.StatusMessage, "was modified on the server after formae last wrote it")
+			assert.Contains(t, refused.StatusMessage, name+" has")
+			content, err := os.ReadFile(filepath.Join(dir, file))
+			require.NoError(t, err)
+			assert.Equal(t, "edited by hand", string(content))
+
+			overwritten := update("")
+			assert.Equal(t, resource.OperationStatusSuccess, overwritten.OperationStatus, overwritten.StatusMessage)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// File Content
+// =============================================================================
+
+// fileContentType is a read-only data source exposing a file the server
+// generates, e.g. a host key fingerprint or an ID file, as input to other
+// resources. The file is read but never written or removed.
+const fileContentType = "SFTP::Files::FileContent"
+
+// FileContentProperties are the properties of a FileContent data source.
+type FileContentProperties struct {
+	// Path is the file read (the native ID).
+	Path       string `json:"path"`
+	Content    string `json:"content"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modifiedAt,omitempty"`
+}
+
+// queryFileContent downloads the regular file at path.
+func queryFileContent(client *asyncsftp.Client, cfg *TargetConfig, path string) (any, error) {
+	target, err := readPath(client, cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.ReadFile(target)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != asyncsftp.FileTypeRegular {
+		return nil, fmt.Errorf("%w: %s is a %s, not a regular file", errInvalidPath, path, info.Type)
+	}
+
+	props := FileContentProperties{
+		Path:    path,
+		Content: info.Content,
+		SHA256:  contentHash(info.Content),
+		Size:    info.Size,
+	}
+	// Open probes cannot see the modification time
+	if !info.ModifiedAt.IsZero() {
+		props.ModifiedAt = formatTimestamp(info.ModifiedAt, cfg.TimestampFormat)
+	}
+	return props, nil
+}
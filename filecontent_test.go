@@ -0,0 +1,59 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileContent verifies the FileContent data source reports a file's
+// content and hash, rejects directories, and leaves the file on delete.
+func TestFileContent(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/id", []byte("node-42"), 0644))
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileContentType,
+		Properties:   json.RawMessage(`{"path":"/upload/id"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+
+	var props FileContentProperties
+	require.NoError(t, json.Unmarshal(created.ProgressResult.ResourceProperties, &props))
+	assert.Equal(t, "node-42", props.Content)
+	assert.Equal(t, contentHash("node-42"), props.SHA256)
+	assert.Equal(t, int64(7), props.Size)
+
+	dir, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileContentType,
+		Properties:   json.RawMessage(`{"path":"/upload"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, dir.ProgressResult.ErrorCode)
+
+	_, err = p.Delete(ctx, &resource.DeleteRequest{
+		ResourceType: fileContentType,
+		NativeID:     "/upload/id",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	info, err := client.ReadFile("/upload/id")
+	require.NoError(t, err)
+	assert.Equal(t, "node-42", info.Content)
+}
@@ -0,0 +1,313 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Resource Handlers
+// =============================================================================
+
+// resourceHandler implements CRUD for one resource type. Handlers share the
+// Plugin's connection, rate limits and async operation tracking.
+type resourceHandler interface {
+	Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error)
+	Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error)
+	Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error)
+	Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error)
+}
+
+// pluginNamespace is the namespace declared in formae-plugin.pkl. The plugin
+// SDK serves exactly one namespace per plugin and the agent rejects
+// operations for any other, so protocol variants are selected by the
+// target's url (scp://, ftps://) instead of separate SCP:: or FTPS:: types.
+// Every registered resource type lives under it.
+const pluginNamespace = "SFTP"
+
+// resourceHandlers registers a handler constructor for each resource type.
+// Adding a resource kind means adding its schema class, an entry here and
+// one in resourceProperties.
+var resourceHandlers = map[string]func(*Plugin) resourceHandler{
+	fileType:             func(p *Plugin) resourceHandler { return &fileHandler{plugin: p} },
+	filePermissionsType:  func(p *Plugin) resourceHandler { return &permissionsHandler{plugin: p} },
+	diskUsageType:        dataSource(queryDiskUsage),
+	directoryListingType: dataSource(queryDirectoryListing),
+	fileContentType:      dataSource(queryFileContent),
+	markerType:           func(p *Plugin) resourceHandler { return &markerHandler{plugin: p} },
+	authorizedKeyType:    func(p *Plugin) resourceHandler { return &authorizedKeyHandler{plugin: p} },
+	patternedFilesType:   func(p *Plugin) resourceHandler { return &patternedFilesHandler{plugin: p} },
+	retentionPolicyType:  func(p *Plugin) resourceHandler { return &retentionPolicyHandler{plugin: p} },
+	downloadType:         func(p *Plugin) resourceHandler { return &downloadHandler{plugin: p} },
+	copyType:             func(p *Plugin) resourceHandler { return &copyHandler{plugin: p} },
+}
+
+// resourceProperties returns an empty properties value of each resource
+// type, which strict mode decodes declared properties into.
+var resourceProperties = map[string]func() any{
+	fileType:             func() any { return &FileProperties{} },
+	filePermissionsType:  func() any { return &PermissionsProperties{} },
+	diskUsageType:        func() any { return &DiskUsageProperties{} },
+	directoryListingType: func() any { return &DirectoryListingProperties{} },
+	fileContentType:      func() any { return &FileContentProperties{} },
+	markerType:           func() any { return &MarkerProperties{} },
+	authorizedKeyType:    func() any { return &AuthorizedKeyProperties{} },
+	patternedFilesType:   func() any { return &PatternedFilesProperties{} },
+	retentionPolicyType:  func() any { return &RetentionPolicyProperties{} },
+	downloadType:         func() any { return &DownloadProperties{} },
+	copyType:             func() any { return &CopyProperties{} },
+}
+
+// checkUnknownProperties rejects a property resourceType does not have,
+// e.g. a misspelled "permisions", which decoding otherwise ignores. Other
+// decoding errors are left for the handler to report.
+func checkUnknownProperties(resourceType string, data json.RawMessage) error {
+	newProps, ok := resourceProperties[resourceType]
+	if !ok || len(data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(newProps())
+	if err == nil {
+		return nil
+	}
+	if field, unknown := strings.CutPrefix(err.Error(), "json: unknown field "); unknown {
+		return fmt.Errorf("unknown property %s for %s (strictProperties is on)", field, resourceType)
+	}
+	return nil
+}
+
+// handler returns the handler for resourceType.
+func (p *Plugin) handler(resourceType string) (resourceHandler, error) {
+	namespace, _, _ := strings.Cut(resourceType, "::")
+	if !strings.EqualFold(namespace, pluginNamespace) {
+		return nil, fmt.Errorf("resource type %q is outside the %s namespace this plugin serves; choose SCP or FTPS with the target url instead",
+			resourceType, pluginNamespace)
+	}
+	newHandler, ok := resourceHandlers[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource type %q", resourceType)
+	}
+	return newHandler(p), nil
+}
+
+// failureResult builds the failure result of a synchronous operation.
+func failureResult(op resource.Operation, nativeID string, code resource.OperationErrorCode, err error) *resource.ProgressResult {
+	return &resource.ProgressResult{
+		Operation:       op,
+		OperationStatus: resource.OperationStatusFailure,
+		ErrorCode:       code,
+		StatusMessage:   err.Error(),
+		NativeID:        nativeID,
+	}
+}
+
+// requestContext prepares a request's context: its logger filtered by the
+// configured level, kept for support bundles when they are enabled, and
+// tagged with the target's name, so logs from several targets can be told
+// apart.
+func (p *Plugin) requestContext(ctx context.Context, targetConfig json.RawMessage) context.Context {
+	ctx = p.settings.withLogLevel(ctx)
+	return plugin.WithLogger(ctx, p.targetLogger(plugin.LoggerFromContext(ctx), targetName(targetConfig)))
+}
+
+// backgroundLogger returns the logger for work that outlives the request
+// that started it, such as a target's temp-file sweeper: the process's
+// default logger, prepared as a request's would be, so it names the target
+// but none of the first request's attributes.
+func (p *Plugin) backgroundLogger(cfg *TargetConfig) plugin.Logger {
+	ctx := p.settings.withLogLevel(plugin.WithLogger(context.Background(), plugin.NewPluginLogger(slog.Default())))
+	return p.targetLogger(plugin.LoggerFromContext(ctx), cfg.Name)
+}
+
+// targetLogger keeps log's records for support bundles when they are
+// enabled and tags them with the target's name.
+func (p *Plugin) targetLogger(log plugin.Logger, name string) plugin.Logger {
+	if p.settings.SupportBundleDir != "" {
+		log = p.support.logs.logger(log)
+	}
+	if name != "" {
+		log = log.With("target", name)
+	}
+	return log
+}
+
+// finish completes a request's result: its status message names the
+// target and any subtrees its last Lists skipped, and repeated failures
+// write a support bundle when enabled.
+func (p *Plugin) finish(ctx context.Context, targetConfig json.RawMessage, result *resource.ProgressResult) {
+	// A document an import prepared no longer describes a changed file
+	if result != nil && result.NativeID != "" {
+		p.imports.take(targetConfig, result.NativeID, time.Now())
+	}
+	p.recordOutcome(ctx, targetConfig, result)
+	if result != nil {
+		p.listGaps.annotate(targetConfig, result)
+	}
+	nameResult(targetConfig, result)
+}
+
+// nameResult prefixes result's status message with the target's name, so
+// reports covering several targets say which one each message is about.
+func nameResult(targetConfig json.RawMessage, result *resource.ProgressResult) {
+	name := targetName(targetConfig)
+	if name == "" || result == nil || result.StatusMessage == "" {
+		return
+	}
+	prefix := "[" + name + "] "
+	if !strings.HasPrefix(result.StatusMessage, prefix) {
+		result.StatusMessage = prefix + result.StatusMessage
+	}
+}
+
+// =============================================================================
+// CRUD Operations
+// =============================================================================
+
+// Create provisions a new resource with its type's handler.
+func (p *Plugin) Create(ctx context.Context, req *resource.CreateRequest) (result *resource.CreateResult, err error) {
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	defer func() {
+		if result != nil {
+			p.finish(ctx, req.TargetConfig, result.ProgressResult)
+		}
+	}()
+	h, err := p.handler(req.ResourceType)
+	if err != nil {
+		return &resource.CreateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationCreate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+			},
+		}, nil
+	}
+	if p.settings.StrictProperties {
+		if err := checkUnknownProperties(req.ResourceType, req.Properties); err != nil {
+			return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+		}
+	}
+	if _, readOnly := h.(*dataSourceHandler); !readOnly {
+		if cfg, err := parseTargetConfig(req.TargetConfig); err == nil {
+			if cfg.DryRun {
+				return &resource.CreateResult{ProgressResult: p.dryRun(ctx, resource.OperationCreate, req.ResourceType, "", nil, req.Properties, req.TargetConfig)}, nil
+			}
+			if deferred := deferredByQuietHours(ctx, cfg, resource.OperationCreate, ""); deferred != nil {
+				return &resource.CreateResult{ProgressResult: deferred}, nil
+			}
+		}
+	}
+	return h.Create(ctx, req)
+}
+
+// Read retrieves the current state of a resource with its type's handler.
+func (p *Plugin) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	h, err := p.handler(req.ResourceType)
+	if err != nil {
+		return &resource.ReadResult{
+			ResourceType: req.ResourceType,
+			ErrorCode:    resource.OperationErrorCodeInvalidRequest,
+		}, nil
+	}
+	if req.ResourceType == fileType {
+		if properties, ok := p.imports.take(req.TargetConfig, req.NativeID, time.Now()); ok {
+			return &resource.ReadResult{ResourceType: req.ResourceType, Properties: properties}, nil
+		}
+	}
+	return h.Read(ctx, req)
+}
+
+// Update modifies an existing resource with its type's handler.
+func (p *Plugin) Update(ctx context.Context, req *resource.UpdateRequest) (result *resource.UpdateResult, err error) {
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	defer func() {
+		if result != nil {
+			p.finish(ctx, req.TargetConfig, result.ProgressResult)
+		}
+	}()
+	h, err := p.handler(req.ResourceType)
+	if err != nil {
+		return &resource.UpdateResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationUpdate,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+	if p.settings.StrictProperties {
+		if err := checkUnknownProperties(req.ResourceType, req.DesiredProperties); err != nil {
+			return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+		}
+	}
+	// Data sources only read, so pauses, dry runs and quiet hours don't
+	// apply to them. Invalid target config is left for the handler to
+	// report.
+	if _, readOnly := h.(*dataSourceHandler); !readOnly {
+		if cfg, err := parseTargetConfig(req.TargetConfig); err == nil {
+			if pattern, paused := pausedBy(cfg, req.NativeID); paused {
+				return &resource.UpdateResult{ProgressResult: p.pausedUpdate(ctx, h, req, pattern)}, nil
+			}
+			if cfg.DryRun {
+				return &resource.UpdateResult{ProgressResult: p.dryRun(ctx, resource.OperationUpdate, req.ResourceType, req.NativeID, req.PriorProperties, req.DesiredProperties, req.TargetConfig)}, nil
+			}
+			if deferred := deferredByQuietHours(ctx, cfg, resource.OperationUpdate, req.NativeID); deferred != nil {
+				return &resource.UpdateResult{ProgressResult: deferred}, nil
+			}
+		}
+	}
+	return h.Update(ctx, req)
+}
+
+// Delete removes a resource with its type's handler.
+func (p *Plugin) Delete(ctx context.Context, req *resource.DeleteRequest) (result *resource.DeleteResult, err error) {
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	defer func() {
+		if result != nil {
+			p.finish(ctx, req.TargetConfig, result.ProgressResult)
+		}
+	}()
+	h, err := p.handler(req.ResourceType)
+	if err != nil {
+		return &resource.DeleteResult{
+			ProgressResult: &resource.ProgressResult{
+				Operation:       resource.OperationDelete,
+				OperationStatus: resource.OperationStatusFailure,
+				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
+				StatusMessage:   err.Error(),
+				NativeID:        req.NativeID,
+			},
+		}, nil
+	}
+	if _, readOnly := h.(*dataSourceHandler); !readOnly {
+		if cfg, err := parseTargetConfig(req.TargetConfig); err == nil {
+			if pattern, paused := pausedBy(cfg, req.NativeID); paused {
+				return &resource.DeleteResult{ProgressResult: pausedDelete(ctx, req, pattern)}, nil
+			}
+			if cfg.DryRun {
+				return &resource.DeleteResult{ProgressResult: p.dryRun(ctx, resource.OperationDelete, req.ResourceType, req.NativeID, nil, nil, req.TargetConfig)}, nil
+			}
+			if deferred := deferredByQuietHours(ctx, cfg, resource.OperationDelete, req.NativeID); deferred != nil {
+				return &resource.DeleteResult{ProgressResult: deferred}, nil
+			}
+		}
+	}
+	return h.Delete(ctx, req)
+}
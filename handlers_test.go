@@ -0,0 +1,759 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// testMemoryTarget returns a memory:// target for the test, whose files are
+// reset when it ends, and p's client for it, to arrange and inspect files.
+// dir is the target's upload directory and settings are further target
+// settings in JSON, e.g. `"maxFileSize":5`.
+func testMemoryTarget(t *testing.T, p *Plugin, dir, settings string) (json.RawMessage, *asyncsftp.Client) {
+	t.Helper()
+	t.Cleanup(func() { asyncsftp.ResetMemory(t.Name()) })
+	config := `{"url":"memory://` + t.Name() + dir + `"`
+	if settings != "" {
+		config += "," + settings
+	}
+	target := json.RawMessage(config + "}")
+	ctx := context.Background()
+	client, err := p.getClient(ctx, plugin.LoggerFromContext(ctx), target)
+	require.NoError(t, err)
+	return target, client
+}
+
+// fieldLogger records every line with its fields, including those added
+// by With.
+type fieldLogger struct {
+	mu     *sync.Mutex
+	fields []any
+	lines  *[]map[string]any
+}
+
+func (l fieldLogger) record(msg string, args []any) {
+	fields := map[string]any{"msg": msg}
+	all := append(slices.Clone(l.fields), args...)
+	for i := 0; i+1 < len(all); i += 2 {
+		fields[fmt.Sprint(all[i])] = all[i+1]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.lines = append(*l.lines, fields)
+}
+
+func (l fieldLogger) Debug(msg string, args ...any) { l.record(msg, args) }
+func (l fieldLogger) Info(msg string, args ...any)  { l.record(msg, args) }
+func (l fieldLogger) Warn(msg string, args ...any)  { l.record(msg, args) }
+func (l fieldLogger) Error(msg string, args ...any) { l.record(msg, args) }
+func (l fieldLogger) With(args ...any) plugin.Logger {
+	return fieldLogger{mu: l.mu, fields: append(slices.Clone(l.fields), args...), lines: l.lines}
+}
+
+// line returns the fields of the first line logged with msg.
+func (l fieldLogger) line(msg string) map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, fields := range *l.lines {
+		if fields["msg"] == msg {
+			return fields
+		}
+	}
+	return nil
+}
+
+// TestCorrelationIDs verifies the label and resource type of the resource
+// that started an upload travel on its operation record, and that the log
+// lines of the request and of the Status calls that follow it carry them
+// with the request ID.
+func TestCorrelationIDs(t *testing.T) {
+	var lines []map[string]any
+	logger := fieldLogger{mu: &sync.Mutex{}, lines: &lines}
+	ctx := plugin.WithLogger(context.Background(), logger)
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	p := &Plugin{}
+	defer func() { _ = p.Close(logger) }()
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Label: "app-config", TargetConfig: target,
+		Properties: json.RawMessage(`{"path":"/upload/missing/app.conf","content":"x"}`)})
+	require.NoError(t, err)
+	requestID := created.ProgressResult.RequestID
+	require.NotEmpty(t, requestID, created.ProgressResult.StatusMessage)
+	require.Eventually(t, func() bool {
+		status, err := p.Status(ctx, &resource.StatusRequest{RequestID: requestID, TargetConfig: target})
+		require.NoError(t, err)
+		return status.ProgressResult.OperationStatus == resource.OperationStatusFailure
+	}, time.Second, time.Millisecond)
+
+	client, err := p.getClient(ctx, logger, target)
+	require.NoError(t, err)
+	op, err := client.GetStatus(requestID)
+	require.NoError(t, err)
+	assert.Equal(t, "app-config", op.Metadata[metaLabel])
+	assert.Equal(t, fileType, op.Metadata[metaResourceType])
+
+	creating := logger.line("creating file resource")
+	require.NotNil(t, creating)
+	assert.Equal(t, "app-config", creating["label"])
+	assert.Equal(t, fileType, creating["resourceType"])
+
+	failed := logger.line("operation failed")
+	require.NotNil(t, failed)
+	assert.Equal(t, requestID, failed["requestID"])
+	assert.Equal(t, "app-config", failed["label"])
+	assert.Equal(t, fileType, failed["resourceType"])
+	assert.Equal(t, "/upload/missing/app.conf", failed["path"])
+}
+
+// TestUpdateCorrelationIDs verifies the rewrite an Update starts carries
+// the label and resource type of the resource on its operation record, and
+// that the Update's log lines carry them with the native ID.
+func TestUpdateCorrelationIDs(t *testing.T) {
+	var lines []map[string]any
+	logger := fieldLogger{mu: &sync.Mutex{}, lines: &lines}
+	ctx := plugin.WithLogger(context.Background(), logger)
+	p := &Plugin{}
+	defer func() { _ = p.Close(logger) }()
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/app.conf", []byte("v1"), 0o644))
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{NativeID: "/upload/app.conf", ResourceType: fileType, Label: "app-config",
+		TargetConfig:      target,
+		PriorProperties:   json.RawMessage(`{"path":"/upload/app.conf","content":"v1"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/app.conf","content":"v2"}`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+
+	started := logger.line("rewrite started")
+	require.NotNil(t, started)
+	assert.Equal(t, "app-config", started["label"])
+	assert.Equal(t, fileType, started["resourceType"])
+	assert.Equal(t, "/upload/app.conf", started["nativeID"])
+	requestID, ok := started["requestID"].(string)
+	require.True(t, ok)
+
+	op, err := client.GetStatus(requestID)
+	require.NoError(t, err)
+	assert.Equal(t, "app-config", op.Metadata[metaLabel])
+	assert.Equal(t, fileType, op.Metadata[metaResourceType])
+}
+
+// TestHandlerDispatch verifies each resource type reaches its own handler
+// and unknown types are rejected without touching the target.
+func TestHandlerDispatch(t *testing.T) {
+	p := &Plugin{}
+
+	h, err := p.handler(fileType)
+	require.NoError(t, err)
+	assert.IsType(t, &fileHandler{}, h)
+
+	h, err = p.handler(filePermissionsType)
+	require.NoError(t, err)
+	assert.IsType(t, &permissionsHandler{}, h)
+
+	created, err := p.Create(context.Background(), &resource.CreateRequest{ResourceType: "SFTP::Files::Unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+
+	_, err = p.handler("FTPS::Files::File")
+	assert.ErrorContains(t, err, "outside the SFTP namespace")
+	for resourceType := range resourceHandlers {
+		assert.True(t, strings.HasPrefix(resourceType, pluginNamespace+"::"), resourceType)
+	}
+
+	for resourceType := range resourceHandlers {
+		assert.Contains(t, resourceProperties, resourceType)
+	}
+
+	read, err := p.Read(context.Background(), &resource.ReadRequest{ResourceType: "SFTP::Files::Unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, read.ErrorCode)
+	assert.Empty(t, p.clients)
+}
+
+// TestListPagination verifies List pages through a directory with
+// PageSize/PageToken, returning every file exactly once.
+func TestListPagination(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "upload", "sub"), 0755))
+	for i := range 5 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "upload", fmt.Sprintf("f%d.txt", i)), nil, 0644))
+	}
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{}
+
+	var all []string
+	var token *string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3, "five files in pages of two")
+		result, err := p.List(context.Background(), &resource.ListRequest{
+			ResourceType: fileType,
+			TargetConfig: target,
+			PageSize:     2,
+			PageToken:    token,
+		})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.NativeIDs), 2)
+		all = append(all, result.NativeIDs...)
+		if token = result.NextPageToken; token == nil {
+			break
+		}
+	}
+	assert.ElementsMatch(t, []string{"/upload/f0.txt", "/upload/f1.txt", "/upload/f2.txt", "/upload/f3.txt", "/upload/f4.txt"}, all)
+
+	bad := "x"
+	_, err := p.List(context.Background(), &resource.ListRequest{ResourceType: fileType, TargetConfig: target, PageToken: &bad})
+	assert.Error(t, err)
+}
+
+// TestListSkippedSubtrees verifies a recursive List returns the files it
+// could read when a subdirectory is unreadable, and that later results on
+// the target name the skipped subtree until a List reads it in full.
+func TestListSkippedSubtrees(t *testing.T) {
+	ctx := context.Background()
+	log := plugin.LoggerFromContext(ctx)
+	p := &Plugin{}
+	defer func() { _ = p.Close(log) }()
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	for _, dir := range []string{"/upload/open", "/upload/secret"} {
+		c, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name(), LocalDir: dir})
+		require.NoError(t, err)
+		require.NoError(t, c.WriteFile(dir+"/a.txt", []byte("a"), 0o644))
+		require.NoError(t, c.Close())
+	}
+	require.NoError(t, client.SetPermissions("/upload/secret", 0o300))
+
+	list := func() []string {
+		result, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+			AdditionalProperties: map[string]string{"recursive": "true"}})
+		require.NoError(t, err)
+		return result.NativeIDs
+	}
+	deleteMissing := func() string {
+		result, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: fileType, TargetConfig: target,
+			NativeID: "/upload/missing.txt"})
+		require.NoError(t, err)
+		return result.ProgressResult.StatusMessage
+	}
+
+	assert.Equal(t, []string{"/upload/open/a.txt"}, list())
+	assert.Contains(t, deleteMissing(),
+		"warning [list-incomplete]: list of /upload skipped subtrees it could not read: /upload/secret (AccessDenied)")
+
+	require.NoError(t, client.SetPermissions("/upload/secret", 0o755))
+	assert.Equal(t, []string{"/upload/open/a.txt", "/upload/secret/a.txt"}, list())
+	assert.NotContains(t, deleteMissing(), "list-incomplete")
+}
+
+// TestListFailures verifies List returns an error carrying the failure's
+// error code, not an empty list, when the target is unreachable or the
+// directory cannot be read, and an empty list only when the directory is
+// missing.
+func TestListFailures(t *testing.T) {
+	ctx := context.Background()
+	log := plugin.LoggerFromContext(ctx)
+	p := &Plugin{}
+	defer func() { _ = p.Close(log) }()
+
+	t.Setenv("SFTP_USERNAME", "u")
+	t.Setenv("SFTP_PASSWORD", "p")
+	result, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType,
+		TargetConfig: json.RawMessage(`{"url":"sftp://127.0.0.1:1/upload"}`)})
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, resource.OperationErrorCodeNetworkFailure, errorCode(err))
+
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/a.txt", []byte("a"), 0o644))
+	require.NoError(t, client.SetPermissions("/upload", 0o300))
+	for _, recursive := range []string{"false", "true"} {
+		result, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+			AdditionalProperties: map[string]string{"recursive": recursive}})
+		require.Error(t, err, "recursive=%s", recursive)
+		assert.Nil(t, result)
+		assert.Equal(t, resource.OperationErrorCodeAccessDenied, errorCode(err))
+	}
+
+	result, err = p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+		AdditionalProperties: map[string]string{"directory": "/upload/missing"}})
+	require.NoError(t, err)
+	assert.Empty(t, result.NativeIDs)
+}
+
+// countingMetrics counts the counters recorded through it by name and
+// attributes.
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *countingMetrics) Counter(name string, value int64, attrs ...attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, attr := range attrs {
+		name += fmt.Sprintf(" %s=%s", attr.Key, attr.Value.Emit())
+	}
+	m.counts[name] += value
+}
+func (m *countingMetrics) UpDownCounter(string, int64, ...attribute.KeyValue) {}
+func (m *countingMetrics) Gauge(string, float64, ...attribute.KeyValue)       {}
+func (m *countingMetrics) Histogram(string, float64, ...attribute.KeyValue)   {}
+
+// TestListFailureMetric verifies a List that fails is counted against the
+// directory it listed, and one of a missing directory is not.
+func TestListFailureMetric(t *testing.T) {
+	metrics := &countingMetrics{counts: map[string]int64{}}
+	ctx := plugin.WithMetrics(context.Background(), metrics)
+	p := &Plugin{}
+	defer func() { _ = p.Close(plugin.LoggerFromContext(ctx)) }()
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.SetPermissions("/upload", 0o300))
+
+	_, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target})
+	require.Error(t, err)
+	_, err = p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+		AdditionalProperties: map[string]string{"directory": "/upload/missing"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"sftp.list_failures directory=/upload": 1}, metrics.counts)
+}
+
+// TestStatusMessageDeduplication verifies Status reports the attempts a
+// deduplicated upload satisfied, alongside any error.
+func TestStatusMessageDeduplication(t *testing.T) {
+	op := &asyncsftp.Operation{Type: asyncsftp.OperationTypeUpload, RequestIDs: []string{"a"}}
+	assert.Empty(t, statusMessage(op))
+
+	op.RequestIDs = []string{"a", "b", "c"}
+	assert.Equal(t, "deduplicated: 3 apply attempts satisfied by one upload (requests a, b, c)", statusMessage(op))
+
+	op.Error = "write failed"
+	assert.Equal(t, "write failed; deduplicated: 3 apply attempts satisfied by one upload (requests a, b, c)", statusMessage(op))
+}
+
+// TestStatusMessageQueued verifies a finished operation reports a noticeable
+// wait for a worker, so it is not mistaken for a slow server.
+func TestStatusMessageQueued(t *testing.T) {
+	op := &asyncsftp.Operation{State: asyncsftp.StateCompleted, Timings: asyncsftp.Timings{Queued: 200 * time.Millisecond}}
+	assert.Empty(t, statusMessage(op))
+
+	op.Timings.Queued = 2500 * time.Millisecond
+	assert.Equal(t, "waited 2.5s for a free worker before starting", statusMessage(op))
+
+	op.State = asyncsftp.StateInProgress
+	assert.Empty(t, statusMessage(op))
+}
+
+// tickingClock moves on by step every time it is read, so each phase an
+// operation times takes at least step.
+type tickingClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+// warningLogger collects the warnings logged through it with their fields.
+type warningLogger struct {
+	warnings *[]map[string]any
+}
+
+func (l warningLogger) Debug(string, ...any) {}
+func (l warningLogger) Info(string, ...any)  {}
+func (l warningLogger) Error(string, ...any) {}
+func (l warningLogger) Warn(msg string, args ...any) {
+	fields := map[string]any{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = args[i+1]
+	}
+	*l.warnings = append(*l.warnings, fields)
+}
+func (l warningLogger) With(...any) plugin.Logger { return l }
+
+// TestWarnIfSlow verifies a slow operation is logged with its per-phase
+// timings once, however often its status is polled, and a fast one not.
+func TestWarnIfSlow(t *testing.T) {
+	clock := &tickingClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), step: time.Second}
+	client, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name(),
+		LocalDir: "/upload", Clock: clock})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	id := client.StartUpload("/upload/slow.txt", "slow", 0644)
+	op, err := client.Wait(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, asyncsftp.StateCompleted, op.State, op.Error)
+
+	var warnings []map[string]any
+	log := warningLogger{&warnings}
+	fast := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","slowOperationThreshold":"1h"}`)
+	warnIfSlow(log, fast, client, op)
+	assert.Empty(t, warnings)
+
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","slowOperationThreshold":"4s"}`)
+	for range 3 {
+		polled, err := client.GetStatus(id)
+		require.NoError(t, err)
+		warnIfSlow(log, target, client, polled)
+	}
+	require.Len(t, warnings, 1)
+	warning := warnings[0]
+	assert.Equal(t, "slow SFTP operation", warning["msg"])
+	assert.Equal(t, "/upload/slow.txt", warning["path"])
+	assert.Equal(t, op.Duration(), warning["duration"])
+	assert.Equal(t, 4*time.Second, warning["threshold"])
+	for _, phase := range []string{"open", "write", "chmod", "stat"} {
+		assert.GreaterOrEqual(t, warning[phase], time.Second, phase)
+	}
+	assert.Equal(t, op.Timings.Write, warning["write"])
+}
+
+// TestSlowOperationThreshold verifies an unset threshold falls back to the
+// default and "0" turns the warning off, however slow the operation.
+func TestSlowOperationThreshold(t *testing.T) {
+	assert.Equal(t, defaultSlowOperationThreshold, (&TargetConfig{}).slowThreshold())
+	assert.Equal(t, 90*time.Second, (&TargetConfig{SlowOperationThreshold: "90s"}).slowThreshold())
+
+	clock := &tickingClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), step: time.Hour}
+	client, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name(),
+		LocalDir: "/upload", Clock: clock})
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+	op, err := client.Wait(context.Background(), client.StartUpload("/upload/slow.txt", "slow", 0644))
+	require.NoError(t, err)
+	require.Greater(t, op.Duration(), time.Hour)
+
+	var warnings []map[string]any
+	warnIfSlow(warningLogger{&warnings}, json.RawMessage(`{"url":"memory://`+t.Name()+`/upload","slowOperationThreshold":"0"}`), client, op)
+	assert.Empty(t, warnings)
+}
+
+// TestStatusAfterReconnect verifies Status still reports a finished create,
+// with its properties, after the plugin closed the client that ran it.
+func TestStatusAfterReconnect(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, TargetConfig: target,
+		Properties: json.RawMessage(`{"path":"/upload/a.txt","content":"hello"}`)})
+	require.NoError(t, err)
+	requestID := created.ProgressResult.RequestID
+	require.Eventually(t, func() bool {
+		op, err := client.GetStatus(requestID)
+		require.NoError(t, err)
+		return op.State != asyncsftp.StateInProgress
+	}, time.Second, time.Millisecond)
+	require.NoError(t, p.Close(plugin.LoggerFromContext(ctx)))
+
+	for range 2 {
+		status, err := p.Status(ctx, &resource.StatusRequest{RequestID: requestID, TargetConfig: target})
+		require.NoError(t, err)
+		require.Equal(t, resource.OperationStatusSuccess, status.ProgressResult.OperationStatus, status.ProgressResult.StatusMessage)
+		var props FileProperties
+		require.NoError(t, json.Unmarshal(status.ProgressResult.ResourceProperties, &props))
+		assert.Equal(t, "hello", props.Content)
+	}
+}
+
+// TestClientPerTarget verifies each target gets a client of its own, while
+// requests whose targets differ only in settings the connection does not
+// use share one.
+func TestClientPerTarget(t *testing.T) {
+	ctx := context.Background()
+	log := plugin.LoggerFromContext(ctx)
+	t.Cleanup(func() {
+		asyncsftp.ResetMemory(t.Name() + "A")
+		asyncsftp.ResetMemory(t.Name() + "B")
+	})
+	first := json.RawMessage(`{"url":"memory://` + t.Name() + `A/upload"}`)
+	second := json.RawMessage(`{"url":"memory://` + t.Name() + `B/upload"}`)
+	p := &Plugin{}
+	defer func() { _ = p.Close(log) }()
+
+	for target, content := range map[string]string{string(first): "first", string(second): "second"} {
+		created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, TargetConfig: json.RawMessage(target),
+			Properties: json.RawMessage(`{"path":"/upload/a.txt","content":"` + content + `"}`)})
+		require.NoError(t, err)
+		client, err := p.getClient(ctx, log, json.RawMessage(target))
+		require.NoError(t, err)
+		op, err := client.Wait(ctx, created.ProgressResult.RequestID)
+		require.NoError(t, err)
+		require.Equal(t, asyncsftp.StateCompleted, op.State, op.Error)
+	}
+
+	a, err := p.getClient(ctx, log, first)
+	require.NoError(t, err)
+	b, err := p.getClient(ctx, log, second)
+	require.NoError(t, err)
+	assert.NotSame(t, a, b)
+	for client, content := range map[*asyncsftp.Client]string{a: "first", b: "second"} {
+		info, err := client.ReadFile("/upload/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, content, info.Content)
+	}
+
+	renamed, err := p.getClient(ctx, log, json.RawMessage(`{"url":"memory://`+t.Name()+`A/upload","name":"edge","slowOperationThreshold":"1m"}`))
+	require.NoError(t, err)
+	assert.Same(t, a, renamed)
+	tuned, err := p.getClient(ctx, log, json.RawMessage(`{"url":"memory://`+t.Name()+`A/upload","maxQueuedOperations":5}`))
+	require.NoError(t, err)
+	assert.NotSame(t, a, tuned)
+	assert.Len(t, p.clients, 3)
+
+	require.NoError(t, p.Close(log))
+	assert.Empty(t, p.clients)
+}
+
+// TestClientDialOutsideLock verifies a target that is still booting only
+// holds up requests for itself: other targets connect and Status answers
+// meanwhile, without dialing, and cancelling the waiting request ends its
+// dial.
+func TestClientDialOutsideLock(t *testing.T) {
+	ctx := context.Background()
+	log := plugin.LoggerFromContext(ctx)
+	t.Cleanup(func() { asyncsftp.ResetMemory(t.Name()) })
+	t.Setenv("SFTP_USERNAME", "u")
+	t.Setenv("SFTP_PASSWORD", "p")
+	p := &Plugin{}
+	defer func() { _ = p.Close(log) }()
+
+	booting := json.RawMessage(`{"url":"sftp://127.0.0.1:1/upload","waitForTarget":"1m","waitForTargetInterval":"50ms"}`)
+	dialCtx, cancel := context.WithCancel(ctx)
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := p.getClient(dialCtx, log, booting)
+		dialed <- err
+	}()
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.clients) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	status, err := p.Status(ctx, &resource.StatusRequest{RequestID: "unknown", TargetConfig: booting})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, status.ProgressResult.OperationStatus)
+	assert.Contains(t, status.ProgressResult.StatusMessage, "operation not found")
+
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, TargetConfig: target,
+		Properties: json.RawMessage(`{"path":"/upload/a.txt","content":"a"}`)})
+	require.NoError(t, err)
+	require.NotEqual(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	status, err = p.Status(ctx, &resource.StatusRequest{RequestID: created.ProgressResult.RequestID, TargetConfig: target})
+	require.NoError(t, err)
+	assert.NotEqual(t, resource.OperationStatusFailure, status.ProgressResult.OperationStatus, status.ProgressResult.StatusMessage)
+
+	cancel()
+	select {
+	case err := <-dialed:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled request kept waiting for the target")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	assert.Len(t, p.clients, 1)
+}
+
+// TestStrictProperties verifies strict mode rejects unknown properties
+// before the handler runs, naming them, and leaves other errors to it.
+func TestStrictProperties(t *testing.T) {
+	ctx := context.Background()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	create := func(p *Plugin, props string) *resource.ProgressResult {
+		result, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(props), TargetConfig: target})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	result := create(&Plugin{}, `{"path":"/upload/a.txt","content":"a","permisions":"0600"}`)
+	assert.NotEqual(t, resource.OperationStatusFailure, result.OperationStatus, "unknown properties are ignored by default")
+
+	strict := &Plugin{settings: Settings{StrictProperties: true}}
+	result = create(strict, `{"path":"/upload/b.txt","content":"b","permisions":"0600"}`)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, result.ErrorCode)
+	assert.Contains(t, result.StatusMessage, `unknown property "permisions"`)
+
+	result = create(strict, `{"path":"/upload/c.txt","content":"c","permissions":"0600","sha256":"x"}`)
+	assert.NotEqual(t, resource.OperationStatusFailure, result.OperationStatus, result.StatusMessage)
+
+	result = create(strict, `{"path":"/upload/d.txt","content":"d","permissions":"nope"}`)
+	assert.Contains(t, result.StatusMessage, "invalid 'permissions'")
+}
+
+// TestTargetName verifies status messages name the target they are about,
+// once, and are left alone for targets without a name.
+func TestTargetName(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	create := func(target string) *resource.ProgressResult {
+		result, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(`{"path":"/etc/passwd"}`), TargetConfig: json.RawMessage(target)})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	named := create(`{"url":"memory://` + t.Name() + `/upload","root":"/upload","name":"prod-edge-eu"}`)
+	assert.Equal(t, resource.OperationStatusFailure, named.OperationStatus)
+	assert.True(t, strings.HasPrefix(named.StatusMessage, "[prod-edge-eu] "), named.StatusMessage)
+	assert.Equal(t, 1, strings.Count(named.StatusMessage, "prod-edge-eu"))
+
+	unnamed := create(`{"url":"memory://` + t.Name() + `/upload","root":"/upload"}`)
+	assert.Equal(t, strings.TrimPrefix(named.StatusMessage, "[prod-edge-eu] "), unnamed.StatusMessage)
+}
+
+// TestPausedResources verifies that updating a paused resource reports what
+// is on the server without changing it, that deleting one is refused, and
+// that pauses are matched by pattern.
+func TestPausedResources(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 64}}
+	target := func(paused string) json.RawMessage {
+		return json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","pausedResources":[` + paused + `]}`)
+	}
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: json.RawMessage(`{"path":"/upload/app.conf","content":"hand-fixed"}`), TargetConfig: target("")})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+
+	result, err := p.Update(ctx, &resource.UpdateRequest{
+		NativeID:          "/upload/app.conf",
+		ResourceType:      fileType,
+		PriorProperties:   json.RawMessage(`{"path":"/upload/app.conf","content":"declared"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/app.conf","content":"declared"}`),
+		TargetConfig:      target(`"/upload/*.conf"`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, result.ProgressResult.OperationStatus, result.ProgressResult.StatusMessage)
+	assert.Contains(t, result.ProgressResult.StatusMessage, `paused by "/upload/*.conf"`)
+	var props FileProperties
+	require.NoError(t, json.Unmarshal(result.ProgressResult.ResourceProperties, &props))
+	assert.Equal(t, "hand-fixed", props.Content)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: target(`"/upload/*.conf"`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusFailure, deleted.ProgressResult.OperationStatus)
+	assert.Equal(t, resource.OperationErrorCodeResourceConflict, deleted.ProgressResult.ErrorCode)
+	assert.Contains(t, deleted.ProgressResult.StatusMessage, `paused by "/upload/*.conf": delete refused`)
+	read, err := p.Read(ctx, &resource.ReadRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: target("")})
+	require.NoError(t, err)
+	assert.Empty(t, read.ErrorCode)
+
+	cfg := &TargetConfig{PausedResources: []string{"/upload/app.conf"}}
+	_, paused := pausedBy(cfg, "/upload/other.conf")
+	assert.False(t, paused)
+	_, err = parseTargetConfig(target(`"["`))
+	assert.ErrorIs(t, err, errInvalidTargetConfig)
+}
+
+// TestAnonymousTarget verifies anonymous targets connect without
+// credentials in the environment.
+func TestAnonymousTarget(t *testing.T) {
+	t.Setenv("SFTP_USERNAME", "")
+	t.Setenv("SFTP_PASSWORD", "")
+	p := &Plugin{}
+
+	_, err := p.clientConfig(&TargetConfig{URL: "sftp://public.example.com"}, "SFTP")
+	assert.ErrorIs(t, err, errMissingCredentials)
+
+	cfg, err := p.clientConfig(&TargetConfig{URL: "sftp://public.example.com", Anonymous: true}, "SFTP")
+	require.NoError(t, err)
+	assert.True(t, cfg.Anonymous)
+
+	_, err = parseTargetConfig(json.RawMessage(`{"url":"sftp://h","anonymous":true,"sshAgent":true}`))
+	assert.ErrorIs(t, err, errInvalidTargetConfig)
+}
+
+// TestTCPTuning verifies the target's TCP options reach the client, with
+// "0" turning keepalives off rather than leaving Go's default.
+func TestTCPTuning(t *testing.T) {
+	p := &Plugin{}
+	cfg, err := parseTargetConfig(json.RawMessage(`{"url":"memory:///upload","tcpKeepAlive":"0","tcpNoDelay":false,"tcpBufferSize":4194304}`))
+	require.NoError(t, err)
+	clientCfg, err := p.clientConfig(cfg, "SFTP")
+	require.NoError(t, err)
+	noDelay := false
+	assert.Equal(t, asyncsftp.TCPOptions{KeepAlive: -1, NoDelay: &noDelay, ReadBuffer: 4194304, WriteBuffer: 4194304}, clientCfg.TCP)
+
+	cfg, err = parseTargetConfig(json.RawMessage(`{"url":"memory:///upload","tcpKeepAlive":"1m"}`))
+	require.NoError(t, err)
+	assert.Equal(t, asyncsftp.TCPOptions{KeepAlive: time.Minute}, cfg.tcpOptions())
+}
+
+// TestDryRun verifies a dryRun target changes nothing and answers each
+// change with what it would have done and a running summary.
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+	target := json.RawMessage(`{"url":"file://` + dir + `","dryRun":true}`)
+	p := &Plugin{}
+
+	big := strings.Repeat("x", 3<<10)
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/big.txt","content":"` + big + `"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+	assert.Equal(t, "dry run: would upload 3.0 KiB to /big.txt; apply so far: 1 uploads totalling 3.0 KiB, 0 deletes; largest: /big.txt (3.0 KiB)", created.ProgressResult.StatusMessage)
+	assert.NoFileExists(t, filepath.Join(dir, "big.txt"))
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{
+		ResourceType:      fileType,
+		NativeID:          "/old.txt",
+		PriorProperties:   json.RawMessage(`{"path":"/old.txt","content":"old","permissions":"0644"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/old.txt","content":"old","permissions":"0600"}`),
+		TargetConfig:      target,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, updated.ProgressResult.StatusMessage, "would change /old.txt without uploading it; apply so far: 1 uploads")
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: fileType, NativeID: "/old.txt", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, deleted.ProgressResult.OperationStatus)
+	assert.Contains(t, deleted.ProgressResult.StatusMessage, "would delete /old.txt; apply so far: 1 uploads totalling 3.0 KiB, 1 deletes")
+	assert.FileExists(t, filepath.Join(dir, "old.txt"))
+
+	missing := filepath.Join(dir, "missing.part")
+	unassembled, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/parts.txt","contentParts":[{"inline":"header"},{"file":"` + missing + `"}]}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusFailure, unassembled.ProgressResult.OperationStatus)
+	assert.Contains(t, unassembled.ProgressResult.StatusMessage, "would upload /parts.txt, of unknown size: content part 1:")
+	assert.Contains(t, unassembled.ProgressResult.StatusMessage, "apply so far: 1 uploads totalling 3.0 KiB, 1 deletes; largest: /big.txt (3.0 KiB)")
+
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 MiB", formatBytes(3<<19))
+}
@@ -0,0 +1,125 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Bulk Import
+// =============================================================================
+
+// ListResult carries nothing but native IDs, so discovery reads every file
+// it lists one Read at a time. A List with import=true reads the page's
+// files there and then, several at once, and keeps each Read's property
+// document for the agent's Read of the file that follows, so onboarding a
+// large tree costs one pass over it.
+
+// importTTL is how long a prepared document waits for the agent's Read.
+const importTTL = 10 * time.Minute
+
+// importConcurrency is how many files an import reads at once.
+const importConcurrency = 8
+
+// importMaxBytes bounds the documents a plugin keeps at once; files beyond
+// it are left to the agent's own Reads.
+const importMaxBytes = 64 << 20
+
+// importState holds documents prepared by import Lists.
+type importState struct {
+	mu    sync.Mutex
+	docs  map[importKey]importDoc
+	bytes int
+}
+
+// importKey identifies a document by the target it was read from and the
+// file's native ID.
+type importKey struct {
+	target   string
+	nativeID string
+}
+
+type importDoc struct {
+	properties string
+	expires    time.Time
+}
+
+// prepareImport reads the files at paths and keeps their documents for
+// the agent's Reads.
+func (p *Plugin) prepareImport(ctx context.Context, targetConfig json.RawMessage, paths []string) {
+	log := plugin.LoggerFromContext(ctx)
+	h := &fileHandler{plugin: p}
+	sem := make(chan struct{}, importConcurrency)
+	var wg sync.WaitGroup
+	var prepared, skipped int
+	var mu sync.Mutex
+	for _, nativeID := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			read, err := h.Read(ctx, &resource.ReadRequest{NativeID: nativeID, ResourceType: fileType, TargetConfig: targetConfig})
+			ok := err == nil && read.ErrorCode == "" && p.imports.put(targetConfig, nativeID, read.Properties, time.Now())
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				prepared++
+			} else {
+				skipped++
+			}
+		}()
+	}
+	wg.Wait()
+	log.Info("import prepared", "documents", prepared, "leftToRead", skipped)
+}
+
+// put keeps a document unless the plugin already holds importMaxBytes of
+// them, and reports whether it did.
+func (s *importState) put(targetConfig json.RawMessage, nativeID, properties string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.docs == nil {
+		s.docs = make(map[importKey]importDoc)
+	}
+	for key, doc := range s.docs {
+		if now.After(doc.expires) {
+			s.bytes -= len(doc.properties)
+			delete(s.docs, key)
+		}
+	}
+	key := importKey{target: string(targetConfig), nativeID: nativeID}
+	if old, ok := s.docs[key]; ok {
+		s.bytes -= len(old.properties)
+	}
+	if s.bytes+len(properties) > importMaxBytes {
+		delete(s.docs, key)
+		return false
+	}
+	s.docs[key] = importDoc{properties: properties, expires: now.Add(importTTL)}
+	s.bytes += len(properties)
+	return true
+}
+
+// take returns and forgets the document prepared for nativeID, if one is
+// still fresh. Each document answers one Read; later Reads go to the server.
+func (s *importState) take(targetConfig json.RawMessage, nativeID string, now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := importKey{target: string(targetConfig), nativeID: nativeID}
+	doc, ok := s.docs[key]
+	if !ok {
+		return "", false
+	}
+	delete(s.docs, key)
+	s.bytes -= len(doc.properties)
+	return doc.properties, now.Before(doc.expires)
+}
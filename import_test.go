@@ -0,0 +1,72 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImport verifies an import List prepares each file's document, which
+// answers the agent's next Read of it once, and that changing the file
+// through formae discards it.
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "upload"), 0755))
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "upload", name), []byte(name), 0644))
+	}
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{}
+
+	listed, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target, AdditionalProperties: map[string]string{"import": "true"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/upload/a.txt", "/upload/b.txt", "/upload/c.txt"}, listed.NativeIDs)
+
+	// Served from the document, though the file is already gone
+	require.NoError(t, os.Remove(filepath.Join(dir, "upload", "a.txt")))
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/a.txt", TargetConfig: target})
+	require.NoError(t, err)
+	var props FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &props))
+	assert.Equal(t, "a.txt", props.Content)
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/a.txt", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeNotFound, read.ErrorCode, "documents answer one Read")
+
+	// A change through formae discards the document
+	_, err = p.Update(ctx, &resource.UpdateRequest{
+		ResourceType:      fileType,
+		NativeID:          "/upload/b.txt",
+		PriorProperties:   json.RawMessage(`{"path":"/upload/b.txt","content":"b.txt","permissions":"0644"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/b.txt","content":"changed","permissions":"0644"}`),
+		TargetConfig:      target,
+	})
+	require.NoError(t, err)
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/b.txt", TargetConfig: target})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &props))
+	assert.Equal(t, "changed", props.Content)
+
+	// Stale documents and ones beyond the budget are not kept
+	var s importState
+	now := time.Now()
+	assert.True(t, s.put(target, "/upload/x", "{}", now))
+	_, ok := s.take(target, "/upload/x", now.Add(importTTL+time.Second))
+	assert.False(t, ok)
+	assert.False(t, s.put(target, "/upload/big", string(make([]byte, importMaxBytes+1)), now))
+	assert.Zero(t, s.bytes)
+}
@@ -0,0 +1,87 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Incomplete Lists
+// =============================================================================
+
+// ListResult carries nothing but native IDs, and a List that fails hands the
+// agent no IDs at all. A recursive List that cannot read some subtrees
+// therefore returns the files it did list, and remembers what it skipped:
+// each later result on the target reports it, with the error code of each
+// subtree, until a List reads the directory in full.
+
+// listGaps holds the subtrees recursive Lists skipped.
+type listGaps struct {
+	mu   sync.Mutex
+	gaps map[listGapKey]string
+}
+
+// listGapKey identifies a listing by the target and directory listed.
+type listGapKey struct {
+	target string
+	dir    string
+}
+
+// describeGap names the subtrees a List of dir skipped and why, e.g.
+// "list of /upload skipped subtrees it could not read: /upload/secret
+// (AccessDenied)".
+func describeGap(dir string, skipped []*asyncsftp.ListError) string {
+	parts := make([]string, len(skipped))
+	for i, s := range skipped {
+		parts[i] = fmt.Sprintf("%s (%s)", s.Path, errorCode(s.Err))
+	}
+	return fmt.Sprintf("list of %s skipped subtrees it could not read: %s", dir, strings.Join(parts, ", "))
+}
+
+// record keeps what a List of dir on the target skipped, forgetting an
+// earlier gap when it skipped nothing.
+func (g *listGaps) record(targetConfig json.RawMessage, dir string, skipped []*asyncsftp.ListError) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := listGapKey{target: string(targetConfig), dir: dir}
+	if len(skipped) == 0 {
+		delete(g.gaps, key)
+		return
+	}
+	if g.gaps == nil {
+		g.gaps = make(map[listGapKey]string)
+	}
+	g.gaps[key] = describeGap(dir, skipped)
+}
+
+// annotate appends the target's outstanding gaps to result's status
+// message.
+func (g *listGaps) annotate(targetConfig json.RawMessage, result *resource.ProgressResult) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var dirs []string
+	for key := range g.gaps {
+		if key.target == string(targetConfig) {
+			dirs = append(dirs, key.dir)
+		}
+	}
+	slices.Sort(dirs)
+	for _, dir := range dirs {
+		warning := fmt.Sprintf("warning [list-incomplete]: %s", g.gaps[listGapKey{target: string(targetConfig), dir: dir}])
+		if result.StatusMessage == "" {
+			result.StatusMessage = warning
+		} else {
+			result.StatusMessage += "; " + warning
+		}
+	}
+}
@@ -4,8 +4,23 @@
 
 package main
 
-import "github.com/platform-engineering-labs/formae/pkg/plugin/sdk"
+import (
+	"log"
+	"log/slog"
+	_ "time/tzdata" // quietHoursTimezone must resolve on hosts without zoneinfo
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/sdk"
+)
 
 func main() {
-	sdk.RunWithManifest(&Plugin{}, sdk.RunConfig{})
+	settings, err := loadSettings()
+	if err != nil {
+		log.Fatalf("Failed to load plugin settings: %v", err)
+	}
+	p := &Plugin{settings: settings}
+	sdk.RunWithManifest(p, sdk.RunConfig{})
+
+	// RunWithManifest returns once the plugin is asked to shut down
+	_ = p.Close(plugin.NewPluginLogger(slog.Default()))
 }
@@ -0,0 +1,323 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// =============================================================================
+// Deployment Manifest
+// =============================================================================
+
+// manifestVersion is bumped on incompatible changes to the manifest layout.
+const manifestVersion = 1
+
+// Manifest is the machine-readable record of formae-managed files kept on
+// the server at the target's manifestPath, so server-side scripts and humans
+// can see what formae owns without querying the agent.
+type Manifest struct {
+	Version   int                      `json:"version"`
+	UpdatedAt string                   `json:"updatedAt"`
+	Files     map[string]ManifestEntry `json:"files"`
+
+	// Patterns and Retention hold the retention policies of PatternedFiles
+	// resources, by pattern, and of RetentionPolicy resources, by directory.
+	Patterns  map[string]PatternEntry `json:"patterns,omitempty"`
+	Retention map[string]PatternEntry `json:"retention,omitempty"`
+
+	// Downloads records where Download resources keep their local copies,
+	// by remote path.
+	Downloads map[string]DownloadEntry `json:"downloads,omitempty"`
+}
+
+// ManifestEntry describes one managed file.
+type ManifestEntry struct {
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	Label        string `json:"label,omitempty"`
+	ResourceType string `json:"resourceType,omitempty"`
+	UpdatedAt    string `json:"updatedAt"`
+
+	// ContentFormat and Declared record the content as declared for json
+	// and yaml files, so Read can report it while the server's copy is
+	// semantically equal.
+	ContentFormat string `json:"contentFormat,omitempty"`
+	Declared      string `json:"declared,omitempty"`
+
+	// WriteMode is "append" for files shared with other writers, where
+	// Declared is the fragment formae appended.
+	WriteMode string `json:"writeMode,omitempty"`
+
+	// Compression is the compression the file is stored with, which Read
+	// undoes before reporting the content.
+	Compression string `json:"compression,omitempty"`
+
+	// ExpandEnv marks files whose Declared content had environment
+	// variables expanded before upload.
+	ExpandEnv bool `json:"expandEnv,omitempty"`
+
+	// CopiedFrom is where a Copy resource's file came from.
+	CopiedFrom *CopySource `json:"copiedFrom,omitempty"`
+}
+
+// PatternEntry records a retention policy.
+type PatternEntry struct {
+	MaxAge    string `json:"maxAge,omitempty"`
+	MaxCount  int    `json:"maxCount,omitempty"`
+	Label     string `json:"label,omitempty"`
+	UpdatedAt string `json:"updatedAt"`
+
+	// Removed lists the files the last sync of a RetentionPolicy removed.
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DownloadEntry records the local copy of a downloaded file.
+type DownloadEntry struct {
+	LocalPath string `json:"localPath"`
+	SHA256    string `json:"sha256"`
+	Label     string `json:"label,omitempty"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// manifestMu serializes read-modify-write cycles on manifests from this
+// process. Writers in other processes are not coordinated.
+var manifestMu sync.Mutex
+
+// contentHash returns the hex-encoded SHA-256 of content.
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// readManifest loads the manifest, returning an empty one if it does not exist.
+func readManifest(client *asyncsftp.Client, path string) (*Manifest, error) {
+	info, err := client.ReadFile(path)
+	if errors.Is(err, asyncsftp.ErrNotFound) {
+		return &Manifest{Version: manifestVersion, Files: map[string]ManifestEntry{},
+			Patterns: map[string]PatternEntry{}, Retention: map[string]PatternEntry{}, Downloads: map[string]DownloadEntry{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal([]byte(info.Content), &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]ManifestEntry{}
+	}
+	if m.Patterns == nil {
+		m.Patterns = map[string]PatternEntry{}
+	}
+	if m.Retention == nil {
+		m.Retention = map[string]PatternEntry{}
+	}
+	if m.Downloads == nil {
+		m.Downloads = map[string]DownloadEntry{}
+	}
+	return &m, nil
+}
+
+// updateManifest applies fn to the manifest at path and writes it back.
+func updateManifest(client *asyncsftp.Client, path string, fn func(m *Manifest)) error {
+	return editManifest(client, path, func(m *Manifest) error {
+		fn(m)
+		return nil
+	})
+}
+
+// editManifest is updateManifest for edits that may refuse, in which case
+// the manifest is left as it was and fn's error returned.
+func editManifest(client *asyncsftp.Client, path string, fn func(m *Manifest) error) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := readManifest(client, path)
+	if err != nil {
+		return err
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	m.Version = manifestVersion
+	m.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return client.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// recordManaged adds or refreshes the manifest entry for a written file.
+// entry carries the resource identity and declared content; the hash, size
+// and timestamp are filled in from info. Writers that streamed the content
+// instead of holding it set entry.SHA256 themselves.
+func recordManaged(client *asyncsftp.Client, manifestPath string, info *asyncsftp.FileInfo, entry ManifestEntry) error {
+	if entry.SHA256 == "" {
+		entry.SHA256 = contentHash(info.Content)
+	}
+	entry.Size = info.Size
+	entry.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if entry.ContentFormat == "" || entry.ContentFormat == contentFormatText {
+		entry.ContentFormat = ""
+		if entry.WriteMode != writeModeAppend && !entry.ExpandEnv {
+			entry.Declared = ""
+		}
+	}
+	return updateManifest(client, manifestPath, func(m *Manifest) {
+		m.Files[info.Path] = entry
+	})
+}
+
+// readDeclared reports a json or yaml file's declared content, an
+// append-mode file's fragment, or an expandEnv file's template, as recorded
+// in the manifest, while the server's copy is semantically equal to,
+// contains or is expanded from it.
+func readDeclared(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, props *FileProperties, envAllowed []string) {
+	if cfg.ManifestPath == "" || props.FileType != string(asyncsftp.FileTypeRegular) {
+		return
+	}
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		log.Debug("could not read deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+		return
+	}
+	entry, ok := m.Files[props.Path]
+	switch {
+	case ok && entry.WriteMode == writeModeAppend:
+		reportAppended(props, entry.Declared)
+	case ok && entry.ExpandEnv:
+		reportExpanded(props, entry.Declared, envAllowed)
+	case ok && entry.ContentFormat != "":
+		reportDeclared(props, entry.ContentFormat, entry.Declared)
+	}
+}
+
+// reportDeclared replaces the read content with the declared content when
+// they are equal as format.
+func reportDeclared(props *FileProperties, format, declared string) {
+	if format == "" || format == contentFormatText {
+		return
+	}
+	props.ContentFormat = format
+	if contentEqual(format, declared, props.Content) {
+		props.Content = declared
+	}
+}
+
+// claimPath records label as the owner of path before a Create writes it,
+// so a second stack declaring the same path fails with errConflict naming
+// the owner instead of silently replacing its file. The claim, an entry
+// without a hash, becomes the file's entry once the upload completes. With
+// adopt the path is taken over from its owner, who is returned. Owners are
+// told apart by resource label; files the manifest records without one are
+// free to claim.
+func claimPath(client *asyncsftp.Client, manifestPath, path, label, resourceType string, adopt bool) (previous string, err error) {
+	err = editManifest(client, manifestPath, func(m *Manifest) error {
+		entry, ok := m.Files[path]
+		if ok && entry.Label != "" && entry.Label != label {
+			if !adopt {
+				return fmt.Errorf("%w: %s is already managed by %s; set adopt to take it over", errConflict, path, entry.owner())
+			}
+			previous = entry.owner()
+		}
+		if ok && entry.Label == label {
+			return nil
+		}
+		m.Files[path] = ManifestEntry{Label: label, ResourceType: resourceType, UpdatedAt: time.Now().UTC().Format(time.RFC3339)}
+		return nil
+	})
+	return previous, err
+}
+
+// releaseClaim removes label's claim on path if no upload ever completed
+// under it, so a failed Create does not lock other stacks out.
+func releaseClaim(client *asyncsftp.Client, manifestPath, path, label string) error {
+	return updateManifest(client, manifestPath, func(m *Manifest) {
+		if entry, ok := m.Files[path]; ok && entry.SHA256 == "" && entry.Label == label {
+			delete(m.Files, path)
+		}
+	})
+}
+
+// owner names the resource an entry belongs to, for conflict messages.
+func (e ManifestEntry) owner() string {
+	if e.ResourceType == "" {
+		return fmt.Sprintf("%q", e.Label)
+	}
+	return fmt.Sprintf("%q (%s)", e.Label, e.ResourceType)
+}
+
+// forgetManaged removes a deleted file from the manifest.
+func forgetManaged(client *asyncsftp.Client, manifestPath, path string) error {
+	return updateManifest(client, manifestPath, func(m *Manifest) {
+		delete(m.Files, path)
+	})
+}
+
+// withManifest runs fn against the target's manifest when one is configured.
+// Manifest failures are logged and never fail the resource operation.
+func withManifest(log plugin.Logger, targetConfig json.RawMessage, fn func(manifestPath string) error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || cfg.ManifestPath == "" {
+		return
+	}
+	if err := fn(cfg.ManifestPath); err != nil {
+		log.Warn("failed to update deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+	}
+}
+
+// Ownership modes accepted by List's "ownership" additional property.
+const (
+	ownershipAll       = "all"
+	ownershipManaged   = "managed"
+	ownershipUnmanaged = "unmanaged"
+)
+
+// filterOwnership returns a function reporting whether List keeps a path:
+// it drops the manifest itself and, for the managed/unmanaged modes, keeps
+// only the paths the manifest does or does not claim. The manifest is read
+// once, up front. Discovering only unmanaged files keeps import noise down.
+func filterOwnership(client *asyncsftp.Client, targetConfig json.RawMessage, mode string) (func(string) bool, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	notManifest := func(path string) bool {
+		return cfg.ManifestPath == "" || path != cfg.ManifestPath
+	}
+
+	switch mode {
+	case "", ownershipAll:
+		return notManifest, nil
+	case ownershipManaged, ownershipUnmanaged:
+	default:
+		return nil, fmt.Errorf("invalid ownership %q: must be all, managed or unmanaged", mode)
+	}
+	if cfg.ManifestPath == "" {
+		return nil, fmt.Errorf("ownership %q requires 'manifestPath' on the target", mode)
+	}
+
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return func(path string) bool {
+		_, managed := m.Files[path]
+		return notManifest(path) && managed == (mode == ownershipManaged)
+	}, nil
+}
@@ -0,0 +1,234 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManifestLifecycle verifies Create and Update record a file's hash,
+// size and owning resource in the deployment manifest, and Delete removes
+// its entry.
+func TestManifestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae-manifest.json"`)
+
+	manifest := func() *Manifest {
+		info, err := client.ReadFile("/upload/.formae-manifest.json")
+		require.NoError(t, err)
+		var m Manifest
+		require.NoError(t, json.Unmarshal([]byte(info.Content), &m))
+		assert.Equal(t, manifestVersion, m.Version)
+		assert.NotEmpty(t, m.UpdatedAt)
+		return &m
+	}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Label: "app-config", TargetConfig: target,
+		Properties: json.RawMessage(`{"path":"/upload/app.conf","content":"v1"}`)})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		status, err := p.Status(ctx, &resource.StatusRequest{RequestID: created.ProgressResult.RequestID, TargetConfig: target})
+		require.NoError(t, err)
+		require.NotEqual(t, resource.OperationStatusFailure, status.ProgressResult.OperationStatus, status.ProgressResult.StatusMessage)
+		return status.ProgressResult.OperationStatus == resource.OperationStatusSuccess
+	}, time.Second, time.Millisecond)
+
+	entry, ok := manifest().Files["/upload/app.conf"]
+	require.True(t, ok)
+	assert.Equal(t, contentHash("v1"), entry.SHA256)
+	assert.Equal(t, int64(2), entry.Size)
+	assert.Equal(t, "app-config", entry.Label)
+	assert.Equal(t, fileType, entry.ResourceType)
+	assert.NotEmpty(t, entry.UpdatedAt)
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{NativeID: "/upload/app.conf", ResourceType: fileType, Label: "app-config",
+		TargetConfig:      target,
+		PriorProperties:   json.RawMessage(`{"path":"/upload/app.conf","content":"v1"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/app.conf","content":"v2.0"}`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+
+	entry = manifest().Files["/upload/app.conf"]
+	assert.Equal(t, contentHash("v2.0"), entry.SHA256)
+	assert.Equal(t, int64(4), entry.Size)
+	assert.Equal(t, "app-config", entry.Label)
+	assert.Equal(t, fileType, entry.ResourceType)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus, deleted.ProgressResult.StatusMessage)
+	assert.NotContains(t, manifest().Files, "/upload/app.conf")
+}
+
+// TestManifestJournalStatus verifies the manifest follows a file from
+// Create through Delete when Status answers from the journal: a finished
+// create records its hash, and a failed one releases its claim, even after
+// the plugin closed the client that ran it.
+func TestManifestJournalStatus(t *testing.T) {
+	ctx := context.Background()
+	log := plugin.LoggerFromContext(ctx)
+	p := &Plugin{}
+	target, _ := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae-manifest.json"`)
+
+	// A client of its own, so reading the manifest never leaves the plugin
+	// one to answer Status with
+	reader, err := asyncsftp.NewClient(asyncsftp.Config{Protocol: asyncsftp.ProtocolMemory, Host: t.Name()})
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+	manifest := func() map[string]ManifestEntry {
+		info, err := reader.ReadFile("/upload/.formae-manifest.json")
+		require.NoError(t, err)
+		var m Manifest
+		require.NoError(t, json.Unmarshal([]byte(info.Content), &m))
+		return m.Files
+	}
+	// create starts an upload, waits for it to finish and closes the
+	// plugin's client, leaving its outcome to the journal
+	create := func(label, properties string) string {
+		created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Label: label, TargetConfig: target,
+			Properties: json.RawMessage(properties)})
+		require.NoError(t, err)
+		requestID := created.ProgressResult.RequestID
+		client := p.cachedClient(target)
+		require.NotNil(t, client)
+		require.Eventually(t, func() bool {
+			op, err := client.GetStatus(requestID)
+			require.NoError(t, err)
+			return op.State != asyncsftp.StateInProgress
+		}, time.Second, time.Millisecond)
+		require.NoError(t, p.Close(log))
+		return requestID
+	}
+	status := func(requestID string) *resource.ProgressResult {
+		require.Nil(t, p.cachedClient(target))
+		result, err := p.Status(ctx, &resource.StatusRequest{RequestID: requestID, TargetConfig: target})
+		require.NoError(t, err)
+		return result.ProgressResult
+	}
+
+	requestID := create("app-config", `{"path":"/upload/app.conf","content":"v1"}`)
+	entry := manifest()["/upload/app.conf"]
+	assert.Equal(t, "app-config", entry.Label)
+	assert.Empty(t, entry.SHA256, "claimed, not yet recorded")
+
+	progress := status(requestID)
+	require.Equal(t, resource.OperationStatusSuccess, progress.OperationStatus, progress.StatusMessage)
+	entry = manifest()["/upload/app.conf"]
+	assert.Equal(t, contentHash("v1"), entry.SHA256)
+	assert.Equal(t, int64(2), entry.Size)
+	assert.Equal(t, "app-config", entry.Label)
+	assert.Equal(t, fileType, entry.ResourceType)
+
+	// Without createParents the upload into a missing directory fails
+	require.NoError(t, p.Close(log))
+	requestID = create("orphan", `{"path":"/upload/missing/orphan.conf","content":"v1"}`)
+	assert.Contains(t, manifest(), "/upload/missing/orphan.conf")
+
+	progress = status(requestID)
+	require.Equal(t, resource.OperationStatusFailure, progress.OperationStatus)
+	assert.NotContains(t, manifest(), "/upload/missing/orphan.conf", "a failed create's claim is released")
+	assert.Contains(t, manifest(), "/upload/app.conf")
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus, deleted.ProgressResult.StatusMessage)
+	assert.Empty(t, manifest())
+}
+
+// TestListOwnership verifies List's ownership filter: unmanaged returns the
+// files the manifest does not record, managed only those it does, and the
+// manifest itself is never listed.
+func TestListOwnership(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae-manifest.json"`)
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{NativeID: "/upload/managed.conf", ResourceType: fileType, Label: "managed",
+		TargetConfig:      target,
+		PriorProperties:   json.RawMessage(`{"path":"/upload/managed.conf","content":""}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/managed.conf","content":"formae"}`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	require.NoError(t, client.WriteFile("/upload/manual.conf", []byte("by hand"), 0o644))
+	require.NoError(t, client.WriteFile("/upload/report.csv", []byte("id"), 0o644))
+
+	list := func(ownership string) ([]string, error) {
+		result, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+			AdditionalProperties: map[string]string{"ownership": ownership}})
+		if err != nil {
+			return nil, err
+		}
+		return result.NativeIDs, nil
+	}
+
+	unmanaged, err := list(ownershipUnmanaged)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/upload/manual.conf", "/upload/report.csv"}, unmanaged)
+	managed, err := list(ownershipManaged)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/managed.conf"}, managed)
+	all, err := list(ownershipAll)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/upload/managed.conf", "/upload/manual.conf", "/upload/report.csv"}, all)
+
+	// Pages are cut after filtering, so each is full and the last one ends
+	// the listing, recursive or not.
+	for _, recursive := range []string{"false", "true"} {
+		var paged []string
+		var token *string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 2, "two unmanaged files in pages of one")
+			result, err := p.List(ctx, &resource.ListRequest{ResourceType: fileType, TargetConfig: target,
+				PageSize: 1, PageToken: token,
+				AdditionalProperties: map[string]string{"ownership": ownershipUnmanaged, "recursive": recursive}})
+			require.NoError(t, err)
+			require.Len(t, result.NativeIDs, 1)
+			paged = append(paged, result.NativeIDs...)
+			if token = result.NextPageToken; token == nil {
+				break
+			}
+		}
+		assert.ElementsMatch(t, unmanaged, paged)
+	}
+
+	_, err = list("mine")
+	assert.ErrorContains(t, err, `invalid ownership "mine"`)
+	_, err = p.List(ctx, &resource.ListRequest{ResourceType: fileType,
+		TargetConfig:         json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`),
+		AdditionalProperties: map[string]string{"ownership": ownershipUnmanaged}})
+	assert.ErrorContains(t, err, "requires 'manifestPath'")
+}
+
+// TestFilterOwnership verifies that before any file is recorded every path
+// but the manifest counts as unmanaged, and that a manifest that cannot be
+// parsed fails the filter instead of treating every file as unmanaged.
+func TestFilterOwnership(t *testing.T) {
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae-manifest.json"`)
+
+	unmanaged, err := filterOwnership(client, target, ownershipUnmanaged)
+	require.NoError(t, err)
+	assert.True(t, unmanaged("/upload/app.conf"))
+	assert.False(t, unmanaged("/upload/.formae-manifest.json"))
+	managed, err := filterOwnership(client, target, ownershipManaged)
+	require.NoError(t, err)
+	assert.False(t, managed("/upload/app.conf"))
+
+	require.NoError(t, client.WriteFile("/upload/.formae-manifest.json", []byte("{"), 0o644))
+	_, err = filterOwnership(client, target, ownershipUnmanaged)
+	assert.ErrorContains(t, err, "invalid manifest /upload/.formae-manifest.json")
+}
@@ -0,0 +1,216 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Marker
+// =============================================================================
+
+// markerType is a flag file whose existence is the point, e.g. a READY file
+// a batch job on the server polls for. A missing marker is created empty;
+// an existing one keeps whatever content it has and only its permissions
+// are enforced.
+const markerType = "SFTP::Files::Marker"
+
+// markerHandler manages SFTP::Files::Marker resources.
+type markerHandler struct {
+	plugin *Plugin
+}
+
+// MarkerProperties are the properties of a Marker resource.
+type MarkerProperties struct {
+	// Path is the absolute path of the marker (the native ID).
+	Path string `json:"path"`
+	// Permissions is the octal mode to enforce. Defaults to "0644".
+	Permissions string `json:"permissions"`
+}
+
+// parseMarkerProperties extracts Marker properties from a JSON request.
+func parseMarkerProperties(data json.RawMessage) (*MarkerProperties, error) {
+	var props MarkerProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid marker properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, fmt.Errorf("marker properties missing 'path'")
+	}
+	if props.Permissions == "" {
+		props.Permissions = "0644"
+	}
+	var perm os.FileMode
+	if _, err := fmt.Sscanf(props.Permissions, "%o", &perm); err != nil || perm > os.ModePerm {
+		return nil, fmt.Errorf("invalid 'permissions' %q: must be an octal mode such as 0644", props.Permissions)
+	}
+	props.Permissions = fmt.Sprintf("%04o", perm)
+	return &props, nil
+}
+
+// touch creates the marker if it is missing and enforces its permissions,
+// returning the marker's resulting properties.
+func touch(client *asyncsftp.Client, props *MarkerProperties) (*MarkerProperties, error) {
+	var perm os.FileMode
+	_, _ = fmt.Sscanf(props.Permissions, "%o", &perm)
+
+	info, err := client.Stat(props.Path)
+	switch {
+	case errors.Is(err, asyncsftp.ErrNotFound):
+		if err := client.WriteFile(props.Path, nil, perm); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case info.Type != asyncsftp.FileTypeRegular:
+		return nil, fmt.Errorf("%w: %s is a %s, not a regular file", errInvalidPath, props.Path, info.Type)
+	default:
+		if err := client.SetPermissions(props.Path, perm); err != nil {
+			return nil, fmt.Errorf("failed to set permissions: %w", err)
+		}
+	}
+
+	info, err = client.Stat(props.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &MarkerProperties{Path: info.Path, Permissions: info.Permissions}, nil
+}
+
+// Create ensures the marker exists.
+func (h *markerHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+
+	props, err := parseMarkerProperties(req.Properties)
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err == nil {
+		props.Path, err = resolvePath(client, req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+
+	current, err := touch(client, props)
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+	log.Info("marker in place", "path", props.Path)
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationCreate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           props.Path,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Read reports whether the marker exists and its permissions.
+func (h *markerHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	info, err := client.Stat(req.NativeID)
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(MarkerProperties{Path: info.Path, Permissions: info.Permissions})
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update re-creates a vanished marker and re-applies its permissions.
+func (h *markerHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	props, err := parseMarkerProperties(req.DesiredProperties)
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	current, err := touch(client, props)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.UpdateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationUpdate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           req.NativeID,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Delete removes the marker, whatever it contains.
+func (h *markerHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	if err := client.Remove(req.NativeID); err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+	log.Info("marker removed", "path", req.NativeID)
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
@@ -0,0 +1,61 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarker verifies a marker is created empty, an existing file keeps its
+// content with only permissions enforced, and delete removes the marker.
+func TestMarker(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: markerType,
+		Properties:   json.RawMessage(`{"path":"/upload/READY","permissions":"600"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.JSONEq(t, `{"path":"/upload/READY","permissions":"0600"}`, string(created.ProgressResult.ResourceProperties))
+	info, err := client.ReadFile("/upload/READY")
+	require.NoError(t, err)
+	assert.Empty(t, info.Content)
+
+	require.NoError(t, client.WriteFile("/upload/DONE", []byte("batch 7\n"), 0600))
+	_, err = p.Create(ctx, &resource.CreateRequest{
+		ResourceType: markerType,
+		Properties:   json.RawMessage(`{"path":"/upload/DONE"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	info, err = client.ReadFile("/upload/DONE")
+	require.NoError(t, err)
+	assert.Equal(t, "batch 7\n", info.Content)
+	assert.Equal(t, "0644", info.Permissions)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{
+		ResourceType: markerType,
+		NativeID:     "/upload/READY",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	_, err = client.Stat("/upload/READY")
+	assert.ErrorIs(t, err, asyncsftp.ErrNotFound)
+}
@@ -0,0 +1,219 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// errInvalidPath marks a declared path that failed safety validation.
+var errInvalidPath = errors.New("invalid path")
+
+// validatePath rejects paths that are unsafe to hand to the server: control
+// characters, empty segments ("a//b", trailing "/"), and - when root is set -
+// anything that resolves outside root, such as "/upload/../etc/passwd".
+// Loosely chrooted servers would otherwise happily write wherever the stack
+// says.
+func validatePath(p, root string) error {
+	if p == "" {
+		return fmt.Errorf("%w: empty path", errInvalidPath)
+	}
+	if i := strings.IndexFunc(p, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("%w: %q contains control character %U", errInvalidPath, p, []rune(p[i:])[0])
+	}
+
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for _, seg := range segments {
+		if seg == "" {
+			return fmt.Errorf("%w: %q contains an empty segment", errInvalidPath, p)
+		}
+	}
+
+	if root == "" {
+		return nil
+	}
+	if !path.IsAbs(p) {
+		return fmt.Errorf("%w: %q must be absolute when the target sets a root", errInvalidPath, p)
+	}
+	cleanRoot := path.Clean(root)
+	cleaned := path.Clean(p)
+	if cleaned != cleanRoot && !strings.HasPrefix(cleaned, strings.TrimSuffix(cleanRoot, "/")+"/") {
+		return fmt.Errorf("%w: %q is outside the target root %q", errInvalidPath, p, cleanRoot)
+	}
+	return nil
+}
+
+// checkPath validates p against the root configured on the target.
+func checkPath(targetConfig json.RawMessage, p string) error {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return err
+	}
+	return validatePath(p, cfg.Root)
+}
+
+// canonicalPath returns the spelling the server resolves p to: p's parent
+// directory with symlinks and "." and ".." resolved, joined with p's own
+// name, which is left alone so a symlink is managed as itself.
+// The parent is resolved as written rather than cleaned first, since
+// "link/.." is the parent of link's target, not the directory holding link.
+func canonicalPath(client *asyncsftp.Client, p string) (string, error) {
+	parent, name := "/", p
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		parent, name = p[:i], p[i+1:]
+		if parent == "" {
+			parent = "/"
+		}
+	}
+	dir, err := realDir(client, parent)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, name), nil
+}
+
+// hasDotSegment reports whether p contains a "." or ".." segment.
+func hasDotSegment(p string) bool {
+	for seg := range strings.SplitSeq(p, "/") {
+		if seg == "." || seg == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// realDir resolves dir with the server's realpath. Servers that cannot
+// resolve paths, and directories that don't exist yet, fall back to the
+// lexically cleaned dir.
+func realDir(client *asyncsftp.Client, dir string) (string, error) {
+	real, err := client.RealPath(dir)
+	switch {
+	case err == nil:
+		return real, nil
+	case errors.Is(err, errors.ErrUnsupported), errors.Is(err, asyncsftp.ErrNotFound):
+		return path.Clean(dir), nil
+	default:
+		return "", err
+	}
+}
+
+// resolvePath returns the path to operate on for a declared path or
+// NativeID p. Paths with "." or ".." segments are canonicalized, so
+// /upload/../upload/./a.txt and /upload/a.txt are the same resource; with
+// stableIds every path is. The canonical path must still lie within the
+// target's root, so a symlinked parent cannot lead outside it.
+func resolvePath(client *asyncsftp.Client, targetConfig json.RawMessage, p string) (string, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || (!cfg.StableIDs && !hasDotSegment(p)) {
+		return p, err
+	}
+	canonical, err := canonicalPath(client, p)
+	if err != nil {
+		return "", err
+	}
+	if err := validatePath(canonical, cfg.Root); err != nil {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// withLocation reports where the file described by props is: its
+// canonical path and its URL on the target. A server that cannot resolve
+// the path, or no client to ask, leaves the canonical path as cleaned.
+func withLocation(log plugin.Logger, client *asyncsftp.Client, targetConfig json.RawMessage, props *FileProperties) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return
+	}
+	props.CanonicalPath = path.Clean(props.Path)
+	if !cfg.StableIDs && client != nil {
+		if canonical, err := canonicalPath(client, props.Path); err == nil {
+			props.CanonicalPath = canonical
+		} else {
+			log.Debug("could not resolve canonical path", "path", props.Path, "error", err)
+		}
+	}
+	props.URL = fileURL(cfg.URL, props.CanonicalPath)
+}
+
+// fileURL returns the URL of the file at p on the target at targetURL,
+// without credentials. Paths on file:// targets are relative to the
+// target's directory.
+func fileURL(targetURL, p string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return ""
+	}
+	if asyncsftp.Protocol(u.Scheme) == asyncsftp.ProtocolFile {
+		u.Path = path.Join("/", u.Path, p)
+	} else {
+		u.Path = p
+	}
+	u.User, u.RawQuery, u.Fragment, u.RawPath = nil, "", "", ""
+	return u.String()
+}
+
+// readPath returns the path reads of p go to: p itself, or with the
+// target's followSymlinks the file its symlinks finally point at, which
+// must lie within the target's root like any declared path.
+func readPath(client *asyncsftp.Client, cfg *TargetConfig, p string) (string, error) {
+	if !cfg.FollowSymlinks {
+		return p, nil
+	}
+	target, err := client.ResolveLink(p)
+	if err != nil {
+		return "", err
+	}
+	if err := validatePath(target, cfg.Root); err != nil {
+		return "", fmt.Errorf("%s links to %s: %w", p, target, err)
+	}
+	return target, nil
+}
+
+// stablePaths returns a function applying resolvePath to listed paths one
+// at a time, resolving each directory once. It reports false for paths that
+// resolve to a file already listed, or through a directory the account may
+// not resolve, so callers can drop them.
+func stablePaths(client *asyncsftp.Client, targetConfig json.RawMessage) (func(string) (string, bool, error), error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.StableIDs {
+		return func(p string) (string, bool, error) { return p, true, nil }, nil
+	}
+	dirs := map[string]string{}
+	seen := map[string]bool{}
+	return func(p string) (string, bool, error) {
+		dir, ok := dirs[path.Dir(p)]
+		if !ok {
+			var err error
+			dir, err = realDir(client, path.Dir(p))
+			if err != nil && !errors.Is(err, os.ErrPermission) {
+				return "", false, err
+			}
+			dirs[path.Dir(p)] = dir
+		}
+		if dir == "" {
+			return "", false, nil
+		}
+		p = path.Join(dir, path.Base(p))
+		if seen[p] || validatePath(p, cfg.Root) != nil {
+			return "", false, nil
+		}
+		seen[p] = true
+		return p, true, nil
+	}, nil
+}
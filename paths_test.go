@@ -0,0 +1,211 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidatePath verifies that unsafe declared paths are rejected before
+// they reach the server.
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		root  string
+		valid bool
+	}{
+		{"plain", "/upload/a.txt", "/upload", true},
+		{"unicode and spaces", "/upload/données ü.txt", "/upload", true},
+		{"dotdot inside root", "/upload/sub/../a.txt", "/upload", true},
+		{"no root configured", "/etc/motd", "", true},
+		{"escapes root", "/upload/../etc/passwd", "/upload", false},
+		{"sibling prefix", "/upload-other/a.txt", "/upload", false},
+		{"relative with root", "upload/a.txt", "/upload", false},
+		{"newline", "/upload/a\nb.txt", "", false},
+		{"nul", "/upload/a\x00b.txt", "", false},
+		{"empty segment", "/upload//a.txt", "", false},
+		{"trailing slash", "/upload/a/", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePath(tt.path, tt.root)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errInvalidPath)
+			}
+		})
+	}
+}
+
+// TestStableIDs verifies that with stableIds a file reached through a
+// symlinked directory gets the same NativeID as through its real one, and
+// that symlinks leading out of the root are refused.
+func TestStableIDs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "data", "upload"), 0755))
+	require.NoError(t, os.Symlink("upload", filepath.Join(dir, "data", "current")))
+	require.NoError(t, os.Symlink("/", filepath.Join(dir, "data", "escape")))
+	target := json.RawMessage(`{"url":"file://` + dir + `","root":"/data","stableIds":true}`)
+	p := &Plugin{}
+	ctx := context.Background()
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/data/current/a.txt","content":"a","permissions":"0644"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Equal(t, "/data/upload/a.txt", created.ProgressResult.NativeID)
+
+	client, err := p.getClient(ctx, plugin.LoggerFromContext(ctx), target)
+	require.NoError(t, err)
+	stable, err := stablePaths(client, target)
+	require.NoError(t, err)
+	var paths []string
+	for _, listed := range []string{"/data/current/a.txt", "/data/upload/a.txt", "/data/escape/etc/passwd"} {
+		path, ok, err := stable(listed)
+		require.NoError(t, err)
+		if ok {
+			paths = append(paths, path)
+		}
+	}
+	assert.Equal(t, []string{"/data/upload/a.txt"}, paths)
+
+	escaped, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/data/escape/tmp/b.txt","content":"b","permissions":"0644"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeAccessDenied, escaped.ProgressResult.ErrorCode)
+}
+
+// TestDotSegments verifies paths spelled with "." and ".." segments are the
+// same resource as their canonical spelling, resolving ".." after symlinks
+// as the server does.
+func TestDotSegments(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "upload", "releases", "7"), 0755))
+	require.NoError(t, os.Symlink("releases/7", filepath.Join(dir, "upload", "current")))
+	target := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	p := &Plugin{}
+	ctx := context.Background()
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: fileType,
+		Properties:   json.RawMessage(`{"path":"/upload/../upload/./a.txt","content":"a","permissions":"0644"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Equal(t, "/upload/a.txt", created.ProgressResult.NativeID)
+	// Let the upload finish before the temporary directory is removed
+	require.Eventually(t, func() bool {
+		status, err := p.Status(ctx, &resource.StatusRequest{RequestID: created.ProgressResult.RequestID, TargetConfig: target})
+		return err == nil && status.ProgressResult.OperationStatus != resource.OperationStatusInProgress
+	}, time.Second, time.Millisecond)
+
+	client, err := p.getClient(ctx, plugin.LoggerFromContext(ctx), target)
+	require.NoError(t, err)
+	resolved, err := resolvePath(client, target, "/upload/current/../b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/upload/releases/b.txt", resolved)
+
+	unchanged, err := resolvePath(client, target, "/upload/current/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "/upload/current/c.txt", unchanged, "paths without dot segments are left alone")
+}
+
+// TestFollowSymlinks verifies symlinks are read as links unless the target
+// sets followSymlinks, and that followed links cannot loop or leave root.
+func TestFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "upload", "releases"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "etc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "upload", "releases", "app.conf"), []byte("v7"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "etc", "shadow"), []byte("secret"), 0600))
+	require.NoError(t, os.Symlink("releases/app.conf", filepath.Join(dir, "upload", "app.conf")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(dir, "upload", "loop")))
+	require.NoError(t, os.Symlink("/etc/shadow", filepath.Join(dir, "upload", "shadow")))
+	require.NoError(t, os.Symlink("releases", filepath.Join(dir, "upload", "current")))
+	p := &Plugin{}
+	ctx := context.Background()
+
+	read := func(target json.RawMessage, nativeID string) *resource.ReadResult {
+		t.Helper()
+		result, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: nativeID, TargetConfig: target})
+		require.NoError(t, err)
+		return result
+	}
+
+	links := json.RawMessage(`{"url":"file://` + dir + `"}`)
+	var props FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read(links, "/upload/app.conf").Properties), &props))
+	assert.Equal(t, "symlink", props.FileType)
+	assert.Equal(t, "releases/app.conf", props.LinkTarget)
+	assert.Empty(t, props.Content)
+
+	follow := json.RawMessage(`{"url":"file://` + dir + `","root":"/upload","followSymlinks":true}`)
+	props = FileProperties{}
+	require.NoError(t, json.Unmarshal([]byte(read(follow, "/upload/app.conf").Properties), &props))
+	assert.Equal(t, "/upload/app.conf", props.Path)
+	assert.Equal(t, "regular", props.FileType)
+	assert.Equal(t, "v7", props.Content)
+
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, read(follow, "/upload/loop").ErrorCode)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, read(follow, "/upload/shadow").ErrorCode, "links out of root are not read")
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: directoryListingType,
+		Properties:   json.RawMessage(`{"path":"/upload"}`),
+		TargetConfig: json.RawMessage(`{"url":"file://` + dir + `","followSymlinks":true}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusFailure, created.ProgressResult.OperationStatus, "the looping link fails the listing")
+	require.NoError(t, os.Remove(filepath.Join(dir, "upload", "loop")))
+
+	for _, tc := range []struct {
+		target json.RawMessage
+		want   DirectoryEntry
+	}{
+		{links, DirectoryEntry{Name: "app.conf", Path: "/upload/app.conf", Size: int64(len("releases/app.conf")), Symlink: true}},
+		{json.RawMessage(`{"url":"file://` + dir + `","followSymlinks":true}`), DirectoryEntry{Name: "app.conf", Path: "/upload/app.conf", Size: 2}},
+	} {
+		created, err := p.Create(ctx, &resource.CreateRequest{
+			ResourceType: directoryListingType,
+			Properties:   json.RawMessage(`{"path":"/upload"}`),
+			TargetConfig: tc.target,
+		})
+		require.NoError(t, err)
+		require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+		var listing DirectoryListingProperties
+		require.NoError(t, json.Unmarshal(created.ProgressResult.ResourceProperties, &listing))
+		require.NotEmpty(t, listing.Files)
+		tc.want.ModifiedAt = listing.Files[0].ModifiedAt
+		assert.Equal(t, tc.want, listing.Files[0])
+		if tc.want.Symlink {
+			assert.Len(t, listing.Files, 3, "links are listed as themselves")
+		} else {
+			assert.Len(t, listing.Files, 2, "the link to a directory is left out")
+		}
+	}
+}
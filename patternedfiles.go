@@ -0,0 +1,422 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Patterned Files
+// =============================================================================
+
+// patternedFilesType is a retention policy for the files matching a glob,
+// e.g. the reports other systems drop into /incoming. formae never writes
+// the files; each create or update removes those the policy no longer
+// keeps, so retention is enforced on every sync that finds drift.
+const patternedFilesType = "SFTP::Files::PatternedFiles"
+
+// patternedFilesHandler manages SFTP::Files::PatternedFiles resources.
+type patternedFilesHandler struct {
+	plugin *Plugin
+}
+
+// PatternedFilesProperties are the properties of a PatternedFiles resource.
+type PatternedFilesProperties struct {
+	// Pattern is the absolute glob selecting the files (the native ID).
+	// Only the last segment may contain wildcards.
+	Pattern string `json:"pattern"`
+	// MaxAge is a Go duration; matching files modified longer ago are
+	// removed.
+	MaxAge string `json:"maxAge,omitempty"`
+	// MaxCount is how many matching files to keep, newest first.
+	MaxCount int `json:"maxCount,omitempty"`
+	// Files lists the matching files, newest first. Read-only.
+	Files []string `json:"files,omitempty"`
+}
+
+// parsePatternedFilesProperties extracts PatternedFiles properties from a
+// JSON request.
+func parsePatternedFilesProperties(data json.RawMessage) (*PatternedFilesProperties, error) {
+	var props PatternedFilesProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid patterned files properties: %w", err)
+	}
+	if props.Pattern == "" {
+		return nil, fmt.Errorf("patterned files properties missing 'pattern'")
+	}
+	if err := validatePattern(props.Pattern); err != nil {
+		return nil, err
+	}
+	if err := validatePolicy(props.MaxAge, props.MaxCount); err != nil {
+		return nil, err
+	}
+	props.Files = nil
+	return &props, nil
+}
+
+// validatePolicy checks the limits of a retention policy, which needs at
+// least one.
+func validatePolicy(maxAge string, maxCount int) error {
+	if maxAge != "" {
+		if d, err := time.ParseDuration(maxAge); err != nil || d <= 0 {
+			return fmt.Errorf("invalid 'maxAge' %q: must be a positive Go duration such as 720h", maxAge)
+		}
+	}
+	if maxCount < 0 {
+		return fmt.Errorf("invalid 'maxCount' %d: must not be negative", maxCount)
+	}
+	if maxAge == "" && maxCount == 0 {
+		return fmt.Errorf("a retention policy needs 'maxAge', 'maxCount' or both")
+	}
+	return nil
+}
+
+// validatePattern requires an absolute pattern whose wildcards are all in
+// its last segment, so matching needs a single directory listing.
+func validatePattern(pattern string) error {
+	if !path.IsAbs(pattern) {
+		return fmt.Errorf("%w: pattern %q must be absolute", errInvalidPath, pattern)
+	}
+	dir, glob := path.Split(pattern)
+	if strings.ContainsAny(dir, `*?[\`) {
+		return fmt.Errorf("%w: pattern %q may only use wildcards in its last segment", errInvalidPath, pattern)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("%w: pattern %q: %w", errInvalidPath, pattern, err)
+	}
+	return nil
+}
+
+// maxAge returns the parsed maximum age, or 0 when there is none.
+func (props *PatternedFilesProperties) maxAge() time.Duration {
+	d, _ := time.ParseDuration(props.MaxAge)
+	return d
+}
+
+// matchingFiles returns the regular files matching pattern, newest first,
+// leaving out the manifest, files other resources manage there, their
+// checksum sidecars, and staged writes, none of which a policy may remove:
+// the resources would only write them again. Files modified at the same
+// time are ordered by name, later names first, since dropped files are
+// usually named after their date.
+func matchingFiles(client *asyncsftp.Client, pattern, manifestPath string) ([]*asyncsftp.FileInfo, error) {
+	m, err := readManifest(client, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deployment manifest: %w", err)
+	}
+	managed := func(p string) bool {
+		if _, ok := m.Files[p]; ok {
+			return true
+		}
+		file, ok := asyncsftp.SidecarFor(p)
+		_, sidecar := m.Files[file]
+		return ok && sidecar
+	}
+
+	dir, glob := path.Split(pattern)
+	var files []*asyncsftp.FileInfo
+	err = client.WalkFiles(path.Clean(dir), func(info *asyncsftp.FileInfo) bool {
+		if ok, _ := path.Match(glob, path.Base(info.Path)); !ok || info.Type != asyncsftp.FileTypeRegular {
+			return true
+		}
+		if info.Path != manifestPath && !managed(info.Path) && !asyncsftp.IsTemporary(info.Path) {
+			files = append(files, info)
+		}
+		return true
+	})
+	if errors.Is(err, asyncsftp.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(files, func(a, b *asyncsftp.FileInfo) int {
+		if c := b.ModifiedAt.Compare(a.ModifiedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.Path, a.Path)
+	})
+	return files, nil
+}
+
+// expired returns the files, newest first, that the policy no longer keeps
+// at now: those beyond the newest maxCount and those older than maxAge.
+// Servers that report no modification time give no age to go by.
+func (props *PatternedFilesProperties) expired(files []*asyncsftp.FileInfo, now time.Time) []*asyncsftp.FileInfo {
+	maxAge := props.maxAge()
+	var expired []*asyncsftp.FileInfo
+	for i, info := range files {
+		tooMany := props.MaxCount > 0 && i >= props.MaxCount
+		tooOld := maxAge > 0 && !info.ModifiedAt.IsZero() && now.Sub(info.ModifiedAt) > maxAge
+		if tooMany || tooOld {
+			expired = append(expired, info)
+		}
+	}
+	return expired
+}
+
+// enforce removes the files the policy no longer keeps and returns the
+// removed paths and the resulting properties. Ages and which files are
+// newest go by modification times, so only maxCount policies, keeping the
+// last files by name, run on targets that do not trust them.
+func enforce(client *asyncsftp.Client, cfg *TargetConfig, props *PatternedFilesProperties) ([]string, *PatternedFilesProperties, error) {
+	if props.maxAge() > 0 && !cfg.trustsMtime() {
+		return nil, nil, fmt.Errorf("%w: 'maxAge' needs modification times, which the target's mtimeProfile %q ignores", errInvalidTargetConfig, cfg.MtimeProfile)
+	}
+	files, err := matchingFiles(client, props.Pattern, cfg.ManifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var removed []string
+	for _, info := range props.expired(files, time.Now()) {
+		if err := client.Remove(info.Path); err != nil {
+			return removed, nil, fmt.Errorf("failed to remove %s: %w", info.Path, err)
+		}
+		removed = append(removed, info.Path)
+	}
+
+	current := *props
+	for _, info := range files {
+		if !slices.Contains(removed, info.Path) {
+			current.Files = append(current.Files, info.Path)
+		}
+	}
+	return removed, &current, nil
+}
+
+// reportPolicy returns the properties Read reports for files under the
+// recorded policy: the declared limits while the files are within them, and
+// the limits the files actually reach otherwise, so the agent sees the drift
+// and the next update enforces the policy again.
+func reportPolicy(pattern string, entry PatternEntry, files []*asyncsftp.FileInfo, now time.Time) *PatternedFilesProperties {
+	props := &PatternedFilesProperties{Pattern: pattern, MaxAge: entry.MaxAge, MaxCount: entry.MaxCount}
+	for _, info := range files {
+		props.Files = append(props.Files, info.Path)
+	}
+	if props.MaxCount > 0 && len(files) > props.MaxCount {
+		props.MaxCount = len(files)
+	}
+	if maxAge := props.maxAge(); maxAge > 0 {
+		var oldest time.Duration
+		for _, info := range files {
+			if !info.ModifiedAt.IsZero() {
+				oldest = max(oldest, now.Sub(info.ModifiedAt))
+			}
+		}
+		if oldest > maxAge {
+			props.MaxAge = oldest.Round(time.Second).String()
+		}
+	}
+	return props
+}
+
+// checkPolicyTarget requires a deployment manifest, which is where
+// retention policies are kept: the files alone cannot tell Read what the
+// policy was.
+func checkPolicyTarget(targetConfig json.RawMessage, resourceType string) (*TargetConfig, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ManifestPath == "" {
+		return nil, fmt.Errorf("%s requires 'manifestPath' on the target", resourceType)
+	}
+	return cfg, nil
+}
+
+// recordPattern stores the policy of a pattern in the manifest.
+func recordPattern(client *asyncsftp.Client, manifestPath, label string, props *PatternedFilesProperties) error {
+	return updateManifest(client, manifestPath, func(m *Manifest) {
+		m.Patterns[props.Pattern] = PatternEntry{
+			MaxAge:    props.MaxAge,
+			MaxCount:  props.MaxCount,
+			Label:     label,
+			UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		}
+	})
+}
+
+// removedMessage names the files a sync removed, or is empty when it
+// removed none.
+func removedMessage(removed []string) string {
+	if len(removed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("removed %d file(s): %s", len(removed), strings.Join(removed, ", "))
+}
+
+// apply enforces the policy and records it, returning the resulting
+// properties and a message naming the removed files.
+func (h *patternedFilesHandler) apply(ctx context.Context, log plugin.Logger, targetConfig json.RawMessage, cfg *TargetConfig, label string, props *PatternedFilesProperties) (*PatternedFilesProperties, string, error) {
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	removed, current, err := enforce(client, cfg, props)
+	if len(removed) > 0 {
+		log.Info("removed files outside retention policy", "pattern", props.Pattern, "files", removed)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := recordPattern(client, cfg.ManifestPath, label, props); err != nil {
+		return nil, "", fmt.Errorf("failed to record policy in deployment manifest: %w", err)
+	}
+
+	return current, removedMessage(removed), nil
+}
+
+// Create records the policy and enforces it.
+func (h *patternedFilesHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+
+	props, err := parsePatternedFilesProperties(req.Properties)
+	var cfg *TargetConfig
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Pattern)
+	}
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, message, err := h.apply(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationCreate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			StatusMessage:      message,
+			NativeID:           props.Pattern,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Read reports the matching files and whether they are within the recorded
+// policy.
+func (h *patternedFilesHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	err := checkPath(req.TargetConfig, req.NativeID)
+	var cfg *TargetConfig
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		log.Error("read failed", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+	entry, ok := m.Patterns[req.NativeID]
+	if !ok {
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: resource.OperationErrorCodeNotFound}, nil
+	}
+
+	files, err := matchingFiles(client, req.NativeID, cfg.ManifestPath)
+	if err != nil {
+		log.Error("read failed", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(reportPolicy(req.NativeID, entry, files, time.Now()))
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update records the desired policy and enforces it.
+func (h *patternedFilesHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	props, err := parsePatternedFilesProperties(req.DesiredProperties)
+	var cfg *TargetConfig
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Pattern)
+	}
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, message, err := h.apply(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.UpdateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationUpdate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			StatusMessage:      message,
+			NativeID:           req.NativeID,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Delete forgets the policy. The matching files are left alone: formae
+// never owned them.
+func (h *patternedFilesHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err == nil {
+		err = updateManifest(client, cfg.ManifestPath, func(m *Manifest) {
+			delete(m.Patterns, req.NativeID)
+		})
+	}
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+	log.Info("retention policy removed", "pattern", req.NativeID)
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
@@ -0,0 +1,103 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPatternedFiles verifies the newest matching files are kept, Read
+// reports drift once more files arrive, and Delete leaves the files alone.
+func TestPatternedFiles(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+	for _, name := range []string{"report-1.csv", "report-2.csv", "report-3.csv", "notes.txt"} {
+		require.NoError(t, client.WriteFile("/upload/"+name, []byte(name), 0644))
+	}
+
+	props := json.RawMessage(`{"pattern":"/upload/report-*.csv","maxCount":2}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: patternedFilesType, Properties: props, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Contains(t, created.ProgressResult.StatusMessage, "/upload/report-1.csv")
+	assert.JSONEq(t, `{"pattern":"/upload/report-*.csv","maxCount":2,"files":["/upload/report-3.csv","/upload/report-2.csv"]}`,
+		string(created.ProgressResult.ResourceProperties))
+	files, err := client.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/upload/.formae.json", "/upload/notes.txt", "/upload/report-2.csv", "/upload/report-3.csv"}, files)
+
+	require.NoError(t, client.WriteFile("/upload/report-4.csv", []byte("4"), 0644))
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: patternedFilesType, NativeID: "/upload/report-*.csv", TargetConfig: target})
+	require.NoError(t, err)
+	var got PatternedFilesProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &got))
+	assert.Equal(t, 3, got.MaxCount)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: patternedFilesType, NativeID: "/upload/report-*.csv", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: patternedFilesType, NativeID: "/upload/report-*.csv", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeNotFound, read.ErrorCode)
+	files, err = client.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.Len(t, files, 5)
+
+	noManifest := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: patternedFilesType, Properties: props, TargetConfig: noManifest})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+	_, err = parsePatternedFilesProperties(json.RawMessage(`{"pattern":"/upload/*/report.csv","maxCount":1}`))
+	assert.Error(t, err)
+}
+
+// TestPatternedFilesMaxAge verifies files older than maxAge expire, files
+// without a modification time never do, and Read reports the oldest age.
+func TestPatternedFilesMaxAge(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []*asyncsftp.FileInfo{
+		{Path: "/in/new.csv", ModifiedAt: now.Add(-time.Hour)},
+		{Path: "/in/old.csv", ModifiedAt: now.Add(-49 * time.Hour)},
+		{Path: "/in/unknown.csv"},
+	}
+	props := &PatternedFilesProperties{Pattern: "/in/*.csv", MaxAge: "48h"}
+	expired := props.expired(files, now)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "/in/old.csv", expired[0].Path)
+
+	reported := reportPolicy(props.Pattern, PatternEntry{MaxAge: "48h"}, files, now)
+	assert.Equal(t, "49h0m0s", reported.MaxAge)
+	reported = reportPolicy(props.Pattern, PatternEntry{MaxAge: "48h"}, files[:1], now)
+	assert.Equal(t, "48h", reported.MaxAge)
+}
+
+// TestPatternedFilesUntrustedMtime verifies maxAge is refused on targets
+// whose modification times are untrusted, while maxCount still runs.
+func TestPatternedFilesUntrustedMtime(t *testing.T) {
+	ctx := context.Background()
+	target := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","manifestPath":"/upload/.formae.json","mtimeProfile":"untrusted"}`)
+	p := &Plugin{}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: patternedFilesType, Properties: json.RawMessage(`{"pattern":"/upload/*.csv","maxAge":"48h"}`), TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, created.ProgressResult.ErrorCode)
+	assert.Contains(t, created.ProgressResult.StatusMessage, "mtimeProfile")
+
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: patternedFilesType, Properties: json.RawMessage(`{"pattern":"/upload/*.csv","maxCount":2}`), TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+}
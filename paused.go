@@ -0,0 +1,68 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Paused Resources
+// =============================================================================
+
+// The plugin SDK does not forward resource annotations, so resources are
+// paused from the target instead: its pausedResources lists native IDs or
+// path patterns whose drift is reported but not corrected, and which are not
+// deleted, e.g. on a shared server during an incident freeze.
+
+// errPaused marks a delete refused because the resource is paused.
+var errPaused = errors.New("resource is paused")
+
+// pausedBy returns the entry of the target's pausedResources that matches
+// nativeID, if any. Entries are native IDs or path.Match patterns.
+func pausedBy(cfg *TargetConfig, nativeID string) (string, bool) {
+	for _, pattern := range cfg.PausedResources {
+		if ok, _ := path.Match(pattern, nativeID); ok || pattern == nativeID {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// pausedUpdate answers an update of a paused resource with its current
+// state, read from the server, instead of changing it. The agent then keeps
+// seeing the drift, and corrects it once the resource is no longer paused.
+func (p *Plugin) pausedUpdate(ctx context.Context, h resourceHandler, req *resource.UpdateRequest, pattern string) *resource.ProgressResult {
+	plugin.LoggerFromContext(ctx).Info("update skipped", "nativeID", req.NativeID, "pausedBy", pattern)
+	read, err := h.Read(ctx, &resource.ReadRequest{NativeID: req.NativeID, ResourceType: req.ResourceType, TargetConfig: req.TargetConfig})
+	if err != nil {
+		return failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)
+	}
+	if read.ErrorCode != "" {
+		return failureResult(resource.OperationUpdate, req.NativeID, read.ErrorCode, fmt.Errorf("paused by %q, and reading its current state failed", pattern))
+	}
+	return &resource.ProgressResult{
+		Operation:          resource.OperationUpdate,
+		OperationStatus:    resource.OperationStatusSuccess,
+		NativeID:           req.NativeID,
+		ResourceProperties: []byte(read.Properties),
+		StatusMessage:      fmt.Sprintf("paused by %q: drift not corrected", pattern),
+	}
+}
+
+// pausedDelete refuses a delete of a paused resource. Skipping it would tell
+// the agent the file is gone while it stays on the server, so the delete
+// fails until the resource is no longer paused.
+func pausedDelete(ctx context.Context, req *resource.DeleteRequest, pattern string) *resource.ProgressResult {
+	plugin.LoggerFromContext(ctx).Info("delete refused", "nativeID", req.NativeID, "pausedBy", pattern)
+	err := fmt.Errorf("%w by %q: delete refused; remove it from pausedResources to delete it", errPaused, pattern)
+	return failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)
+}
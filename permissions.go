@@ -0,0 +1,230 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// File Permissions
+// =============================================================================
+
+// filePermissionsType manages the permissions and ownership of a file that
+// something else creates and writes, e.g. a vendor-installed config file.
+// Content is never read or written, and deleting the resource leaves the
+// file in place.
+const filePermissionsType = "SFTP::Files::FilePermissions"
+
+// permissionsHandler manages SFTP::Files::FilePermissions resources.
+type permissionsHandler struct {
+	plugin *Plugin
+}
+
+// PermissionsProperties are the properties of a FilePermissions resource.
+type PermissionsProperties struct {
+	// Path is the absolute path of an existing file (the native ID).
+	Path string `json:"path"`
+	// Permissions is the octal mode to enforce (e.g., "0640").
+	Permissions string `json:"permissions"`
+	// UID and GID are the numeric owner and group to enforce. Unset leaves
+	// ownership alone.
+	UID *int `json:"uid,omitempty"`
+	GID *int `json:"gid,omitempty"`
+}
+
+// parsePermissionsProperties extracts FilePermissions properties from a JSON request.
+func parsePermissionsProperties(data json.RawMessage) (*PermissionsProperties, error) {
+	var props PermissionsProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid file permissions properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, fmt.Errorf("file permissions properties missing 'path'")
+	}
+	var perm os.FileMode
+	if _, err := fmt.Sscanf(props.Permissions, "%o", &perm); err != nil || perm > os.ModePerm {
+		return nil, fmt.Errorf("invalid 'permissions' %q: must be an octal mode such as 0644", props.Permissions)
+	}
+	props.Permissions = fmt.Sprintf("%04o", perm)
+	if (props.UID == nil) != (props.GID == nil) {
+		return nil, fmt.Errorf("'uid' and 'gid' must be set together")
+	}
+	return &props, nil
+}
+
+// permissionsFromInfo converts remote file metadata into FilePermissions
+// properties. Ownership is omitted on transports that do not report it.
+func permissionsFromInfo(info *asyncsftp.FileInfo) PermissionsProperties {
+	props := PermissionsProperties{
+		Path:        info.Path,
+		Permissions: info.Permissions,
+	}
+	if info.Owner != nil {
+		props.UID, props.GID = &info.Owner.UID, &info.Owner.GID
+	}
+	return props
+}
+
+// applyPermissions enforces props on the existing file at props.Path and
+// returns the file's resulting properties.
+func applyPermissions(client *asyncsftp.Client, props *PermissionsProperties) (*PermissionsProperties, error) {
+	if _, err := client.Stat(props.Path); err != nil {
+		return nil, err
+	}
+
+	var perm os.FileMode
+	_, _ = fmt.Sscanf(props.Permissions, "%o", &perm)
+	if err := client.SetPermissions(props.Path, perm); err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if props.UID != nil {
+		if err := client.SetOwner(props.Path, *props.UID, *props.GID); err != nil {
+			if errors.Is(err, errors.ErrUnsupported) {
+				err = fmt.Errorf("%w: this target cannot change file ownership; leave 'uid' and 'gid' unset", errInvalidTargetConfig)
+			}
+			return nil, fmt.Errorf("failed to set owner: %w", err)
+		}
+	}
+
+	info, err := client.Stat(props.Path)
+	if err != nil {
+		return nil, err
+	}
+	current := permissionsFromInfo(info)
+	return &current, nil
+}
+
+// Create starts managing an existing file's permissions. The file must
+// already exist: this resource never creates content.
+func (h *permissionsHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+
+	props, err := parsePermissionsProperties(req.Properties)
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err == nil {
+		props.Path, err = resolvePath(client, req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+
+	current, err := applyPermissions(client, props)
+	if err != nil {
+		if errors.Is(err, asyncsftp.ErrNotFound) {
+			err = fmt.Errorf("%s does not exist: %s only manages files created elsewhere", props.Path, filePermissionsType)
+			return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+		}
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+	log.Info("managing permissions of existing file", "path", props.Path, "permissions", current.Permissions)
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationCreate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           props.Path,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Read reports the file's current permissions and ownership.
+func (h *permissionsHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	info, err := client.Stat(req.NativeID)
+	if err != nil {
+		if !errors.Is(err, asyncsftp.ErrNotFound) {
+			log.Error("read failed", "error", err)
+		}
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(permissionsFromInfo(info))
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update re-applies the desired permissions and ownership.
+func (h *permissionsHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	props, err := parsePermissionsProperties(req.DesiredProperties)
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	current, err := applyPermissions(client, props)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.UpdateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationUpdate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			NativeID:           req.NativeID,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Delete stops managing the file. The file, its permissions and its
+// ownership are left as they are.
+func (h *permissionsHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	if err := checkPath(req.TargetConfig, req.NativeID); err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	log.Info("releasing file permissions; file left in place", "path", req.NativeID)
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
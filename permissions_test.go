@@ -0,0 +1,66 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFilePermissions verifies the permissions-only resource enforces mode
+// and ownership on an existing file without touching its content, and
+// leaves the file behind on delete.
+func TestFilePermissions(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", "")
+	require.NoError(t, client.WriteFile("/upload/vendor.conf", []byte("vendor=1\n"), 0666))
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: filePermissionsType,
+		Properties:   json.RawMessage(`{"path":"/upload/vendor.conf","permissions":"640","uid":1000,"gid":100}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.JSONEq(t, `{"path":"/upload/vendor.conf","permissions":"0640","uid":1000,"gid":100}`,
+		string(created.ProgressResult.ResourceProperties))
+
+	read, err := p.Read(ctx, &resource.ReadRequest{
+		ResourceType: filePermissionsType,
+		NativeID:     "/upload/vendor.conf",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(created.ProgressResult.ResourceProperties), read.Properties)
+
+	missing, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: filePermissionsType,
+		Properties:   json.RawMessage(`{"path":"/upload/missing.conf","permissions":"0640"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeInvalidRequest, missing.ProgressResult.ErrorCode)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{
+		ResourceType: filePermissionsType,
+		NativeID:     "/upload/vendor.conf",
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+
+	info, err := client.ReadFile("/upload/vendor.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "vendor=1\n", info.Content)
+}
@@ -0,0 +1,83 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+)
+
+// batchMaxSize is the largest upload, in bytes, written in a batch. Larger
+// uploads spend their time on the wire rather than waiting for round trips.
+const batchMaxSize = 64 << 10
+
+// batchedUpload is a queued upload that may be written in a batch.
+type batchedUpload struct {
+	op      *Operation
+	content string
+	opts    UploadOptions
+}
+
+// batchable reports whether an upload of content may be written in a batch:
+// small, and written in place without checks that run their own commands.
+func (c *Client) batchable(content string, opts UploadOptions) bool {
+	return c.batchSize > 1 && len(content) <= batchMaxSize && !opts.Append &&
+		opts.ValidateCommand == "" && !opts.Verify && opts.CacheDir == "" && opts.Sidecar == nil
+}
+
+// uploadBatch writes first together with up to Config.UploadBatchSize-1
+// other batchable uploads still queued. Their uploads run at once on the
+// one session, so the server receives each phase's requests (create, write,
+// close, chmod) for every file back to back instead of waiting out a round
+// trip per request and file, while the batch holds a single worker.
+func (c *Client) uploadBatch(first *batchedUpload) {
+	batch := append([]*batchedUpload{first}, c.queue.takeUploads(c.batchSize-1, first.op.Path)...)
+	var wg sync.WaitGroup
+	for _, u := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.dequeued(u.op)
+			c.doUpload(u.op, u.content, u.opts)
+		}()
+	}
+	wg.Wait()
+}
+
+// takeUploads removes up to n queued batchable uploads from the queue, in
+// queue order, skipping paths already in the batch so that uploads of one
+// file still run one after another.
+func (q *workQueue) takeUploads(n int, first string) []*batchedUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	candidates := slices.Clone(q.tasks)
+	slices.SortFunc(candidates, func(a, b *task) int {
+		if q.tasks.lessTask(a, b) {
+			return -1
+		}
+		return 1
+	})
+	paths := map[string]bool{first: true}
+	taken := map[*task]bool{}
+	var batch []*batchedUpload
+	for _, t := range candidates {
+		if len(batch) == n {
+			break
+		}
+		if t.upload == nil || paths[t.op.Path] {
+			continue
+		}
+		paths[t.op.Path] = true
+		taken[t] = true
+		batch = append(batch, t.upload)
+	}
+	if len(batch) > 0 {
+		q.tasks = slices.DeleteFunc(q.tasks, func(t *task) bool { return taken[t] })
+		heap.Init(&q.tasks)
+	}
+	return batch
+}
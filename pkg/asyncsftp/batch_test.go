@@ -0,0 +1,71 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTakeUploads verifies a batch takes queued batchable uploads by
+// priority, one per path, and leaves everything else queued.
+func TestTakeUploads(t *testing.T) {
+	q := newWorkQueue(0)
+	upload := func(path string, priority Priority) {
+		u := &batchedUpload{op: &Operation{Path: path, Priority: priority}}
+		q.submitUpload(u, func() {})
+	}
+	upload("/upload/a", PriorityNormal)
+	q.submit(&Operation{Path: "/upload/big", Priority: PriorityHigh}, func() {})
+	upload("/upload/b", PriorityNormal)
+	upload("/upload/a", PriorityNormal)
+	upload("/upload/c", PriorityHigh)
+	upload("/upload/first", PriorityHigh)
+
+	var paths []string
+	for _, u := range q.takeUploads(3, "/upload/first") {
+		paths = append(paths, u.op.Path)
+	}
+	assert.Equal(t, []string{"/upload/c", "/upload/a", "/upload/b"}, paths)
+	assert.Equal(t, 3, q.queued(), "the large upload, the second write of a and first itself stay queued")
+}
+
+// TestUploadBatch verifies small uploads queued behind a busy worker are
+// all written once it frees up, batched or not.
+func TestUploadBatch(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1, UploadBatchSize: 4})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c.queue.submit(&Operation{Priority: PriorityHigh}, func() { close(started); <-release })
+	<-started
+
+	ids := map[string]string{}
+	for i := range 6 {
+		path := fmt.Sprintf("/upload/%d.txt", i)
+		ids[path] = c.StartUploadWithOptions(path, path, UploadOptions{Permissions: 0644})
+	}
+	ids["/upload/verified.txt"] = c.StartUploadWithOptions("/upload/verified.txt", "v", UploadOptions{Permissions: 0644, Verify: true})
+	close(release)
+
+	for path, id := range ids {
+		op, err := c.Wait(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, StateCompleted, op.State, op.Error)
+		info, err := c.ReadFile(path)
+		require.NoError(t, err)
+		if path != "/upload/verified.txt" {
+			assert.Equal(t, path, info.Content)
+		}
+	}
+}
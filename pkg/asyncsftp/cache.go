@@ -0,0 +1,74 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// cacheMinSize is the smallest upload served from or added to the artifact
+// cache. Config-sized files are cheaper to send than to look up and copy,
+// and would only fill the cache.
+const cacheMinSize = smallUploadSize
+
+// fromCache copies the cache entry for content to target on the server and
+// reports whether it could. Entries that are missing or of the wrong size
+// are not used, and one whose copy does not hash to the content is removed
+// so the upload replaces it. Target then holds whatever was copied and is
+// overwritten by the upload.
+func (c *Client) fromCache(dir, target, content string) bool {
+	sum := contentSum(content)
+	entry := path.Join(dir, sum)
+	info, err := c.fs.Lstat(entry)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != int64(len(content)) {
+		return false
+	}
+	got, err := c.copyRemote(entry, target)
+	if err != nil {
+		return false
+	}
+	if got != sum {
+		_ = c.fs.Remove(entry)
+		return false
+	}
+	return true
+}
+
+// addToCache copies the uploaded file at p into the cache under a staged
+// name first, so a concurrent lookup never finds a partial entry. Failures
+// only cost the next deployment an upload.
+func (c *Client) addToCache(dir, p, content string) {
+	sum := contentSum(content)
+	entry := path.Join(dir, sum)
+	if _, err := c.fs.Lstat(entry); err == nil {
+		return
+	}
+	staged := fmt.Sprintf("%s.tmp.%s", entry, uuid.New().String())
+	if got, err := c.copyRemote(p, staged); err != nil || got != sum || c.fs.Rename(staged, entry) != nil {
+		_ = c.fs.Remove(staged)
+	}
+}
+
+// copyRemote copies src to dst without the content crossing the network and
+// returns the copy's hex-encoded sha256.
+func (c *Client) copyRemote(src, dst string) (string, error) {
+	if cp, ok := c.fs.(copier); ok {
+		if err := cp.Copy(src, dst); err != nil {
+			return "", err
+		}
+		return c.hashFile(dst, sha256.New())
+	}
+	out, err := c.Exec(fmt.Sprintf("cp -- %s %s && sha256sum -- %s", shellQuote(src), shellQuote(dst), shellQuote(dst)))
+	if err != nil {
+		return "", fmt.Errorf("cp failed: %w", err)
+	}
+	sum, _, _ := strings.Cut(out, " ")
+	return sum, nil
+}
@@ -2,29 +2,60 @@
 //
 // SPDX-License-Identifier: FSL-1.1-ALv2
 
-// Package asyncsftp wraps the pkg/sftp library with an async interface.
-// Operations return immediately with an operation ID that can be polled for completion.
 package asyncsftp
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"net"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pkg/sftp"
+	"github.com/kr/fs"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
-// Client wraps an SFTP connection with async operation support.
+// Client wraps an SFTP (or SCP/FTPS) connection with async operation support.
 type Client struct {
-	sftpClient *sftp.Client
-	sshClient  *ssh.Client
+	fs             Transport
+	sshClient      *ssh.Client   // nil unless connected over SSH
+	lost           chan struct{} // closed when the SSH connection ends
+	endpoint       string
+	connectTimings Timings
+	warnings       []Warning // found while connecting, see Warnings
+	limits         Limits    // sizes the session keeps to, see Limits
+	mtimePrecision time.Duration
+	ignoreMtime    bool
+	session        Session // who and where the client is logged in, see Session
+	probe          Probe
+	queue          *workQueue
+	maxQueued      int
+	batchSize      int // see Config.UploadBatchSize
+	stats          sessionCounters
+	clock          Clock
+	journal        *Journal   // nil unless Config.Journal is set
+	intents        *intentLog // nil unless Config.IntentDir is set
+	wire           fairShare  // turns between concurrent uploads' writes
+
+	operations *operationTable
+	statCache  *statCache // nil unless Config.StatCacheTTL is set
 
 	mu         sync.RWMutex
-	operations map[string]*Operation
+	uploads    map[string]*Operation // in-progress uploads by uploadKey
+	slowWarned map[string]bool       // operations whose slowness was logged
 }
 
 // Config holds connection settings.
@@ -33,44 +64,580 @@ type Config struct {
 	Port     string
 	Username string
 	Password string
+
+	// PrivateKey, when set, is a PEM-encoded private key tried first when
+	// authenticating over SSH. PrivateKeyPassphrase decrypts it if it is
+	// encrypted.
+	PrivateKey           []byte
+	PrivateKeyPassphrase string
+
+	// AgentSocket, when set, authenticates with the keys held by the
+	// ssh-agent listening on this Unix socket, before trying Password.
+	AgentSocket string
+
+	// Anonymous logs in to servers that require no credentials: as
+	// Username, or AnonymousUser when it is empty, with Password even when
+	// it is empty, which public SFTP endpoints that accept any password or
+	// none then take. FTPS sends the conventional "anonymous@" instead of
+	// an empty password.
+	Anonymous bool
+
+	// Protocol selects the transport. Defaults to ProtocolSFTP, which falls
+	// back to SCP when the server has the SFTP subsystem disabled.
+	Protocol Protocol
+
+	// InsecureSkipVerify disables TLS certificate verification for FTPS.
+	InsecureSkipVerify bool
+
+	// LocalDir is the directory ProtocolFile serves paths from. For
+	// ProtocolMemory, Host names the store and LocalDir is created in it.
+	LocalDir string
+
+	// RotateEndpoints re-resolves Host on every dial and rotates the starting
+	// address across all resolved records, falling over to the next address
+	// when a dial fails. Use this for SFTP farms behind round-robin DNS.
+	RotateEndpoints bool
+
+	// Resolver looks Host up for RotateEndpoints. Defaults to
+	// net.DefaultResolver; tests substitute a fake.
+	Resolver Resolver
+
+	// Probe selects how existence and metadata are checked. Defaults to
+	// ProbeStat.
+	Probe Probe
+
+	// Clock supplies the time for operation timestamps, timings and expiry.
+	// Defaults to the system clock; tests substitute a fake.
+	Clock Clock
+
+	// OperationTTL is how long a finished operation stays available to
+	// GetStatus. Defaults to DefaultOperationTTL.
+	OperationTTL time.Duration
+
+	// Journal, when set, records every finished operation so GetStatus on
+	// a later client sharing it can still answer for this one's.
+	Journal *Journal
+
+	// IntentDir, when set, is a directory on this host where each upload
+	// is recorded until it finishes. GetStatus on a client created after
+	// the process crashed then answers for uploads it never saw finish,
+	// by checking whether the file on the server holds their content.
+	IntentDir string
+
+	// Workers is the number of operations run concurrently. Further
+	// operations wait in a priority queue. Defaults to DefaultWorkers.
+	Workers int
+
+	// MaxQueued is the number of waiting operations beyond which Admit
+	// reports ErrQueueFull. Zero means unlimited.
+	MaxQueued int
+
+	// UploadBatchSize, when above one, lets a worker write up to this many
+	// queued small uploads together, pipelining their requests on the
+	// session, which cuts the time many small files take on high-latency
+	// links. Uploads that are verified, validated, cached or appended are
+	// never batched.
+	UploadBatchSize int
+
+	// DialTimeout bounds the TCP connect and the SSH handshake, or the FTPS
+	// TLS handshake and login. Defaults to DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// TCP tunes the TCP connections dialed to the server, including FTPS
+	// data connections.
+	TCP TCPOptions
+
+	// StatCacheTTL, when positive, is how long Stat results (including
+	// missing files) and directory listings are reused for Stat. The
+	// client forgets a path whenever it changes it; changes made by others
+	// show up once the entry expires.
+	StatCacheTTL time.Duration
+
+	// DialAttempts is how many times Connect dials an unreachable server.
+	// DialBackoff is the wait before the second attempt, doubling before
+	// each further one, and OnDialRetry, when set, is called before each
+	// wait. NewClient dials once.
+	DialAttempts int
+	DialBackoff  time.Duration
+	OnDialRetry  func(attempt int, backoff time.Duration, err error)
+
+	// WaitFor, when positive, has Connect keep dialing an unreachable
+	// server every WaitInterval (default DefaultWaitInterval) until WaitFor
+	// has passed instead of counting DialAttempts, for servers still being
+	// provisioned that refuse connections until they have booted.
+	WaitFor      time.Duration
+	WaitInterval time.Duration
+
+	// MaxPacket overrides the SFTP packet payload size, for servers that
+	// accept less than DefaultMaxPacket or more than they advertise.
+	// MaxFileSize, when positive, refuses uploads larger than the server
+	// accepts with ErrTooLarge, for appliances that cap file sizes.
+	MaxPacket   int
+	MaxFileSize int64
+
+	// SFTPSubsystem opens SFTP on a subsystem other than "sftp", for
+	// servers that expose it under another name. SFTPServerCommand instead
+	// runs the SFTP server over exec (e.g. "/usr/libexec/sftp-server"),
+	// for servers that define no subsystem. At most one may be set.
+	SFTPSubsystem     string
+	SFTPServerCommand string
+
+	// MtimePrecision truncates reported modification times, e.g. to
+	// time.Second for servers that store whole seconds. IgnoreMtime
+	// reports none at all, for servers whose times cannot be trusted.
+	// Times at or before the Unix epoch are always reported as none.
+	MtimePrecision time.Duration
+	IgnoreMtime    bool
+
+	// SFTPVersion, when SFTPVersion3, restricts SFTP sessions to the base
+	// version 3 protocol: extensions the server advertises, such as
+	// posix-rename and statvfs, are not used. Zero uses every supported
+	// extension the server offers.
+	SFTPVersion int
+}
+
+// DefaultDialTimeout applies when Config.DialTimeout is unset.
+const DefaultDialTimeout = 10 * time.Second
+
+// DefaultWaitInterval applies when Config.WaitFor is set without a
+// WaitInterval.
+const DefaultWaitInterval = 5 * time.Second
+
+// Resolver looks up the addresses of a host. *net.Resolver is one.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
 }
 
-// NewClient creates a new async SFTP client.
+// endpointCounter rotates the first address tried across dials so successive
+// connections spread over every resolved endpoint.
+var endpointCounter atomic.Uint64
+
+// NewClient creates a new async client connected over cfg.Protocol.
 func NewClient(cfg Config) (*Client, error) {
-	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+	switch cfg.Probe {
+	case "", ProbeStat, ProbeOpen, ProbeExec:
+	default:
+		return nil, fmt.Errorf("%w: unsupported probe %q", ErrInvalidConfig, cfg.Probe)
+	}
+	if cfg.SFTPVersion != 0 && cfg.SFTPVersion != SFTPVersion3 {
+		return nil, fmt.Errorf("%w: unsupported SFTP version %d", ErrInvalidConfig, cfg.SFTPVersion)
+	}
+	if cfg.SFTPSubsystem != "" && cfg.SFTPServerCommand != "" {
+		return nil, fmt.Errorf("%w: both an SFTP subsystem and a server command", ErrInvalidConfig)
+	}
+	if cfg.MaxPacket < 0 || cfg.MaxFileSize < 0 {
+		return nil, fmt.Errorf("%w: negative size limit", ErrInvalidConfig)
+	}
+	if err := cfg.TCP.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+
+	var c *Client
+	var err error
+	switch cfg.Protocol {
+	case "", ProtocolSFTP, ProtocolSCP:
+		c, err = newSSHClient(cfg)
+	case ProtocolFTPS:
+		c, err = newFTPSClient(cfg)
+	case ProtocolFile:
+		c, err = newLocalClient(cfg)
+	case ProtocolMemory:
+		c = &Client{
+			fs:       memoryStore(cfg.Host, cfg.LocalDir),
+			endpoint: "memory://" + cfg.Host,
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported protocol %q", ErrInvalidConfig, cfg.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.probe = cfg.Probe
+	c.limits.MaxFileSize = cfg.MaxFileSize
+	c.mtimePrecision, c.ignoreMtime = cfg.MtimePrecision, cfg.IgnoreMtime
+	c.clock = cfg.Clock
+	if c.clock == nil {
+		c.clock = systemClock{}
+	}
+	operationTTL := cfg.OperationTTL
+	if operationTTL <= 0 {
+		operationTTL = DefaultOperationTTL
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	c.queue = newWorkQueue(workers)
+	c.maxQueued = cfg.MaxQueued
+	c.batchSize = cfg.UploadBatchSize
+	c.journal = cfg.Journal
+	if c.intents, err = newIntentLog(cfg.IntentDir, intentHost(cfg)); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	c.operations = newOperationTable(operationTTL)
+	c.statCache = newStatCache(cfg.StatCacheTTL, c.clock)
+	c.uploads = make(map[string]*Operation)
+	user, _ := cfg.login()
+	c.session = c.resolveSession(user)
+	return c, nil
+}
+
+// AnonymousUser is the user an anonymous Config logs in as when it names
+// none.
+const AnonymousUser = "anonymous"
+
+// anonymousFTPPassword is the password RFC 1635 suggests for anonymous FTP.
+const anonymousFTPPassword = "anonymous@"
+
+// login returns the user and password to log in with.
+func (cfg Config) login() (user, password string) {
+	if !cfg.Anonymous {
+		return cfg.Username, cfg.Password
+	}
+	user = cmp.Or(cfg.Username, AnonymousUser)
+	if cfg.Protocol == ProtocolFTPS {
+		return user, cmp.Or(cfg.Password, anonymousFTPPassword)
+	}
+	return user, cfg.Password
+}
+
+// parsePrivateKey parses a PEM-encoded private key, decrypting it with
+// passphrase when one is given.
+func parsePrivateKey(pemKey []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(pemKey, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(pemKey)
+}
+
+// newSSHClient connects over SSH and opens the SFTP subsystem, or uses SCP
+// when asked to or when the subsystem is unavailable.
+func newSSHClient(cfg Config) (*Client, error) {
+	var auth []ssh.AuthMethod
+	if len(cfg.PrivateKey) > 0 {
+		signer, err := parsePrivateKey(cfg.PrivateKey, cfg.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: private key: %w", ErrInvalidConfig, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.AgentSocket != "" {
+		// The agent is only needed for the handshake. It signs on the key's
+		// behalf, so hardware-backed keys (sk-ssh-ed25519) prompt for touch
+		// or PIN there.
+		conn, err := net.Dial("unix", cfg.AgentSocket)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ssh agent unavailable: %w", ErrAuthFailed, err)
+		}
+		defer func() { _ = conn.Close() }()
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	user, password := cfg.login()
+	switch {
+	case cfg.Anonymous:
+		// The "none" method is always tried first; servers that want a
+		// password, any password, get the configured one or an empty one
+		auth = append(auth, ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	case password != "":
+		auth = append(auth, ssh.Password(password))
+	}
 
 	sshConfig := &ssh.ClientConfig{
-		User: cfg.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(cfg.Password),
-		},
+		User:            user,
+		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // For development only
-		Timeout:         10 * time.Second,
+		Timeout:         cfg.DialTimeout,
 	}
 
-	sshClient, err := ssh.Dial("tcp", addr, sshConfig)
+	sshClient, timings, err := dialSSH(cfg, sshConfig)
 	if err != nil {
 		return nil, fmt.Errorf("ssh dial failed: %w", err)
 	}
 
-	sftpClient, err := sftp.NewClient(sshClient)
+	var transport Transport = &scpTransport{ssh: sshClient}
+	var warnings []Warning
+	var limits Limits
+	if cfg.Protocol != ProtocolSCP {
+		sftpClient, maxPacket, err := openSFTP(sshClient, cfg)
+		version, unsupported := 0, false
+		if err != nil {
+			version, unsupported = unsupportedSFTPVersion(err)
+		}
+		switch {
+		case err == nil:
+			transport = newSFTPTransport(sftpClient, cfg.SFTPVersion == SFTPVersion3)
+			limits.MaxPacket = maxPacket
+		case strings.Contains(err.Error(), "subsystem request failed") && cfg.SFTPSubsystem == "":
+			// SFTP disabled on the server; fall back to SCP over exec. A
+			// subsystem asked for by name must exist instead.
+			warnings = append(warnings, Warning{
+				Code:    WarningSCPFallback,
+				Message: "server has the SFTP subsystem disabled; using SCP, which needs a POSIX shell on the server",
+			})
+		case unsupported:
+			warnings = append(warnings, Warning{
+				Code:    WarningSFTPVersion,
+				Message: fmt.Sprintf("server speaks SFTP version %d, only version %d is supported; using SCP, which needs a POSIX shell on the server", version, SFTPVersion3),
+			})
+		default:
+			_ = sshClient.Close()
+			return nil, fmt.Errorf("sftp client failed: %w", err)
+		}
+	}
+
+	lost := make(chan struct{})
+	go func() {
+		_ = sshClient.Wait()
+		close(lost)
+	}()
+	return &Client{
+		fs:             transport,
+		sshClient:      sshClient,
+		lost:           lost,
+		endpoint:       sshClient.RemoteAddr().String(),
+		connectTimings: timings,
+		warnings:       warnings,
+		limits:         limits,
+	}, nil
+}
+
+// newLocalClient serves paths from the local filesystem beneath cfg.LocalDir.
+func newLocalClient(cfg Config) (*Client, error) {
+	transport, err := newLocalTransport(cfg.LocalDir)
 	if err != nil {
-		_ = sshClient.Close()
-		return nil, fmt.Errorf("sftp client failed: %w", err)
+		return nil, fmt.Errorf("open local root failed: %w", err)
 	}
+	return &Client{
+		fs:       transport,
+		endpoint: "file://" + cfg.LocalDir,
+	}, nil
+}
 
+// newFTPSClient connects to an FTP server and upgrades the session to TLS.
+func newFTPSClient(cfg Config) (*Client, error) {
+	conn, timings, err := dialFTPS(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ftps dial failed: %w", err)
+	}
 	return &Client{
-		sftpClient: sftpClient,
-		sshClient:  sshClient,
-		operations: make(map[string]*Operation),
+		fs:             conn,
+		endpoint:       conn.RemoteAddr().String(),
+		connectTimings: timings,
 	}, nil
 }
 
-// Close closes the SFTP and SSH connections.
+// dialSSH opens the SSH connection. Without RotateEndpoints the host is handed
+// to the resolver as-is, which pins to the first resolved address.
+func dialSSH(cfg Config, sshConfig *ssh.ClientConfig) (*ssh.Client, Timings, error) {
+	if !cfg.RotateEndpoints {
+		return dialAddr(net.JoinHostPort(cfg.Host, cfg.Port), sshConfig, cfg.TCP)
+	}
+
+	var resolver Resolver = net.DefaultResolver
+	if cfg.Resolver != nil {
+		resolver = cfg.Resolver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	addrs, err := resolver.LookupHost(ctx, cfg.Host)
+	if err != nil {
+		return nil, Timings{}, fmt.Errorf("%w: resolve %s: %w", ErrUnreachable, cfg.Host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, Timings{}, fmt.Errorf("%w: no addresses for host %s", ErrUnreachable, cfg.Host)
+	}
+
+	start := int(endpointCounter.Add(1) % uint64(len(addrs)))
+	var lastErr error
+	for i := range addrs {
+		addr := net.JoinHostPort(addrs[(start+i)%len(addrs)], cfg.Port)
+		sshClient, timings, err := dialAddr(addr, sshConfig, cfg.TCP)
+		if err == nil {
+			return sshClient, timings, nil
+		}
+		// Every endpoint shares the same credentials; don't retry a rejection
+		if errors.Is(err, ErrAuthFailed) {
+			return nil, timings, err
+		}
+		lastErr = err
+	}
+	return nil, Timings{}, lastErr
+}
+
+// dialAddr dials a single address and performs the SSH handshake, timing the
+// TCP connect and the handshake/authentication separately.
+func dialAddr(addr string, sshConfig *ssh.ClientConfig, tcp TCPOptions) (*ssh.Client, Timings, error) {
+	var timings Timings
+
+	start := time.Now()
+	conn, err := tcp.dial(addr, sshConfig.Timeout)
+	timings.Dial = time.Since(start)
+	if err != nil {
+		return nil, timings, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	start = time.Now()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	timings.Auth = time.Since(start)
+	if err != nil {
+		_ = conn.Close()
+		// x/crypto/ssh has no typed error for rejected credentials
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, timings, fmt.Errorf("%w: %w", ErrAuthFailed, err)
+		}
+		return nil, timings, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), timings, nil
+}
+
+// Disconnected reports whether the connection to the server ended: the
+// server closed it, the network broke or the client was closed. Operations
+// on the client fail from then on, so callers connect a new one, which with
+// RotateEndpoints resolves the host afresh.
+func (c *Client) Disconnected() bool {
+	if c.lost == nil {
+		return false
+	}
+	select {
+	case <-c.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConnectTimings returns how long dialing and authenticating took when the
+// client connected.
+func (c *Client) ConnectTimings() Timings {
+	return c.connectTimings
+}
+
+// MeasureClockSkew estimates how far the server's clock is ahead of the local
+// one (negative when behind) by creating an empty file in dir, comparing its
+// modification time with the local time of the write, and removing it again.
+// Most servers report whole-second mtimes, so the estimate is accurate to
+// about a second.
+func (c *Client) MeasureClockSkew(dir string) (time.Duration, error) {
+	probe := path.Join(dir, ".formae-clock-"+uuid.New().String())
+
+	// Compared against the server's real clock, so this reads the system
+	// clock rather than c.clock
+	before := time.Now()
+	f, err := c.fs.Create(probe)
+	if err != nil {
+		return 0, fmt.Errorf("create failed: %w", err)
+	}
+	err = f.Close()
+	after := time.Now()
+	defer func() { _ = c.fs.Remove(probe) }()
+	if err != nil {
+		return 0, fmt.Errorf("write failed: %w", err)
+	}
+
+	stat, err := c.lstat(probe)
+	if err != nil {
+		return 0, fmt.Errorf("stat failed: %w", err)
+	}
+	modTime := c.modTime(stat)
+	if modTime.IsZero() {
+		return 0, fmt.Errorf("server did not report a modification time: %w", errors.ErrUnsupported)
+	}
+	local := before.Add(after.Sub(before) / 2).Truncate(time.Second)
+	return modTime.Sub(local), nil
+}
+
+// Endpoint returns the remote address this client is connected to.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// ProtocolInfo describes the protocol a Client's session speaks.
+type ProtocolInfo struct {
+	Protocol Protocol
+	// Version is the negotiated SFTP version, or zero for other protocols.
+	Version int
+	// Extensions are the SFTP extensions in use, sorted by name.
+	Extensions []string
+	// ServerVersion is the SSH server's version banner (e.g.
+	// "SSH-2.0-OpenSSH_9.6"), and Algorithms what the SSH handshake
+	// negotiated. Both are empty for protocols without SSH.
+	ServerVersion string
+	Algorithms    SSHAlgorithms
+}
+
+// SSHAlgorithms are the algorithms an SSH session negotiated. Cipher and
+// MAC are those of data sent to the server.
+type SSHAlgorithms struct {
+	KeyExchange string
+	HostKey     string
+	Cipher      string
+	MAC         string
+}
+
+// ProtocolInfo reports the protocol the session negotiated, so callers can
+// see which extensions an SFTP server's quirks left enabled.
+func (c *Client) ProtocolInfo() ProtocolInfo {
+	info := c.transportInfo()
+	if c.sshClient != nil {
+		info.ServerVersion = string(c.sshClient.ServerVersion())
+		if conn, ok := c.sshClient.Conn.(ssh.AlgorithmsConnMetadata); ok {
+			algorithms := conn.Algorithms()
+			info.Algorithms = SSHAlgorithms{
+				KeyExchange: algorithms.KeyExchange,
+				HostKey:     algorithms.HostKey,
+				Cipher:      algorithms.Write.Cipher,
+				MAC:         algorithms.Write.MAC,
+			}
+		}
+	}
+	return info
+}
+
+// transportInfo describes the protocol c's transport speaks.
+func (c *Client) transportInfo() ProtocolInfo {
+	switch t := c.fs.(type) {
+	case *sftpTransport:
+		info := ProtocolInfo{Protocol: ProtocolSFTP, Version: SFTPVersion3}
+		for name := range t.extensions {
+			info.Extensions = append(info.Extensions, name)
+		}
+		slices.Sort(info.Extensions)
+		return info
+	case *scpTransport:
+		return ProtocolInfo{Protocol: ProtocolSCP}
+	case *ftpsConn:
+		return ProtocolInfo{Protocol: ProtocolFTPS}
+	case *localTransport:
+		return ProtocolInfo{Protocol: ProtocolFile}
+	default:
+		return ProtocolInfo{Protocol: ProtocolMemory}
+	}
+}
+
+// Close closes the transport and, if any, the SSH connection.
 func (c *Client) Close() error {
+	// Operations that never started fail rather than vanish, so a journal
+	// can still answer for them
+	for _, t := range c.queue.close() {
+		c.completeOperation(t.op, StateFailure, ErrClientClosed)
+	}
+
 	var errs []error
-	if c.sftpClient != nil {
-		if err := c.sftpClient.Close(); err != nil {
+	if c.fs != nil {
+		if err := c.fs.Close(); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -92,23 +659,92 @@ func (c *Client) Close() error {
 // StartUpload begins uploading content to a file.
 // Returns an operation ID to poll for completion.
 func (c *Client) StartUpload(path string, content string, permissions os.FileMode) string {
-	opID := uuid.New().String()
+	return c.StartUploadWithOptions(path, content, UploadOptions{Permissions: permissions})
+}
 
+// StartUploadWithOptions is StartUpload with additional upload options.
+//
+// A request identical to an upload still in progress (same path, content
+// and permissions) is attached to that upload instead of starting a second
+// one, so an agent retrying after a network hiccup does not race its own
+// earlier write. The request still gets its own ID, which resolves to the
+// shared operation and is listed in its RequestIDs.
+func (c *Client) StartUploadWithOptions(path string, content string, opts UploadOptions) string {
+	key := uploadKey(path, content, opts)
+
+	priority := opts.Priority
+	if priority == 0 {
+		priority = PriorityFor(OperationTypeUpload, int64(len(content)))
+	}
+
+	id := uuid.New().String()
 	op := &Operation{
-		ID:        opID,
-		Type:      OperationTypeUpload,
-		Path:      path,
-		State:     StateInProgress,
-		StartedAt: time.Now(),
+		ID:         id,
+		Type:       OperationTypeUpload,
+		Priority:   priority,
+		Path:       path,
+		Metadata:   opts.Metadata,
+		State:      StateInProgress,
+		StartedAt:  c.clock.Now(),
+		RequestIDs: []string{id},
+		key:        key,
+	}
+	c.recordIntent(id, path, content, opts)
+	if c.trackUpload(op) {
+		return id
 	}
 
-	c.mu.Lock()
-	c.operations[opID] = op
-	c.mu.Unlock()
+	if c.batchable(content, opts) {
+		upload := &batchedUpload{op: op, content: content, opts: opts}
+		c.queue.submitUpload(upload, func() { c.uploadBatch(upload) })
+		return op.ID
+	}
+	c.queue.submit(op, func() { c.dequeued(op); c.doUpload(op, content, opts) })
+
+	return op.ID
+}
 
-	go c.doUpload(op, content, permissions)
+// uploadKey identifies an upload request by its path, content hash and
+// every option that changes what ends up on the server. Priority and
+// Metadata only change how the upload is run and reported, so requests
+// differing in nothing else still attach to each other.
+func uploadKey(path, content string, opts UploadOptions) string {
+	sum := sha256.Sum256([]byte(content))
 
-	return opID
+	// Pointers and middleware are keyed by what they hold, not where
+	var sidecar, parentOwner string
+	if opts.Sidecar != nil {
+		sidecar = fmt.Sprintf("%+v", *opts.Sidecar)
+	}
+	if opts.ParentOwner != nil {
+		parentOwner = fmt.Sprintf("%+v", *opts.ParentOwner)
+	}
+	middleware := middlewareNames(opts.Middleware)
+	opts.Sidecar, opts.ParentOwner, opts.Middleware = nil, nil, nil
+	opts.Priority, opts.Metadata = 0, nil
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%x\x00%s\x00%s\x00%s\x00%#v", path, sum, sidecar, parentOwner, middleware, opts)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// trackUpload records op unless an identical upload is still in progress,
+// in which case op's ID is attached to that operation, op is discarded and
+// trackUpload reports true.
+func (c *Client) trackUpload(op *Operation) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.uploads[op.key]; ok {
+		c.operations.update(existing, func() {
+			existing.RequestIDs = append(existing.RequestIDs, op.ID)
+		})
+		c.operations.add(op.ID, existing, c.clock.Now())
+		return true
+	}
+	c.uploads[op.key] = op
+	c.track(op)
+	return false
 }
 
 // StartDelete begins deleting a file.
@@ -119,32 +755,76 @@ func (c *Client) StartDelete(path string) string {
 	op := &Operation{
 		ID:        opID,
 		Type:      OperationTypeDelete,
+		Priority:  PriorityFor(OperationTypeDelete, 0),
 		Path:      path,
 		State:     StateInProgress,
-		StartedAt: time.Now(),
+		StartedAt: c.clock.Now(),
 	}
+	c.track(op)
 
-	c.mu.Lock()
-	c.operations[opID] = op
-	c.mu.Unlock()
-
-	go c.doDelete(op)
+	c.queue.submit(op, func() { c.dequeued(op); c.doDelete(op) })
 
 	return opID
 }
 
-// GetStatus returns the current status of an operation.
+// GetStatus returns the current status of an operation, falling back to
+// the journal for operations finished by an earlier client.
 func (c *Client) GetStatus(operationID string) (*Operation, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	op, ok := c.operations[operationID]
+	op, ok := c.operations.get(operationID, c.clock.Now())
 	if !ok {
+		if c.journal != nil {
+			if op, ok := c.journal.Lookup(operationID); ok {
+				return op, nil
+			}
+		}
+		if op, ok := c.recover(operationID); ok {
+			return op.Copy(), nil
+		}
 		return nil, fmt.Errorf("operation not found: %s", operationID)
 	}
 
 	// Return a copy to avoid race conditions
-	return op.Copy(), nil
+	return c.operations.snapshot(op), nil
+}
+
+// MarkSlowWarned records that the slow-operation warning for operationID
+// was logged, and reports whether it had not been before, so an operation
+// whose status is polled repeatedly is reported once.
+func (c *Client) MarkSlowWarned(operationID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.slowWarned[operationID] {
+		return false
+	}
+	// Forget operations that expired since
+	now := c.clock.Now()
+	for id := range c.slowWarned {
+		if _, ok := c.operations.get(id, now); !ok {
+			delete(c.slowWarned, id)
+		}
+	}
+	if c.slowWarned == nil {
+		c.slowWarned = make(map[string]bool)
+	}
+	c.slowWarned[operationID] = true
+	return true
+}
+
+// Wait blocks until an operation finishes or ctx is done, and returns its
+// final status. Operations finished by an earlier client are answered from
+// the journal.
+func (c *Client) Wait(ctx context.Context, operationID string) (*Operation, error) {
+	op, ok := c.operations.get(operationID, c.clock.Now())
+	if !ok {
+		return c.GetStatus(operationID)
+	}
+	select {
+	case <-op.done:
+		return op.Copy(), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for operation %s: %w", operationID, ctx.Err())
+	}
 }
 
 // =============================================================================
@@ -152,116 +832,609 @@ func (c *Client) GetStatus(operationID string) (*Operation, error) {
 // =============================================================================
 
 // ReadFile reads a file and returns its contents and metadata.
+// The path itself is inspected without following symlinks: a symlink is
+// reported with its target, and a directory without content.
 func (c *Client) ReadFile(path string) (*FileInfo, error) {
-	// Get file info
-	stat, err := c.sftpClient.Stat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("stat failed: %w", err)
+	return c.ReadFileThrough(path)
+}
+
+// ReadFileThrough reads a file like ReadFile, undoing the middleware it was
+// uploaded through, so content and size are as uploaded rather than as
+// stored.
+func (c *Client) ReadFileThrough(path string, mws ...Middleware) (*FileInfo, error) {
+	// The content is read fresh, so its metadata is too
+	info, err := c.stat(path)
+	if err != nil || info.Type != FileTypeRegular {
+		return info, err
 	}
 
 	// Read content
-	f, err := c.sftpClient.Open(path)
+	f, err := c.fs.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open failed: %w", err)
 	}
 	defer func() { _ = f.Close() }()
 
-	content, err := io.ReadAll(f)
+	stored := &countingReader{r: f}
+	r, err := readPipeline(stored, mws)
 	if err != nil {
 		return nil, fmt.Errorf("read failed: %w", err)
 	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+	info.Content = string(content)
+	info.Size = int64(len(content))
+	c.stats.bytesDown.Add(stored.n)
 
-	return &FileInfo{
+	return info, nil
+}
+
+// ReadRange returns up to length bytes of a file starting at offset, for spot
+// checks of files too large to download. Fewer bytes are returned when the
+// file ends first. SFTP and local files are read from offset directly;
+// other transports stream and discard what comes before it.
+func (c *Client) ReadRange(path string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("invalid range %d+%d", offset, length)
+	}
+	f, err := c.fs.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("open failed: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	switch f := f.(type) {
+	case io.ReaderAt:
+		r = io.NewSectionReader(f, offset, length)
+	case io.Seeker:
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek failed: %w", err)
+		}
+	default:
+		if _, err := io.CopyN(io.Discard, f, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read failed: %w", err)
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+	c.stats.bytesDown.Add(int64(len(data)))
+	return data, nil
+}
+
+// Stat returns a path's metadata without reading its content.
+// Symlinks are not followed; their target is returned in LinkTarget.
+func (c *Client) Stat(path string) (*FileInfo, error) {
+	cached, ok, gen := c.statCache.get(path)
+	if ok {
+		if cached == nil {
+			return nil, ErrNotFound
+		}
+		return cached, nil
+	}
+
+	info, err := c.stat(path)
+	switch {
+	case err == nil:
+		c.statCache.put(path, info, gen)
+	case errors.Is(err, ErrNotFound):
+		c.statCache.put(path, nil, gen)
+	}
+	return info, err
+}
+
+// stat is Stat without the cache.
+func (c *Client) stat(path string) (*FileInfo, error) {
+	stat, err := c.lstat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("stat failed: %w", err)
+	}
+
+	info := &FileInfo{
 		Path:        path,
-		Content:     string(content),
-		Permissions: fmt.Sprintf("%04o", stat.Mode().Perm()),
+		Type:        fileTypeOf(stat.Mode()),
+		Permissions: permissionsOf(stat),
 		Size:        stat.Size(),
-		ModifiedAt:  stat.ModTime(),
-	}, nil
+		ModifiedAt:  c.modTime(stat),
+		Owner:       ownerOf(stat),
+	}
+
+	if info.Type == FileTypeSymlink {
+		target, err := c.fs.ReadLink(path)
+		if err != nil {
+			return nil, fmt.Errorf("readlink failed: %w", err)
+		}
+		info.LinkTarget = target
+	}
+
+	return info, nil
+}
+
+// WriteFile synchronously replaces a file's content. The content is written
+// to a temporary sibling and renamed into place, so readers never observe a
+// partially written file.
+func (c *Client) WriteFile(path string, content []byte, permissions os.FileMode) error {
+	defer c.statCache.forget(path)
+	tmp := fmt.Sprintf("%s.tmp.%s", path, uuid.New().String())
+
+	f, err := c.fs.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create failed: %w", err)
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		c.stats.bytesUp.Add(int64(len(content)))
+		err = c.chmod(tmp, permissions)
+	}
+	if err == nil {
+		err = c.fs.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = c.fs.Remove(tmp)
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// chmod sets permissions, ignoring transports that cannot represent them.
+func (c *Client) chmod(path string, permissions os.FileMode) error {
+	if err := c.fs.Chmod(path, permissions); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
 }
 
 // SetPermissions changes file permissions (synchronous, fast operation).
 func (c *Client) SetPermissions(path string, permissions os.FileMode) error {
-	return c.sftpClient.Chmod(path, permissions)
+	defer c.statCache.forget(path)
+	return c.refused(path, c.chmod(path, permissions))
+}
+
+// RealPath returns the server's canonical absolute form of path, with "."
+// and ".." removed and symlinks resolved. Transports that cannot resolve
+// symlinks (FTPS) return errors.ErrUnsupported.
+func (c *Client) RealPath(path string) (string, error) {
+	t, ok := c.fs.(realpather)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	real, err := t.RealPath(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("realpath failed: %w", err)
+	}
+	return real, nil
+}
+
+// SetOwner changes a file's numeric owner and group. Returns
+// errors.ErrUnsupported on transports without ownership (FTPS).
+func (c *Client) SetOwner(path string, uid, gid int) error {
+	t, ok := c.fs.(chowner)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	defer c.statCache.forget(path)
+	return t.Chown(path, uid, gid)
+}
+
+// Remove synchronously deletes a file. A file that is already gone is not
+// an error.
+func (c *Client) Remove(path string) error {
+	defer c.statCache.forget(path)
+	if err := c.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove failed: %w", err)
+	}
+	return nil
 }
 
 // ListFiles returns all file paths in a directory.
 func (c *Client) ListFiles(dir string) ([]string, error) {
-	entries, err := c.sftpClient.ReadDir(dir)
+	var paths []string
+	err := c.WalkFiles(dir, func(info *FileInfo) bool {
+		paths = append(paths, info.Path)
+		return true
+	})
+	return paths, err
+}
+
+// ReadDir returns the metadata of every non-directory entry in dir, without
+// content. Symlinks are reported as such but not resolved.
+func (c *Client) ReadDir(dir string) ([]*FileInfo, error) {
+	var infos []*FileInfo
+	err := c.WalkFiles(dir, func(info *FileInfo) bool {
+		infos = append(infos, info)
+		return true
+	})
+	return infos, err
+}
+
+// WalkFiles calls fn with the metadata of each non-directory entry in dir,
+// in the order the server lists them, until fn returns false. Transports
+// that can read a directory incrementally (file://) stop reading as soon as
+// fn does, so callers that only need part of a huge directory don't hold all
+// of it; the others fetch the listing first, as pkg/sftp's ReadDir has no
+// incremental form.
+func (c *Client) WalkFiles(dir string, fn func(*FileInfo) bool) error {
+	// Listings carry what a stat would return, except symlink targets and
+	// what other probes would leave out, so they fill the stat cache
+	gen := c.statCache.generation()
+	prime := c.probe == "" || c.probe == ProbeStat
+	visit := func(entry os.FileInfo) bool {
+		if entry.IsDir() {
+			return true
+		}
+		// Names are passed through byte-for-byte: no Unicode normalization,
+		// so NFC and NFD spellings stay distinct files.
+		info := c.listedInfo(path.Join(dir, entry.Name()), entry)
+		if prime && info.Type != FileTypeSymlink {
+			c.statCache.put(info.Path, info, gen)
+		}
+		return fn(info)
+	}
+
+	var err error
+	if streamer, ok := c.fs.(dirStreamer); ok {
+		err = streamer.ReadDirFunc(dir, visit)
+	} else {
+		var entries []os.FileInfo
+		entries, err = c.fs.ReadDir(dir)
+		for _, entry := range entries {
+			if !visit(entry) {
+				break
+			}
+		}
+	}
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrNotFound
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
 		}
-		return nil, fmt.Errorf("readdir failed: %w", err)
+		return fmt.Errorf("readdir failed: %w", err)
 	}
+	return nil
+}
 
+// ListTree returns all file paths under dir, descending into subdirectories.
+// Subdirectories that cannot be read are skipped and reported alongside the
+// paths that were listed; only a failure on dir itself returns an error.
+func (c *Client) ListTree(dir string) ([]string, []*ListError, error) {
 	var paths []string
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			paths = append(paths, dir+"/"+entry.Name())
+	skipped, err := c.WalkTree(dir, func(info *FileInfo) bool {
+		paths = append(paths, info.Path)
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return paths, skipped, nil
+}
+
+// WalkTree calls fn with the metadata of each non-directory entry under dir,
+// descending into subdirectories, until fn returns false. Only the directory
+// being read is held at a time, so callers that need part of a huge tree
+// don't hold all of it. Subdirectories that cannot be read are skipped and
+// reported; only a failure on dir itself returns an error.
+func (c *Client) WalkTree(dir string, fn func(*FileInfo) bool) ([]*ListError, error) {
+	var skipped []*ListError
+
+	walker := fs.WalkFS(dir, walkFS{c.fs})
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if walker.Path() == dir {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, ErrNotFound
+				}
+				return nil, fmt.Errorf("readdir failed: %w", err)
+			}
+			skipped = append(skipped, &ListError{Path: walker.Path(), Err: err})
+			continue
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		if !fn(c.listedInfo(walker.Path(), walker.Stat())) {
+			break
 		}
 	}
-	return paths, nil
+	return skipped, nil
+}
+
+// listedInfo returns the metadata a directory listing carries for the entry
+// at p.
+func (c *Client) listedInfo(p string, entry os.FileInfo) *FileInfo {
+	return &FileInfo{
+		Path:        p,
+		Type:        fileTypeOf(entry.Mode()),
+		Permissions: permissionsOf(entry),
+		Size:        entry.Size(),
+		ModifiedAt:  c.modTime(entry),
+		Owner:       ownerOf(entry),
+	}
 }
 
 // =============================================================================
 // Internal implementation
 // =============================================================================
 
-func (c *Client) doUpload(op *Operation, content string, permissions os.FileMode) {
-	// Create/overwrite the file
-	f, err := c.sftpClient.Create(op.Path)
+func (c *Client) doUpload(op *Operation, content string, opts UploadOptions) {
+	// Only this worker writes op's timings, so reading them is safe
+	timings := op.Timings
+	result, err := c.upload(op, content, opts, &timings)
+
+	// GetStatus copies operations concurrently, so publish under the lock
+	c.operations.update(op, func() {
+		op.Timings = timings
+		op.Result = result
+	})
+
 	if err != nil {
-		c.completeOperation(op, StateFailure, fmt.Errorf("create failed: %w", err))
+		c.completeOperation(op, StateFailure, c.refused(op.Path, err))
 		return
 	}
+	c.completeOperation(op, StateCompleted, nil)
+}
 
-	// Write content
-	_, err = f.Write([]byte(content))
-	if closeErr := f.Close(); closeErr != nil && err == nil {
-		err = closeErr
+// upload writes content to op's path, recording each phase's duration in
+// timings.
+func (c *Client) upload(op *Operation, content string, opts UploadOptions, timings *Timings) (_ *FileInfo, err error) {
+	// Middleware may shrink what is stored, so only plain content is
+	// measured against the server's limit before writing
+	if len(opts.Middleware) == 0 {
+		if err := c.CheckSize(int64(len(content))); err != nil {
+			return nil, err
+		}
 	}
+	if opts.CreateParents {
+		created, err := c.createParents(op.Path, opts.ParentPermissions, opts.ParentOwner)
+		c.operations.update(op, func() { op.CreatedDirs = created })
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Append {
+		return c.appendContent(op, content, opts, timings)
+	}
+	path := op.Path
+	// A verified or cached upload is written to a staged sibling first, so
+	// the live file is only replaced once the checks accept it. Cache
+	// entries hold content as declared, so uploads through middleware that
+	// may change the stored bytes bypass the cache.
+	cached := opts.CacheDir != "" && len(content) >= cacheMinSize && len(opts.Middleware) == 0
+	target := path
+	if opts.ValidateCommand != "" || opts.Verify || cached {
+		target = fmt.Sprintf("%s.tmp.%s", path, uuid.New().String())
+		defer func() {
+			if err != nil {
+				_ = c.fs.Remove(target)
+			}
+		}()
+	}
+
+	h, err := opts.VerifyHash.New()
 	if err != nil {
-		c.completeOperation(op, StateFailure, fmt.Errorf("write failed: %w", err))
-		return
+		return nil, err
+	}
+	start := c.clock.Now()
+	var stored string
+	hit := cached && c.fromCache(opts.CacheDir, target, content)
+	if hit {
+		timings.Write = c.since(start)
+		c.operations.update(op, func() {
+			op.BytesWritten = int64(len(content))
+			op.CacheHit = true
+		})
+		h.Write([]byte(content))
+		stored = hex.EncodeToString(h.Sum(nil))
+	} else if stored, err = c.writeContent(op, target, path, content, opts.Middleware, h, timings); err != nil {
+		return nil, err
 	}
 
 	// Set permissions
-	if err := c.sftpClient.Chmod(op.Path, permissions); err != nil {
-		c.completeOperation(op, StateFailure, fmt.Errorf("chmod failed: %w", err))
-		return
+	start = c.clock.Now()
+	err = c.chmod(target, opts.Permissions)
+	timings.Chmod = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("chmod failed: %w", err)
+	}
+
+	if target != path {
+		if err := c.verify(target, stored, opts); err != nil {
+			return nil, c.quarantine(target, path, opts.QuarantineDir, err)
+		}
+		if err := c.fs.Rename(target, path); err != nil {
+			return nil, fmt.Errorf("rename failed: %w", err)
+		}
+	}
+
+	if len(opts.Xattrs) > 0 {
+		if err := c.SetXattrs(path, opts.Xattrs, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.ACL) > 0 {
+		if err := c.SetACL(path, opts.ACLType, opts.ACL); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SELinuxContext != "" {
+		if err := c.SetSELinuxContext(path, opts.SELinuxContext); err != nil {
+			return nil, err
+		}
+	}
+
+	if cached && !hit {
+		c.addToCache(opts.CacheDir, path, content)
+	}
+
+	if opts.Sidecar != nil {
+		if err := c.uploadSidecar(path, content, stored, opts); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get final file info
-	stat, err := c.sftpClient.Stat(op.Path)
+	start = c.clock.Now()
+	stat, err := c.lstat(path)
+	timings.Stat = c.since(start)
 	if err != nil {
-		c.completeOperation(op, StateFailure, fmt.Errorf("stat failed: %w", err))
-		return
+		return nil, fmt.Errorf("stat failed: %w", err)
 	}
 
-	op.Result = &FileInfo{
-		Path:        op.Path,
+	return &FileInfo{
+		Path:        path,
+		Type:        FileTypeRegular,
 		Content:     content,
-		Permissions: fmt.Sprintf("%04o", stat.Mode().Perm()),
-		Size:        stat.Size(),
-		ModifiedAt:  stat.ModTime(),
+		Permissions: permissionsOf(stat),
+		Size:        int64(len(content)),
+		ModifiedAt:  c.modTime(stat),
+	}, nil
+}
+
+// writeContent sends content through mws to target, which is either path
+// itself or its staged sibling, and returns the hex-encoded hash of the
+// bytes stored with h.
+func (c *Client) writeContent(op *Operation, target, path, content string, mws []Middleware, h hash.Hash, timings *Timings) (string, error) {
+	// Create/overwrite the file
+	start := c.clock.Now()
+	f, err := c.fs.Create(target)
+	timings.Open = c.since(start)
+	if err != nil {
+		return "", fmt.Errorf("create failed: %w", err)
 	}
 
-	c.completeOperation(op, StateCompleted, nil)
+	// Write content
+	start = c.clock.Now()
+	digest := newDigest(h)
+	w := newPipeline(f, append(slices.Clone(mws), digest))
+	written, err := c.writeFair(op, w, []byte(content))
+	if closeErr := w.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	timings.Write = c.since(start)
+	if err != nil {
+		// A staged copy is removed by the caller; a file written in place
+		// was truncated on open, so anything still there is incomplete
+		partial := false
+		if target == path {
+			_, statErr := c.fs.Lstat(path)
+			partial = !errors.Is(statErr, os.ErrNotExist)
+		}
+		return "", &PartialWriteError{Path: path, Written: written, Total: int64(len(content)), Partial: partial, Err: err}
+	}
+	c.stats.bytesUp.Add(digest.n)
+	return digest.sum(), nil
+}
+
+// appendContent adds content to the end of op's file unless the file already
+// contains it. Checking and appending are not atomic, but the append never
+// rewrites what other writers added in the meantime.
+func (c *Client) appendContent(op *Operation, content string, opts UploadOptions, timings *Timings) (*FileInfo, error) {
+	a, ok := c.fs.(appender)
+	if !ok {
+		return nil, fmt.Errorf("append failed: %w", errors.ErrUnsupported)
+	}
+	path := op.Path
+
+	var existing string
+	info, err := c.ReadFileThrough(path, opts.Middleware...)
+	switch {
+	case err == nil && info.Type != FileTypeRegular:
+		return nil, fmt.Errorf("append failed: %s is a %s, not a regular file", path, info.Type)
+	case err == nil:
+		existing = info.Content
+	case !errors.Is(err, ErrNotFound):
+		return nil, fmt.Errorf("append failed: %w", err)
+	}
+
+	if !strings.Contains(existing, content) {
+		data := content
+		if existing != "" && !strings.HasSuffix(existing, "\n") {
+			data = "\n" + data
+		}
+
+		start := c.clock.Now()
+		f, err := a.Append(path)
+		timings.Open = c.since(start)
+		if err != nil {
+			return nil, fmt.Errorf("append failed: %w", err)
+		}
+		// Stages such as gzip write a self-contained segment, which reads
+		// back as a continuation of what is already there
+		start = c.clock.Now()
+		digest := newDigest(sha256.New())
+		w := newPipeline(f, append(slices.Clone(opts.Middleware), digest))
+		written, err := c.writeFair(op, w, []byte(data))
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		timings.Write = c.since(start)
+		if err != nil {
+			return nil, &PartialWriteError{Path: path, Written: written, Total: int64(len(data)), Partial: written > 0, Err: err}
+		}
+		c.stats.bytesUp.Add(digest.n)
+	}
+
+	start := c.clock.Now()
+	err = c.chmod(path, opts.Permissions)
+	timings.Chmod = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("chmod failed: %w", err)
+	}
+	if len(opts.Xattrs) > 0 {
+		if err := c.SetXattrs(path, opts.Xattrs, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.ACL) > 0 {
+		if err := c.SetACL(path, opts.ACLType, opts.ACL); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SELinuxContext != "" {
+		if err := c.SetSELinuxContext(path, opts.SELinuxContext); err != nil {
+			return nil, err
+		}
+	}
+
+	start = c.clock.Now()
+	stat, err := c.lstat(path)
+	timings.Stat = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("stat failed: %w", err)
+	}
+	return &FileInfo{
+		Path:        path,
+		Type:        FileTypeRegular,
+		Content:     content,
+		Permissions: permissionsOf(stat),
+		Size:        stat.Size(),
+		ModifiedAt:  c.modTime(stat),
+	}, nil
 }
 
 func (c *Client) doDelete(op *Operation) {
-	err := c.sftpClient.Remove(op.Path)
+	err := c.fs.Remove(op.Path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, os.ErrNotExist) {
 			// Already deleted - treat as success
 			c.completeOperation(op, StateCompleted, nil)
 			return
 		}
-		c.completeOperation(op, StateFailure, fmt.Errorf("remove failed: %w", err))
+		c.completeOperation(op, StateFailure, c.refused(op.Path, fmt.Errorf("remove failed: %w", err)))
 		return
 	}
 
@@ -269,12 +1442,29 @@ func (c *Client) doDelete(op *Operation) {
 }
 
 func (c *Client) completeOperation(op *Operation, state OperationState, err error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	op.State = state
-	op.CompletedAt = time.Now()
-	if err != nil {
-		op.Error = err.Error()
+	c.statCache.forget(op.Path, op.From)
+	// Stop attaching requests first, so the journal records them all
+	if op.key != "" {
+		c.mu.Lock()
+		delete(c.uploads, op.key)
+		c.mu.Unlock()
+	}
+	c.operations.update(op, func() {
+		op.State = state
+		op.CompletedAt = c.clock.Now()
+		if err != nil {
+			op.Err = err
+			op.Error = err.Error()
+		}
+	})
+	c.stats.recordOperation(op)
+	if c.journal != nil {
+		c.journal.record(op)
+	}
+	if op.Type == OperationTypeUpload {
+		c.intents.remove(op.RequestIDs...)
+	}
+	if op.done != nil {
+		close(op.done)
 	}
 }
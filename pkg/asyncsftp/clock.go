@@ -0,0 +1,36 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import "time"
+
+// Clock tells the client the time. Operation timestamps, phase timings and
+// operation expiry all read it, so tests can substitute a fake and drive
+// them without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// DefaultOperationTTL is how long finished operations stay queryable when
+// Config.OperationTTL is not set.
+const DefaultOperationTTL = time.Hour
+
+// since returns the time elapsed on the client's clock since start.
+func (c *Client) since(start time.Time) time.Duration {
+	return c.clock.Now().Sub(start)
+}
+
+// track records a new operation and forgets finished operations that
+// completed more than the TTL ago. The agent polls Status within seconds,
+// so only abandoned operations expire.
+func (c *Client) track(op *Operation) {
+	op.done = make(chan struct{})
+	c.operations.add(op.ID, op, c.clock.Now())
+}
@@ -0,0 +1,63 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// TestOperationExpiry verifies operation timestamps come from the client's
+// clock and finished operations are forgotten once older than the TTL.
+func TestOperationExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload",
+		Clock: clock, OperationTTL: time.Minute})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	first := waitFor(t, c, c.StartUpload("/upload/a.txt", "a", 0644))
+	assert.Equal(t, clock.now, first.StartedAt)
+	assert.Equal(t, clock.now, first.CompletedAt)
+	assert.Zero(t, first.Duration())
+	assert.Zero(t, first.Timings.Write)
+
+	clock.Advance(time.Minute)
+	second := c.StartUpload("/upload/b.txt", "b", 0644)
+	_, err = c.GetStatus(first.ID)
+	assert.NoError(t, err, "operations expire only after the TTL")
+
+	waitFor(t, c, second)
+	clock.Advance(time.Second)
+	c.StartDelete("/upload/b.txt")
+	_, err = c.GetStatus(first.ID)
+	assert.Error(t, err)
+	_, err = c.GetStatus(second)
+	assert.NoError(t, err)
+}
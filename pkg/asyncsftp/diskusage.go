@@ -0,0 +1,49 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DiskUsage is the capacity of the filesystem holding a path, as reported
+// by statvfs(3).
+type DiskUsage struct {
+	TotalBytes     uint64
+	FreeBytes      uint64 // including blocks reserved for root
+	AvailableBytes uint64 // available to unprivileged users
+	TotalFiles     uint64
+	FreeFiles      uint64
+	ReadOnly       bool // mounted read-only; not reported over SCP
+}
+
+// statvfser is implemented by transports that can report filesystem
+// capacity.
+type statvfser interface {
+	StatVFS(path string) (*DiskUsage, error)
+}
+
+// DiskUsage reports the capacity of the filesystem holding path. SFTP
+// servers need the statvfs@openssh.com extension; transports and servers
+// without an equivalent return errors.ErrUnsupported.
+func (c *Client) DiskUsage(path string) (*DiskUsage, error) {
+	t, ok := c.fs.(statvfser)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	usage, err := t.StatVFS(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("statvfs failed: %w", err)
+	}
+	return usage, nil
+}
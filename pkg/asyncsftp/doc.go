@@ -0,0 +1,43 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+// Package asyncsftp wraps the pkg/sftp library with an async interface.
+// Operations return immediately with an operation ID that can be polled for completion.
+// Servers without SFTP can be reached over SCP or FTPS, and the local
+// filesystem or an in-memory one served, through the same interface.
+//
+// The package is supported for use outside the plugin. Connect with Dial
+// and options, or fill in a Config for Connect or NewClient:
+//
+//	client, err := asyncsftp.Dial(ctx, "sftp.example.com",
+//		asyncsftp.WithKeyAuth("deploy", key, ""),
+//		asyncsftp.WithTimeout(5*time.Second),
+//		asyncsftp.WithRetry(3, time.Second))
+//
+// Start an operation, then wait for it with Wait or poll it with
+// GetStatus. A failed operation keeps its error in Operation.Err, and
+// connection errors are returned directly; both wrap the package's
+// sentinel errors, so callers branch with errors.Is:
+//
+//   - ErrNotFound: the file does not exist
+//   - ErrAuthFailed: the server rejected the credentials
+//   - ErrUnreachable: the server could not be reached; Connect retries it
+//   - ErrInvalidConfig: the Config cannot work, e.g. an unknown protocol
+//   - ErrReadOnly: the server's filesystem refuses writes
+//   - ErrTooLarge: an upload exceeds Config.MaxFileSize
+//   - ErrNoSpace: the server is out of space, or the account over quota
+//   - ErrInterrupted: an upload recovered through Config.IntentDir never
+//     reached the server
+//   - ErrSymlinkLoop: ResolveLink met a chain of symlinks that loops
+//   - ErrQueueFull: Admit refused more work
+//   - ErrChecksumMismatch, ErrValidationFailed: an upload was rejected
+//   - ErrExecUnavailable: the server offers no remote commands
+//   - ErrClientClosed: the client closed before the operation started
+//
+// Error messages keep the text servers send with a refusal, often quota or
+// policy details; ServerMessage extracts it.
+//
+// Synchronous methods such as Stat, ReadFile and WriteFile serve reads
+// and small writes without an operation ID.
+package asyncsftp
@@ -0,0 +1,188 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeResolver answers every lookup with the addresses it is given and
+// counts the lookups.
+type fakeResolver struct {
+	mu      sync.Mutex
+	addrs   []string
+	lookups int
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, _ string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	return r.addrs, nil
+}
+
+func (r *fakeResolver) set(addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs = addrs
+}
+
+// sftpServer serves SFTP over SSH from memory, to any password.
+type sftpServer struct {
+	config *ssh.ServerConfig
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newSFTPServer(t *testing.T) *sftpServer {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(signer)
+	return &sftpServer{config: config}
+}
+
+// serve accepts connections on ln until it is closed.
+func (s *sftpServer) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *sftpServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					go func() {
+						_ = sftp.NewRequestServer(channel, sftp.InMemHandler()).Serve()
+						_ = channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// drop closes every connection the server accepted.
+func (s *sftpServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+// TestRotateEndpoints verifies every connection resolves the host afresh
+// and successive connections start at each resolved address in turn, and
+// that a client notices when the server drops its connection.
+func TestRotateEndpoints(t *testing.T) {
+	// Three servers on one port, at three loopback addresses
+	first, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(first.Addr().String())
+	require.NoError(t, err)
+	listeners := []net.Listener{first}
+	for _, ip := range []string{"127.0.0.2", "127.0.0.3"} {
+		ln, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+		if err != nil {
+			_ = first.Close()
+			t.Skipf("cannot listen on %s: %v", ip, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	servers := make([]*sftpServer, len(listeners))
+	for i, ln := range listeners {
+		defer func() { _ = ln.Close() }()
+		servers[i] = newSFTPServer(t)
+		go servers[i].serve(ln)
+	}
+
+	resolver := &fakeResolver{addrs: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}}
+	cfg := Config{Host: "sftp.example.com", Port: port, Username: "u", Password: "p",
+		Protocol: ProtocolSFTP, RotateEndpoints: true, Resolver: resolver, DialTimeout: 5 * time.Second}
+	connect := func() *Client {
+		c, err := NewClient(cfg)
+		require.NoError(t, err)
+		return c
+	}
+	host := func(c *Client) string {
+		h, _, err := net.SplitHostPort(c.Endpoint())
+		require.NoError(t, err)
+		return h
+	}
+
+	var endpoints []string
+	for range 3 {
+		c := connect()
+		endpoints = append(endpoints, host(c))
+		require.NoError(t, c.Close())
+	}
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, endpoints)
+	assert.Equal(t, 3, resolver.lookups)
+
+	// The farm shrinks; the next connection sees it
+	resolver.set("127.0.0.3")
+	c := connect()
+	defer func() { _ = c.Close() }()
+	assert.Equal(t, "127.0.0.3", host(c))
+	assert.Equal(t, 4, resolver.lookups)
+	assert.False(t, c.Disconnected())
+
+	servers[2].drop()
+	require.Eventually(t, c.Disconnected, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestRotateEndpointsNoAddresses verifies a host that resolves to nothing
+// fails as unreachable instead of dialing.
+func TestRotateEndpointsNoAddresses(t *testing.T) {
+	resolver := &fakeResolver{}
+	_, err := NewClient(Config{Host: "sftp.example.com", Port: "22", Username: "u", Password: "p",
+		Protocol: ProtocolSFTP, RotateEndpoints: true, Resolver: resolver, DialTimeout: 5 * time.Second})
+	require.ErrorIs(t, err, ErrUnreachable)
+	assert.ErrorContains(t, err, "no addresses for host")
+	assert.Equal(t, 1, resolver.lookups)
+}
@@ -0,0 +1,87 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// The in-memory protocol stands in for a server so the examples run
+// anywhere; against a real one, pass its host and credentials instead:
+//
+//	asyncsftp.Dial(ctx, "sftp.example.com", asyncsftp.WithKeyAuth("deploy", key, ""))
+
+func ExampleDial() {
+	ctx := context.Background()
+	client, err := asyncsftp.Dial(ctx, "example-dial",
+		asyncsftp.WithProtocol(asyncsftp.ProtocolMemory),
+		asyncsftp.WithLocalDir("/upload"),
+		asyncsftp.WithTimeout(5*time.Second),
+		asyncsftp.WithRetry(3, time.Second))
+	if err != nil {
+		fmt.Println("dial failed:", err)
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	fmt.Println(client.Endpoint())
+	// Output: memory://example-dial
+}
+
+func ExampleClient_Wait() {
+	ctx := context.Background()
+	client, err := asyncsftp.Dial(ctx, "example-wait",
+		asyncsftp.WithProtocol(asyncsftp.ProtocolMemory),
+		asyncsftp.WithLocalDir("/upload"))
+	if err != nil {
+		fmt.Println("dial failed:", err)
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	opID := client.StartUpload("/upload/hello.txt", "hello", 0644)
+	op, err := client.Wait(ctx, opID)
+	if err != nil {
+		fmt.Println("wait failed:", err)
+		return
+	}
+	fmt.Println(op.State, op.Result.Size)
+	// Output: COMPLETED 5
+}
+
+func ExampleClient_GetStatus() {
+	ctx := context.Background()
+	client, err := asyncsftp.Dial(ctx, "example-status",
+		asyncsftp.WithProtocol(asyncsftp.ProtocolMemory),
+		asyncsftp.WithLocalDir("/upload"))
+	if err != nil {
+		fmt.Println("dial failed:", err)
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	opID := client.StartMove("/upload/missing.txt", "/upload/moved.txt")
+	for {
+		op, err := client.GetStatus(opID)
+		if err != nil {
+			fmt.Println("status failed:", err)
+			return
+		}
+		if op.State == asyncsftp.StateInProgress {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		fmt.Println(op.State, errors.Is(op.Err, asyncsftp.ErrNotFound))
+		return
+	}
+	// Output: FAILURE true
+}
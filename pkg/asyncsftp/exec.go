@@ -0,0 +1,292 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrExecUnavailable indicates the account cannot run remote commands (for
+// example an SFTP-only account with ForceCommand internal-sftp), or the
+// required tool is not installed on the server.
+var ErrExecUnavailable = errors.New("remote command execution unavailable")
+
+// exitCommandNotFound is the shell's exit status for a missing command.
+const exitCommandNotFound = 127
+
+// shellQuote quotes s for safe use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Exec runs a command over a new SSH session and returns its stdout.
+// A non-zero exit is returned as an error carrying stderr.
+func (c *Client) Exec(cmd string) (string, error) {
+	if c.sshClient == nil {
+		return "", fmt.Errorf("%w: not connected over SSH", ErrExecUnavailable)
+	}
+	return runSSH(c.sshClient, cmd, nil)
+}
+
+// runSSH runs cmd in a new session on client, feeding it stdin if non-nil.
+func runSSH(client *ssh.Client, cmd string, stdin io.Reader) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrExecUnavailable, err)
+	}
+	defer func() { _ = session.Close() }()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(cmd); err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitStatus() == exitCommandNotFound {
+			return "", fmt.Errorf("%w: %s", ErrExecUnavailable, strings.TrimSpace(stderr.String()))
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w", msg, err)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// ValidateFileVar names the shell variable holding the staged path for an
+// upload's ValidateCommand.
+const ValidateFileVar = "FORMAE_FILE"
+
+// ErrValidationFailed indicates an upload's ValidateCommand rejected the
+// staged content.
+var ErrValidationFailed = errors.New("validation failed")
+
+// validate runs cmd against the staged file at path.
+func (c *Client) validate(cmd, path string) error {
+	if cmd == "" {
+		return nil
+	}
+	_, err := c.Exec(fmt.Sprintf("%s=%s; export %s; %s", ValidateFileVar, shellQuote(path), ValidateFileVar, cmd))
+	if err != nil && !errors.Is(err, ErrExecUnavailable) {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	return err
+}
+
+// Checksum returns the hex-encoded SHA-256 of a file, computed on the server
+// so the content is never transferred. Requires shell access and sha256sum,
+// unless the transport hashes files itself.
+func (c *Client) Checksum(path string) (string, error) {
+	if t, ok := c.fs.(checksummer); ok {
+		sum, err := t.Checksum(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return sum, err
+	}
+	out, err := c.Exec("sha256sum -- " + shellQuote(path))
+	if err != nil {
+		return "", fmt.Errorf("sha256sum failed: %w", err)
+	}
+	sum, _, _ := strings.Cut(out, " ")
+	if len(sum) != 64 {
+		return "", fmt.Errorf("unexpected sha256sum output: %q", out)
+	}
+	return sum, nil
+}
+
+// =============================================================================
+// Extended attributes
+// =============================================================================
+
+// XattrPrefix is the only extended attribute namespace managed by the client.
+const XattrPrefix = "user."
+
+// GetXattrs returns the user.* extended attributes of a file.
+// Requires shell access and getfattr on the server.
+func (c *Client) GetXattrs(path string) (map[string]string, error) {
+	out, err := c.Exec(fmt.Sprintf("getfattr --absolute-names --dump --encoding=hex --match=%s -- %s",
+		shellQuote("^user\\."), shellQuote(path)))
+	if err != nil {
+		return nil, fmt.Errorf("getfattr failed: %w", err)
+	}
+	return parseGetfattr(out)
+}
+
+// SetXattrs sets and removes user.* extended attributes on a file.
+// Requires shell access and setfattr on the server.
+func (c *Client) SetXattrs(path string, set map[string]string, remove []string) error {
+	var cmds []string
+	for name, value := range set {
+		cmds = append(cmds, fmt.Sprintf("setfattr -n %s -v 0x%s -- %s",
+			shellQuote(name), hex.EncodeToString([]byte(value)), shellQuote(path)))
+	}
+	for _, name := range remove {
+		cmds = append(cmds, fmt.Sprintf("setfattr -x %s -- %s", shellQuote(name), shellQuote(path)))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	if _, err := c.Exec(strings.Join(cmds, " && ")); err != nil {
+		return fmt.Errorf("setfattr failed: %w", err)
+	}
+	return nil
+}
+
+// parseGetfattr parses `getfattr --dump --encoding=hex` output.
+func parseGetfattr(out string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		decoded, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid getfattr value for %s: %w", name, err)
+		}
+		attrs[name] = string(decoded)
+	}
+	return attrs, scanner.Err()
+}
+
+// =============================================================================
+// Access control lists
+// =============================================================================
+
+// ACL flavours, named after the filesystems that use them.
+const (
+	ACLTypePOSIX = "posix" // getfacl/setfacl
+	ACLTypeNFS4  = "nfs4"  // nfs4_getfacl/nfs4_setfacl
+)
+
+// NFS4ModePrincipals are the NFSv4 principals whose entries chmod rewrites
+// from the mode bits.
+var NFS4ModePrincipals = []string{"OWNER@", "GROUP@", "EVERYONE@"}
+
+// GetACL returns the extended ACL entries of a file: every entry except
+// those that mirror its permission bits (POSIX user::, group::, other:: and
+// mask::, NFSv4 OWNER@, GROUP@ and EVERYONE@). Requires shell access and the
+// ACL tools of aclType on the server.
+func (c *Client) GetACL(path, aclType string) ([]string, error) {
+	extended, _, err := c.getACL(path, aclType)
+	return extended, err
+}
+
+// getACL returns a file's extended and mode-derived ACL entries.
+func (c *Client) getACL(path, aclType string) (extended, base []string, err error) {
+	var out string
+	switch aclType {
+	case ACLTypeNFS4:
+		out, err = c.Exec("nfs4_getfacl " + shellQuote(path))
+		if err != nil {
+			return nil, nil, fmt.Errorf("nfs4_getfacl failed: %w", err)
+		}
+	default:
+		out, err = c.Exec("getfacl --omit-header --absolute-names -- " + shellQuote(path))
+		if err != nil {
+			return nil, nil, fmt.Errorf("getfacl failed: %w", err)
+		}
+	}
+	extended, base = parseACL(aclType, out)
+	return extended, base, nil
+}
+
+// SetACL replaces the extended ACL entries of a file with entries; an empty
+// list removes them all. The entries that mirror the permission bits are
+// left alone. Requires shell access and the ACL tools of aclType.
+func (c *Client) SetACL(path, aclType string, entries []string) error {
+	// setfacl also changes the group permission bits
+	defer c.statCache.forget(path)
+	switch aclType {
+	case ACLTypeNFS4:
+		// nfs4_setfacl -s replaces the whole list, so keep the mode-derived
+		// entries after the declared ones
+		_, base, err := c.getACL(path, aclType)
+		if err != nil {
+			return err
+		}
+		spec := strings.Join(append(slices.Clone(entries), base...), ",")
+		if _, err := c.Exec(fmt.Sprintf("nfs4_setfacl -s %s %s", shellQuote(spec), shellQuote(path))); err != nil {
+			return fmt.Errorf("nfs4_setfacl failed: %w", err)
+		}
+	default:
+		cmd := "setfacl -b -- " + shellQuote(path)
+		if len(entries) > 0 {
+			cmd += fmt.Sprintf(" && setfacl -m %s -- %s", shellQuote(strings.Join(entries, ",")), shellQuote(path))
+		}
+		if _, err := c.Exec(cmd); err != nil {
+			return fmt.Errorf("setfacl failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseACL splits getfacl or nfs4_getfacl output into extended and
+// mode-derived entries, dropping comments and getfacl's #effective notes.
+func parseACL(aclType, out string) (extended, base []string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entry := fields[0]
+		parts := strings.Split(entry, ":")
+		var derived bool
+		if aclType == ACLTypeNFS4 {
+			derived = len(parts) == 4 && slices.Contains(NFS4ModePrincipals, parts[2])
+		} else {
+			derived = len(parts) >= 3 && parts[len(parts)-2] == ""
+		}
+		if derived {
+			base = append(base, entry)
+		} else {
+			extended = append(extended, entry)
+		}
+	}
+	return extended, base
+}
+
+// =============================================================================
+// SELinux contexts
+// =============================================================================
+
+// GetSELinuxContext returns the SELinux security context of a file, e.g.
+// "system_u:object_r:httpd_sys_content_t:s0", or "" when the server does
+// not label files. Requires shell access and GNU stat on the server.
+func (c *Client) GetSELinuxContext(path string) (string, error) {
+	out, err := c.Exec("stat -c %C -- " + shellQuote(path))
+	if err != nil {
+		return "", fmt.Errorf("stat failed: %w", err)
+	}
+	// stat prints "?" for a file without a context
+	if label := strings.TrimSpace(out); label != "?" {
+		return label, nil
+	}
+	return "", nil
+}
+
+// SetSELinuxContext sets the SELinux security context of a file.
+// Requires shell access and chcon on the server.
+func (c *Client) SetSELinuxContext(path, context string) error {
+	if _, err := c.Exec(fmt.Sprintf("chcon -- %s %s", shellQuote(context), shellQuote(path))); err != nil {
+		return fmt.Errorf("chcon failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseGetfattr verifies parsing of hex-encoded getfattr dumps.
+func TestParseGetfattr(t *testing.T) {
+	out := "# file: /upload/app.tar.gz\nuser.build=0x31323334\nuser.source=0x6769743a616263\n\n"
+
+	attrs, err := parseGetfattr(out)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"user.build":  "1234",
+		"user.source": "git:abc",
+	}, attrs)
+}
+
+// TestParseACL verifies extended ACL entries are told apart from the ones
+// mirroring the permission bits.
+func TestParseACL(t *testing.T) {
+	extended, base := parseACL(ACLTypePOSIX, "user::rw-\nuser:deploy:rwx\t\t#effective:r--\ngroup::r--\ngroup:web:r--\nmask::r--\nother::---\n\n")
+	assert.Equal(t, []string{"user:deploy:rwx", "group:web:r--"}, extended)
+	assert.Equal(t, []string{"user::rw-", "group::r--", "mask::r--", "other::---"}, base)
+
+	extended, base = parseACL(ACLTypeNFS4, "# file: /upload/a.txt\nA::deploy@example.com:rxtncy\nA::OWNER@:rwatTcCy\nA:g:GROUP@:rtcy\nA::EVERYONE@:rtcy\n")
+	assert.Equal(t, []string{"A::deploy@example.com:rxtncy"}, extended)
+	assert.Equal(t, []string{"A::OWNER@:rwatTcCy", "A:g:GROUP@:rtcy", "A::EVERYONE@:rtcy"}, base)
+}
+
+// TestShellQuote verifies that embedded quotes cannot break out of a word.
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/upload/it'\''s here'`, shellQuote("/upload/it's here"))
+}
+
+// TestChecksum verifies a transport that hashes files itself answers
+// Checksum without exec, following symlinks, and that a missing file is
+// ErrNotFound and a directory an error.
+func TestChecksum(t *testing.T) {
+	defer ResetMemory(t.Name())
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload/dir"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.WriteFile("/upload/app.tar.gz", []byte("artifact"), 0o644))
+	require.NoError(t, MemorySymlink(t.Name(), "app.tar.gz", "/upload/current"))
+	want := sha256.Sum256([]byte("artifact"))
+
+	for _, p := range []string{"/upload/app.tar.gz", "/upload/current"} {
+		sum, err := c.Checksum(p)
+		require.NoError(t, err, p)
+		assert.Equal(t, hex.EncodeToString(want[:]), sum, p)
+	}
+	_, err = c.Checksum("/upload/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.Checksum("/upload/dir")
+	assert.Error(t, err)
+}
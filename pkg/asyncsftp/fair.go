@@ -0,0 +1,103 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// fairShareChunk is how much content an upload writes per turn. Large
+// enough that a lone upload is not slowed by taking turns, small enough
+// that a multi-megabyte upload yields to the others several times a second.
+const fairShareChunk = 256 << 10
+
+// fairShare takes turns between uploads writing over one session. Turns
+// are granted in the order they were asked for, and an upload asks again
+// after every chunk, so concurrent uploads advance round-robin instead of
+// the first large one holding the session's write window until it is done.
+// The zero value is ready to use.
+type fairShare struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+// acquire blocks until it is the caller's turn.
+func (f *fairShare) acquire() {
+	f.mu.Lock()
+	if !f.busy {
+		f.busy = true
+		f.mu.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	f.waiting = append(f.waiting, turn)
+	f.mu.Unlock()
+	<-turn
+}
+
+// release hands the turn to the longest waiting caller.
+func (f *fairShare) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.waiting) == 0 {
+		f.busy = false
+		return
+	}
+	next := f.waiting[0]
+	f.waiting = f.waiting[1:]
+	close(next)
+}
+
+// writeFair writes content to w one turn per chunk, recording op's progress
+// after each chunk. It returns how many bytes w accepted.
+func (c *Client) writeFair(op *Operation, w io.Writer, content []byte) (int64, error) {
+	var written int64
+	for written < int64(len(content)) {
+		chunk := content[written:min(written+fairShareChunk, int64(len(content)))]
+
+		c.wire.acquire()
+		n, err := w.Write(chunk)
+		c.wire.release()
+
+		written += int64(n)
+		c.operations.update(op, func() { op.BytesWritten = written })
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// copyFair copies src to w like writeFair, reading one chunk at a time so
+// only that chunk is held in memory. It returns how many bytes w accepted.
+func (c *Client) copyFair(op *Operation, w io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, fairShareChunk)
+	var written int64
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		if n > 0 {
+			c.wire.acquire()
+			m, err := w.Write(buf[:n])
+			c.wire.release()
+
+			written += int64(m)
+			c.operations.update(op, func() { op.BytesWritten = written })
+			if err != nil {
+				return written, err
+			}
+		}
+		switch readErr {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return written, nil
+		default:
+			return written, fmt.Errorf("read source: %w", readErr)
+		}
+	}
+}
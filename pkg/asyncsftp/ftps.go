@@ -0,0 +1,533 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ftpsConn is a Transport over explicit FTPS (RFC 4217): a plain FTP control
+// connection upgraded with AUTH TLS, and TLS-protected passive data
+// connections. FTP allows one transfer per control connection at a time, so
+// every command is serialized; an open reader or writer holds the connection
+// until it is closed.
+type ftpsConn struct {
+	mu      sync.Mutex
+	raw     net.Conn
+	text    *textproto.Conn
+	tlsConf *tls.Config
+	host    string
+	mlst    bool          // server supports MLST/MLSD (RFC 3659)
+	timeout time.Duration // bounds connects, the TLS handshake and login
+	tcp     TCPOptions
+}
+
+var _ Transport = (*ftpsConn)(nil)
+
+// dialFTPS connects, upgrades to TLS and logs in, timing the TCP connect and
+// the handshake/login separately.
+func dialFTPS(cfg Config) (*ftpsConn, Timings, error) {
+	var timings Timings
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+
+	start := time.Now()
+	raw, err := cfg.TCP.dial(addr, cfg.DialTimeout)
+	timings.Dial = time.Since(start)
+	if err != nil {
+		return nil, timings, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	c := &ftpsConn{
+		raw:  raw,
+		text: textproto.NewConn(raw),
+		tlsConf: &tls.Config{
+			ServerName:         cfg.Host,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+			// Servers commonly require data connections to resume the
+			// control connection's TLS session.
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		},
+		host:    raw.RemoteAddr().(*net.TCPAddr).IP.String(),
+		timeout: cfg.DialTimeout,
+		tcp:     cfg.TCP,
+	}
+
+	start = time.Now()
+	_ = raw.SetDeadline(time.Now().Add(c.timeout))
+	err = c.login(cfg.login())
+	_ = raw.SetDeadline(time.Time{})
+	timings.Auth = time.Since(start)
+	if err != nil {
+		_ = raw.Close()
+		return nil, timings, err
+	}
+	return c, timings, nil
+}
+
+// login performs the greeting, AUTH TLS upgrade, credentials and channel setup.
+func (c *ftpsConn) login(user, password string) error {
+	if _, _, err := c.text.ReadResponse(220); err != nil {
+		return fmt.Errorf("%w: greeting: %w", ErrUnreachable, err)
+	}
+	if _, err := c.cmd(234, "AUTH TLS"); err != nil {
+		return fmt.Errorf("%w: AUTH TLS: %w", ErrUnreachable, err)
+	}
+
+	tlsConn := tls.Client(c.raw, c.tlsConf)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("%w: tls handshake: %w", ErrUnreachable, err)
+	}
+	c.text = textproto.NewConn(tlsConn)
+
+	code, err := c.cmd(2, "USER %s", user)
+	if code == 331 {
+		code, err = c.cmd(2, "PASS %s", password)
+	}
+	if code == 530 {
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	}
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	// Protect the data channel and transfer bytes verbatim
+	for _, line := range []string{"PBSZ 0", "PROT P", "TYPE I"} {
+		if _, err := c.cmd(2, "%s", line); err != nil {
+			return fmt.Errorf("%s failed: %w", line, err)
+		}
+	}
+
+	if _, msg, err := c.cmdMessage(2, "FEAT"); err == nil {
+		for _, feat := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(feat)), "MLST") {
+				c.mlst = true
+			}
+		}
+	}
+	return nil
+}
+
+// cmd sends a command and reads its response, which must match expect
+// (a full code, or a single digit for the class).
+func (c *ftpsConn) cmd(expect int, format string, args ...any) (int, error) {
+	code, _, err := c.cmdMessage(expect, format, args...)
+	return code, err
+}
+
+func (c *ftpsConn) cmdMessage(expect int, format string, args ...any) (int, string, error) {
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.text.ReadResponse(expect)
+}
+
+// pathError maps an FTP reply to the os errors callers test for, keeping
+// the reply for its text. 550 is "file unavailable", which servers use for
+// missing paths.
+func pathError(op, p string, err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		switch protoErr.Code {
+		case 550:
+			err = fmt.Errorf("%w: %w", os.ErrNotExist, err)
+		case 553, 532:
+			err = fmt.Errorf("%w: %w", os.ErrPermission, err)
+		}
+	}
+	return &os.PathError{Op: op, Path: p, Err: err}
+}
+
+// RemoteAddr returns the control connection's remote address.
+func (c *ftpsConn) RemoteAddr() net.Addr {
+	return c.raw.RemoteAddr()
+}
+
+// =============================================================================
+// Data connections
+// =============================================================================
+
+// openData opens a TLS-protected passive data connection, preferring EPSV.
+func (c *ftpsConn) openData() (net.Conn, error) {
+	port, err := c.epsv()
+	if err != nil {
+		port, err = c.pasv()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The server-supplied address is ignored in favour of the control
+	// connection's, which is what works behind NAT.
+	conn, err := c.tcp.dial(net.JoinHostPort(c.host, port), c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data connection: %w", ErrUnreachable, err)
+	}
+	return tls.Client(conn, c.tlsConf), nil
+}
+
+// epsv parses "229 Entering Extended Passive Mode (|||port|)".
+func (c *ftpsConn) epsv() (string, error) {
+	_, msg, err := c.cmdMessage(229, "EPSV")
+	if err != nil {
+		return "", err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start+2 {
+		return "", fmt.Errorf("malformed EPSV reply: %s", msg)
+	}
+	inner := msg[start+1 : end]
+	parts := strings.Split(inner, inner[:1])
+	if len(parts) != 5 {
+		return "", fmt.Errorf("malformed EPSV reply: %s", msg)
+	}
+	return parts[3], nil
+}
+
+// pasv parses "227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)".
+func (c *ftpsConn) pasv() (string, error) {
+	_, msg, err := c.cmdMessage(227, "PASV")
+	if err != nil {
+		return "", err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("malformed PASV reply: %s", msg)
+	}
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("malformed PASV reply: %s", msg)
+	}
+	hi, err1 := strconv.Atoi(parts[4])
+	lo, err2 := strconv.Atoi(parts[5])
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("malformed PASV reply: %s", msg)
+	}
+	return strconv.Itoa(hi<<8 | lo), nil
+}
+
+// transfer opens a data connection and starts command on it. The caller
+// holds c.mu.
+func (c *ftpsConn) transfer(format string, args ...any) (net.Conn, error) {
+	data, err := c.openData()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.cmd(1, format, args...); err != nil {
+		_ = data.Close()
+		return nil, err
+	}
+	return data, nil
+}
+
+// dataStream is an in-progress transfer. Closing it waits for the server to
+// confirm the transfer and releases the control connection.
+type dataStream struct {
+	net.Conn
+	c    *ftpsConn
+	done bool
+}
+
+func (s *dataStream) Close() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	defer s.c.mu.Unlock()
+
+	err := s.Conn.Close()
+	if _, _, respErr := s.c.text.ReadResponse(2); respErr != nil {
+		return respErr
+	}
+	return err
+}
+
+// =============================================================================
+// Transport
+// =============================================================================
+
+func (c *ftpsConn) Create(p string) (io.WriteCloser, error) {
+	c.mu.Lock()
+	data, err := c.transfer("STOR %s", p)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, pathError("create", p, err)
+	}
+	return &dataStream{Conn: data, c: c}, nil
+}
+
+func (c *ftpsConn) Append(p string) (io.WriteCloser, error) {
+	c.mu.Lock()
+	data, err := c.transfer("APPE %s", p)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, pathError("append", p, err)
+	}
+	return &dataStream{Conn: data, c: c}, nil
+}
+
+func (c *ftpsConn) Open(p string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	data, err := c.transfer("RETR %s", p)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, pathError("open", p, err)
+	}
+	return &dataStream{Conn: data, c: c}, nil
+}
+
+func (c *ftpsConn) Lstat(p string) (os.FileInfo, error) {
+	if p == "/" {
+		return &remoteFileInfo{name: "/", mode: os.ModeDir | 0755}, nil
+	}
+
+	if c.mlst {
+		c.mu.Lock()
+		_, msg, err := c.cmdMessage(250, "MLST %s", p)
+		c.mu.Unlock()
+		if err != nil {
+			return nil, pathError("stat", p, err)
+		}
+		// The facts line is the only continuation line, indented by a space
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, " ") {
+				if info, ok := parseMLSx(strings.TrimPrefix(line, " ")); ok {
+					info.name = path.Base(p)
+					return info, nil
+				}
+			}
+		}
+		return nil, &os.PathError{Op: "stat", Path: p, Err: fmt.Errorf("malformed MLST reply: %s", msg)}
+	}
+
+	// Without MLST, find the entry in its parent's listing
+	entries, err := c.ReadDir(path.Dir(p))
+	if err != nil {
+		return nil, pathError("stat", p, err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == path.Base(p) {
+			return entry, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+}
+
+func (c *ftpsConn) ReadLink(p string) (string, error) {
+	info, err := c.Lstat(p)
+	if err != nil {
+		return "", err
+	}
+	fi, ok := info.(*remoteFileInfo)
+	if !ok || fi.linkTarget == "" {
+		return "", &os.PathError{Op: "readlink", Path: p, Err: errors.ErrUnsupported}
+	}
+	return fi.linkTarget, nil
+}
+
+func (c *ftpsConn) ReadDir(dir string) ([]os.FileInfo, error) {
+	command, parse := "LIST", parseLIST
+	if c.mlst {
+		command, parse = "MLSD", parseMLSx
+	}
+
+	c.mu.Lock()
+	data, err := c.transfer("%s %s", command, dir)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, pathError("readdir", dir, err)
+	}
+	stream := &dataStream{Conn: data, c: c}
+	listing, err := io.ReadAll(stream)
+	if closeErr := stream.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, pathError("readdir", dir, err)
+	}
+
+	var entries []os.FileInfo
+	for _, line := range strings.Split(string(listing), "\n") {
+		info, ok := parse(strings.TrimRight(line, "\r"))
+		if ok && info.name != "." && info.name != ".." {
+			entries = append(entries, info)
+		}
+	}
+	return entries, nil
+}
+
+// Chmod uses SITE CHMOD, which many servers do not implement.
+func (c *ftpsConn) Chmod(p string, mode os.FileMode) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	code, err := c.cmd(200, "SITE CHMOD %04o %s", mode.Perm(), p)
+	if code == 500 || code == 502 || code == 504 {
+		return errors.ErrUnsupported
+	}
+	if err != nil {
+		return pathError("chmod", p, err)
+	}
+	return nil
+}
+
+// Mkdir creates a directory.
+func (c *ftpsConn) Mkdir(p string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.cmd(257, "MKD %s", p); err != nil {
+		return pathError("mkdir", p, err)
+	}
+	return nil
+}
+
+// Remove deletes a file.
+func (c *ftpsConn) Remove(p string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.cmd(250, "DELE %s", p); err != nil {
+		return pathError("remove", p, err)
+	}
+	return nil
+}
+
+// Rename relies on the server's rename, which on Unix servers replaces the
+// destination atomically.
+func (c *ftpsConn) Rename(oldpath, newpath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.cmd(350, "RNFR %s", oldpath); err != nil {
+		return pathError("rename", oldpath, err)
+	}
+	if _, err := c.cmd(250, "RNTO %s", newpath); err != nil {
+		return pathError("rename", newpath, err)
+	}
+	return nil
+}
+
+func (c *ftpsConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = c.cmd(221, "QUIT")
+	return c.text.Close()
+}
+
+// =============================================================================
+// Listings
+// =============================================================================
+
+// parseMLSx parses an RFC 3659 entry: "fact=value;fact=value; name".
+// Servers that do not report unix.mode get 0644 files and 0755 directories.
+func parseMLSx(line string) (*remoteFileInfo, bool) {
+	facts, name, ok := strings.Cut(line, " ")
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	info := &remoteFileInfo{name: name, mode: 0644}
+	perm := os.FileMode(0)
+	for _, fact := range strings.Split(facts, ";") {
+		key, value, _ := strings.Cut(fact, "=")
+		switch strings.ToLower(key) {
+		case "type":
+			kind := strings.ToLower(value)
+			switch {
+			case kind == "cdir" || kind == "pdir":
+				return nil, false
+			case kind == "dir":
+				info.mode = os.ModeDir | 0755
+			case strings.HasPrefix(kind, "os.unix=slink"), strings.HasPrefix(kind, "os.unix=symlink"):
+				info.mode = os.ModeSymlink | 0777
+				if _, target, ok := strings.Cut(value, ":"); ok {
+					info.linkTarget = target
+				}
+			}
+		case "size":
+			info.size, _ = strconv.ParseInt(value, 10, 64)
+		case "modify":
+			info.modTime, _ = time.Parse("20060102150405", value[:min(len(value), 14)])
+		case "unix.mode":
+			if m, err := strconv.ParseUint(value, 8, 32); err == nil {
+				perm = os.FileMode(m).Perm()
+			}
+		}
+	}
+	if perm != 0 {
+		info.mode = info.mode&^os.ModePerm | perm
+	}
+	return info, true
+}
+
+// parseLIST parses a Unix "ls -l" style line, the de-facto LIST format:
+// "-rw-r--r-- 1 owner group 1234 Jan 02 15:04 name".
+func parseLIST(line string) (*remoteFileInfo, bool) {
+	// Skip eight fields, keeping the rest intact so names may contain spaces
+	rest := line
+	fields := make([]string, 0, 8)
+	for len(fields) < 8 {
+		rest = strings.TrimLeft(rest, " ")
+		field, tail, ok := strings.Cut(rest, " ")
+		if !ok {
+			return nil, false
+		}
+		fields = append(fields, field)
+		rest = tail
+	}
+	name := strings.TrimLeft(rest, " ")
+	if len(fields[0]) != 10 || name == "" {
+		return nil, false
+	}
+
+	info := &remoteFileInfo{name: name}
+	switch fields[0][0] {
+	case 'd':
+		info.mode = os.ModeDir
+	case 'l':
+		info.mode = os.ModeSymlink
+		info.name, info.linkTarget, _ = strings.Cut(name, " -> ")
+	case '-':
+	default:
+		info.mode = os.ModeIrregular
+	}
+	for i, c := range fields[0][1:] {
+		if c != '-' {
+			info.mode |= 1 << (8 - i)
+		}
+	}
+	info.size, _ = strconv.ParseInt(fields[4], 10, 64)
+	info.modTime = parseLISTTime(fields[5], fields[6], fields[7])
+	return info, true
+}
+
+// parseLISTTime parses "Jan 02 15:04" (within the last year) or "Jan 02 2006".
+func parseLISTTime(month, day, yearOrTime string) time.Time {
+	if strings.Contains(yearOrTime, ":") {
+		now := time.Now().UTC()
+		t, err := time.Parse("Jan 2 15:04 2006", fmt.Sprintf("%s %s %s %d", month, day, yearOrTime, now.Year()))
+		if err != nil {
+			return time.Time{}
+		}
+		if t.After(now) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t
+	}
+	t, _ := time.Parse("Jan 2 2006", fmt.Sprintf("%s %s %s", month, day, yearOrTime))
+	return t
+}
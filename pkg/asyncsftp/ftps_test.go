@@ -0,0 +1,57 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseMLSx verifies parsing of RFC 3659 listing entries.
+func TestParseMLSx(t *testing.T) {
+	info, ok := parseMLSx("type=file;size=12;modify=20250102030405.123;UNIX.mode=0640; my file.txt")
+	require.True(t, ok)
+	assert.Equal(t, "my file.txt", info.Name())
+	assert.Equal(t, int64(12), info.Size())
+	assert.Equal(t, os.FileMode(0640), info.Mode())
+	assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), info.ModTime())
+
+	info, ok = parseMLSx("type=dir;modify=20250102030405; sub")
+	require.True(t, ok)
+	assert.True(t, info.IsDir())
+
+	info, ok = parseMLSx("type=OS.unix=slink:/upload/target; link")
+	require.True(t, ok)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+	assert.Equal(t, "/upload/target", info.linkTarget)
+
+	_, ok = parseMLSx("type=cdir; .")
+	assert.False(t, ok)
+}
+
+// TestParseLIST verifies parsing of Unix-style LIST lines.
+func TestParseLIST(t *testing.T) {
+	info, ok := parseLIST("-rw-r-----    1 owner    group        1234 Jan 02  2024 my  file.txt")
+	require.True(t, ok)
+	assert.Equal(t, "my  file.txt", info.Name())
+	assert.Equal(t, int64(1234), info.Size())
+	assert.Equal(t, os.FileMode(0640), info.Mode())
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), info.ModTime())
+
+	info, ok = parseLIST("lrwxrwxrwx 1 owner group 6 Jan 02 15:04 link -> target")
+	require.True(t, ok)
+	assert.Equal(t, "link", info.Name())
+	assert.Equal(t, "target", info.linkTarget)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	_, ok = parseLIST("total 8")
+	assert.False(t, ok)
+}
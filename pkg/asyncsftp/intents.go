@@ -0,0 +1,224 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInterrupted indicates an upload whose process stopped before it
+// finished, and which did not leave the file holding its content.
+// Uploading again is safe.
+var ErrInterrupted = errors.New("upload interrupted")
+
+// intentMaxAge is how long an intent is kept for an upload nobody asks
+// about any more.
+const intentMaxAge = 24 * time.Hour
+
+// intentLog records each upload in a directory on the client's host before
+// it starts and removes the record once it finishes, so a client created
+// after the process crashed can still tell, from the server, whether an
+// upload it never saw finish took effect.
+type intentLog struct {
+	dir  string
+	host string // intents of other servers sharing dir are ignored
+}
+
+// intent is what is known of an upload before it starts. The content
+// itself is not recorded, only its hash.
+type intent struct {
+	ID          string            `json:"id"`
+	Host        string            `json:"host"`
+	Path        string            `json:"path"`
+	SHA256      string            `json:"sha256"`
+	Size        int64             `json:"size"`
+	Append      bool              `json:"append,omitempty"`
+	Transformed bool              `json:"transformed,omitempty"` // stored through middleware
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+}
+
+// newIntentLog returns the log kept in dir for host, or nil when dir is
+// empty, which disables it.
+func newIntentLog(dir, host string) (*intentLog, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%w: intent directory: %w", ErrInvalidConfig, err)
+	}
+	return &intentLog{dir: dir, host: host}, nil
+}
+
+func (l *intentLog) file(id string) string {
+	return filepath.Join(l.dir, id+".json")
+}
+
+// write records in, dropping intents left longer than intentMaxAge. A
+// failure to record is not worth failing the upload for: the upload then
+// merely cannot be recovered.
+func (l *intentLog) write(in intent) {
+	if l == nil {
+		return
+	}
+	l.prune(in.StartedAt)
+	data, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(l.file(in.ID), data, 0o600)
+}
+
+// read returns the intent recorded for id on this log's host.
+func (l *intentLog) read(id string) (intent, bool) {
+	var in intent
+	if l == nil || strings.ContainsAny(id, `/\`) {
+		return in, false
+	}
+	data, err := os.ReadFile(l.file(id))
+	if err != nil || json.Unmarshal(data, &in) != nil || in.Host != l.host {
+		return in, false
+	}
+	return in, true
+}
+
+// remove forgets the intents of ids.
+func (l *intentLog) remove(ids ...string) {
+	if l == nil {
+		return
+	}
+	for _, id := range ids {
+		_ = os.Remove(l.file(id))
+	}
+}
+
+// prune removes intents recorded more than intentMaxAge before now.
+func (l *intentLog) prune(now time.Time) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && strings.HasSuffix(entry.Name(), ".json") && now.Sub(info.ModTime()) > intentMaxAge {
+			_ = os.Remove(filepath.Join(l.dir, entry.Name()))
+		}
+	}
+}
+
+// recordIntent records the upload of content that request id asks for.
+func (c *Client) recordIntent(id, p, content string, opts UploadOptions) {
+	if c.intents == nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(content))
+	c.intents.write(intent{
+		ID:          id,
+		Host:        c.intents.host,
+		Path:        p,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(content)),
+		Append:      opts.Append,
+		Transformed: len(opts.Middleware) > 0,
+		Metadata:    opts.Metadata,
+		StartedAt:   c.clock.Now(),
+	})
+}
+
+// recover answers for an upload an earlier process started but never saw
+// finish, by checking what reached the server: the upload completed if the
+// file holds its content, or ends with it when appending. Otherwise it
+// failed with ErrInterrupted, naming any staged copy it left behind. The
+// answer is kept in the journal and the intent removed.
+func (c *Client) recover(id string) (*Operation, bool) {
+	in, ok := c.intents.read(id)
+	if !ok {
+		return nil, false
+	}
+
+	op := &Operation{
+		ID:          id,
+		Type:        OperationTypeUpload,
+		Path:        in.Path,
+		Metadata:    in.Metadata,
+		State:       StateCompleted,
+		StartedAt:   in.StartedAt,
+		CompletedAt: c.clock.Now(),
+		RequestIDs:  []string{id},
+		done:        make(chan struct{}),
+	}
+	close(op.done)
+
+	info, err := c.ReadFile(in.Path)
+	if err == nil {
+		err = in.check(info)
+	}
+	if err == nil {
+		op.Result = info
+	} else {
+		if staged := c.stagedCopies(in.Path); len(staged) > 0 {
+			err = fmt.Errorf("%w; staged copies left behind: %s", err, strings.Join(staged, ", "))
+		}
+		op.State = StateFailure
+		op.Err = fmt.Errorf("%w before %s was written: %v", ErrInterrupted, in.Path, err)
+		op.Error = op.Err.Error()
+	}
+
+	if c.journal != nil {
+		c.journal.record(op)
+	}
+	c.intents.remove(id)
+	return op, true
+}
+
+// check reports whether the file described by info holds the intended
+// content.
+func (in intent) check(info *FileInfo) error {
+	content := info.Content
+	if in.Append {
+		if int64(len(content)) < in.Size {
+			return errors.New("the appended content is missing")
+		}
+		content = content[int64(len(content))-in.Size:]
+	}
+	switch {
+	case in.Transformed:
+		return errors.New("content stored through middleware cannot be verified")
+	case info.Type != FileTypeRegular, contentSum(content) != in.SHA256:
+		return errors.New("the file does not hold the uploaded content")
+	}
+	return nil
+}
+
+// stagedCopies returns the staged siblings of p that uploads left behind.
+func (c *Client) stagedCopies(p string) []string {
+	entries, err := c.fs.ReadDir(path.Dir(p))
+	if err != nil {
+		return nil
+	}
+	var staged []string
+	prefix := path.Base(p) + ".tmp."
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) && temporaryName.MatchString(entry.Name()) {
+			staged = append(staged, path.Join(path.Dir(p), entry.Name()))
+		}
+	}
+	return staged
+}
+
+// intentHost identifies the server of cfg in intent records.
+func intentHost(cfg Config) string {
+	return cmp.Or(cfg.Host, cfg.LocalDir)
+}
@@ -0,0 +1,80 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRecoverInterruptedUpload verifies a client created after a crash
+// answers for uploads it never saw finish from what reached the server.
+func TestRecoverInterruptedUpload(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	cfg := Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1, IntentDir: t.TempDir()}
+
+	// The crashed client's only worker never gets to the upload
+	crashed, err := NewClient(cfg)
+	require.NoError(t, err)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	crashed.queue.submit(&Operation{Priority: PriorityHigh}, func() { close(started); <-release })
+	<-started
+	lost := crashed.StartUploadWithOptions("/upload/a.txt", "hello", UploadOptions{Permissions: 0644, Metadata: map[string]string{"label": "a"}})
+
+	c, err := NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op, err := c.GetStatus(lost)
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrInterrupted)
+	assert.Equal(t, "a", op.Metadata["label"])
+	_, err = c.GetStatus(lost)
+	assert.ErrorContains(t, err, "operation not found", "answered once, without a journal")
+
+	// An upload that completed before the crash
+	require.NoError(t, c.WriteFile("/upload/b.txt", []byte("hello"), 0644))
+	c.recordIntent("written", "/upload/b.txt", "hello", UploadOptions{})
+	op, err = c.GetStatus("written")
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, op.State, op.Error)
+	require.NotNil(t, op.Result)
+	assert.Equal(t, "hello", op.Result.Content)
+
+	// An append is only complete if the file ends with it
+	require.NoError(t, c.WriteFile("/upload/log.txt", []byte("one\ntwo\n"), 0644))
+	c.recordIntent("appended", "/upload/log.txt", "two\n", UploadOptions{Append: true})
+	c.recordIntent("not-appended", "/upload/log.txt", "three\n", UploadOptions{Append: true})
+	op, err = c.GetStatus("appended")
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, op.State, op.Error)
+	op, err = c.GetStatus("not-appended")
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+
+	// A staged copy left behind is named
+	staged := "/upload/b.txt.tmp.0f8fad5b-d9cb-469f-a165-70867728950e"
+	require.NoError(t, c.WriteFile(staged, []byte("changed"), 0644))
+	c.recordIntent("staged", "/upload/b.txt", "changed", UploadOptions{})
+	op, err = c.GetStatus("staged")
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+	assert.Contains(t, op.Error, staged)
+
+	// Intents of another server sharing the directory are not answered
+	other, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name() + "-other", LocalDir: "/upload", IntentDir: cfg.IntentDir})
+	require.NoError(t, err)
+	defer func() { _ = other.Close() }()
+	c.recordIntent("elsewhere", "/upload/b.txt", "hello", UploadOptions{})
+	_, err = other.GetStatus("elsewhere")
+	assert.ErrorContains(t, err, "operation not found")
+}
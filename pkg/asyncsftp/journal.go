@@ -0,0 +1,105 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed fails operations still waiting for a worker when their
+// client is closed.
+var ErrClientClosed = errors.New("client closed before the operation started")
+
+// Journal keeps finished operations answerable after the client that ran
+// them is closed, e.g. when the caller reconnects between polls. Successive
+// clients share one through Config.Journal, and GetStatus falls back to it
+// for operations the client does not know. Entries expire ttl after the
+// operation completed.
+type Journal struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	clock Clock
+	ops   map[string]*Operation // by every request ID the operation answers
+}
+
+// NewJournal returns an empty journal keeping operations for ttl (default
+// DefaultOperationTTL) on clock (default the system clock).
+func NewJournal(ttl time.Duration, clock Clock) *Journal {
+	if ttl <= 0 {
+		ttl = DefaultOperationTTL
+	}
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &Journal{ttl: ttl, clock: clock, ops: make(map[string]*Operation)}
+}
+
+// record stores a copy of the finished op under each of its request IDs and
+// forgets expired entries.
+func (j *Journal) record(op *Operation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	finished := op.Copy()
+	ids := finished.RequestIDs
+	if len(ids) == 0 {
+		ids = []string{finished.ID}
+	}
+	for _, id := range ids {
+		j.ops[id] = finished
+	}
+}
+
+// Lookup returns a copy of the operation answering requestID, if it has not
+// expired.
+func (j *Journal) Lookup(requestID string) (*Operation, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	op, ok := j.ops[requestID]
+	if !ok {
+		return nil, false
+	}
+	return op.Copy(), true
+}
+
+// Recent returns up to n of the journal's operations, most recently
+// completed first, e.g. for a support bundle.
+func (j *Journal) Recent(n int) []*Operation {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	seen := make(map[*Operation]bool, len(j.ops))
+	var ops []*Operation
+	for _, op := range j.ops {
+		if !seen[op] {
+			seen[op] = true
+			ops = append(ops, op)
+		}
+	}
+	slices.SortFunc(ops, func(a, b *Operation) int { return b.CompletedAt.Compare(a.CompletedAt) })
+	ops = ops[:min(n, len(ops))]
+	for i, op := range ops {
+		ops[i] = op.Copy()
+	}
+	return ops
+}
+
+// prune forgets entries that completed more than the TTL ago. Callers hold
+// j.mu.
+func (j *Journal) prune() {
+	now := j.clock.Now()
+	for id, op := range j.ops {
+		if now.Sub(op.CompletedAt) > j.ttl {
+			delete(j.ops, id)
+		}
+	}
+}
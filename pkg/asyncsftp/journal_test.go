@@ -0,0 +1,62 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJournal verifies a later client answers for operations an earlier,
+// closed client finished or never started, until they expire.
+func TestJournal(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	journal := NewJournal(time.Minute, clock)
+	cfg := Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1, Clock: clock, Journal: journal}
+
+	first, err := NewClient(cfg)
+	require.NoError(t, err)
+	done := waitFor(t, first, first.StartUpload("/upload/a.txt", "hello", 0644))
+	require.Equal(t, StateCompleted, done.State, done.Error)
+
+	// Occupy the only worker so the delete is still queued at close
+	release := make(chan struct{})
+	started := make(chan struct{})
+	first.queue.submit(&Operation{Priority: PriorityHigh}, func() { close(started); <-release })
+	<-started
+	queued := first.StartDelete("/upload/a.txt")
+	require.NoError(t, first.Close())
+	close(release)
+
+	second, err := NewClient(cfg)
+	require.NoError(t, err)
+	defer func() { _ = second.Close() }()
+
+	op, err := second.GetStatus(done.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, op.State)
+	require.NotNil(t, op.Result)
+	assert.Equal(t, "hello", op.Result.Content)
+
+	op, err = second.GetStatus(queued)
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+	assert.Equal(t, ErrClientClosed.Error(), op.Error)
+
+	recent := journal.Recent(5)
+	require.Len(t, recent, 2)
+	assert.ElementsMatch(t, []string{done.ID, op.ID}, []string{recent[0].ID, recent[1].ID})
+	assert.Len(t, journal.Recent(1), 1)
+
+	clock.Advance(2 * time.Minute)
+	_, err = second.GetStatus(done.ID)
+	assert.ErrorContains(t, err, "operation not found")
+	assert.Empty(t, journal.Recent(5))
+}
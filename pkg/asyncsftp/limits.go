@@ -0,0 +1,76 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrTooLarge indicates content larger than the server accepts, so the
+// upload is refused before any of it is sent.
+var ErrTooLarge = errors.New("content exceeds the server's limit")
+
+// DefaultMaxPacket is the largest SFTP packet payload written or read at
+// once unless the server is known to accept more. Every server must accept
+// 32 KiB; some appliances drop the connection on anything larger.
+const DefaultMaxPacket = 32 << 10
+
+// openSSHMaxPacket is the largest payload OpenSSH 8.7 and later accept,
+// their 256 KiB message limit less room for the header. Those versions
+// advertise limits@openssh.com.
+const openSSHMaxPacket = 255 << 10
+
+// Limits are the sizes a session keeps to.
+type Limits struct {
+	// MaxPacket is the largest SFTP packet payload; writes and reads are
+	// split into packets of at most this size. Zero for other protocols.
+	MaxPacket int
+	// MaxFileSize is the largest file the server accepts, zero when
+	// unknown. SFTP cannot ask for it, so it comes from Config.MaxFileSize.
+	MaxFileSize int64
+}
+
+// Limits reports the sizes the session keeps to.
+func (c *Client) Limits() Limits {
+	return c.limits
+}
+
+// CheckSize returns an error wrapping ErrTooLarge when a file of size bytes
+// exceeds the server's maximum file size.
+func (c *Client) CheckSize(size int64) error {
+	if c.limits.MaxFileSize > 0 && size > c.limits.MaxFileSize {
+		return fmt.Errorf("%w: %d bytes is more than the %d bytes the server accepts", ErrTooLarge, size, c.limits.MaxFileSize)
+	}
+	return nil
+}
+
+// openSFTP opens the SFTP subsystem with packets of cfg.MaxPacket bytes or,
+// when unset, the largest the server is known to accept: 32 KiB, or more
+// for OpenSSH servers advertising their limits. It returns the packet size
+// in use.
+func openSFTP(sshClient *ssh.Client, cfg Config) (*sftp.Client, int, error) {
+	if cfg.MaxPacket > 0 {
+		client, err := newSFTPClient(sshClient, cfg, sftp.MaxPacketUnchecked(cfg.MaxPacket))
+		return client, cfg.MaxPacket, err
+	}
+	client, err := newSFTPClient(sshClient, cfg)
+	if err != nil || cfg.SFTPVersion == SFTPVersion3 {
+		return client, DefaultMaxPacket, err
+	}
+	if _, ok := client.HasExtension("limits@openssh.com"); !ok {
+		return client, DefaultMaxPacket, nil
+	}
+	// The packet size is fixed when the subsystem opens, so reopen it
+	larger, err := newSFTPClient(sshClient, cfg, sftp.MaxPacketUnchecked(openSSHMaxPacket))
+	if err != nil {
+		return client, DefaultMaxPacket, nil
+	}
+	_ = client.Close()
+	return larger, openSSHMaxPacket, nil
+}
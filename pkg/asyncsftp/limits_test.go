@@ -0,0 +1,41 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaxFileSize verifies uploads beyond MaxFileSize fail with ErrTooLarge
+// without writing anything, and that smaller ones go ahead.
+func TestMaxFileSize(t *testing.T) {
+	ctx := context.Background()
+	client, err := Dial(ctx, t.Name(), WithProtocol(ProtocolMemory), WithLocalDir("/upload"), WithMaxFileSize(5))
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	assert.Equal(t, Limits{MaxFileSize: 5}, client.Limits())
+	assert.NoError(t, client.CheckSize(5))
+
+	op, err := client.Wait(ctx, client.StartUpload("/upload/big.txt", "hello world", 0644))
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrTooLarge)
+	_, err = client.Stat("/upload/big.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	op, err = client.Wait(ctx, client.StartUpload("/upload/small.txt", "hello", 0644))
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, op.State, op.Error)
+
+	_, err = Dial(ctx, t.Name(), WithProtocol(ProtocolMemory), WithMaxFileSize(-1))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
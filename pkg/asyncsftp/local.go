@@ -0,0 +1,202 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localTransport is a Transport over the agent host's own filesystem. Paths
+// are resolved beneath dir with os.Root, so they cannot escape it via ".."
+// or symlinks; with dir "/" they are ordinary absolute paths.
+type localTransport struct {
+	root *os.Root
+}
+
+var (
+	_ Transport   = (*localTransport)(nil)
+	_ dirStreamer = (*localTransport)(nil)
+	_ copier      = (*localTransport)(nil)
+	_ mkdirer     = (*localTransport)(nil)
+)
+
+func newLocalTransport(dir string) (*localTransport, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &localTransport{root: root}, nil
+}
+
+// rel converts a client path to one relative to the root.
+func rel(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// localPathError reports errors with the client path rather than the
+// root-relative one.
+func localPathError(p string, err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		pathErr.Path = p
+	}
+	return err
+}
+
+func (t *localTransport) Create(p string) (io.WriteCloser, error) {
+	f, err := t.root.Create(rel(p))
+	if err != nil {
+		return nil, localPathError(p, err)
+	}
+	return f, nil
+}
+
+func (t *localTransport) Append(p string) (io.WriteCloser, error) {
+	f, err := t.root.OpenFile(rel(p), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, localPathError(p, err)
+	}
+	return f, nil
+}
+
+func (t *localTransport) Open(p string) (io.ReadCloser, error) {
+	f, err := t.root.Open(rel(p))
+	if err != nil {
+		return nil, localPathError(p, err)
+	}
+	return f, nil
+}
+
+func (t *localTransport) Lstat(p string) (os.FileInfo, error) {
+	info, err := t.root.Lstat(rel(p))
+	return info, localPathError(p, err)
+}
+
+func (t *localTransport) ReadLink(p string) (string, error) {
+	target, err := t.root.Readlink(rel(p))
+	return target, localPathError(p, err)
+}
+
+// RealPath resolves symlinks beneath the root. A path whose symlinks lead
+// out of the root is refused with os.ErrPermission, as every other
+// operation on it would be.
+func (t *localTransport) RealPath(p string) (string, error) {
+	base, err := filepath.EvalSymlinks(t.root.Name())
+	if err != nil {
+		return "", localPathError(p, err)
+	}
+	// Joined without cleaning, so ".." after a symlink leaves its target
+	resolved, err := filepath.EvalSymlinks(base + string(filepath.Separator) + filepath.FromSlash(strings.TrimPrefix(p, "/")))
+	if err != nil {
+		return "", localPathError(p, err)
+	}
+	r, err := filepath.Rel(base, resolved)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", &os.PathError{Op: "realpath", Path: p, Err: os.ErrPermission}
+	}
+	return path.Join("/", filepath.ToSlash(r)), nil
+}
+
+func (t *localTransport) ReadDir(dir string) ([]os.FileInfo, error) {
+	f, err := t.root.Open(rel(dir))
+	if err != nil {
+		return nil, localPathError(dir, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	entries, err := f.Readdir(-1)
+	return entries, localPathError(dir, err)
+}
+
+// readDirBatch is how many entries ReadDirFunc reads from the kernel at a
+// time.
+const readDirBatch = 256
+
+// ReadDirFunc reads dir readDirBatch entries at a time, stopping as soon as
+// fn returns false.
+func (t *localTransport) ReadDirFunc(dir string, fn func(os.FileInfo) bool) error {
+	f, err := t.root.Open(rel(dir))
+	if err != nil {
+		return localPathError(dir, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	for {
+		entries, err := f.Readdir(readDirBatch)
+		for _, entry := range entries {
+			if !fn(entry) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return localPathError(dir, err)
+		}
+	}
+}
+
+func (t *localTransport) Chmod(p string, mode os.FileMode) error {
+	return localPathError(p, t.root.Chmod(rel(p), mode))
+}
+
+func (t *localTransport) Chown(p string, uid, gid int) error {
+	return localPathError(p, t.root.Lchown(rel(p), uid, gid))
+}
+
+// StatVFS opens p beneath the root and reports its filesystem's capacity.
+func (t *localTransport) StatVFS(p string) (*DiskUsage, error) {
+	f, err := t.root.Open(rel(p))
+	if err != nil {
+		return nil, localPathError(p, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	usage, err := statfs(f)
+	return usage, localPathError(p, err)
+}
+
+func (t *localTransport) Mkdir(p string) error {
+	return localPathError(p, t.root.Mkdir(rel(p), 0755))
+}
+
+func (t *localTransport) Remove(p string) error {
+	return localPathError(p, t.root.Remove(rel(p)))
+}
+
+// Copy replaces dst with a copy of src.
+func (t *localTransport) Copy(src, dst string) error {
+	in, err := t.root.Open(rel(src))
+	if err != nil {
+		return localPathError(src, err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := t.root.Create(rel(dst))
+	if err != nil {
+		return localPathError(dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Rename replaces newpath atomically (rename(2)).
+func (t *localTransport) Rename(oldpath, newpath string) error {
+	return localPathError(oldpath, t.root.Rename(rel(oldpath), rel(newpath)))
+}
+
+func (t *localTransport) Close() error {
+	return t.root.Close()
+}
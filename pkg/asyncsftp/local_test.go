@@ -0,0 +1,169 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitFor polls an operation until it leaves the in-progress state.
+func waitFor(t *testing.T, c *Client, opID string) *Operation {
+	t.Helper()
+	var op *Operation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = c.GetStatus(opID)
+		require.NoError(t, err)
+		return op.State != StateInProgress
+	}, 5*time.Second, 10*time.Millisecond)
+	return op
+}
+
+// TestLocalClient exercises the shared client layer over the local transport.
+func TestLocalClient(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: t.TempDir()})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	assert.Equal(t, ProtocolInfo{Protocol: ProtocolFile}, c.ProtocolInfo())
+
+	_, err = NewClient(Config{Protocol: ProtocolFile, LocalDir: t.TempDir(), SFTPVersion: 4})
+	assert.Error(t, err, "only SFTP version 3 can be forced")
+
+	op := waitFor(t, c, c.StartUpload("/a.txt", "hello", 0640))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, "0640", op.Result.Permissions)
+	assert.Equal(t, int64(5), op.Result.Size)
+
+	require.NoError(t, c.WriteFile("/sub/../b.txt", []byte("world"), 0600))
+
+	info, err := c.ReadFile("/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "world", info.Content)
+	assert.Equal(t, "0600", info.Permissions)
+
+	paths, err := c.ListFiles("/")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/a.txt", "/b.txt"}, paths)
+
+	var visited int
+	require.NoError(t, c.WalkFiles("/", func(*FileInfo) bool {
+		visited++
+		return false
+	}))
+	assert.Equal(t, 1, visited, "walk stops when the callback does")
+
+	op = waitFor(t, c, c.StartDelete("/a.txt"))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	_, err = c.Stat("/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Stat("/../../etc/passwd")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	usage, err := c.DiskUsage("/")
+	require.NoError(t, err)
+	assert.NotZero(t, usage.TotalBytes)
+	assert.LessOrEqual(t, usage.AvailableBytes, usage.FreeBytes)
+
+	_, err = c.DiskUsage("/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestAppendUpload verifies append mode adds content once, after a newline
+// the file lacked, and creates missing files.
+func TestAppendUpload(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: t.TempDir()})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.WriteFile("/drop.csv", []byte("1,other"), 0644))
+
+	opts := UploadOptions{Permissions: 0644, Append: true}
+	for range 2 {
+		op := waitFor(t, c, c.StartUploadWithOptions("/drop.csv", "2,formae\n", opts))
+		require.Equal(t, StateCompleted, op.State, op.Error)
+		assert.Equal(t, "2,formae\n", op.Result.Content)
+		assert.Equal(t, int64(17), op.Result.Size)
+	}
+	info, err := c.ReadFile("/drop.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "1,other\n2,formae\n", info.Content)
+
+	op := waitFor(t, c, c.StartUploadWithOptions("/new.csv", "1,formae\n", opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	info, err = c.ReadFile("/new.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "1,formae\n", info.Content)
+}
+
+// TestSweepTemporary verifies only temporary files older than the minimum
+// age are removed, at any depth.
+func TestSweepTemporary(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: dir})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{
+		"app.conf.tmp.0d6f4c1e-7b7a-4d8e-9a51-3c2f1b0e9d77",
+		"sub/.formae-clock-5a0e2b3c-1d4f-4e6a-8b7c-9d0e1f2a3b4c",
+		"fresh.tmp.9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+		"notes.tmp.txt",
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, nil, 0644))
+		if !strings.HasPrefix(name, "fresh") {
+			require.NoError(t, os.Chtimes(p, old, old))
+		}
+	}
+
+	removed, err := c.SweepTemporary("/", time.Hour)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"/app.conf.tmp.0d6f4c1e-7b7a-4d8e-9a51-3c2f1b0e9d77",
+		"/sub/.formae-clock-5a0e2b3c-1d4f-4e6a-8b7c-9d0e1f2a3b4c",
+	}, removed)
+	paths, err := c.ListFiles("/")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/fresh.tmp.9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d", "/notes.tmp.txt"}, paths)
+}
+
+// TestReadRange verifies ranges are read from seekable local files and
+// streamed ones alike, and cut short at the end of the file.
+func TestReadRange(t *testing.T) {
+	local, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: t.TempDir()})
+	require.NoError(t, err)
+	defer func() { _ = local.Close() }()
+	memory, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = memory.Close() }()
+
+	for _, c := range []*Client{local, memory} {
+		require.NoError(t, c.WriteFile("/data.bin", []byte("0123456789"), 0644))
+
+		data, err := c.ReadRange("/data.bin", 2, 3)
+		require.NoError(t, err)
+		assert.Equal(t, "234", string(data))
+		data, err = c.ReadRange("/data.bin", 8, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "89", string(data))
+		data, err = c.ReadRange("/data.bin", 20, 5)
+		require.NoError(t, err)
+		assert.Empty(t, data)
+
+		_, err = c.ReadRange("/missing.bin", 0, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+}
@@ -0,0 +1,478 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// memoryStores holds every in-memory filesystem by name, so clients for the
+// same memory:// target share files for the life of the process.
+var (
+	memoryStoresMu sync.Mutex
+	memoryStores   = map[string]*memoryFS{}
+)
+
+// memoryFS is a Transport backed by an in-process filesystem, for hermetic
+// tests. It follows SFTP semantics: parent directories must exist,
+// removing a non-empty directory fails, a directory without read
+// permission cannot be listed, and opening a path follows its symlinks.
+type memoryFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memoryNode // keyed by clean absolute path
+}
+
+type memoryNode struct {
+	mode    os.FileMode
+	data    []byte // a symlink's target
+	modTime time.Time
+	owner   FileOwner
+}
+
+// memoryMaxLinks is how many symlinks resolving one path may follow before
+// it is taken for a loop, as Linux's MAXSYMLINKS.
+const memoryMaxLinks = 40
+
+var (
+	_ Transport   = (*memoryFS)(nil)
+	_ copier      = (*memoryFS)(nil)
+	_ checksummer = (*memoryFS)(nil)
+	_ mkdirer     = (*memoryFS)(nil)
+)
+
+// memoryStore returns the named filesystem, creating it with dirs (and their
+// parents) on first use.
+func memoryStore(name string, dirs ...string) *memoryFS {
+	memoryStoresMu.Lock()
+	defer memoryStoresMu.Unlock()
+
+	m, ok := memoryStores[name]
+	if !ok {
+		m = &memoryFS{nodes: map[string]*memoryNode{
+			"/": {mode: os.ModeDir | 0755, modTime: time.Now()},
+		}}
+		memoryStores[name] = m
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, dir := range dirs {
+		for p := memoryPath(dir); m.nodes[p] == nil; p = path.Dir(p) {
+			m.nodes[p] = &memoryNode{mode: os.ModeDir | 0755, modTime: time.Now()}
+		}
+	}
+	return m
+}
+
+// ResetMemory discards the named memory:// filesystem, so the next client
+// for it starts empty. Clients already open keep the files they had. Tests
+// reusing a name across runs reset it from t.Cleanup.
+func ResetMemory(name string) {
+	memoryStoresMu.Lock()
+	defer memoryStoresMu.Unlock()
+
+	delete(memoryStores, name)
+}
+
+// MemorySymlink creates p in the named memory:// filesystem as a symlink to
+// target, which may be relative to p's directory. Clients cannot create
+// symlinks, so tests of symlink handling make them here.
+func MemorySymlink(name, target, p string) error {
+	m := memoryStore(name)
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkParent("symlink", p); err != nil {
+		return err
+	}
+	if m.nodes[p] != nil {
+		return &os.PathError{Op: "symlink", Path: p, Err: os.ErrExist}
+	}
+	m.nodes[p] = &memoryNode{mode: os.ModeSymlink | 0o777, data: []byte(target), modTime: time.Now()}
+	return nil
+}
+
+// memoryPath normalizes p to the store's clean absolute key.
+func memoryPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func notExist(op, p string) error {
+	return &os.PathError{Op: op, Path: p, Err: os.ErrNotExist}
+}
+
+// resolve follows the symlinks in every component of p, as a server does
+// when opening it. The caller holds m.mu.
+func (m *memoryFS) resolve(op, p string) (string, error) {
+	links := 0
+walk:
+	for {
+		done, parts := "/", strings.Split(memoryPath(p), "/")[1:]
+		for i, part := range parts {
+			next := path.Join(done, part)
+			node := m.nodes[next]
+			if node == nil || node.mode&os.ModeSymlink == 0 {
+				done = next
+				continue
+			}
+			if links++; links > memoryMaxLinks {
+				return "", &os.PathError{Op: op, Path: p, Err: syscall.ELOOP}
+			}
+			target := string(node.data)
+			if !path.IsAbs(target) {
+				target = path.Join(done, target)
+			}
+			p = path.Join(append([]string{target}, parts[i+1:]...)...)
+			continue walk
+		}
+		return done, nil
+	}
+}
+
+// checkParent verifies p's parent is an existing directory. The caller holds m.mu.
+func (m *memoryFS) checkParent(op, p string) error {
+	parent := m.nodes[path.Dir(p)]
+	if parent == nil {
+		return notExist(op, p)
+	}
+	if !parent.mode.IsDir() {
+		return &os.PathError{Op: op, Path: p, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// memoryWriter buffers content and commits it to the file on Close,
+// replacing or, for Append, extending the file's data.
+type memoryWriter struct {
+	bytes.Buffer
+	m         *memoryFS
+	path      string
+	appending bool
+}
+
+func (w *memoryWriter) Close() error {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+
+	if err := w.m.checkParent("create", w.path); err != nil {
+		return err
+	}
+	node := w.m.nodes[w.path]
+	if node == nil {
+		node = &memoryNode{mode: 0644}
+		w.m.nodes[w.path] = node
+	}
+	if w.appending {
+		node.data = append(node.data, w.Bytes()...)
+	} else {
+		node.data = slices.Clone(w.Bytes())
+	}
+	node.modTime = time.Now()
+	return nil
+}
+
+func (m *memoryFS) Create(p string) (io.WriteCloser, error) {
+	return m.openWriter("create", p, false)
+}
+
+func (m *memoryFS) Append(p string) (io.WriteCloser, error) {
+	return m.openWriter("append", p, true)
+}
+
+func (m *memoryFS) openWriter(op, p string, appending bool) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.resolve(op, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkParent(op, p); err != nil {
+		return nil, err
+	}
+	if node := m.nodes[p]; node != nil && node.mode.IsDir() {
+		return nil, &os.PathError{Op: op, Path: p, Err: syscall.EISDIR}
+	}
+	return &memoryWriter{m: m, path: p, appending: appending}, nil
+}
+
+func (m *memoryFS) Open(p string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.resolve("open", p)
+	if err != nil {
+		return nil, err
+	}
+	node := m.nodes[p]
+	if node == nil {
+		return nil, notExist("open", p)
+	}
+	return io.NopCloser(bytes.NewReader(slices.Clone(node.data))), nil
+}
+
+func (m *memoryFS) Lstat(p string) (os.FileInfo, error) {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[p]
+	if node == nil {
+		return nil, notExist("stat", p)
+	}
+	return node.info(p), nil
+}
+
+func (n *memoryNode) info(p string) *remoteFileInfo {
+	return &remoteFileInfo{
+		name:    path.Base(p),
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+		owner:   &FileOwner{UID: n.owner.UID, GID: n.owner.GID},
+	}
+}
+
+// RealPath cleans p and resolves its symlinks.
+func (m *memoryFS) RealPath(p string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.resolve("realpath", p)
+	if err != nil {
+		return "", err
+	}
+	if m.nodes[key] == nil {
+		return "", notExist("realpath", p)
+	}
+	return key, nil
+}
+
+// ReadLink returns the target of the symlink p.
+func (m *memoryFS) ReadLink(p string) (string, error) {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[p]
+	if node == nil {
+		return "", notExist("readlink", p)
+	}
+	if node.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: p, Err: syscall.EINVAL}
+	}
+	return string(node.data), nil
+}
+
+func (m *memoryFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, err := m.resolve("readdir", dir)
+	if err != nil {
+		return nil, err
+	}
+	node := m.nodes[dir]
+	if node == nil {
+		return nil, notExist("readdir", dir)
+	}
+	if !node.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+	if node.mode.Perm()&0o444 == 0 {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: os.ErrPermission}
+	}
+
+	var entries []os.FileInfo
+	for p, child := range m.nodes {
+		if p != "/" && path.Dir(p) == dir {
+			entries = append(entries, child.info(p))
+		}
+	}
+	slices.SortFunc(entries, func(a, b os.FileInfo) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (m *memoryFS) Chmod(p string, mode os.FileMode) error {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[p]
+	if node == nil {
+		return notExist("chmod", p)
+	}
+	node.mode = node.mode&^os.ModePerm | mode.Perm()
+	return nil
+}
+
+func (m *memoryFS) Chown(p string, uid, gid int) error {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[p]
+	if node == nil {
+		return notExist("chown", p)
+	}
+	node.owner = FileOwner{UID: uid, GID: gid}
+	return nil
+}
+
+func (m *memoryFS) Mkdir(p string) error {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkParent("mkdir", p); err != nil {
+		return err
+	}
+	if m.nodes[p] != nil {
+		return &os.PathError{Op: "mkdir", Path: p, Err: os.ErrExist}
+	}
+	m.nodes[p] = &memoryNode{mode: os.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+// Nominal capacity reported for in-memory stores, which have no real limit.
+const (
+	memoryCapacity = 1 << 30
+	memoryMaxFiles = 1 << 20
+)
+
+// StatVFS reports usage against a nominal 1 GiB, 1M-file capacity.
+func (m *memoryFS) StatVFS(p string) (*DiskUsage, error) {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nodes[p] == nil {
+		return nil, notExist("statvfs", p)
+	}
+	var used uint64
+	for _, node := range m.nodes {
+		used += uint64(len(node.data))
+	}
+	free := uint64(memoryCapacity) - min(used, memoryCapacity)
+	return &DiskUsage{
+		TotalBytes:     memoryCapacity,
+		FreeBytes:      free,
+		AvailableBytes: free,
+		TotalFiles:     memoryMaxFiles,
+		FreeFiles:      memoryMaxFiles - uint64(len(m.nodes)),
+	}, nil
+}
+
+func (m *memoryFS) Remove(p string) error {
+	p = memoryPath(p)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[p]
+	if node == nil {
+		return notExist("remove", p)
+	}
+	if node.mode.IsDir() {
+		for other := range m.nodes {
+			if other != p && path.Dir(other) == p {
+				return &os.PathError{Op: "remove", Path: p, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// Copy replaces dst with a copy of the regular file src.
+func (m *memoryFS) Copy(src, dst string) error {
+	src, dst = memoryPath(src), memoryPath(dst)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[src]
+	if node == nil {
+		return notExist("copy", src)
+	}
+	if !node.mode.IsRegular() {
+		return &os.PathError{Op: "copy", Path: src, Err: syscall.EINVAL}
+	}
+	if err := m.checkParent("copy", dst); err != nil {
+		return err
+	}
+	if old := m.nodes[dst]; old != nil && old.mode.IsDir() {
+		return &os.PathError{Op: "copy", Path: dst, Err: syscall.EISDIR}
+	}
+	m.nodes[dst] = &memoryNode{mode: node.mode, data: slices.Clone(node.data), modTime: time.Now()}
+	return nil
+}
+
+// Checksum hashes the regular file p in place.
+func (m *memoryFS) Checksum(p string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.resolve("checksum", p)
+	if err != nil {
+		return "", err
+	}
+	node := m.nodes[p]
+	if node == nil {
+		return "", notExist("checksum", p)
+	}
+	if !node.mode.IsRegular() {
+		return "", &os.PathError{Op: "checksum", Path: p, Err: syscall.EINVAL}
+	}
+	sum := sha256.Sum256(node.data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Rename replaces newpath, like posix-rename. Directories cannot be renamed.
+func (m *memoryFS) Rename(oldpath, newpath string) error {
+	oldpath, newpath = memoryPath(oldpath), memoryPath(newpath)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.nodes[oldpath]
+	if node == nil {
+		return notExist("rename", oldpath)
+	}
+	if err := m.checkParent("rename", newpath); err != nil {
+		return err
+	}
+	if node.mode.IsDir() {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: syscall.EISDIR}
+	}
+	if dst := m.nodes[newpath]; dst != nil && dst.mode.IsDir() {
+		return &os.PathError{Op: "rename", Path: newpath, Err: syscall.EISDIR}
+	}
+	delete(m.nodes, oldpath)
+	m.nodes[newpath] = node
+	return nil
+}
+
+// Close is a no-op: the files outlive the client.
+func (m *memoryFS) Close() error {
+	return nil
+}
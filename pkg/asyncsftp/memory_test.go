@@ -0,0 +1,443 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryClient verifies the in-memory store follows SFTP semantics and is
+// shared between clients for the same store name.
+func TestMemoryClient(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload/sub"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op := waitFor(t, c, c.StartUpload("/upload/a.txt", "hello", 0600))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, "0600", op.Result.Permissions)
+
+	op = waitFor(t, c, c.StartUpload("/missing/a.txt", "hello", 0600))
+	assert.Equal(t, StateFailure, op.State)
+
+	require.NoError(t, c.WriteFile("/upload/sub/b.txt", []byte("world"), 0644))
+
+	other, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name()})
+	require.NoError(t, err)
+	paths, _, err := other.ListTree("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/a.txt", "/upload/sub/b.txt"}, paths)
+
+	info, err := other.ReadFile("/upload/sub/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "world", info.Content)
+
+	op = waitFor(t, c, c.StartDelete("/upload/a.txt"))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	_, err = other.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestValidatedUpload verifies a validated upload that cannot pass leaves
+// the live file untouched and removes its staged copy.
+func TestValidatedUpload(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op := waitFor(t, c, c.StartUpload("/upload/app.conf", "v1", 0644))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+
+	// The memory store has no SSH exec, so the command can never run
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/app.conf", "v2", UploadOptions{Permissions: 0644, ValidateCommand: "true"}))
+	assert.Equal(t, StateFailure, op.State)
+	assert.Contains(t, op.Error, ErrExecUnavailable.Error())
+
+	info, err := c.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", info.Content)
+	paths, _, err := c.ListTree("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/app.conf"}, paths)
+}
+
+// TestMeasureClockSkew verifies the skew probe sees no skew on a store that
+// shares the local clock, and cleans up after itself.
+func TestMeasureClockSkew(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	skew, err := c.MeasureClockSkew("/upload")
+	require.NoError(t, err)
+	assert.Less(t, skew.Abs(), time.Second)
+
+	paths, err := c.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = c.MeasureClockSkew("/missing")
+	assert.Error(t, err)
+}
+
+// TestSessionStats verifies operations, failures and bytes moved are counted.
+func TestSessionStats(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	waitFor(t, c, c.StartUpload("/upload/a.txt", "hello", 0644))
+	waitFor(t, c, c.StartUpload("/missing/a.txt", "hello", 0644))
+	_, err = c.ReadFile("/upload/a.txt")
+	require.NoError(t, err)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(2), stats.Operations)
+	assert.Equal(t, int64(1), stats.Failures)
+	assert.Equal(t, int64(5), stats.BytesUploaded)
+	assert.Equal(t, int64(5), stats.BytesDownloaded)
+}
+
+// TestUploadIdempotency verifies a retried upload attaches to the identical
+// upload still in progress, while different requests and later retries start
+// their own operations.
+func TestUploadIdempotency(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	// Occupy the only worker so uploads stay in progress
+	release := make(chan struct{})
+	c.queue.submit(&Operation{Priority: PriorityHigh}, func() { <-release })
+
+	first := c.StartUpload("/upload/a.txt", "hello", 0644)
+	retry := c.StartUpload("/upload/a.txt", "hello", 0644)
+	assert.NotEqual(t, first, retry, "each request gets its own ID")
+	attached, err := c.GetStatus(retry)
+	require.NoError(t, err)
+	assert.Equal(t, first, attached.ID)
+	assert.Equal(t, []string{first, retry}, attached.RequestIDs)
+	assert.NotEqual(t, first, c.StartUpload("/upload/a.txt", "world", 0644))
+	assert.NotEqual(t, first, c.StartUpload("/upload/a.txt", "hello", 0600))
+	close(release)
+
+	op := waitFor(t, c, first)
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.NotEqual(t, first, c.StartUpload("/upload/a.txt", "hello", 0644))
+}
+
+// TestUploadIdempotencyOptions verifies uploads of the same content to the
+// same path only attach to each other when every option that changes the
+// result matches.
+func TestUploadIdempotencyOptions(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	release := make(chan struct{})
+	c.queue.submit(&Operation{Priority: PriorityHigh}, func() { <-release })
+	defer close(release)
+
+	base := func() UploadOptions {
+		return UploadOptions{Permissions: 0644, Sidecar: &Sidecar{Algorithm: HashSHA256}, ParentOwner: &FileOwner{UID: 1000, GID: 1000}}
+	}
+	attachedTo := func(opts UploadOptions) string {
+		op, err := c.GetStatus(c.StartUploadWithOptions("/upload/a.txt", "hello", opts))
+		require.NoError(t, err)
+		return op.ID
+	}
+	first := attachedTo(base())
+
+	same := base()
+	same.Priority, same.Metadata = PriorityLow, map[string]string{"label": "retry"}
+	assert.Equal(t, first, attachedTo(same), "priority and metadata do not change the result")
+
+	variants := map[string]func(*UploadOptions){
+		"xattrs":            func(o *UploadOptions) { o.Xattrs = map[string]string{"user.owner": "ops"} },
+		"acl":               func(o *UploadOptions) { o.ACL = []string{"u:deploy:rw-"} },
+		"selinuxContext":    func(o *UploadOptions) { o.SELinuxContext = "system_u:object_r:httpd_sys_content_t:s0" },
+		"validateCommand":   func(o *UploadOptions) { o.ValidateCommand = "true" },
+		"verify":            func(o *UploadOptions) { o.Verify = true },
+		"verifyHash":        func(o *UploadOptions) { o.VerifyHash = HashSHA512 },
+		"sidecar":           func(o *UploadOptions) { o.Sidecar = &Sidecar{Algorithm: HashSHA512} },
+		"sidecarFormat":     func(o *UploadOptions) { o.Sidecar.Format = SidecarBSD },
+		"noSidecar":         func(o *UploadOptions) { o.Sidecar = nil },
+		"quarantineDir":     func(o *UploadOptions) { o.QuarantineDir = "/upload/.quarantine" },
+		"cacheDir":          func(o *UploadOptions) { o.CacheDir = "/upload/.cache" },
+		"middleware":        func(o *UploadOptions) { o.Middleware = []Middleware{Gzip()} },
+		"append":            func(o *UploadOptions) { o.Append = true },
+		"createParents":     func(o *UploadOptions) { o.CreateParents = true },
+		"parentPermissions": func(o *UploadOptions) { o.ParentPermissions = 0700 },
+		"parentOwner":       func(o *UploadOptions) { o.ParentOwner = &FileOwner{UID: 0, GID: 0} },
+		"permissions":       func(o *UploadOptions) { o.Permissions = 0600 },
+	}
+	for name, vary := range variants {
+		opts := base()
+		vary(&opts)
+		assert.NotEqual(t, first, attachedTo(opts), name)
+	}
+}
+
+// failingFS is a Transport whose writes fail once limit bytes are written.
+type failingFS struct {
+	Transport
+	limit int
+}
+
+func (f failingFS) Create(p string) (io.WriteCloser, error) {
+	w, err := f.Transport.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	return &failingWriter{WriteCloser: w, left: f.limit}, nil
+}
+
+type failingWriter struct {
+	io.WriteCloser
+	left int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.left {
+		n, _ := w.WriteCloser.Write(p[:w.left])
+		w.left = 0
+		return n, errors.New("connection lost")
+	}
+	w.left -= len(p)
+	return w.WriteCloser.Write(p)
+}
+
+// TestPartialWriteError verifies a failed write reports how far it got and
+// whether the file was left incomplete.
+func TestPartialWriteError(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	c.fs = failingFS{Transport: c.fs, limit: fairShareChunk + 10}
+
+	content := strings.Repeat("x", 2*fairShareChunk)
+	op := waitFor(t, c, c.StartUpload("/upload/big.bin", content, 0644))
+	require.Equal(t, StateFailure, op.State)
+	assert.Equal(t, fmt.Sprintf("write failed after %d of %d bytes, a partial file may remain at /upload/big.bin: connection lost",
+		fairShareChunk+10, len(content)), op.Error)
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/staged.bin", content, UploadOptions{Permissions: 0644, ValidateCommand: "true"}))
+	require.Equal(t, StateFailure, op.State)
+	assert.Contains(t, op.Error, "no partial file remains")
+}
+
+// asciiFS is a Transport that rewrites line endings on write, like an FTP
+// server transferring in ASCII mode.
+type asciiFS struct {
+	Transport
+}
+
+func (f asciiFS) Create(p string) (io.WriteCloser, error) {
+	w, err := f.Transport.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	return asciiWriter{w}, nil
+}
+
+type asciiWriter struct {
+	io.WriteCloser
+}
+
+func (w asciiWriter) Write(p []byte) (int, error) {
+	if _, err := w.WriteCloser.Write([]byte(strings.ReplaceAll(string(p), "\n", "\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// TestQuarantine verifies an upload that reads back differently never
+// replaces the live file, and is moved into the quarantine directory when
+// one is configured.
+func TestQuarantine(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload/.quarantine"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.WriteFile("/upload/app.conf", []byte("v1\n"), 0644))
+	c.fs = asciiFS{Transport: c.fs}
+
+	op := waitFor(t, c, c.StartUploadWithOptions("/upload/app.conf", "v2\n", UploadOptions{Permissions: 0644, Verify: true}))
+	require.Equal(t, StateFailure, op.State)
+	assert.Contains(t, op.Error, ErrChecksumMismatch.Error())
+	paths, _, err := c.ListTree("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/app.conf"}, paths, "the staged copy is removed")
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/app.conf", "v3\n", UploadOptions{Permissions: 0644, Verify: true, QuarantineDir: "/upload/.quarantine"}))
+	require.Equal(t, StateFailure, op.State)
+	quarantined, err := c.ListFiles("/upload/.quarantine")
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	assert.Regexp(t, `^/upload/\.quarantine/app\.conf\.\d{8}T\d{6}\.\d{3}Z$`, quarantined[0])
+	assert.Contains(t, op.Error, "rejected file quarantined at "+quarantined[0])
+
+	info, err := c.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "v1\n", info.Content)
+	info, err = c.ReadFile(quarantined[0])
+	require.NoError(t, err)
+	assert.Equal(t, "v3\r\n", info.Content)
+}
+
+// TestVerifyHash verifies uploads are verified with the algorithm they ask
+// for, and that an unknown one is refused before anything is written.
+func TestVerifyHash(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	for _, alg := range []HashAlgorithm{"", HashSHA256, HashSHA512, HashXXHash} {
+		op := waitFor(t, c, c.StartUploadWithOptions("/upload/ok.txt", "hello\n", UploadOptions{Permissions: 0644, Verify: true, VerifyHash: alg}))
+		assert.Equal(t, StateCompleted, op.State, op.Error)
+	}
+
+	sum, err := HashXXHash.Sum([]byte("hello\n"))
+	require.NoError(t, err)
+	assert.Len(t, sum, 16)
+	sum, err = HashSHA512.Sum([]byte("hello\n"))
+	require.NoError(t, err)
+	assert.Len(t, sum, 128)
+
+	c.fs = asciiFS{Transport: c.fs}
+	op := waitFor(t, c, c.StartUploadWithOptions("/upload/app.conf", "v2\n", UploadOptions{Permissions: 0644, Verify: true, VerifyHash: HashXXHash}))
+	require.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrChecksumMismatch)
+	assert.Contains(t, op.Error, "wrote xxhash ")
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/app.conf", "v2\n", UploadOptions{Permissions: 0644, Verify: true, VerifyHash: "crc32"}))
+	require.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrInvalidConfig)
+	_, err = c.Stat("/upload/app.conf")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestArtifactCache verifies a large upload is added to the cache and later
+// uploads of the same content are copied from it, while an entry that no
+// longer matches its name is uploaded over.
+func TestArtifactCache(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload/.cache"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	content := strings.Repeat("x", cacheMinSize)
+	opts := UploadOptions{Permissions: 0644, CacheDir: "/upload/.cache"}
+	op := waitFor(t, c, c.StartUploadWithOptions("/upload/a.bin", content, opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.False(t, op.CacheHit)
+	entries, err := c.ListFiles("/upload/.cache")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/.cache/" + contentSum(content)}, entries)
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/b.bin", content, opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.True(t, op.CacheHit)
+	assert.Equal(t, int64(len(content)), c.Stats().BytesUploaded, "the second upload was not sent")
+	info, err := c.ReadFile("/upload/b.bin")
+	require.NoError(t, err)
+	assert.Equal(t, content, info.Content)
+
+	require.NoError(t, c.WriteFile(entries[0], []byte(strings.Repeat("y", cacheMinSize)), 0644))
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/c.bin", content, opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.False(t, op.CacheHit)
+	info, err = c.ReadFile("/upload/c.bin")
+	require.NoError(t, err)
+	assert.Equal(t, content, info.Content)
+	info, err = c.ReadFile(entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, content, info.Content, "the corrupt entry was replaced")
+}
+
+// readOnlyFS is a Transport whose writes fail the way OpenSSH reports
+// EROFS, with a generic failure, and whose statvfs shows a read-only mount.
+type readOnlyFS struct {
+	Transport
+}
+
+func (f readOnlyFS) Create(string) (io.WriteCloser, error) {
+	return nil, &sftp.StatusError{Code: sshFxFailure}
+}
+
+func (f readOnlyFS) StatVFS(p string) (*DiskUsage, error) {
+	usage, err := f.Transport.(statvfser).StatVFS(p)
+	if err != nil {
+		return nil, err
+	}
+	usage.ReadOnly = true
+	return usage, nil
+}
+
+// TestReadOnly verifies writes to a read-only filesystem fail with
+// ErrReadOnly, whether the server says so or only statvfs does, while other
+// failures are left alone.
+func TestReadOnly(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	memory := c.fs
+
+	c.fs = readOnlyFS{Transport: memory}
+	op := waitFor(t, c, c.StartUpload("/upload/a.txt", "hello", 0644))
+	require.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrReadOnly)
+	assert.Contains(t, op.Error, "filesystem is read-only: create failed")
+
+	c.fs = memory
+	assert.ErrorIs(t, c.readOnly("/upload/a.txt", &os.PathError{Op: "open", Path: "/upload/a.txt", Err: syscall.EROFS}), ErrReadOnly)
+	assert.ErrorIs(t, c.readOnly("/upload/a.txt", &sftp.StatusError{Code: sshFxWriteProtect}), ErrReadOnly)
+	assert.ErrorIs(t, c.readOnly("/upload/a.txt", errors.New("scp: /upload/a.txt: Read-only file system")), ErrReadOnly)
+	assert.NotErrorIs(t, c.readOnly("/upload/a.txt", &sftp.StatusError{Code: sshFxFailure}), ErrReadOnly, "statvfs shows a writable mount")
+	assert.NotErrorIs(t, c.readOnly("/upload/a.txt", os.ErrPermission), ErrReadOnly)
+}
+
+// TestListTreeSkipsUnreadable verifies ListTree returns the files it could
+// read alongside the subdirectories it could not, and fails outright only
+// when the directory listed is itself unreadable.
+func TestListTreeSkipsUnreadable(t *testing.T) {
+	defer ResetMemory(t.Name())
+	for _, dir := range []string{"/upload/open", "/upload/secret"} {
+		c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: dir})
+		require.NoError(t, err)
+		require.NoError(t, c.WriteFile(dir+"/a.txt", []byte("a"), 0o644))
+		require.NoError(t, c.Close())
+	}
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name()})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.WriteFile("/upload/a.txt", []byte("a"), 0o644))
+	require.NoError(t, c.SetPermissions("/upload/secret", 0o300))
+
+	paths, skipped, err := c.ListTree("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/a.txt", "/upload/open/a.txt"}, paths)
+	require.Len(t, skipped, 1)
+	assert.Equal(t, "/upload/secret", skipped[0].Path)
+	assert.ErrorIs(t, skipped[0], os.ErrPermission)
+
+	_, _, err = c.ListTree("/upload/secret")
+	assert.ErrorIs(t, err, os.ErrPermission)
+	_, _, err = c.ListTree("/upload/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
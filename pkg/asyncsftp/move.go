@@ -0,0 +1,137 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// StartMove begins moving the file at from to path, replacing any file
+// there. Returns an operation ID to poll for completion.
+func (c *Client) StartMove(from, path string) string {
+	opID := uuid.New().String()
+
+	op := &Operation{
+		ID:        opID,
+		Type:      OperationTypeMove,
+		Priority:  PriorityFor(OperationTypeMove, 0),
+		Path:      path,
+		From:      from,
+		State:     StateInProgress,
+		StartedAt: c.clock.Now(),
+	}
+	c.track(op)
+
+	c.queue.submit(op, func() { c.dequeued(op); c.doMove(op) })
+
+	return opID
+}
+
+func (c *Client) doMove(op *Operation) {
+	err := c.move(op.From, op.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Moved by an earlier attempt - treat as success
+		if _, statErr := c.fs.Lstat(op.Path); statErr == nil {
+			err = nil
+		} else {
+			err = fmt.Errorf("%w: %s", ErrNotFound, op.From)
+		}
+	}
+	if err != nil {
+		c.completeOperation(op, StateFailure, c.refused(op.Path, err))
+		return
+	}
+
+	result, err := c.stat(op.Path)
+	if err != nil {
+		c.completeOperation(op, StateFailure, err)
+		return
+	}
+	c.operations.update(op, func() { op.Result = result })
+	c.completeOperation(op, StateCompleted, nil)
+}
+
+// move renames from to to. Servers refuse renames across filesystems,
+// which OpenSSH reports as a generic failure, and some refuse them
+// altogether; the file is then copied and the original removed once the
+// copy is in place.
+func (c *Client) move(from, to string) error {
+	err := c.fs.Rename(from, to)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if copyErr := c.copyAndRemove(from, to); copyErr != nil {
+		return fmt.Errorf("rename failed: %w; copying instead failed: %w", err, copyErr)
+	}
+	return nil
+}
+
+// copyAndRemove copies the regular file from to a staged sibling of to,
+// with the same permissions, checks the copy against the original, renames
+// it into place and removes from.
+func (c *Client) copyAndRemove(from, to string) error {
+	info, err := c.fs.Lstat(from)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is a %s, not a regular file", from, fileTypeOf(info.Mode()))
+	}
+
+	staged := fmt.Sprintf("%s.tmp.%s", to, uuid.New().String())
+	err = c.copyFile(from, staged)
+	if err == nil {
+		err = c.chmod(staged, info.Mode().Perm())
+	}
+	if err == nil {
+		var want, got string
+		if want, err = c.hashFile(from, sha256.New()); err == nil {
+			if got, err = c.hashFile(staged, sha256.New()); err == nil && got != want {
+				err = fmt.Errorf("copy of %s does not match the original", from)
+			}
+		}
+	}
+	if err == nil {
+		err = c.fs.Rename(staged, to)
+	}
+	if err != nil {
+		_ = c.fs.Remove(staged)
+		return err
+	}
+	if err := c.fs.Remove(from); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("copied to %s but could not remove the original: %w", to, err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst, on the server where the transport can and
+// through the client otherwise.
+func (c *Client) copyFile(src, dst string) error {
+	if cp, ok := c.fs.(copier); ok {
+		return cp.Copy(src, dst)
+	}
+	in, err := c.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := c.fs.Create(dst)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	c.stats.bytesDown.Add(n)
+	c.stats.bytesUp.Add(n)
+	return err
+}
@@ -0,0 +1,70 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crossDeviceFS is a Transport that refuses to rename anything but staged
+// files, the way OpenSSH reports a rename across filesystems, and cannot
+// copy on the server.
+type crossDeviceFS struct {
+	Transport
+}
+
+func (f crossDeviceFS) Rename(oldpath, newpath string) error {
+	if !strings.Contains(oldpath, ".tmp.") {
+		return &sftp.StatusError{Code: sshFxFailure}
+	}
+	return f.Transport.Rename(oldpath, newpath)
+}
+
+// TestMove verifies moves rename the file, succeed again when retried after
+// the file moved, fail for a missing file, and fall back to copying when
+// the server refuses the rename.
+func TestMove(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.WriteFile("/upload/a.txt", []byte("hello"), 0600))
+
+	op := waitFor(t, c, c.StartMove("/upload/a.txt", "/upload/b.txt"))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, OperationTypeMove, op.Type)
+	assert.Equal(t, "/upload/a.txt", op.From)
+	require.NotNil(t, op.Result)
+	assert.Equal(t, "/upload/b.txt", op.Result.Path)
+	_, err = c.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	op = waitFor(t, c, c.StartMove("/upload/a.txt", "/upload/b.txt"))
+	assert.Equal(t, StateCompleted, op.State, op.Error)
+	op = waitFor(t, c, c.StartMove("/upload/missing.txt", "/upload/c.txt"))
+	require.Equal(t, StateFailure, op.State)
+	assert.ErrorIs(t, op.Err, ErrNotFound)
+
+	memory := c.fs
+	c.fs = crossDeviceFS{Transport: memory}
+	op = waitFor(t, c, c.StartMove("/upload/b.txt", "/upload/c.txt"))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	info, err := c.ReadFile("/upload/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", info.Content)
+	assert.Equal(t, "0600", info.Permissions)
+	_, err = c.Stat("/upload/b.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+	files, err := c.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/c.txt"}, files)
+}
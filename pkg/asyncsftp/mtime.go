@@ -0,0 +1,23 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"os"
+	"time"
+)
+
+// modTime returns info's modification time as the client reports it: zero
+// when the server reports none, or the Unix epoch, which servers without
+// modification times often send instead, or when Config.IgnoreMtime is set;
+// otherwise truncated to Config.MtimePrecision, so times from servers that
+// store whole seconds compare equal to what they were set to.
+func (c *Client) modTime(info os.FileInfo) time.Time {
+	t := info.ModTime()
+	if c.ignoreMtime || t.IsZero() || t.Unix() <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(c.mtimePrecision)
+}
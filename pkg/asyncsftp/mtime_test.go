@@ -0,0 +1,32 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestModTime verifies modification times are truncated to the configured
+// precision, and that the Unix epoch and ignored times are reported as none.
+func TestModTime(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 678_000_000, time.UTC)
+	info := func(t time.Time) *remoteFileInfo { return &remoteFileInfo{modTime: t} }
+
+	c := &Client{}
+	assert.Equal(t, at, c.modTime(info(at)))
+	assert.True(t, c.modTime(info(time.Unix(0, 0))).IsZero())
+	assert.True(t, c.modTime(info(time.Time{})).IsZero())
+
+	c.mtimePrecision = time.Second
+	assert.Equal(t, at.Truncate(time.Second), c.modTime(info(at)))
+
+	c.ignoreMtime = true
+	assert.True(t, c.modTime(info(at)).IsZero())
+}
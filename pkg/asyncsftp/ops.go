@@ -0,0 +1,101 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// operationShards is how many locks the operation table is split across.
+// The agent polls every in-flight resource, so with thousands of them a
+// single lock would make each poll wait on the others and on progress
+// updates from the workers.
+const operationShards = 64
+
+// operationTable holds a client's operations by request ID. Each shard
+// guards its part of the map and the changing fields of the operations
+// whose own ID falls in it; finished operations no longer change and are
+// read without locking.
+type operationTable struct {
+	ttl    time.Duration
+	shards [operationShards]operationShard
+}
+
+type operationShard struct {
+	mu  sync.RWMutex
+	ops map[string]*Operation
+}
+
+func newOperationTable(ttl time.Duration) *operationTable {
+	t := &operationTable{ttl: ttl}
+	for i := range t.shards {
+		t.shards[i].ops = make(map[string]*Operation)
+	}
+	return t
+}
+
+// shard returns the shard holding id.
+func (t *operationTable) shard(id string) *operationShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &t.shards[h.Sum32()%operationShards]
+}
+
+// expired reports whether op finished more than the TTL before now.
+func (t *operationTable) expired(op *Operation, now time.Time) bool {
+	return op.finished() && now.Sub(op.CompletedAt) > t.ttl
+}
+
+// add records op under id, which is op's own ID or that of a request it
+// satisfies, and forgets expired operations sharing the shard.
+func (t *operationTable) add(id string, op *Operation, now time.Time) {
+	s := t.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for old, existing := range s.ops {
+		if t.expired(existing, now) {
+			delete(s.ops, old)
+		}
+	}
+	s.ops[id] = op
+}
+
+// get returns the operation recorded under id, unless it expired by now.
+func (t *operationTable) get(id string, now time.Time) (*Operation, bool) {
+	s := t.shard(id)
+	s.mu.RLock()
+	op, ok := s.ops[id]
+	s.mu.RUnlock()
+
+	if !ok || t.expired(op, now) {
+		return nil, false
+	}
+	return op, true
+}
+
+// update runs fn, which changes op's fields, so that concurrent snapshots
+// see all of the change or none of it.
+func (t *operationTable) update(op *Operation, fn func()) {
+	s := t.shard(op.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn()
+}
+
+// snapshot returns a copy of op.
+func (t *operationTable) snapshot(op *Operation) *Operation {
+	if op.finished() {
+		return op.Copy()
+	}
+	s := t.shard(op.ID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return op.Copy()
+}
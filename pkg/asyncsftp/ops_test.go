@@ -0,0 +1,66 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentStatus verifies many operations can be polled while they
+// run and report their final state once finished.
+func TestConcurrentStatus(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op := waitFor(t, c, c.StartUpload(fmt.Sprintf("/upload/%d.txt", i), "content", 0644))
+			assert.Equal(t, StateCompleted, op.State)
+			assert.EqualValues(t, len("content"), op.BytesWritten)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 200, c.Stats().Operations)
+}
+
+// TestWait verifies Wait returns an operation's final status, and gives up
+// when its context is done first.
+func TestWait(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op, err := c.Wait(context.Background(), c.StartUpload("/upload/a.txt", "a", 0644))
+	require.NoError(t, err)
+	assert.Equal(t, StateCompleted, op.State)
+
+	op, err = c.Wait(context.Background(), c.StartMove("/upload/missing.txt", "/upload/b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, StateFailure, op.State)
+
+	release := make(chan struct{})
+	c.queue.submit(&Operation{}, func() { <-release })
+	queued := c.StartUpload("/upload/c.txt", "c", 0644)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Wait(ctx, queued)
+	assert.ErrorIs(t, err, context.Canceled)
+	close(release)
+
+	_, err = c.Wait(context.Background(), "unknown")
+	assert.Error(t, err)
+}
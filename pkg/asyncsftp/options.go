@@ -0,0 +1,154 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidConfig indicates a Config the client cannot be created from,
+// such as an unknown protocol or an unreadable private key. Retrying does
+// not help.
+var ErrInvalidConfig = errors.New("invalid client configuration")
+
+// Option sets one field of the Config that Dial connects with.
+type Option func(*Config)
+
+// WithPort connects to port instead of the protocol's default.
+func WithPort(port string) Option {
+	return func(cfg *Config) { cfg.Port = port }
+}
+
+// WithProtocol selects the transport. The default is ProtocolSFTP.
+func WithProtocol(protocol Protocol) Option {
+	return func(cfg *Config) { cfg.Protocol = protocol }
+}
+
+// WithLocalDir sets the directory ProtocolFile serves, or the directory
+// ProtocolMemory creates in its store.
+func WithLocalDir(dir string) Option {
+	return func(cfg *Config) { cfg.LocalDir = dir }
+}
+
+// WithPassword authenticates as username with password.
+func WithPassword(username, password string) Option {
+	return func(cfg *Config) { cfg.Username, cfg.Password = username, password }
+}
+
+// WithKeyAuth authenticates as username with a PEM-encoded private key,
+// decrypted with passphrase when it is encrypted. Other authentication
+// set up by further options is tried after the key.
+func WithKeyAuth(username string, privateKey []byte, passphrase string) Option {
+	return func(cfg *Config) {
+		cfg.Username, cfg.PrivateKey, cfg.PrivateKeyPassphrase = username, privateKey, passphrase
+	}
+}
+
+// WithAgent authenticates as username with the keys of the ssh-agent
+// listening on socket.
+func WithAgent(username, socket string) Option {
+	return func(cfg *Config) { cfg.Username, cfg.AgentSocket = username, socket }
+}
+
+// WithAnonymous logs in without credentials, as AnonymousUser, for public
+// servers that accept any password or none.
+func WithAnonymous() Option {
+	return func(cfg *Config) { cfg.Anonymous = true }
+}
+
+// WithTimeout bounds each dial, from the TCP connect to the end of the
+// handshake. The default is DefaultDialTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *Config) { cfg.DialTimeout = d }
+}
+
+// WithTCP tunes the TCP connections dialed to the server.
+func WithTCP(opts TCPOptions) Option {
+	return func(cfg *Config) { cfg.TCP = opts }
+}
+
+// WithRetry dials an unreachable server up to attempts times, waiting
+// backoff before the second attempt and twice as long before each further
+// one.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(cfg *Config) { cfg.DialAttempts, cfg.DialBackoff = attempts, backoff }
+}
+
+// WithWaitForServer dials an unreachable server every interval until
+// maxWait has passed, for servers that may still be booting.
+func WithWaitForServer(maxWait, interval time.Duration) Option {
+	return func(cfg *Config) { cfg.WaitFor, cfg.WaitInterval = maxWait, interval }
+}
+
+// WithMaxFileSize refuses uploads larger than size bytes with ErrTooLarge,
+// for servers that cap file sizes.
+func WithMaxFileSize(size int64) Option {
+	return func(cfg *Config) { cfg.MaxFileSize = size }
+}
+
+// WithWorkers runs up to n operations at once. The default is
+// DefaultWorkers.
+func WithWorkers(n int) Option {
+	return func(cfg *Config) { cfg.Workers = n }
+}
+
+// WithJournal records finished operations in journal, so later clients
+// sharing it can answer for them.
+func WithJournal(journal *Journal) Option {
+	return func(cfg *Config) { cfg.Journal = journal }
+}
+
+// Dial connects to host configured by opts. It is Connect for callers who
+// prefer options to filling in a Config.
+func Dial(ctx context.Context, host string, opts ...Option) (*Client, error) {
+	cfg := Config{Host: host}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return Connect(ctx, cfg)
+}
+
+// Connect creates a client like NewClient, dialing again while the server
+// is unreachable as cfg.DialAttempts, or cfg.WaitFor when set, allows.
+// Rejected credentials and invalid configuration are never retried. Once
+// ctx is done no further attempt is made.
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	attempts, backoff := max(cfg.DialAttempts, 1), cfg.DialBackoff
+	var deadline time.Time
+	if cfg.WaitFor > 0 {
+		deadline = time.Now().Add(cfg.WaitFor)
+		backoff = cmp.Or(cfg.WaitInterval, DefaultWaitInterval)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		client, err := NewClient(cfg)
+		if err == nil || !errors.Is(err, ErrUnreachable) {
+			return client, err
+		}
+		if deadline.IsZero() && attempt == attempts {
+			return nil, err
+		}
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("still unreachable after waiting %s: %w", cfg.WaitFor, err)
+		}
+		if cfg.OnDialRetry != nil {
+			cfg.OnDialRetry(attempt, backoff, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		if deadline.IsZero() {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConnect verifies Connect retries only unreachable servers, reporting
+// each retry, and stops once its context is done.
+func TestConnect(t *testing.T) {
+	var retries []int
+	_, err := Connect(context.Background(), Config{
+		Host: "127.0.0.1", Port: "1", Username: "u", Password: "p",
+		DialAttempts: 3, DialBackoff: time.Millisecond,
+		OnDialRetry: func(attempt int, _ time.Duration, _ error) { retries = append(retries, attempt) },
+	})
+	assert.ErrorIs(t, err, ErrUnreachable)
+	assert.Equal(t, []int{1, 2}, retries)
+
+	retries = nil
+	_, err = Connect(context.Background(), Config{
+		Host: "127.0.0.1", Port: "1", PrivateKey: []byte("not a key"),
+		DialAttempts: 3, OnDialRetry: func(attempt int, _ time.Duration, _ error) { retries = append(retries, attempt) },
+	})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Empty(t, retries)
+
+	_, err = Connect(context.Background(), Config{Host: "127.0.0.1", SFTPSubsystem: "sftp2", SFTPServerCommand: "sftp-server"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	retries = nil
+	_, err = Dial(context.Background(), "127.0.0.1", WithPort("1"), WithPassword("u", "p"),
+		WithWaitForServer(50*time.Millisecond, 10*time.Millisecond), func(cfg *Config) {
+			cfg.OnDialRetry = func(attempt int, backoff time.Duration, _ error) {
+				assert.Equal(t, 10*time.Millisecond, backoff)
+				retries = append(retries, attempt)
+			}
+		})
+	assert.ErrorIs(t, err, ErrUnreachable)
+	assert.ErrorContains(t, err, "after waiting 50ms")
+	assert.GreaterOrEqual(t, len(retries), 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Dial(ctx, t.Name(), WithProtocol(ProtocolMemory), WithLocalDir("/upload"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	client, err := Dial(context.Background(), t.Name(), WithProtocol(ProtocolMemory), WithLocalDir("/upload"), WithWorkers(2))
+	require.NoError(t, err)
+	_ = client.Close()
+}
+
+// TestAnonymousLogin verifies the user and password anonymous configs log
+// in with.
+func TestAnonymousLogin(t *testing.T) {
+	cfg := Config{}
+	WithAnonymous()(&cfg)
+	user, password := cfg.login()
+	assert.Equal(t, AnonymousUser, user)
+	assert.Empty(t, password)
+
+	cfg.Protocol = ProtocolFTPS
+	_, password = cfg.login()
+	assert.Equal(t, "anonymous@", password)
+
+	cfg.Username, cfg.Password = "guest", "guest"
+	user, password = cfg.login()
+	assert.Equal(t, "guest", user)
+	assert.Equal(t, "guest", password)
+}
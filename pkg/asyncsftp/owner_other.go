@@ -0,0 +1,12 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build !unix
+
+package asyncsftp
+
+// sysOwner reports no ownership where the platform has no numeric owners.
+func sysOwner(sys any) *FileOwner {
+	return nil
+}
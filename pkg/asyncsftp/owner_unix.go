@@ -0,0 +1,17 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unix
+
+package asyncsftp
+
+import "syscall"
+
+// sysOwner reads ownership from a local file's stat result.
+func sysOwner(sys any) *FileOwner {
+	if st, ok := sys.(*syscall.Stat_t); ok {
+		return &FileOwner{UID: int(st.Uid), GID: int(st.Gid)}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+)
+
+// DefaultParentPermissions applies to created parent directories when
+// UploadOptions.ParentPermissions is unset.
+const DefaultParentPermissions os.FileMode = 0755
+
+// createParents creates the missing directories above p, outermost first,
+// and returns them. Each gets perm, which mkdir alone would narrow by the
+// server's umask, and owner when set.
+func (c *Client) createParents(p string, perm os.FileMode, owner *FileOwner) ([]string, error) {
+	m, ok := c.fs.(mkdirer)
+	if !ok {
+		return nil, fmt.Errorf("creating directories: %w", errors.ErrUnsupported)
+	}
+	if perm == 0 {
+		perm = DefaultParentPermissions
+	}
+
+	// Walk up to the nearest existing ancestor, which must be a directory
+	var missing []string
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		info, err := c.fs.Lstat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s is a %s, not a directory", dir, fileTypeOf(info.Mode()))
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || dir == "/" || dir == "." {
+			return nil, fmt.Errorf("stat %s: %w", dir, err)
+		}
+		missing = append(missing, dir)
+	}
+	slices.Reverse(missing)
+
+	var created []string
+	for _, dir := range missing {
+		if err := m.Mkdir(dir); err != nil {
+			// Another writer may have created it meanwhile
+			if info, statErr := c.fs.Lstat(dir); statErr == nil && info.IsDir() {
+				continue
+			}
+			return created, fmt.Errorf("mkdir %s: %w", dir, err)
+		}
+		created = append(created, dir)
+		if err := c.chmod(dir, perm); err != nil {
+			return created, fmt.Errorf("chmod %s: %w", dir, err)
+		}
+		if owner != nil {
+			if err := c.SetOwner(dir, owner.UID, owner.GID); err != nil {
+				return created, fmt.Errorf("chown %s: %w", dir, err)
+			}
+		}
+	}
+	c.statCache.forget(created...)
+	return created, nil
+}
@@ -0,0 +1,50 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateParents verifies uploads create missing parent directories with
+// their own permissions and owner, and report which they created.
+func TestCreateParents(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op := waitFor(t, c, c.StartUpload("/upload/a/b/c.txt", "c", 0600))
+	assert.Equal(t, StateFailure, op.State, "parents are not created unless asked")
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/a/b/c.txt", "c", UploadOptions{
+		Permissions:       0600,
+		CreateParents:     true,
+		ParentPermissions: 0750,
+		ParentOwner:       &FileOwner{UID: 1000, GID: 1000},
+	}))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, []string{"/upload/a", "/upload/a/b"}, op.CreatedDirs)
+	for _, dir := range op.CreatedDirs {
+		info, err := c.Stat(dir)
+		require.NoError(t, err)
+		assert.Equal(t, FileTypeDirectory, info.Type)
+		assert.Equal(t, "0750", info.Permissions)
+		assert.Equal(t, &FileOwner{UID: 1000, GID: 1000}, info.Owner)
+	}
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/a/b/d.txt", "d", UploadOptions{Permissions: 0600, CreateParents: true}))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Empty(t, op.CreatedDirs)
+
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/a/b/c.txt/e.txt", "e", UploadOptions{Permissions: 0600, CreateParents: true}))
+	assert.Equal(t, StateFailure, op.State)
+	assert.Contains(t, op.Error, "not a directory")
+}
@@ -0,0 +1,233 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+// Middleware is a stage of the transfer pipeline between the caller's
+// content and the remote file. On upload, content passes through the stages
+// in order before reaching the transport; on read, the stages are undone in
+// reverse. Stages that only observe or pace the bytes pass them on
+// unchanged, so features such as compression, encryption or bandwidth limits
+// are composed per upload instead of built into the client.
+type Middleware interface {
+	// Name identifies the stage in errors and deduplication keys.
+	Name() string
+
+	// Writer returns a writer that processes bytes and passes them to w.
+	// Closing it flushes the stage without closing w.
+	Writer(w io.Writer) io.WriteCloser
+
+	// Reader returns a reader that undoes what Writer did to the bytes r
+	// produces.
+	Reader(r io.Reader) (io.Reader, error)
+}
+
+// middlewareNames returns the stages' names joined with "|".
+func middlewareNames(mws []Middleware) string {
+	names := make([]string, len(mws))
+	for i, mw := range mws {
+		names[i] = mw.Name()
+	}
+	return strings.Join(names, "|")
+}
+
+// =============================================================================
+// Pipeline
+// =============================================================================
+
+// pipeline wraps w so bytes written to the result pass through mws[0]
+// first and w last.
+type pipeline struct {
+	io.Writer
+	stages []io.WriteCloser // outermost first
+	dest   io.WriteCloser
+}
+
+func newPipeline(w io.WriteCloser, mws []Middleware) *pipeline {
+	p := &pipeline{Writer: w, dest: w}
+	p.stages = make([]io.WriteCloser, len(mws))
+	for i := len(mws) - 1; i >= 0; i-- {
+		s := mws[i].Writer(p.Writer)
+		p.stages[i] = s
+		p.Writer = s
+	}
+	return p
+}
+
+// Close flushes every stage into the next, then closes the destination.
+func (p *pipeline) Close() error {
+	var err error
+	for _, s := range p.stages {
+		if closeErr := s.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := p.dest.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readPipeline returns a reader that undoes mws on what r produces.
+func readPipeline(r io.Reader, mws []Middleware) (io.Reader, error) {
+	for i := len(mws) - 1; i >= 0; i-- {
+		next, err := mws[i].Reader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mws[i].Name(), err)
+		}
+		r = next
+	}
+	return r, nil
+}
+
+// =============================================================================
+// Stages
+// =============================================================================
+
+// Gzip compresses content on upload and decompresses it on read, so the
+// server stores the gzip encoding while callers see the content.
+func Gzip() Middleware { return gzipStage{} }
+
+type gzipStage struct{}
+
+func (gzipStage) Name() string { return "gzip" }
+
+func (gzipStage) Writer(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+
+func (gzipStage) Reader(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+
+// Throttle limits the bytes passing through to bytesPerSecond, e.g. to
+// leave bandwidth on a shared link. After a compressing stage it limits
+// the bytes on the wire rather than the content.
+func Throttle(bytesPerSecond int64) Middleware { return throttleStage{rate: bytesPerSecond} }
+
+type throttleStage struct {
+	rate int64
+}
+
+func (t throttleStage) Name() string { return fmt.Sprintf("throttle(%d)", t.rate) }
+
+func (t throttleStage) Writer(w io.Writer) io.WriteCloser {
+	return &throttledWriter{w: w, pacer: newPacer(t.rate)}
+}
+
+func (t throttleStage) Reader(r io.Reader) (io.Reader, error) {
+	return &throttledReader{r: r, pacer: newPacer(t.rate)}, nil
+}
+
+// pacer sleeps as needed to keep a transfer at its rate, in bursts of a
+// tenth of a second's worth of bytes.
+type pacer struct {
+	rate  int64
+	burst int
+	start time.Time
+	done  int64
+}
+
+func newPacer(rate int64) *pacer {
+	return &pacer{rate: rate, burst: int(max(rate/10, 1))}
+}
+
+// wait blocks until n more bytes are within the rate.
+func (p *pacer) wait(n int) {
+	if p.rate <= 0 {
+		return
+	}
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	p.done += int64(n)
+	due := p.start.Add(time.Duration(p.done * int64(time.Second) / p.rate))
+	time.Sleep(time.Until(due))
+}
+
+type throttledWriter struct {
+	w     io.Writer
+	pacer *pacer
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), t.pacer.burst)]
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		t.pacer.wait(n)
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (t *throttledWriter) Close() error { return nil }
+
+type throttledReader struct {
+	r     io.Reader
+	pacer *pacer
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p[:min(len(p), t.pacer.burst)])
+	t.pacer.wait(n)
+	return n, err
+}
+
+// digestStage hashes and counts the bytes passing through. The client puts
+// one last in every upload pipeline, so verification and transfer stats see
+// the bytes as stored rather than as declared. It hashes with sha256 unless
+// the upload verifies with another algorithm.
+type digestStage struct {
+	h hash.Hash
+	n int64
+}
+
+func newDigest(h hash.Hash) *digestStage { return &digestStage{h: h} }
+
+func (d *digestStage) Name() string { return "digest" }
+
+func (d *digestStage) Writer(w io.Writer) io.WriteCloser { return &digestWriter{w: w, d: d} }
+
+func (d *digestStage) Reader(r io.Reader) (io.Reader, error) { return r, nil }
+
+// sum returns the hex-encoded hash of the bytes written so far.
+func (d *digestStage) sum() string { return hex.EncodeToString(d.h.Sum(nil)) }
+
+type digestWriter struct {
+	w io.Writer
+	d *digestStage
+}
+
+func (w *digestWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.d.h.Write(p[:n])
+	w.d.n += int64(n)
+	return n, err
+}
+
+func (w *digestWriter) Close() error { return nil }
+
+// countingReader counts the bytes read from r, i.e. the bytes a read
+// pipeline took from the transport.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
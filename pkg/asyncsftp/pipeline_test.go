@@ -0,0 +1,85 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMiddlewarePipeline verifies content uploaded through gzip is stored
+// compressed, verified as stored, and read back as uploaded, including
+// after an append.
+func TestMiddlewarePipeline(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	content := strings.Repeat("hello world\n", 1000)
+	opts := UploadOptions{Permissions: 0644, Verify: true, Middleware: []Middleware{Gzip()}}
+	op := waitFor(t, c, c.StartUploadWithOptions("/upload/app.log", content, opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, content, op.Result.Content)
+
+	raw, err := c.ReadFile("/upload/app.log")
+	require.NoError(t, err)
+	assert.Less(t, raw.Size, int64(len(content)))
+	zr, err := gzip.NewReader(strings.NewReader(raw.Content))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(plain))
+	assert.Equal(t, raw.Size, c.Stats().BytesUploaded, "stats count the bytes sent")
+
+	info, err := c.ReadFileThrough("/upload/app.log", Gzip())
+	require.NoError(t, err)
+	assert.Equal(t, content, info.Content)
+	assert.Equal(t, int64(len(content)), info.Size)
+
+	opts.Verify, opts.Append = false, true
+	op = waitFor(t, c, c.StartUploadWithOptions("/upload/app.log", "appended\n", opts))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	info, err = c.ReadFileThrough("/upload/app.log", Gzip())
+	require.NoError(t, err)
+	assert.Equal(t, content+"appended\n", info.Content)
+
+	_, err = c.ReadFileThrough("/upload/missing.log", Gzip())
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, c.WriteFile("/upload/plain.txt", []byte("not gzip"), 0644))
+	_, err = c.ReadFileThrough("/upload/plain.txt", Gzip())
+	assert.ErrorContains(t, err, "gzip:")
+}
+
+// TestThrottle verifies a throttled transfer takes at least as long as its
+// rate allows, in both directions.
+func TestThrottle(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 64<<10)
+
+	var buf bytes.Buffer
+	w := Throttle(256 << 10).Writer(&buf)
+	start := time.Now()
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, data, buf.Bytes())
+
+	r, err := Throttle(256 << 10).Reader(bytes.NewReader(data))
+	require.NoError(t, err)
+	start = time.Now()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, data, got)
+}
@@ -0,0 +1,62 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Probe selects how the client checks that a path exists and reads its
+// metadata. Some accounts can write files they are not allowed to stat, so
+// Stat, ReadFile and the post-upload check can use an alternative probe.
+type Probe string
+
+const (
+	// ProbeStat uses the transport's lstat (the default).
+	ProbeStat Probe = "stat"
+	// ProbeOpen opens the file for reading. It only learns that a regular
+	// file exists: permissions and modification time are not reported.
+	ProbeOpen Probe = "open"
+	// ProbeExec runs stat over SSH exec, bypassing SFTP restrictions.
+	// Requires shell access.
+	ProbeExec Probe = "exec"
+)
+
+// openedFileInfo is what an open probe learns about a path.
+type openedFileInfo struct {
+	remoteFileInfo
+}
+
+// lstat returns p's metadata, without following symlinks, using the
+// client's probe.
+func (c *Client) lstat(p string) (os.FileInfo, error) {
+	switch c.probe {
+	case ProbeOpen:
+		f, err := c.fs.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		_ = f.Close()
+		return &openedFileInfo{remoteFileInfo{name: path.Base(p)}}, nil
+	case ProbeExec:
+		if c.sshClient == nil {
+			return nil, fmt.Errorf("%w: not connected over SSH", ErrExecUnavailable)
+		}
+		return (&scpTransport{ssh: c.sshClient}).Lstat(p)
+	default:
+		return c.fs.Lstat(p)
+	}
+}
+
+// permissionsOf formats fi's permission bits, or returns "" when the probe
+// could not learn them.
+func permissionsOf(fi os.FileInfo) string {
+	if _, ok := fi.(*openedFileInfo); ok {
+		return ""
+	}
+	return fmt.Sprintf("%04o", fi.Mode().Perm())
+}
@@ -0,0 +1,49 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProbes verifies the alternative existence probes: open reports a file
+// without permissions or mtime, and exec needs an SSH connection.
+func TestProbes(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Probe: ProbeOpen})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	op := waitFor(t, c, c.StartUpload("/upload/a.txt", "hello", 0600))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, "", op.Result.Permissions)
+	assert.Equal(t, int64(5), op.Result.Size)
+
+	info, err := c.Stat("/upload/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, FileTypeRegular, info.Type)
+	assert.Equal(t, "", info.Permissions)
+	assert.True(t, info.ModifiedAt.IsZero())
+
+	info, err = c.ReadFile("/upload/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", info.Content)
+	assert.Equal(t, int64(5), info.Size)
+
+	_, err = c.Stat("/upload/missing.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	c, err = NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), Probe: ProbeExec})
+	require.NoError(t, err)
+	_, err = c.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrExecUnavailable)
+
+	_, err = NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), Probe: "guess"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultWorkers is the number of operations run concurrently when
+// Config.Workers is not set.
+const DefaultWorkers = 4
+
+// ErrQueueFull indicates Config.MaxQueued operations are already waiting for
+// a worker. Callers should back off and retry rather than pile on more work.
+var ErrQueueFull = errors.New("operation queue is full")
+
+// task is a queued operation waiting for a worker.
+type task struct {
+	op     *Operation
+	seq    uint64
+	run    func()
+	upload *batchedUpload // set when the task may join a batch
+}
+
+// taskHeap orders tasks by priority (highest first), then by submission order.
+type taskHeap []*task
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool { return h.lessTask(h[i], h[j]) }
+
+func (taskHeap) lessTask(a, b *task) bool {
+	if a.op.Priority != b.op.Priority {
+		return a.op.Priority > b.op.Priority
+	}
+	return a.seq < b.seq
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x any) { *h = append(*h, x.(*task)) }
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return t
+}
+
+// workQueue is a priority queue drained by a fixed pool of workers.
+type workQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	tasks  taskHeap
+	seq    uint64
+	closed bool
+}
+
+// newWorkQueue starts workers goroutines draining the queue.
+func newWorkQueue(workers int) *workQueue {
+	q := &workQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// submit queues run for the given operation.
+func (q *workQueue) submit(op *Operation, run func()) {
+	q.push(&task{op: op, run: run})
+}
+
+// submitUpload queues an upload that may be written in a batch, either
+// started by run or taken into another worker's batch.
+func (q *workQueue) submitUpload(upload *batchedUpload, run func()) {
+	q.push(&task{op: upload.op, run: run, upload: upload})
+}
+
+func (q *workQueue) push(t *task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	t.seq = q.seq
+	heap.Push(&q.tasks, t)
+	q.cond.Signal()
+}
+
+// queued returns the number of operations waiting for a worker.
+func (q *workQueue) queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.tasks)
+}
+
+// Admit reports whether the client can take another async operation without
+// it waiting behind a full queue. It returns ErrQueueFull once
+// Config.MaxQueued operations are waiting; with no limit it always admits.
+func (c *Client) Admit() error {
+	if c.maxQueued > 0 && c.queue.queued() >= c.maxQueued {
+		return fmt.Errorf("%w: %d operations waiting", ErrQueueFull, c.maxQueued)
+	}
+	return nil
+}
+
+// dequeued records how long op waited for a worker. It is called by the
+// worker as the first step of running the operation.
+func (c *Client) dequeued(op *Operation) {
+	c.operations.update(op, func() { op.Timings.Queued = c.since(op.StartedAt) })
+}
+
+// close stops the workers once they finish their current task. Tasks still
+// queued are dropped and returned.
+func (q *workQueue) close() []*task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+	dropped := q.tasks
+	q.tasks = nil
+	return dropped
+}
+
+func (q *workQueue) worker() {
+	for {
+		q.mu.Lock()
+		for len(q.tasks) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		t := heap.Pop(&q.tasks).(*task)
+		q.mu.Unlock()
+
+		t.run()
+	}
+}
@@ -0,0 +1,142 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWorkQueuePriority verifies that queued operations start highest priority
+// first and in submission order within a priority.
+func TestWorkQueuePriority(t *testing.T) {
+	q := newWorkQueue(0) // no workers yet: queue everything first
+	defer q.close()
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	submit := func(name string, priority Priority) {
+		wg.Add(1)
+		q.submit(&Operation{ID: name, Priority: priority}, func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+
+	submit("big-artifact", PriorityNormal)
+	submit("config-1", PriorityHigh)
+	submit("backfill", PriorityLow)
+	submit("config-2", PriorityHigh)
+
+	go q.worker()
+	wg.Wait()
+
+	assert.Equal(t, []string{"config-1", "config-2", "big-artifact", "backfill"}, order)
+}
+
+// TestPriorityFor verifies that deletes and small uploads are prioritized.
+func TestPriorityFor(t *testing.T) {
+	assert.Equal(t, PriorityHigh, PriorityFor(OperationTypeDelete, 0))
+	assert.Equal(t, PriorityHigh, PriorityFor(OperationTypeUpload, 512))
+	assert.Equal(t, PriorityNormal, PriorityFor(OperationTypeUpload, 5<<30))
+}
+
+// TestAdmit verifies the client refuses new work once MaxQueued operations
+// are waiting, and admits it again as the queue drains.
+func TestAdmit(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1, MaxQueued: 1})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	// Occupy the only worker so the next operation waits
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c.queue.submit(&Operation{Priority: PriorityHigh}, func() { close(started); <-release })
+	<-started
+
+	require.NoError(t, c.Admit())
+	opID := c.StartUpload("/upload/a.txt", "hello", 0644)
+	assert.ErrorIs(t, c.Admit(), ErrQueueFull)
+
+	close(release)
+	waitFor(t, c, opID)
+	assert.NoError(t, c.Admit())
+}
+
+// TestQueuedTiming verifies an operation records how long it waited for a
+// worker, separately from the time it spent running.
+func TestQueuedTiming(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload", Workers: 1, Clock: clock})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c.queue.submit(&Operation{Priority: PriorityHigh}, func() { close(started); <-release })
+	<-started
+
+	opID := c.StartUpload("/upload/a.txt", "hello", 0644)
+	clock.Advance(3 * time.Second)
+	close(release)
+
+	op := waitFor(t, c, opID)
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, 3*time.Second, op.Timings.Queued)
+
+	op = waitFor(t, c, c.StartUpload("/upload/b.txt", "hello", 0644))
+	assert.Zero(t, op.Timings.Queued)
+}
+
+// TestFairShare verifies turns are handed out in the order they were asked
+// for, and that an upload takes one turn per chunk while recording progress.
+func TestFairShare(t *testing.T) {
+	var f fairShare
+	f.acquire()
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.acquire()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			f.release()
+		}()
+		// Wait until the waiter is queued so arrival order is known
+		require.Eventually(t, func() bool {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			return len(f.waiting) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	f.release()
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2}, order)
+	assert.False(t, f.busy)
+
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	content := strings.Repeat("x", 2*fairShareChunk+1)
+	op := waitFor(t, c, c.StartUpload("/upload/big.bin", content, 0644))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, int64(len(content)), op.BytesWritten)
+}
@@ -0,0 +1,66 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"syscall"
+
+	"github.com/pkg/sftp"
+)
+
+// ErrReadOnly indicates a write failed because the server's filesystem is
+// read-only, e.g. remounted for maintenance. Unlike a permission problem it
+// usually clears by itself, so the write is worth retrying later.
+var ErrReadOnly = errors.New("filesystem is read-only")
+
+// SFTP status codes that bear on read-only filesystems. Version 3 servers
+// such as OpenSSH report EROFS as a generic failure; later protocol
+// versions have a dedicated write-protect status.
+const (
+	sshFxFailure      = 4
+	sshFxWriteProtect = 12
+)
+
+// stRdonly is the ST_RDONLY bit of statvfs(3)'s f_flag (MNT_RDONLY on the
+// BSDs and macOS).
+const stRdonly = 1
+
+// readOnlyMessage matches the EROFS text that SCP and FTPS servers pass on
+// from the remote shell or filesystem.
+var readOnlyMessage = regexp.MustCompile(`(?i)read-only file ?system`)
+
+// readOnly returns err marked with ErrReadOnly when it shows the filesystem
+// holding p is read-only, and err unchanged otherwise. A generic SFTP
+// failure is followed up with statvfs on p's directory, where available,
+// since that is all OpenSSH reports for EROFS.
+func (c *Client) readOnly(p string, err error) error {
+	if err == nil || errors.Is(err, ErrReadOnly) {
+		return err
+	}
+	var status *sftp.StatusError
+	switch {
+	case errors.Is(err, syscall.EROFS), readOnlyMessage.MatchString(err.Error()):
+	case errors.As(err, &status) && status.Code == sshFxWriteProtect:
+	case errors.As(err, &status) && status.Code == sshFxFailure && c.mountedReadOnly(path.Dir(p)):
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrReadOnly, err)
+}
+
+// mountedReadOnly reports whether statvfs shows dir's filesystem as
+// read-only. Transports without statvfs report false.
+func (c *Client) mountedReadOnly(dir string) bool {
+	t, ok := c.fs.(statvfser)
+	if !ok {
+		return false
+	}
+	usage, err := t.StatVFS(dir)
+	return err == nil && usage.ReadOnly
+}
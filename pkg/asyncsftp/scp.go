@@ -0,0 +1,307 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// scpTransport is a Transport for SSH servers with the SFTP subsystem
+// disabled. Uploads use the SCP sink protocol; everything else runs
+// coreutils commands over SSH exec, so the server needs a POSIX shell with
+// scp, stat, find, cat, chmod, chown, mv and rm.
+type scpTransport struct {
+	ssh *ssh.Client
+}
+
+var _ Transport = (*scpTransport)(nil)
+
+// statFormat prints raw mode (hex), size, mtime (epoch seconds), owner,
+// group and name.
+const statFormat = `'%f %s %Y %u %g %n'`
+
+// scpPathError maps command failures to the os errors callers test for,
+// keeping the remote command's text.
+func scpPathError(op, p string, err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "No such file or directory"):
+		err = fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	case strings.Contains(msg, "Permission denied"):
+		err = fmt.Errorf("%w: %w", os.ErrPermission, err)
+	}
+	return &os.PathError{Op: op, Path: p, Err: err}
+}
+
+func (t *scpTransport) run(op, p, cmd string) (string, error) {
+	out, err := runSSH(t.ssh, cmd, nil)
+	if err != nil {
+		return "", scpPathError(op, p, err)
+	}
+	return out, nil
+}
+
+// scpWriter buffers content and sends it with SCP on Close.
+type scpWriter struct {
+	bytes.Buffer
+	t    *scpTransport
+	path string
+}
+
+func (w *scpWriter) Close() error {
+	return w.t.upload(w.path, w.Bytes())
+}
+
+func (t *scpTransport) Create(p string) (io.WriteCloser, error) {
+	return &scpWriter{t: t, path: p}, nil
+}
+
+// upload speaks the sink side of the SCP protocol: after each message the
+// remote scp answers with a zero byte, or 1/2 followed by an error line.
+func (t *scpTransport) upload(p string, content []byte) error {
+	session, err := t.ssh.NewSession()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExecUnavailable, err)
+	}
+	defer func() { _ = session.Close() }()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	if err := session.Start("scp -t " + shellQuote(p)); err != nil {
+		return fmt.Errorf("%w: %w", ErrExecUnavailable, err)
+	}
+
+	acks := bufio.NewReader(stdout)
+	ack := func() error {
+		status, err := acks.ReadByte()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return errors.New(msg)
+			}
+			return err
+		}
+		if status != 0 {
+			msg, _ := acks.ReadString('\n')
+			return errors.New(strings.TrimSpace(msg))
+		}
+		return nil
+	}
+
+	err = ack()
+	if err == nil {
+		_, err = fmt.Fprintf(stdin, "C0644 %d %s\n", len(content), path.Base(p))
+	}
+	if err == nil {
+		err = ack()
+	}
+	if err == nil {
+		_, err = stdin.Write(append(content, 0))
+	}
+	if err == nil {
+		err = ack()
+	}
+	_ = stdin.Close()
+	if waitErr := session.Wait(); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return scpPathError("create", p, err)
+	}
+	return nil
+}
+
+// scpAppender buffers content and appends it with cat on Close.
+type scpAppender struct {
+	bytes.Buffer
+	t    *scpTransport
+	path string
+}
+
+func (w *scpAppender) Close() error {
+	if _, err := runSSH(w.t.ssh, "cat >> "+shellQuote(w.path), &w.Buffer); err != nil {
+		return scpPathError("append", w.path, err)
+	}
+	return nil
+}
+
+func (t *scpTransport) Append(p string) (io.WriteCloser, error) {
+	return &scpAppender{t: t, path: p}, nil
+}
+
+func (t *scpTransport) Open(p string) (io.ReadCloser, error) {
+	out, err := t.run("open", p, "cat -- "+shellQuote(p))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(out)), nil
+}
+
+func (t *scpTransport) Lstat(p string) (os.FileInfo, error) {
+	out, err := t.run("stat", p, "stat -c "+statFormat+" -- "+shellQuote(p))
+	if err != nil {
+		return nil, err
+	}
+	info, ok := parseStat(strings.TrimSuffix(out, "\n"))
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: p, Err: fmt.Errorf("malformed stat output: %q", out)}
+	}
+	return info, nil
+}
+
+func (t *scpTransport) ReadLink(p string) (string, error) {
+	out, err := t.run("readlink", p, "readlink -- "+shellQuote(p))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (t *scpTransport) RealPath(p string) (string, error) {
+	out, err := t.run("realpath", p, "realpath -- "+shellQuote(p))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (t *scpTransport) ReadDir(dir string) ([]os.FileInfo, error) {
+	out, err := t.run("readdir", dir, fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -exec stat -c %s -- {} +",
+		shellQuote(dir), statFormat))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []os.FileInfo
+	for _, line := range strings.Split(out, "\n") {
+		if info, ok := parseStat(line); ok {
+			entries = append(entries, info)
+		}
+	}
+	return entries, nil
+}
+
+func (t *scpTransport) Chmod(p string, mode os.FileMode) error {
+	_, err := t.run("chmod", p, fmt.Sprintf("chmod %04o -- %s", mode.Perm(), shellQuote(p)))
+	return err
+}
+
+func (t *scpTransport) Chown(p string, uid, gid int) error {
+	_, err := t.run("chown", p, fmt.Sprintf("chown -h %d:%d -- %s", uid, gid, shellQuote(p)))
+	return err
+}
+
+// StatVFS runs stat -f, reporting the fundamental block size, block counts
+// and inode counts.
+func (t *scpTransport) StatVFS(p string) (*DiskUsage, error) {
+	out, err := t.run("statvfs", p, "stat -f -c '%S %b %f %a %c %d' -- "+shellQuote(p))
+	if err != nil {
+		return nil, err
+	}
+	usage, ok := parseStatfs(strings.TrimSpace(out))
+	if !ok {
+		return nil, &os.PathError{Op: "statvfs", Path: p, Err: fmt.Errorf("malformed stat output: %q", out)}
+	}
+	return usage, nil
+}
+
+// parseStatfs parses a line of StatVFS's stat -f output.
+func parseStatfs(line string) (*DiskUsage, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 6 {
+		return nil, false
+	}
+	var n [6]uint64
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		n[i] = v
+	}
+	return &DiskUsage{
+		TotalBytes:     n[1] * n[0],
+		FreeBytes:      n[2] * n[0],
+		AvailableBytes: n[3] * n[0],
+		TotalFiles:     n[4],
+		FreeFiles:      n[5],
+	}, true
+}
+
+func (t *scpTransport) Mkdir(p string) error {
+	_, err := t.run("mkdir", p, "mkdir -- "+shellQuote(p))
+	return err
+}
+
+func (t *scpTransport) Remove(p string) error {
+	_, err := t.run("remove", p, "rm -- "+shellQuote(p))
+	return err
+}
+
+// Rename uses mv, which replaces the destination atomically within a
+// filesystem.
+func (t *scpTransport) Rename(oldpath, newpath string) error {
+	_, err := t.run("rename", oldpath, fmt.Sprintf("mv -f -- %s %s", shellQuote(oldpath), shellQuote(newpath)))
+	return err
+}
+
+// Close is a no-op: the SSH connection is owned by the Client.
+func (t *scpTransport) Close() error {
+	return nil
+}
+
+// parseStat parses a line of statFormat output.
+func parseStat(line string) (*remoteFileInfo, bool) {
+	fields := strings.SplitN(line, " ", 6)
+	if len(fields) != 6 {
+		return nil, false
+	}
+	raw, err1 := strconv.ParseUint(fields[0], 16, 32)
+	size, err2 := strconv.ParseInt(fields[1], 10, 64)
+	mtime, err3 := strconv.ParseInt(fields[2], 10, 64)
+	uid, err4 := strconv.Atoi(fields[3])
+	gid, err5 := strconv.Atoi(fields[4])
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, false
+	}
+
+	info := &remoteFileInfo{
+		name:    path.Base(fields[5]),
+		size:    size,
+		mode:    os.FileMode(raw).Perm(),
+		modTime: time.Unix(mtime, 0),
+		owner:   &FileOwner{UID: uid, GID: gid},
+	}
+	// Map the S_IFMT bits of st_mode to os.FileMode types
+	switch raw & 0170000 {
+	case 0040000:
+		info.mode |= os.ModeDir
+	case 0120000:
+		info.mode |= os.ModeSymlink
+	case 0100000:
+	default:
+		info.mode |= os.ModeIrregular
+	}
+	return info, true
+}
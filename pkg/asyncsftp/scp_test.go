@@ -0,0 +1,54 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseStat verifies parsing of `stat -c '%f %s %Y %u %g %n'` lines.
+func TestParseStat(t *testing.T) {
+	info, ok := parseStat("81a4 5 1735786800 1000 100 /upload/my file.txt")
+	require.True(t, ok)
+	assert.Equal(t, "my file.txt", info.Name())
+	assert.Equal(t, int64(5), info.Size())
+	assert.Equal(t, os.FileMode(0644), info.Mode())
+	assert.Equal(t, time.Unix(1735786800, 0), info.ModTime())
+	assert.Equal(t, &FileOwner{UID: 1000, GID: 100}, ownerOf(info))
+
+	info, ok = parseStat("41ed 4096 1735786800 0 0 /upload/sub")
+	require.True(t, ok)
+	assert.Equal(t, os.ModeDir|0755, info.Mode())
+
+	info, ok = parseStat("a1ff 7 1735786800 0 0 /upload/link")
+	require.True(t, ok)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	_, ok = parseStat("")
+	assert.False(t, ok)
+}
+
+// TestParseStatfs verifies parsing of `stat -f -c '%S %b %f %a %c %d'` lines.
+func TestParseStatfs(t *testing.T) {
+	usage, ok := parseStatfs("4096 1000 600 500 2048 1024")
+	require.True(t, ok)
+	assert.Equal(t, &DiskUsage{
+		TotalBytes:     4096000,
+		FreeBytes:      2457600,
+		AvailableBytes: 2048000,
+		TotalFiles:     2048,
+		FreeFiles:      1024,
+	}, usage)
+
+	_, ok = parseStatfs("4096 1000 600")
+	assert.False(t, ok)
+}
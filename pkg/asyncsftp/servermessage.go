@@ -0,0 +1,81 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/sftp"
+)
+
+// ErrNoSpace indicates a write the server refused for lack of space, on its
+// filesystem or in the account's quota. Retrying does not help until
+// something is removed or the quota raised.
+var ErrNoSpace = errors.New("no space left on server")
+
+// SFTP status codes of protocol versions 5 and later that managed file
+// transfer appliances send to version 3 clients too.
+const (
+	sshFxNoSpaceOnFilesystem = 14
+	sshFxQuotaExceeded       = 15
+)
+
+// noSpaceMessage matches the out-of-space and quota texts servers send with
+// generic failures.
+var noSpaceMessage = regexp.MustCompile(`(?i)no space left|disk full|quota exceeded|exceeded storage allocation|insufficient storage`)
+
+// ServerMessage returns the text the server sent with the refusal err
+// carries: an SFTP status message or an FTP reply. It is empty when err came
+// from elsewhere, or when the server sent no text. The pkg/sftp library
+// drops the text of SFTP permission and no-such-file statuses, so those
+// have none.
+func ServerMessage(err error) string {
+	var status *sftp.StatusError
+	var reply *textproto.Error
+	switch {
+	case errors.As(err, &status):
+		// The message is unexported, but quoted in Error
+		quoted, ok := strings.CutPrefix(status.Error(), "sftp: ")
+		if !ok {
+			return ""
+		}
+		quoted, err := strconv.QuotedPrefix(quoted)
+		if err != nil {
+			return ""
+		}
+		msg, _ := strconv.Unquote(quoted)
+		return strings.TrimSpace(msg)
+	case errors.As(err, &reply):
+		return strings.TrimSpace(reply.Msg)
+	}
+	return ""
+}
+
+// refused returns err marked with the sentinel for the reason the server
+// refused a write of p, when it is one callers branch on: ErrReadOnly or
+// ErrNoSpace. The server's own text stays in the message.
+func (c *Client) refused(p string, err error) error {
+	err = c.readOnly(p, err)
+	if err == nil || errors.Is(err, ErrReadOnly) || errors.Is(err, ErrNoSpace) {
+		return err
+	}
+	var status *sftp.StatusError
+	var reply *textproto.Error
+	switch {
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+	case errors.As(err, &status) && (status.Code == sshFxNoSpaceOnFilesystem || status.Code == sshFxQuotaExceeded):
+	case errors.As(err, &reply) && (reply.Code == 452 || reply.Code == 552):
+	case noSpaceMessage.MatchString(err.Error()):
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrNoSpace, err)
+}
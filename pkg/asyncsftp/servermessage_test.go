@@ -0,0 +1,54 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServerMessage verifies the server's text is kept and extracted from
+// SFTP statuses and FTP replies, and that refusals for lack of space are
+// marked with ErrNoSpace.
+func TestServerMessage(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	reply := &textproto.Error{Code: 553, Msg: "Upload denied by policy PCI-7"}
+	refused := pathError("create", "/upload/a.txt", reply)
+	assert.ErrorIs(t, refused, os.ErrPermission)
+	assert.Contains(t, refused.Error(), "Upload denied by policy PCI-7")
+	assert.Equal(t, "Upload denied by policy PCI-7", ServerMessage(fmt.Errorf("write failed: %w", refused)))
+
+	scp := scpPathError("create", "/upload/a.txt", errors.New("scp: /upload/a.txt: Permission denied"))
+	assert.ErrorIs(t, scp, os.ErrPermission)
+	assert.Contains(t, scp.Error(), "scp: /upload/a.txt: Permission denied")
+
+	assert.Empty(t, ServerMessage(&sftp.StatusError{Code: sshFxFailure}))
+	assert.Empty(t, ServerMessage(errors.New("boom")))
+
+	for _, err := range []error{
+		&sftp.StatusError{Code: sshFxQuotaExceeded},
+		&sftp.StatusError{Code: sshFxNoSpaceOnFilesystem},
+		&textproto.Error{Code: 552, Msg: "Exceeded storage allocation"},
+		&os.PathError{Op: "write", Path: "/upload/a.txt", Err: syscall.ENOSPC},
+		errors.New("scp: /upload/a.txt: Disk quota exceeded"),
+	} {
+		assert.ErrorIs(t, c.refused("/upload/a.txt", fmt.Errorf("write failed: %w", err)), ErrNoSpace, err.Error())
+	}
+	assert.NotErrorIs(t, c.refused("/upload/a.txt", &sftp.StatusError{Code: sshFxFailure}), ErrNoSpace)
+	assert.NotErrorIs(t, c.refused("/upload/a.txt", os.ErrPermission), ErrNoSpace)
+}
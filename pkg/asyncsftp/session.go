@@ -0,0 +1,60 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Session describes who a client is logged in as and where, resolved once
+// on connect: the fastest way to tell why a path that exists on the host
+// is missing over SFTP.
+type Session struct {
+	// User is the user the client logged in as.
+	User string `json:"user,omitempty"`
+
+	// Home is the directory relative paths resolve against, the server's
+	// real path of "."; the login user's home directory on most servers.
+	// Empty when the server cannot resolve paths.
+	Home string `json:"home,omitempty"`
+
+	// Chrooted reports that the session appears to be confined to a
+	// chroot, such as OpenSSH's ChrootDirectory: neither /etc nor /usr
+	// exists at its root, so absolute paths are not those of the host.
+	Chrooted bool `json:"chrooted,omitempty"`
+}
+
+// String describes the session for log and error messages, e.g.
+// "user deploy, home /upload, chrooted".
+func (s Session) String() string {
+	parts := []string{"user " + cmp.Or(s.User, "unknown"), "home " + cmp.Or(s.Home, "unknown")}
+	if s.Chrooted {
+		parts = append(parts, "chrooted")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Session returns who the client is logged in as and where.
+func (c *Client) Session() Session {
+	return c.session
+}
+
+// resolveSession looks up where user's session landed. Failures leave the
+// fields they would have filled empty.
+func (c *Client) resolveSession(user string) Session {
+	s := Session{User: user}
+	if home, err := c.RealPath("."); err == nil {
+		s.Home = home
+	}
+	missing := func(p string) bool {
+		_, err := c.fs.Lstat(p)
+		return errors.Is(err, os.ErrNotExist)
+	}
+	s.Chrooted = s.Home != "" && missing("/etc") && missing("/usr")
+	return s
+}
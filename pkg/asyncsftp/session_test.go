@@ -0,0 +1,35 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSession verifies the session's user, home and chroot detection.
+func TestSession(t *testing.T) {
+	chroot, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: t.TempDir(), Username: "deploy"})
+	require.NoError(t, err)
+	defer func() { _ = chroot.Close() }()
+	assert.Equal(t, Session{User: "deploy", Home: "/", Chrooted: true}, chroot.Session())
+	assert.Equal(t, "user deploy, home /, chrooted", chroot.Session().String())
+
+	root := t.TempDir()
+	for _, dir := range []string{"etc", "usr"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, dir), 0o755))
+	}
+	host, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: root})
+	require.NoError(t, err)
+	defer func() { _ = host.Close() }()
+	assert.False(t, host.Session().Chrooted)
+	assert.Equal(t, "user unknown, home /", host.Session().String())
+}
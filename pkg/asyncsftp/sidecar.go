@@ -0,0 +1,167 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// SidecarFormat is the layout of the line in a checksum sidecar.
+type SidecarFormat string
+
+const (
+	// SidecarGNU is "<hash>  <name>", as sha256sum prints it and -c checks
+	// it. It is the default.
+	SidecarGNU SidecarFormat = "gnu"
+	// SidecarBSD is "SHA256 (<name>) = <hash>", as the BSD sha256 command
+	// prints it.
+	SidecarBSD SidecarFormat = "bsd"
+	// SidecarHash is the hash alone.
+	SidecarHash SidecarFormat = "hash"
+)
+
+// sidecarAlgorithms are the algorithms whose sidecars RemoveSidecars looks
+// for.
+var sidecarAlgorithms = []HashAlgorithm{HashSHA256, HashSHA512, HashMD5}
+
+// Sidecar is a checksum file written next to an upload, as many B2B
+// file-transfer specs require, e.g. report.csv.sha256 next to report.csv.
+type Sidecar struct {
+	Algorithm HashAlgorithm
+	Format    SidecarFormat
+}
+
+// Path returns the sidecar's path for the file at p: p with the algorithm
+// as extension.
+func (s Sidecar) Path(p string) string {
+	return p + "." + s.Algorithm.String()
+}
+
+// SidecarFor returns the file a sidecar at p would describe, p without its
+// algorithm extension, and whether p has one of the extensions sidecars
+// are written with.
+func SidecarFor(p string) (string, bool) {
+	for _, alg := range sidecarAlgorithms {
+		if file, ok := strings.CutSuffix(p, "."+alg.String()); ok && file != "" && !strings.HasSuffix(file, "/") {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// line returns the sidecar's content for the file name hashing to sum.
+func (s Sidecar) line(name, sum string) (string, error) {
+	switch s.Format {
+	case "", SidecarGNU:
+		return fmt.Sprintf("%s  %s\n", sum, name), nil
+	case SidecarBSD:
+		return fmt.Sprintf("%s (%s) = %s\n", strings.ToUpper(s.Algorithm.String()), name, sum), nil
+	case SidecarHash:
+		return sum + "\n", nil
+	}
+	return "", fmt.Errorf("%w: unknown sidecar format %q", ErrInvalidConfig, s.Format)
+}
+
+// WriteSidecar writes the sidecar s for the file at p, hashing the file as
+// stored. The sidecar replaces any earlier one atomically and gets the
+// file's permissions.
+func (c *Client) WriteSidecar(p string, s Sidecar) error {
+	h, err := s.Algorithm.New()
+	if err != nil {
+		return err
+	}
+	sum, err := c.hashFile(p, h)
+	if err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+	stat, err := c.lstat(p)
+	if err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+	return c.writeSidecar(p, s, sum, stat.Mode().Perm())
+}
+
+// uploadSidecar writes opts.Sidecar for an upload of content to p whose
+// stored bytes hash to stored with opts.VerifyHash, only hashing again
+// when the sidecar uses another algorithm.
+func (c *Client) uploadSidecar(p, content, stored string, opts UploadOptions) error {
+	s := *opts.Sidecar
+	if s.Algorithm.String() == opts.VerifyHash.String() {
+		return c.writeSidecar(p, s, stored, opts.Permissions)
+	}
+	h, err := s.Algorithm.New()
+	if err != nil {
+		return err
+	}
+	if len(opts.Middleware) == 0 {
+		h.Write([]byte(content))
+		return c.writeSidecar(p, s, hex.EncodeToString(h.Sum(nil)), opts.Permissions)
+	}
+	sum, err := c.hashFile(p, h)
+	if err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+	return c.writeSidecar(p, s, sum, opts.Permissions)
+}
+
+// writeSidecar writes the sidecar s for the file at p hashing to sum.
+func (c *Client) writeSidecar(p string, s Sidecar, sum string, permissions os.FileMode) error {
+	line, err := s.line(path.Base(p), sum)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteFile(s.Path(p), []byte(line), permissions); err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+	return nil
+}
+
+// RemoveSidecars removes the checksum sidecars next to p that describe it,
+// and returns their paths. A sidecar naming another file, or whose bare
+// hash is not p's, is left alone, so files that only happen to share the
+// name survive.
+func (c *Client) RemoveSidecars(p string) ([]string, error) {
+	var removed []string
+	for _, alg := range sidecarAlgorithms {
+		s := Sidecar{Algorithm: alg}
+		info, err := c.ReadFile(s.Path(p))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			continue
+		case err != nil:
+			return removed, err
+		case info.Type != FileTypeRegular || !c.describes(p, s, strings.TrimSpace(info.Content)):
+			continue
+		}
+		if err := c.Remove(s.Path(p)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, s.Path(p))
+	}
+	return removed, nil
+}
+
+// describes reports whether line, a sidecar's content, is the checksum of
+// the file at p in any of the formats.
+func (c *Client) describes(p string, s Sidecar, line string) bool {
+	name := path.Base(p)
+	if sum, file, ok := strings.Cut(line, "  "); ok && !strings.Contains(sum, " ") {
+		return file == name
+	}
+	if strings.HasPrefix(line, strings.ToUpper(s.Algorithm.String())+" (") {
+		return strings.Contains(line, "("+name+") = ")
+	}
+	if strings.ContainsAny(line, " \n") {
+		return false
+	}
+	h, _ := s.Algorithm.New()
+	sum, err := c.hashFile(p, h)
+	return err == nil && strings.EqualFold(sum, line)
+}
@@ -0,0 +1,63 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSidecar verifies checksum files are written in each layout with the
+// upload, hash the bytes as stored, and are only removed when they describe
+// the file.
+func TestSidecar(t *testing.T) {
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	sum, err := HashSHA256.Sum([]byte("hello\n"))
+	require.NoError(t, err)
+
+	for format, want := range map[SidecarFormat]string{
+		SidecarGNU:  sum + "  a.txt\n",
+		SidecarBSD:  "SHA256 (a.txt) = " + sum + "\n",
+		SidecarHash: sum + "\n",
+	} {
+		op := waitFor(t, c, c.StartUploadWithOptions("/upload/a.txt", "hello\n", UploadOptions{Permissions: 0644, Sidecar: &Sidecar{Algorithm: HashSHA256, Format: format}}))
+		require.Equal(t, StateCompleted, op.State, op.Error)
+		info, err := c.ReadFile("/upload/a.txt.sha256")
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Content, format)
+		assert.Equal(t, "0644", info.Permissions)
+	}
+
+	// Compressed uploads are hashed as stored
+	op := waitFor(t, c, c.StartUploadWithOptions("/upload/b.txt", "hello\n", UploadOptions{Permissions: 0644, Middleware: []Middleware{Gzip()}, Sidecar: &Sidecar{Algorithm: HashMD5}}))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	stored, err := c.ReadFile("/upload/b.txt")
+	require.NoError(t, err)
+	md5sum, err := HashMD5.Sum([]byte(stored.Content))
+	require.NoError(t, err)
+	info, err := c.ReadFile("/upload/b.txt.md5")
+	require.NoError(t, err)
+	assert.Equal(t, md5sum+"  b.txt\n", info.Content)
+
+	// A stale bare hash and a sidecar naming another file are not a.txt's
+	require.NoError(t, c.WriteFile("/upload/a.txt.sha512", []byte("0123abcd\n"), 0644))
+	require.NoError(t, c.WriteFile("/upload/a.txt.md5", []byte(md5sum+"  other.txt\n"), 0644))
+	removed, err := c.RemoveSidecars("/upload/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/a.txt.sha256"}, removed)
+	_, err = c.Stat("/upload/a.txt.sha512")
+	assert.NoError(t, err)
+
+	require.NoError(t, c.WriteSidecar("/upload/a.txt", Sidecar{Algorithm: HashSHA512, Format: SidecarHash}))
+	removed, err = c.RemoveSidecars("/upload/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/a.txt.sha512"}, removed)
+}
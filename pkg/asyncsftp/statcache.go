@@ -0,0 +1,117 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"sync"
+	"time"
+)
+
+// statCache remembers Stat results, including missing files, for a short
+// while. Applies that read many files in the same directories would
+// otherwise stat each of them several times: once to read it, again to
+// check it before an update or delete, and once more in discovery.
+//
+// The client forgets a path whenever it changes it. Changes made by others,
+// or by commands run through Exec, show up once the entry expires.
+type statCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	gen     uint64 // bumped by every invalidation
+	entries map[string]statEntry
+	swept   time.Time // when expired entries were last dropped
+}
+
+type statEntry struct {
+	info *FileInfo // nil when the file was missing
+	at   time.Time
+}
+
+// newStatCache returns a cache keeping entries for ttl, or nil when ttl is
+// not positive, which disables caching.
+func newStatCache(ttl time.Duration, clock Clock) *statCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &statCache{ttl: ttl, clock: clock, entries: make(map[string]statEntry)}
+}
+
+// get returns the cached result for path, with a nil info meaning the
+// file was missing, and whether there was one. The generation lets the
+// caller store a fresh result only if nothing was invalidated while it was
+// fetched.
+func (s *statCache) get(path string) (info *FileInfo, cached bool, gen uint64) {
+	if s == nil {
+		return nil, false, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[path]
+	if !ok || s.clock.Now().Sub(entry.at) > s.ttl {
+		return nil, false, s.gen
+	}
+	if entry.info != nil {
+		copy := *entry.info
+		info = &copy
+	}
+	return info, true, s.gen
+}
+
+// put caches info for path, or that path is missing when info is nil,
+// unless anything was invalidated since gen.
+func (s *statCache) put(path string, info *FileInfo, gen uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gen != gen {
+		return
+	}
+	if info != nil {
+		copy := *info
+		info = &copy
+	}
+	now := s.clock.Now()
+	if now.Sub(s.swept) > s.ttl {
+		for p, entry := range s.entries {
+			if now.Sub(entry.at) > s.ttl {
+				delete(s.entries, p)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[path] = statEntry{info: info, at: now}
+}
+
+// generation returns the current generation, for results put after a
+// listing.
+func (s *statCache) generation() uint64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gen
+}
+
+// forget drops the cached results for paths.
+func (s *statCache) forget(paths ...string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for _, p := range paths {
+		delete(s.entries, p)
+	}
+}
@@ -0,0 +1,58 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStatCache verifies Stat results are reused until they expire, and
+// that the client's own writes are seen at once.
+func TestStatCache(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload",
+		Clock: clock, StatCacheTTL: time.Second})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	// A second client on the same store changes files behind c's back
+	other, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = other.Close() }()
+
+	_, err = c.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, other.WriteFile("/upload/a.txt", []byte("a"), 0644))
+	_, err = c.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound, "missing files are cached too")
+
+	clock.Advance(2 * time.Second)
+	info, err := c.Stat("/upload/a.txt")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, info.Size)
+
+	require.NoError(t, c.WriteFile("/upload/a.txt", []byte("abc"), 0600))
+	info, err = c.Stat("/upload/a.txt")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, info.Size)
+	assert.Equal(t, "0600", info.Permissions)
+
+	waitFor(t, c, c.StartDelete("/upload/a.txt"))
+	_, err = c.Stat("/upload/a.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Listings fill the cache
+	require.NoError(t, other.WriteFile("/upload/b.txt", []byte("b"), 0644))
+	_, err = c.ReadDir("/upload")
+	require.NoError(t, err)
+	require.NoError(t, other.Remove("/upload/b.txt"))
+	_, err = c.Stat("/upload/b.txt")
+	assert.NoError(t, err)
+}
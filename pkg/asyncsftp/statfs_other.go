@@ -0,0 +1,17 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build !linux && !darwin && !freebsd
+
+package asyncsftp
+
+import (
+	"errors"
+	"os"
+)
+
+// statfs is not implemented on this platform.
+func statfs(*os.File) (*DiskUsage, error) {
+	return nil, errors.ErrUnsupported
+}
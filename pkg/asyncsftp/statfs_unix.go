@@ -0,0 +1,29 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build linux || darwin || freebsd
+
+package asyncsftp
+
+import (
+	"os"
+	"syscall"
+)
+
+// statfs reports the capacity of the filesystem holding an open file.
+func statfs(f *os.File) (*DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Fstatfs(int(f.Fd()), &st); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: f.Name(), Err: err}
+	}
+	bsize := uint64(st.Bsize)
+	return &DiskUsage{
+		TotalBytes:     uint64(st.Blocks) * bsize,
+		FreeBytes:      uint64(st.Bfree) * bsize,
+		AvailableBytes: uint64(st.Bavail) * bsize,
+		TotalFiles:     uint64(st.Files),
+		FreeFiles:      uint64(st.Ffree),
+		ReadOnly:       uint64(st.Flags)&stRdonly != 0,
+	}, nil
+}
@@ -0,0 +1,51 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SessionStats summarizes the work a client has done since it connected.
+type SessionStats struct {
+	Operations      int64         // async operations completed or failed
+	Failures        int64         // async operations that failed
+	BytesUploaded   int64         // content bytes written
+	BytesDownloaded int64         // content bytes read
+	AverageLatency  time.Duration // mean duration of async operations
+}
+
+// sessionCounters accumulates SessionStats without taking the client lock.
+type sessionCounters struct {
+	operations atomic.Int64
+	failures   atomic.Int64
+	bytesUp    atomic.Int64
+	bytesDown  atomic.Int64
+	latency    atomic.Int64 // total nanoseconds across operations
+}
+
+// recordOperation counts a finished async operation.
+func (s *sessionCounters) recordOperation(op *Operation) {
+	s.operations.Add(1)
+	if op.State == StateFailure {
+		s.failures.Add(1)
+	}
+	s.latency.Add(int64(op.Duration()))
+}
+
+// Stats returns the client's session statistics so far.
+func (c *Client) Stats() SessionStats {
+	stats := SessionStats{
+		Operations:      c.stats.operations.Load(),
+		Failures:        c.stats.failures.Load(),
+		BytesUploaded:   c.stats.bytesUp.Load(),
+		BytesDownloaded: c.stats.bytesDown.Load(),
+	}
+	if stats.Operations > 0 {
+		stats.AverageLatency = time.Duration(c.stats.latency.Load() / stats.Operations)
+	}
+	return stats
+}
@@ -0,0 +1,144 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// OpenFile opens the regular file at path for reading in pieces, for files
+// too large to read whole, and returns its metadata. Other file types are
+// reported without being opened. The caller closes the reader; what is read
+// counts towards the session's downloads.
+func (c *Client) OpenFile(path string) (*FileInfo, io.ReadCloser, error) {
+	info, err := c.stat(path)
+	if err != nil || info.Type != FileTypeRegular {
+		return info, nil, err
+	}
+	f, err := c.fs.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open failed: %w", err)
+	}
+	return info, &downloadReader{ReadCloser: f, c: c}, nil
+}
+
+// downloadReader counts what is read through it towards the session's
+// downloads.
+type downloadReader struct {
+	io.ReadCloser
+	c *Client
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.c.stats.bytesDown.Add(int64(n))
+	return n, err
+}
+
+// StartUploadFrom begins writing the size bytes src yields to path, through
+// a staged sibling renamed into place once all of them were written, so
+// content too large to hold in memory is never held whole. src must stay
+// readable until the operation finishes. Unlike StartUpload the content is
+// not known up front, so identical requests are not deduplicated and no
+// intent is recorded. Returns an operation ID to poll for completion.
+func (c *Client) StartUploadFrom(path string, src io.Reader, size int64, permissions os.FileMode) string {
+	opID := uuid.New().String()
+
+	op := &Operation{
+		ID:         opID,
+		Type:       OperationTypeUpload,
+		Priority:   PriorityFor(OperationTypeUpload, size),
+		Path:       path,
+		State:      StateInProgress,
+		StartedAt:  c.clock.Now(),
+		RequestIDs: []string{opID},
+	}
+	c.track(op)
+
+	c.queue.submit(op, func() { c.dequeued(op); c.doUploadFrom(op, src, size, permissions) })
+
+	return opID
+}
+
+func (c *Client) doUploadFrom(op *Operation, src io.Reader, size int64, permissions os.FileMode) {
+	// Only this worker writes op's timings, so reading them is safe
+	timings := op.Timings
+	result, err := c.uploadFrom(op, src, size, permissions, &timings)
+
+	c.operations.update(op, func() {
+		op.Timings = timings
+		op.Result = result
+	})
+
+	if err != nil {
+		c.completeOperation(op, StateFailure, c.refused(op.Path, err))
+		return
+	}
+	c.completeOperation(op, StateCompleted, nil)
+}
+
+// uploadFrom writes src to a staged sibling of op's path and renames it
+// into place, recording each phase's duration in timings. The result
+// carries no content.
+func (c *Client) uploadFrom(op *Operation, src io.Reader, size int64, permissions os.FileMode, timings *Timings) (_ *FileInfo, err error) {
+	if err := c.CheckSize(size); err != nil {
+		return nil, err
+	}
+	path := op.Path
+	staged := fmt.Sprintf("%s.tmp.%s", path, uuid.New().String())
+	defer func() {
+		if err != nil {
+			_ = c.fs.Remove(staged)
+		}
+	}()
+
+	start := c.clock.Now()
+	f, err := c.fs.Create(staged)
+	timings.Open = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("create failed: %w", err)
+	}
+	start = c.clock.Now()
+	written, err := c.copyFair(op, f, src)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	timings.Write = c.since(start)
+	if err == nil && written != size {
+		err = fmt.Errorf("source ended after %d of %d bytes", written, size)
+	}
+	if err != nil {
+		return nil, &PartialWriteError{Path: path, Written: written, Total: size, Err: err}
+	}
+	c.stats.bytesUp.Add(written)
+
+	start = c.clock.Now()
+	err = c.chmod(staged, permissions)
+	timings.Chmod = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("chmod failed: %w", err)
+	}
+	if err := c.fs.Rename(staged, path); err != nil {
+		return nil, fmt.Errorf("rename failed: %w", err)
+	}
+
+	start = c.clock.Now()
+	stat, err := c.lstat(path)
+	timings.Stat = c.since(start)
+	if err != nil {
+		return nil, fmt.Errorf("stat failed: %w", err)
+	}
+	return &FileInfo{
+		Path:        path,
+		Type:        FileTypeRegular,
+		Permissions: permissionsOf(stat),
+		Size:        written,
+		ModifiedAt:  c.modTime(stat),
+	}, nil
+}
@@ -0,0 +1,57 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUploadFrom verifies streamed uploads land whole through a staged
+// copy, leave nothing behind when the source ends early, and that OpenFile
+// streams regular files and only reports other types.
+func TestUploadFrom(t *testing.T) {
+	t.Cleanup(func() { ResetMemory(t.Name()) })
+	c, err := NewClient(Config{Protocol: ProtocolMemory, Host: t.Name(), LocalDir: "/upload"})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	content := strings.Repeat("x", 3*fairShareChunk+1)
+	op := waitFor(t, c, c.StartUploadFrom("/upload/a.bin", strings.NewReader(content), int64(len(content)), 0o640))
+	require.Equal(t, StateCompleted, op.State, op.Error)
+	assert.Equal(t, int64(len(content)), op.BytesWritten)
+	require.NotNil(t, op.Result)
+	assert.Equal(t, "0640", op.Result.Permissions)
+	assert.Empty(t, op.Result.Content)
+
+	info, r, err := c.OpenFile("/upload/a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+	read, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, content, string(read))
+
+	op = waitFor(t, c, c.StartUploadFrom("/upload/a.bin", strings.NewReader("short"), 10, 0o640))
+	require.Equal(t, StateFailure, op.State)
+	var partial *PartialWriteError
+	require.ErrorAs(t, op.Err, &partial)
+	assert.Equal(t, int64(5), partial.Written)
+	paths, err := c.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/upload/a.bin"}, paths)
+
+	require.NoError(t, MemorySymlink(t.Name(), "/upload/a.bin", "/upload/link"))
+	info, r, err = c.OpenFile("/upload/link")
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.Equal(t, FileTypeSymlink, info.Type)
+}
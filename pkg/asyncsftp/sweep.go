@@ -0,0 +1,63 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/kr/fs"
+)
+
+// temporaryName matches the files a Client creates and removes again by
+// itself: staged writes (<name>.tmp.<uuid>) and clock-skew probes
+// (.formae-clock-<uuid>). A crash between creating one and renaming or
+// removing it leaves it behind.
+var temporaryName = regexp.MustCompile(`(\.tmp\.|^\.formae-clock-)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// IsTemporary reports whether p names one of the temporary files a Client
+// creates and removes again by itself.
+func IsTemporary(p string) bool {
+	return temporaryName.MatchString(path.Base(p))
+}
+
+// SweepTemporary removes temporary files that earlier, crashed runs left
+// under dir. Only files last modified more than minAge ago are removed, so
+// those of operations still running are left alone. It returns the removed
+// paths; subdirectories that cannot be read are skipped, and files that
+// cannot be removed are reported in the error.
+func (c *Client) SweepTemporary(dir string, minAge time.Duration) ([]string, error) {
+	var removed []string
+	var errs []error
+
+	walker := fs.WalkFS(dir, walkFS{c.fs})
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if walker.Path() == dir {
+				return nil, fmt.Errorf("readdir failed: %w", err)
+			}
+			continue
+		}
+		info := walker.Stat()
+		if !info.Mode().IsRegular() || !temporaryName.MatchString(path.Base(walker.Path())) {
+			continue
+		}
+		// Servers that report no modification time give no age to go by
+		if modTime := c.modTime(info); modTime.IsZero() || c.since(modTime) < minAge {
+			continue
+		}
+		if err := c.fs.Remove(walker.Path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("remove %s: %w", walker.Path(), err))
+			continue
+		}
+		removed = append(removed, walker.Path())
+	}
+	c.statCache.forget(removed...)
+	return removed, errors.Join(errs...)
+}
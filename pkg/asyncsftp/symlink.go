@@ -0,0 +1,46 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrSymlinkLoop indicates a symlink that cannot be resolved, because its
+// chain of links comes back on itself or is longer than maxSymlinkHops.
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// maxSymlinkHops bounds the links ResolveLink follows, as Linux's
+// MAXSYMLINKS does.
+const maxSymlinkHops = 40
+
+// ResolveLink returns the path p's symlinks finally point at: p itself
+// when it is not a symlink. Relative targets are resolved against the
+// directory of the link. A chain that visits a link twice or exceeds
+// maxSymlinkHops fails with ErrSymlinkLoop, and a link to nothing with
+// ErrNotFound.
+func (c *Client) ResolveLink(p string) (string, error) {
+	seen := map[string]bool{}
+	for hops := 0; ; hops++ {
+		info, err := c.stat(p)
+		if err != nil {
+			return "", err
+		}
+		if info.Type != FileTypeSymlink {
+			return p, nil
+		}
+		if seen[p] || hops == maxSymlinkHops {
+			return "", fmt.Errorf("%w: %s", ErrSymlinkLoop, p)
+		}
+		seen[p] = true
+		target := info.LinkTarget
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = path.Clean(target)
+	}
+}
@@ -0,0 +1,48 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveLink verifies symlink chains are followed to their final
+// target, and that loops are refused rather than followed forever.
+func TestResolveLink(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "etc", "app"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "etc", "app", "app.conf"), []byte("a"), 0644))
+	require.NoError(t, os.Symlink("app/app.conf", filepath.Join(dir, "etc", "current")))
+	require.NoError(t, os.Symlink("/etc/current", filepath.Join(dir, "etc", "latest")))
+	require.NoError(t, os.Symlink("ping", filepath.Join(dir, "etc", "pong")))
+	require.NoError(t, os.Symlink("pong", filepath.Join(dir, "etc", "ping")))
+	require.NoError(t, os.Symlink("missing", filepath.Join(dir, "etc", "dangling")))
+
+	c, err := NewClient(Config{Protocol: ProtocolFile, LocalDir: dir})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	for link, want := range map[string]string{
+		"/etc/app/app.conf": "/etc/app/app.conf",
+		"/etc/current":      "/etc/app/app.conf",
+		"/etc/latest":       "/etc/app/app.conf",
+	} {
+		got, err := c.ResolveLink(link)
+		require.NoError(t, err, link)
+		assert.Equal(t, want, got, link)
+	}
+
+	_, err = c.ResolveLink("/etc/ping")
+	assert.ErrorIs(t, err, ErrSymlinkLoop)
+	_, err = c.ResolveLink("/etc/dangling")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
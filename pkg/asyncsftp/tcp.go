@@ -0,0 +1,77 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// TCPOptions tunes the TCP connections a client dials, e.g. larger buffers
+// for servers on high-latency links, where the kernel's defaults cap
+// throughput well below the bandwidth. The zero value keeps Go's defaults:
+// keepalive probes every 15 seconds, TCP_NODELAY on and the kernel's
+// buffer sizes.
+type TCPOptions struct {
+	// KeepAlive is the interval between keepalive probes on an idle
+	// connection. Negative disables them.
+	KeepAlive time.Duration
+
+	// NoDelay, when set, turns TCP_NODELAY on or off. Off lets the kernel
+	// coalesce small writes (Nagle's algorithm).
+	NoDelay *bool
+
+	// ReadBuffer and WriteBuffer, when positive, are the socket receive and
+	// send buffer sizes in bytes. The kernel may cap or round them.
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// validate rejects options no socket can take.
+func (o TCPOptions) validate() error {
+	if o.ReadBuffer < 0 || o.WriteBuffer < 0 {
+		return fmt.Errorf("%w: negative TCP buffer size", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// dial connects to addr over TCP within timeout and applies the options.
+func (o TCPOptions) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout, KeepAlive: o.KeepAlive}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return conn, nil
+	}
+	if err := o.apply(tcp); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// apply sets the socket options on conn.
+func (o TCPOptions) apply(conn *net.TCPConn) error {
+	if o.NoDelay != nil {
+		if err := conn.SetNoDelay(*o.NoDelay); err != nil {
+			return fmt.Errorf("set TCP_NODELAY: %w", err)
+		}
+	}
+	if o.ReadBuffer > 0 {
+		if err := conn.SetReadBuffer(o.ReadBuffer); err != nil {
+			return fmt.Errorf("set receive buffer: %w", err)
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := conn.SetWriteBuffer(o.WriteBuffer); err != nil {
+			return fmt.Errorf("set send buffer: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTCPOptions verifies tuned connections are dialed, and that options no
+// socket can take are refused before dialing.
+func TestTCPOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	noDelay := false
+	conn, err := TCPOptions{KeepAlive: -1, NoDelay: &noDelay, ReadBuffer: 1 << 20, WriteBuffer: 1 << 20}.dial(ln.Addr().String(), time.Second)
+	require.NoError(t, err)
+	_ = conn.Close()
+
+	_, err = NewClient(Config{Protocol: ProtocolMemory, LocalDir: "/upload", TCP: TCPOptions{ReadBuffer: -1}})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -0,0 +1,300 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/kr/fs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Protocol selects the Transport a Client connects with.
+type Protocol string
+
+const (
+	ProtocolSFTP   Protocol = "sftp"
+	ProtocolSCP    Protocol = "scp"
+	ProtocolFTPS   Protocol = "ftps"
+	ProtocolFile   Protocol = "file"
+	ProtocolMemory Protocol = "memory"
+)
+
+// Transport is the file-transfer protocol a Client drives. Operation
+// tracking, queuing and the sync helpers are shared; only these primitives
+// differ between protocols.
+type Transport interface {
+	// Create opens path for writing, truncating any existing file.
+	Create(path string) (io.WriteCloser, error)
+	// Open opens path for reading.
+	Open(path string) (io.ReadCloser, error)
+	// Lstat returns path's metadata without following symlinks.
+	Lstat(path string) (os.FileInfo, error)
+	// ReadLink returns a symlink's target.
+	ReadLink(path string) (string, error)
+	// ReadDir lists a directory's entries.
+	ReadDir(dir string) ([]os.FileInfo, error)
+	// Chmod changes permissions. Transports without permission support
+	// return errors.ErrUnsupported.
+	Chmod(path string, mode os.FileMode) error
+	// Remove deletes a file.
+	Remove(path string) error
+	// Rename moves oldpath to newpath, replacing any existing file.
+	Rename(oldpath, newpath string) error
+	// Close releases the connection.
+	Close() error
+}
+
+// chowner is implemented by transports that can change file ownership.
+type chowner interface {
+	Chown(path string, uid, gid int) error
+}
+
+// realpather is implemented by transports that can resolve a path's
+// symlinks and "." and ".." segments.
+type realpather interface {
+	RealPath(path string) (string, error)
+}
+
+// appender is implemented by transports that can write to the end of a
+// file, creating it if needed, without rewriting what is already there.
+type appender interface {
+	Append(path string) (io.WriteCloser, error)
+}
+
+// copier is implemented by transports that can copy a file without the
+// content crossing the network.
+type copier interface {
+	Copy(src, dst string) error
+}
+
+// checksummer is implemented by transports that can hash a file on the
+// server, without exec.
+type checksummer interface {
+	// Checksum returns the hex-encoded SHA-256 of the regular file p.
+	Checksum(p string) (string, error)
+}
+
+// mkdirer is implemented by transports that can create directories.
+type mkdirer interface {
+	// Mkdir creates the directory p, whose parent must exist.
+	Mkdir(p string) error
+}
+
+// dirStreamer is implemented by transports that can read a directory in
+// batches, calling fn for each entry until it returns false.
+type dirStreamer interface {
+	ReadDirFunc(dir string, fn func(os.FileInfo) bool) error
+}
+
+// ownerOf extracts ownership from a transport's FileInfo, or nil.
+func ownerOf(fi os.FileInfo) *FileOwner {
+	switch sys := fi.Sys().(type) {
+	case *sftp.FileStat:
+		return &FileOwner{UID: int(sys.UID), GID: int(sys.GID)}
+	case *FileOwner:
+		return sys
+	default:
+		return sysOwner(sys)
+	}
+}
+
+// walkFS adapts a Transport to the kr/fs walker.
+type walkFS struct {
+	Transport
+}
+
+func (walkFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+var _ fs.FileSystem = walkFS{}
+
+// remoteFileInfo is an os.FileInfo parsed from a server listing, for
+// transports whose protocol has no native stat.
+type remoteFileInfo struct {
+	name       string
+	size       int64
+	mode       os.FileMode
+	modTime    time.Time
+	linkTarget string
+	owner      *FileOwner
+}
+
+func (fi *remoteFileInfo) Name() string       { return fi.name }
+func (fi *remoteFileInfo) Size() int64        { return fi.size }
+func (fi *remoteFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *remoteFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *remoteFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *remoteFileInfo) Sys() any           { return fi.owner }
+
+// =============================================================================
+// SFTP
+// =============================================================================
+
+// SFTPVersion3 is the SFTP protocol version pkg/sftp negotiates. It
+// rejects servers that will not speak it during the handshake.
+const SFTPVersion3 = 3
+
+// sftpExtensions are the OpenSSH extensions sftpTransport uses when the
+// server advertises them.
+var sftpExtensions = []string{"posix-rename@openssh.com", "statvfs@openssh.com"}
+
+// sftpTransport is the default Transport over an SSH SFTP subsystem.
+type sftpTransport struct {
+	client     *sftp.Client
+	extensions map[string]bool // usable extensions, by name
+}
+
+// newSFTPTransport records which of sftpExtensions the server offers. With
+// strictV3 none are used, for servers that advertise extensions they
+// implement incorrectly.
+func newSFTPTransport(client *sftp.Client, strictV3 bool) *sftpTransport {
+	t := &sftpTransport{client: client, extensions: map[string]bool{}}
+	if strictV3 {
+		return t
+	}
+	for _, name := range sftpExtensions {
+		if _, ok := client.HasExtension(name); ok {
+			t.extensions[name] = true
+		}
+	}
+	return t
+}
+
+// newSFTPClient opens an SFTP session on the standard "sftp" subsystem, on
+// the subsystem cfg.SFTPSubsystem names, or by running
+// cfg.SFTPServerCommand over exec.
+func newSFTPClient(sshClient *ssh.Client, cfg Config, opts ...sftp.ClientOption) (*sftp.Client, error) {
+	if cfg.SFTPSubsystem == "" && cfg.SFTPServerCommand == "" {
+		return sftp.NewClient(sshClient, opts...)
+	}
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	if cfg.SFTPServerCommand != "" {
+		err = session.Start(cfg.SFTPServerCommand)
+	} else {
+		err = session.RequestSubsystem(cfg.SFTPSubsystem)
+	}
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	client, err := sftp.NewClientPipe(stdout, stdin, opts...)
+	if err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("%s did not speak SFTP: %w", sftpServerName(cfg), err)
+	}
+	go func() {
+		_ = client.Wait()
+		_ = session.Close()
+	}()
+	return client, nil
+}
+
+// sftpServerName describes where cfg finds the SFTP server, for errors.
+func sftpServerName(cfg Config) string {
+	if cfg.SFTPServerCommand != "" {
+		return fmt.Sprintf("command %q", cfg.SFTPServerCommand)
+	}
+	return fmt.Sprintf("subsystem %q", cfg.SFTPSubsystem)
+}
+
+func (t *sftpTransport) Create(path string) (io.WriteCloser, error) {
+	return t.client.Create(path)
+}
+
+func (t *sftpTransport) Append(path string) (io.WriteCloser, error) {
+	return t.client.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+}
+
+func (t *sftpTransport) Open(path string) (io.ReadCloser, error) {
+	return t.client.Open(path)
+}
+
+func (t *sftpTransport) Lstat(path string) (os.FileInfo, error) {
+	return t.client.Lstat(path)
+}
+
+func (t *sftpTransport) ReadLink(path string) (string, error) {
+	return t.client.ReadLink(path)
+}
+
+func (t *sftpTransport) ReadDir(dir string) ([]os.FileInfo, error) {
+	return t.client.ReadDir(dir)
+}
+
+func (t *sftpTransport) RealPath(path string) (string, error) {
+	return t.client.RealPath(path)
+}
+
+func (t *sftpTransport) Chmod(path string, mode os.FileMode) error {
+	return t.client.Chmod(path, mode)
+}
+
+func (t *sftpTransport) Chown(path string, uid, gid int) error {
+	return t.client.Chown(path, uid, gid)
+}
+
+// StatVFS uses the statvfs@openssh.com extension.
+func (t *sftpTransport) StatVFS(path string) (*DiskUsage, error) {
+	if !t.extensions["statvfs@openssh.com"] {
+		return nil, errors.ErrUnsupported
+	}
+	st, err := t.client.StatVFS(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskUsage{
+		TotalBytes:     st.Blocks * st.Frsize,
+		FreeBytes:      st.Bfree * st.Frsize,
+		AvailableBytes: st.Bavail * st.Frsize,
+		TotalFiles:     st.Files,
+		FreeFiles:      st.Ffree,
+		ReadOnly:       st.Flag&stRdonly != 0,
+	}, nil
+}
+
+func (t *sftpTransport) Mkdir(path string) error {
+	return t.client.Mkdir(path)
+}
+
+func (t *sftpTransport) Remove(path string) error {
+	return t.client.Remove(path)
+}
+
+// Rename uses the atomic posix-rename extension when the server supports it.
+func (t *sftpTransport) Rename(oldpath, newpath string) error {
+	if t.extensions["posix-rename@openssh.com"] {
+		return t.client.PosixRename(oldpath, newpath)
+	}
+	// Plain SFTP rename refuses to overwrite an existing file
+	if err := t.client.Remove(newpath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return t.client.Rename(oldpath, newpath)
+}
+
+func (t *sftpTransport) Close() error {
+	return t.client.Close()
+}
@@ -6,12 +6,79 @@ package asyncsftp
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"slices"
 	"time"
 )
 
 // ErrNotFound indicates the file does not exist.
 var ErrNotFound = errors.New("file not found")
 
+// ErrAuthFailed indicates the server rejected the supplied credentials.
+var ErrAuthFailed = errors.New("authentication failed")
+
+// ErrUnreachable indicates the server could not be reached or the connection
+// was lost.
+var ErrUnreachable = errors.New("server unreachable")
+
+// ListError records a subdirectory that could not be listed during a tree walk.
+type ListError struct {
+	Path string
+	Err  error
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("list %s: %v", e.Path, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// PartialWriteError records how far a write got before it failed and
+// whether the file was left incomplete, for servers without atomic writes
+// where an operator may have to clean up by hand.
+type PartialWriteError struct {
+	Path string
+	// Written is how many bytes of Total the transport accepted. Transports
+	// that buffer content (SCP, the memory store) only send it on close.
+	Written int64
+	Total   int64
+	// Partial reports that Path may hold an incomplete write: a truncated
+	// file, or the start of an append.
+	Partial bool
+	Err     error
+}
+
+func (e *PartialWriteError) Error() string {
+	remains := "no partial file remains"
+	if e.Partial {
+		remains = "a partial file may remain at " + e.Path
+	}
+	return fmt.Sprintf("write failed after %d of %d bytes, %s: %v", e.Written, e.Total, remains, e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// QuarantineError records an upload that failed verification and was moved
+// aside for inspection instead of being removed.
+type QuarantineError struct {
+	// Path is where the rejected file now is.
+	Path string
+	Err  error
+}
+
+func (e *QuarantineError) Error() string {
+	return fmt.Sprintf("%v; rejected file quarantined at %s", e.Err, e.Path)
+}
+
+func (e *QuarantineError) Unwrap() error {
+	return e.Err
+}
+
 // OperationState represents the state of an async operation.
 type OperationState string
 
@@ -27,18 +94,168 @@ type OperationType string
 const (
 	OperationTypeUpload OperationType = "UPLOAD"
 	OperationTypeDelete OperationType = "DELETE"
+	OperationTypeMove   OperationType = "MOVE"
 )
 
+// Priority orders queued operations. Higher priorities are started first;
+// operations of equal priority run in submission order. The zero value means
+// "derive from the operation" (see PriorityFor).
+type Priority int
+
+const (
+	PriorityLow Priority = iota + 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// smallUploadSize is the largest upload that is prioritized as a quick
+// config-style write by PriorityFor.
+const smallUploadSize = 1 << 20
+
+// PriorityFor derives a default priority from the operation type and payload
+// size: deletes, moves and small uploads jump ahead of large artifact
+// uploads.
+func PriorityFor(opType OperationType, size int64) Priority {
+	if opType == OperationTypeDelete || opType == OperationTypeMove || size <= smallUploadSize {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// UploadOptions tunes an upload beyond its path and content.
+type UploadOptions struct {
+	Permissions os.FileMode
+
+	// Priority overrides the priority derived from the content size.
+	Priority Priority
+
+	// Xattrs are user.* extended attributes applied after the write.
+	// Requires shell access on the server (see SetXattrs).
+	Xattrs map[string]string
+
+	// ACL are extended ACL entries of type ACLType applied after the write.
+	// Requires shell access on the server (see SetACL).
+	ACL     []string
+	ACLType string
+
+	// SELinuxContext is the security context set after the write, which
+	// files created over SFTP do not get from the policy. Requires shell
+	// access on the server (see SetSELinuxContext).
+	SELinuxContext string
+
+	// ValidateCommand, when set, stages the content in a temporary sibling
+	// and runs this shell command over SSH exec with the staged path in
+	// $FORMAE_FILE, e.g. "nginx -t -c $FORMAE_FILE". The file is renamed
+	// into place only if the command exits zero; otherwise the staged copy
+	// is removed and the upload fails with the command's output.
+	ValidateCommand string
+
+	// Verify stages the content like ValidateCommand and reads the staged
+	// copy back before it is renamed into place, failing the upload with
+	// ErrChecksumMismatch if it does not hash to what was written.
+	Verify bool
+
+	// VerifyHash is the algorithm Verify hashes with, HashSHA256 when
+	// empty. An unknown algorithm fails the upload with ErrInvalidConfig.
+	VerifyHash HashAlgorithm
+
+	// Sidecar, when set, writes a checksum file next to the upload once it
+	// is in place, hashing the bytes as stored. Appends do not write one.
+	Sidecar *Sidecar
+
+	// QuarantineDir, when set, is an existing directory that staged uploads
+	// failing Verify or ValidateCommand are moved into, as
+	// <name>.<timestamp>, instead of being removed. The failure is then a
+	// QuarantineError.
+	QuarantineDir string
+
+	// CacheDir, when set, is an existing directory on the server holding
+	// copies of earlier uploads named by their sha256. Uploads of at least
+	// cacheMinSize are copied from there on the server when an entry
+	// matches, and added to it after uploading otherwise. Copies need
+	// shell access, except on local and memory targets.
+	CacheDir string
+
+	// Middleware are the transfer stages content passes through on its way
+	// to the file, in order, e.g. Gzip() then Throttle(n). Read the file
+	// back with ReadFileThrough and the same stages.
+	Middleware []Middleware
+
+	// Append adds content to the end of the file, creating it if needed,
+	// unless the file already contains it. A file that does not end in a
+	// newline gets one first. The result's Content is the appended content
+	// and its Size the resulting file size. Not combined with
+	// ValidateCommand.
+	Append bool
+
+	// CreateParents creates the missing directories above the file, with
+	// ParentPermissions (default 0755) and, when set, ParentOwner. The
+	// operation reports them in CreatedDirs. Directories created for an
+	// upload that then fails are left in place.
+	CreateParents     bool
+	ParentPermissions os.FileMode
+	ParentOwner       *FileOwner
+
+	// Metadata is opaque caller data carried on the operation record.
+	Metadata map[string]string
+}
+
 // Operation represents an async SFTP operation.
 type Operation struct {
 	ID          string
 	Type        OperationType
+	Priority    Priority
 	Path        string
+	From        string // where a move takes the file from
+	Metadata    map[string]string
 	State       OperationState
 	Error       string
+	Err         error // the failure behind Error, for errors.Is
 	Result      *FileInfo
+	Timings     Timings
 	StartedAt   time.Time
 	CompletedAt time.Time
+
+	// BytesWritten is how much of an upload's content has been written so
+	// far.
+	BytesWritten int64
+
+	// CacheHit reports that the upload was copied from CacheDir instead of
+	// sent over the network.
+	CacheHit bool
+
+	// CreatedDirs are the directories an upload with CreateParents created,
+	// outermost first.
+	CreatedDirs []string
+
+	// RequestIDs are the requests this operation satisfies: its own ID,
+	// then each identical upload request attached while it was running.
+	RequestIDs []string
+
+	key  string        // idempotency key of an upload, see uploadKey
+	done chan struct{} // closed once the operation has finished
+}
+
+// finished reports whether the operation has finished, after which its
+// fields no longer change.
+func (o *Operation) finished() bool {
+	if o.done == nil {
+		return false
+	}
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Duration returns how long the operation ran, or zero if it is still running.
+func (o *Operation) Duration() time.Duration {
+	if o.CompletedAt.IsZero() {
+		return 0
+	}
+	return o.CompletedAt.Sub(o.StartedAt)
 }
 
 // Copy returns a copy of the operation (to avoid race conditions).
@@ -47,6 +264,8 @@ func (o *Operation) Copy() *Operation {
 		return nil
 	}
 	copy := *o
+	copy.RequestIDs = slices.Clone(o.RequestIDs)
+	copy.CreatedDirs = slices.Clone(o.CreatedDirs)
 	if o.Result != nil {
 		resultCopy := *o.Result
 		copy.Result = &resultCopy
@@ -54,11 +273,59 @@ func (o *Operation) Copy() *Operation {
 	return &copy
 }
 
+// Timings breaks an operation or connection down into its SFTP phases.
+// Phases that did not run are left at zero.
+type Timings struct {
+	// Queued is how long an operation waited for a free worker before it
+	// started; time the plugin held it back rather than time spent on SFTP.
+	Queued time.Duration
+	Dial   time.Duration
+	Auth   time.Duration
+	Open   time.Duration
+	Write  time.Duration
+	Chmod  time.Duration
+	Stat   time.Duration
+}
+
+// FileType classifies what a remote path points at.
+type FileType string
+
+const (
+	FileTypeRegular   FileType = "regular"
+	FileTypeSymlink   FileType = "symlink"
+	FileTypeDirectory FileType = "directory"
+	FileTypeOther     FileType = "other"
+)
+
+// fileTypeOf classifies a mode as returned by Lstat.
+func fileTypeOf(mode os.FileMode) FileType {
+	switch {
+	case mode.IsRegular():
+		return FileTypeRegular
+	case mode&os.ModeSymlink != 0:
+		return FileTypeSymlink
+	case mode.IsDir():
+		return FileTypeDirectory
+	default:
+		return FileTypeOther
+	}
+}
+
 // FileInfo contains file metadata and content.
+// Content is only populated for regular files; LinkTarget only for symlinks.
 type FileInfo struct {
 	Path        string
+	Type        FileType
 	Content     string
+	LinkTarget  string
 	Permissions string // e.g., "0644"
 	Size        int64
 	ModifiedAt  time.Time
+	Owner       *FileOwner // nil when the transport does not report ownership
+}
+
+// FileOwner is a file's numeric owner and group.
+type FileOwner struct {
+	UID int
+	GID int
 }
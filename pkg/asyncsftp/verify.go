@@ -0,0 +1,128 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// ErrChecksumMismatch indicates a staged upload read back differently from
+// what was written, e.g. through a transfer that rewrote line endings or
+// a full disk that truncated it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// HashAlgorithm is the hash Verify compares a staged upload by, for
+// partners that mandate one for delivery acceptance.
+type HashAlgorithm string
+
+const (
+	// HashSHA256 is the default.
+	HashSHA256 HashAlgorithm = "sha256"
+	HashSHA512 HashAlgorithm = "sha512"
+	// HashXXHash is the 64-bit xxHash: several times faster than the
+	// others on large artifacts, but no defence against tampering.
+	HashXXHash HashAlgorithm = "xxhash"
+	// HashMD5 is only for checksum sidecars partners still ask for.
+	HashMD5 HashAlgorithm = "md5"
+)
+
+// New returns a new hash of the algorithm, or ErrInvalidConfig for one the
+// package does not know. The empty algorithm is HashSHA256.
+func (a HashAlgorithm) New() (hash.Hash, error) {
+	switch a {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("%w: unknown hash algorithm %q", ErrInvalidConfig, a)
+}
+
+// Sum returns the hex-encoded hash of content.
+func (a HashAlgorithm) Sum(content []byte) (string, error) {
+	h, err := a.New()
+	if err != nil {
+		return "", err
+	}
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (a HashAlgorithm) String() string {
+	if a == "" {
+		return string(HashSHA256)
+	}
+	return string(a)
+}
+
+// quarantineTimeFormat names quarantined files by when they were rejected.
+const quarantineTimeFormat = "20060102T150405.000Z"
+
+// verify checks a staged upload, whose stored bytes hash to want with
+// opts.VerifyHash, before it replaces the live file.
+func (c *Client) verify(staged, want string, opts UploadOptions) error {
+	if opts.Verify {
+		h, err := opts.VerifyHash.New()
+		if err != nil {
+			return err
+		}
+		got, err := c.hashFile(staged, h)
+		if err != nil {
+			return fmt.Errorf("verify failed: %w", err)
+		}
+		if got != want {
+			return fmt.Errorf("%w: wrote %s %.12s, read back %.12s", ErrChecksumMismatch, opts.VerifyHash, want, got)
+		}
+	}
+	return c.validate(opts.ValidateCommand, staged)
+}
+
+// contentSum returns the hex-encoded sha256 of content.
+func contentSum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile reads p back and returns its hex-encoded hash with h.
+func (c *Client) hashFile(p string, h hash.Hash) (string, error) {
+	f, err := c.fs.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// quarantine moves a staged upload that failed verification into dir,
+// named after the file it was meant to replace, copying it when dir is on
+// another filesystem, and returns cause with its new location. Without a dir, or if the move fails, the staged copy is
+// left to the caller to remove.
+func (c *Client) quarantine(staged, target, dir string, cause error) error {
+	if dir == "" {
+		return cause
+	}
+	dest := path.Join(dir, path.Base(target)+"."+c.clock.Now().UTC().Format(quarantineTimeFormat))
+	if err := c.move(staged, dest); err != nil {
+		return fmt.Errorf("%w (quarantine to %s failed: %w)", cause, dir, err)
+	}
+	return &QuarantineError{Path: dest, Err: cause}
+}
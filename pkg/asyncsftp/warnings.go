@@ -0,0 +1,59 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package asyncsftp
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Warning describes a server limitation found while connecting. The client
+// works around it, but with weaker guarantees or on a different protocol,
+// which users should hear about before it surprises them.
+type Warning struct {
+	// Code identifies the limitation, e.g. WarningNonAtomicRename.
+	Code    string
+	Message string
+}
+
+// Warning codes.
+const (
+	// WarningSCPFallback: the server disabled the SFTP subsystem, so files
+	// are transferred with SCP and managed with shell commands.
+	WarningSCPFallback = "scp-fallback"
+	// WarningSFTPVersion: the server speaks an SFTP version other than 3,
+	// which pkg/sftp cannot negotiate, so SCP is used instead.
+	WarningSFTPVersion = "sftp-version"
+	// WarningNonAtomicRename: the server lacks posix-rename, so replacing a
+	// file by rename removes the old one first.
+	WarningNonAtomicRename = "non-atomic-rename"
+)
+
+// unexpectedVersion matches pkg/sftp's handshake error for servers that do
+// not speak version 3.
+var unexpectedVersion = regexp.MustCompile(`unexpected server version: want \d+, got (\d+)`)
+
+// unsupportedSFTPVersion returns the version an SFTP handshake failed on.
+func unsupportedSFTPVersion(err error) (int, bool) {
+	m := unexpectedVersion.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	version, err := strconv.Atoi(m[1])
+	return version, err == nil
+}
+
+// Warnings returns the limitations found on this client's server.
+func (c *Client) Warnings() []Warning {
+	warnings := slices.Clone(c.warnings)
+	if t, ok := c.fs.(*sftpTransport); ok && !t.extensions["posix-rename@openssh.com"] {
+		warnings = append(warnings, Warning{
+			Code:    WarningNonAtomicRename,
+			Message: "server does not support posix-rename; staged uploads remove the old file before renaming the new one into place, so it is briefly missing",
+		})
+	}
+	return warnings
+}
@@ -0,0 +1,33 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package asyncsftp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWarnings verifies the SFTP version a handshake failed on is recognized
+// and that warnings found on connect are reported without being shared.
+func TestWarnings(t *testing.T) {
+	version, ok := unsupportedSFTPVersion(errors.New("sftp: unexpected server version: want 3, got 2"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, version)
+	_, ok = unsupportedSFTPVersion(errors.New("ssh: subsystem request failed"))
+	assert.False(t, ok)
+
+	c := &Client{fs: &sftpTransport{extensions: map[string]bool{}}, warnings: make([]Warning, 1, 4)}
+	c.warnings[0] = Warning{Code: WarningSFTPVersion}
+	warnings := c.Warnings()
+	assert.Equal(t, []string{WarningSFTPVersion, WarningNonAtomicRename}, []string{warnings[0].Code, warnings[1].Code})
+	assert.Len(t, c.Warnings(), 2)
+
+	c.fs = &sftpTransport{extensions: map[string]bool{"posix-rename@openssh.com": true}}
+	assert.Len(t, c.Warnings(), 1)
+}
@@ -0,0 +1,88 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// =============================================================================
+// Newer Remote Files
+// =============================================================================
+
+// mtimeSlack absorbs servers that report whole-second modification times.
+const mtimeSlack = time.Second
+
+// lastWrite is what is known of formae's last write of a file: the hash of
+// its content, or failing that when it was written by the server's clock.
+type lastWrite struct {
+	sha256 string
+	at     time.Time
+	source string // "manifest" or "prior state"
+}
+
+// knownWrite returns formae's last write of the file at p: the deployment
+// manifest's entry when there is one, otherwise the prior state.
+func knownWrite(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, p string, prior *FileProperties) (lastWrite, bool) {
+	if cfg.ManifestPath != "" {
+		m, err := readManifest(client, cfg.ManifestPath)
+		if err != nil {
+			log.Debug("could not read deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+		} else if entry, ok := m.Files[p]; ok && entry.SHA256 != "" {
+			return lastWrite{sha256: entry.SHA256, source: "manifest"}, true
+		}
+	}
+	if prior == nil {
+		return lastWrite{}, false
+	}
+	at, _ := parseTimestamp(prior.ModifiedAt)
+	return lastWrite{sha256: prior.SHA256, at: at, source: "prior state"}, prior.SHA256 != "" || !at.IsZero()
+}
+
+// checkNewer refuses, with errConflict, to overwrite the file at p when it
+// changed on the server after formae last wrote it: its content no longer
+// hashes to what formae wrote or, where only the time is known, it was
+// modified later. Without anything to compare against it is let through.
+func checkNewer(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, p string, prior *FileProperties, current *asyncsftp.FileInfo) error {
+	known, ok := knownWrite(log, client, cfg, p, prior)
+	if !ok {
+		log.Warn("protectNewer has nothing to compare against; overwriting", "path", p)
+		return nil
+	}
+	if known.sha256 != "" {
+		info, err := client.ReadFileThrough(p, compressionStages(managedCompression(log, client, cfg, p))...)
+		if err != nil {
+			return fmt.Errorf("protectNewer: %w", err)
+		}
+		if got := contentHash(info.Content); got != known.sha256 {
+			return fmt.Errorf("%w: %s was modified on the server after formae last wrote it (sha256 %.12s, %s has %.12s); "+
+				"reconcile the change, or unset protectNewer to overwrite it", errConflict, p, got, known.source, known.sha256)
+		}
+		return nil
+	}
+	if current.ModifiedAt.After(known.at.Add(mtimeSlack)) {
+		return fmt.Errorf("%w: %s was modified on the server at %s, after formae last wrote it at %s; "+
+			"reconcile the change, or unset protectNewer to overwrite it", errConflict, p,
+			current.ModifiedAt.UTC().Format(time.RFC3339), known.at.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// parseTimestamp reads a modification time in any timestampFormat.
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	epoch, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return time.Unix(epoch, 0), nil
+}
@@ -0,0 +1,127 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Quiet Hours
+// =============================================================================
+
+// errQuietHours marks a write deferred because the target is in one of its
+// quiet-hours windows.
+var errQuietHours = errors.New("target is in quiet hours")
+
+// weekdays maps the day names accepted in quiet-hours windows.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// quietWindow is a daily span during which writes are deferred.
+// A window whose end is before its start runs past midnight into the next
+// day.
+type quietWindow struct {
+	spec       string
+	days       [7]bool // days the window starts on; all false means every day
+	start, end int     // minutes since midnight
+}
+
+// parseQuietWindow parses "[days ]HH:MM-HH:MM", where days is a comma
+// separated list of names or ranges, e.g. "Mon-Fri 01:00-04:00" or
+// "Sat,Sun 22:00-06:00".
+func parseQuietWindow(spec string) (quietWindow, error) {
+	w := quietWindow{spec: spec}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid quiet hours %q: expected \"[days ]HH:MM-HH:MM\"", spec)
+	}
+	if len(fields) == 2 {
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(strings.ToLower(part), "-")
+			first, ok1 := weekdays[from]
+			last, ok2 := weekdays[to]
+			if !isRange {
+				last, ok2 = first, ok1
+			}
+			if !ok1 || !ok2 {
+				return w, fmt.Errorf("invalid quiet hours %q: unknown day in %q", spec, part)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[len(fields)-1], "-")
+	start, err1 := time.Parse("15:04", from)
+	end, err2 := time.Parse("15:04", to)
+	if !ok || err1 != nil || err2 != nil || from == to {
+		return w, fmt.Errorf("invalid quiet hours %q: expected a time range such as 01:00-04:00", spec)
+	}
+	w.start = start.Hour()*60 + start.Minute()
+	w.end = end.Hour()*60 + end.Minute()
+	return w, nil
+}
+
+// startsOn reports whether the window opens on day.
+func (w quietWindow) startsOn(day time.Weekday) bool {
+	return w.days == [7]bool{} || w.days[day]
+}
+
+// contains reports whether t, in the window's time zone, falls inside it.
+func (w quietWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.startsOn(t.Weekday()) && m >= w.start && m < w.end
+	}
+	// Past midnight: the evening part belongs to today's window, the early
+	// morning part to yesterday's
+	return (w.startsOn(t.Weekday()) && m >= w.start) ||
+		(w.startsOn((t.Weekday()+6)%7) && m < w.end)
+}
+
+// checkQuietHours returns errQuietHours when now falls in one of the
+// target's quiet-hours windows.
+func checkQuietHours(cfg *TargetConfig, now time.Time) error {
+	if len(cfg.QuietHours) == 0 {
+		return nil
+	}
+	loc, _ := time.LoadLocation(cfg.quietHoursTimezone())
+	now = now.In(loc)
+	for _, spec := range cfg.QuietHours {
+		w, _ := parseQuietWindow(spec)
+		if w.contains(now) {
+			return fmt.Errorf("%w (%s %s): write deferred", errQuietHours, spec, loc)
+		}
+	}
+	return nil
+}
+
+// deferredByQuietHours returns the Throttling failure that defers op on
+// nativeID while the target is in quiet hours, or nil outside them. Creates
+// and deletes are deferred as well as updates: each changes the server as
+// much as a drift correction does.
+func deferredByQuietHours(ctx context.Context, cfg *TargetConfig, op resource.Operation, nativeID string) *resource.ProgressResult {
+	err := checkQuietHours(cfg, time.Now())
+	if err == nil {
+		return nil
+	}
+	plugin.LoggerFromContext(ctx).Info(strings.ToLower(string(op))+" deferred", "nativeID", nativeID, "reason", err)
+	countThrottled(ctx, "quiet_hours")
+	return failureResult(op, nativeID, errorCode(err), err)
+}
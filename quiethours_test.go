@@ -0,0 +1,105 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQuietWindow verifies day lists, ranges and windows that run past
+// midnight.
+func TestQuietWindow(t *testing.T) {
+	// 2025-01-03 is a Friday
+	at := func(day int, clock string) time.Time {
+		tod, err := time.Parse("15:04", clock)
+		require.NoError(t, err)
+		return time.Date(2025, 1, day, tod.Hour(), tod.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"01:00-04:00", at(5, "01:00"), true},
+		{"01:00-04:00", at(5, "04:00"), false},
+		{"Mon-Fri 01:00-04:00", at(3, "02:00"), true},
+		{"Mon-Fri 01:00-04:00", at(4, "02:00"), false},
+		{"Sat,Sun 01:00-04:00", at(5, "02:00"), true},
+		{"Fri 22:00-06:00", at(3, "23:30"), true},
+		{"Fri 22:00-06:00", at(4, "05:59"), true},
+		{"Fri 22:00-06:00", at(3, "05:59"), false},
+		{"Fri-Mon 12:00-13:00", at(5, "12:30"), true},
+		{"Fri-Mon 12:00-13:00", at(7, "12:30"), false},
+	}
+	for _, tt := range tests {
+		w, err := parseQuietWindow(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, w.contains(tt.at), "%s at %s", tt.spec, tt.at.Format("Mon 15:04"))
+	}
+
+	for _, bad := range []string{"", "1:00", "Funday 01:00-02:00", "01:00-01:00", "Mon 01:00-25:00", "a b c"} {
+		_, err := parseQuietWindow(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestCheckQuietHours verifies windows are evaluated in the configured zone.
+func TestCheckQuietHours(t *testing.T) {
+	cfg := &TargetConfig{QuietHours: []string{"01:00-04:00"}, QuietHoursTimezone: "Asia/Tokyo"}
+	assert.ErrorIs(t, checkQuietHours(cfg, time.Date(2025, 1, 2, 17, 30, 0, 0, time.UTC)), errQuietHours)
+	assert.NoError(t, checkQuietHours(cfg, time.Date(2025, 1, 2, 1, 30, 0, 0, time.UTC)))
+	assert.NoError(t, checkQuietHours(&TargetConfig{}, time.Now()))
+}
+
+// TestQuietHoursDeferWrites verifies creates, updates and deletes are all
+// deferred with Throttling during quiet hours, leaving the server alone.
+func TestQuietHoursDeferWrites(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() { asyncsftp.ResetMemory(t.Name()) })
+	open := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	quiet := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload","quietHours":["00:00-12:00","12:00-00:00"]}`)
+	p := &Plugin{settings: Settings{SyncCreateMaxSize: 64}}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, TargetConfig: quiet,
+		Properties: json.RawMessage(`{"path":"/upload/new.conf","content":"a"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeThrottling, created.ProgressResult.ErrorCode)
+
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, TargetConfig: open,
+		Properties: json.RawMessage(`{"path":"/upload/app.conf","content":"a"}`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: quiet,
+		PriorProperties:   json.RawMessage(`{"path":"/upload/app.conf","content":"a"}`),
+		DesiredProperties: json.RawMessage(`{"path":"/upload/app.conf","content":"b"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeThrottling, updated.ProgressResult.ErrorCode)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{NativeID: "/upload/app.conf", ResourceType: fileType, TargetConfig: quiet})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeThrottling, deleted.ProgressResult.ErrorCode)
+	assert.Contains(t, deleted.ProgressResult.StatusMessage, "write deferred")
+
+	for path, want := range map[string]resource.OperationErrorCode{
+		"/upload/new.conf": resource.OperationErrorCodeNotFound,
+		"/upload/app.conf": "",
+	} {
+		read, err := p.Read(ctx, &resource.ReadRequest{NativeID: path, ResourceType: fileType, TargetConfig: open})
+		require.NoError(t, err)
+		assert.Equal(t, want, read.ErrorCode, path)
+	}
+}
@@ -0,0 +1,249 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Retention Policy
+// =============================================================================
+
+// retentionPolicyType prunes a whole directory, e.g. a partner drop box:
+// PatternedFiles with every file in the directory matching. Each create or
+// update removes the files beyond the policy and reports which.
+const retentionPolicyType = "SFTP::Files::RetentionPolicy"
+
+// retentionPolicyHandler manages SFTP::Files::RetentionPolicy resources.
+type retentionPolicyHandler struct {
+	plugin *Plugin
+}
+
+// RetentionPolicyProperties are the properties of a RetentionPolicy
+// resource.
+type RetentionPolicyProperties struct {
+	// Path is the absolute path of the directory to prune (the native ID).
+	// Subdirectories and their contents are left alone.
+	Path string `json:"path"`
+	// MaxAge is a Go duration; files modified longer ago are removed.
+	MaxAge string `json:"maxAge,omitempty"`
+	// MaxCount is how many files to keep, newest first.
+	MaxCount int `json:"maxCount,omitempty"`
+	// Deleted lists the files the last sync removed. Read-only.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// parseRetentionPolicyProperties extracts RetentionPolicy properties from a
+// JSON request.
+func parseRetentionPolicyProperties(data json.RawMessage) (*RetentionPolicyProperties, error) {
+	var props RetentionPolicyProperties
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, fmt.Errorf("invalid retention policy properties: %w", err)
+	}
+	if props.Path == "" {
+		return nil, fmt.Errorf("retention policy properties missing 'path'")
+	}
+	if !path.IsAbs(props.Path) {
+		return nil, fmt.Errorf("%w: %q must be absolute", errInvalidPath, props.Path)
+	}
+	if err := validatePolicy(props.MaxAge, props.MaxCount); err != nil {
+		return nil, err
+	}
+	props.Path = path.Clean(props.Path)
+	props.Deleted = nil
+	return &props, nil
+}
+
+// pattern returns the policy as PatternedFiles matching every file in the
+// directory.
+func (props *RetentionPolicyProperties) pattern() *PatternedFilesProperties {
+	return &PatternedFilesProperties{Pattern: path.Join(props.Path, "*"), MaxAge: props.MaxAge, MaxCount: props.MaxCount}
+}
+
+// apply enforces the policy and records it with the files it removed,
+// returning the resulting properties.
+func (h *retentionPolicyHandler) apply(ctx context.Context, log plugin.Logger, targetConfig json.RawMessage, cfg *TargetConfig, label string, props *RetentionPolicyProperties) (*RetentionPolicyProperties, []string, error) {
+	client, err := h.plugin.getClient(ctx, log, targetConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removed, _, err := enforce(client, cfg, props.pattern())
+	if len(removed) > 0 {
+		log.Info("removed files outside retention policy", "path", props.Path, "files", removed)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	err = updateManifest(client, cfg.ManifestPath, func(m *Manifest) {
+		m.Retention[props.Path] = PatternEntry{
+			MaxAge:    props.MaxAge,
+			MaxCount:  props.MaxCount,
+			Label:     label,
+			UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+			Removed:   removed,
+		}
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to record policy in deployment manifest: %w", err)
+	}
+
+	current := *props
+	current.Deleted = removed
+	return &current, removed, nil
+}
+
+// Create records the policy and prunes the directory.
+func (h *retentionPolicyHandler) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "resourceType", req.ResourceType)
+
+	props, err := parseRetentionPolicyProperties(req.Properties)
+	var cfg *TargetConfig
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, removed, err := h.apply(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.CreateResult{ProgressResult: failureResult(resource.OperationCreate, "", errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.CreateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationCreate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			StatusMessage:      removedMessage(removed),
+			NativeID:           props.Path,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Read reports the recorded policy, or the limits the directory actually
+// reaches once it has drifted beyond them.
+func (h *retentionPolicyHandler) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	err := checkPath(req.TargetConfig, req.NativeID)
+	var cfg *TargetConfig
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		log.Error("read rejected", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err != nil {
+		log.Error("read failed to connect", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		log.Error("read failed", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+	entry, ok := m.Retention[req.NativeID]
+	if !ok {
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: resource.OperationErrorCodeNotFound}, nil
+	}
+
+	files, err := matchingFiles(client, path.Join(req.NativeID, "*"), cfg.ManifestPath)
+	if err != nil {
+		log.Error("read failed", "error", err)
+		return &resource.ReadResult{ResourceType: req.ResourceType, ErrorCode: errorCode(err)}, nil
+	}
+
+	reported := reportPolicy(path.Join(req.NativeID, "*"), entry, files, time.Now())
+	propsJSON, _ := json.Marshal(RetentionPolicyProperties{
+		Path:     req.NativeID,
+		MaxAge:   reported.MaxAge,
+		MaxCount: reported.MaxCount,
+		Deleted:  entry.Removed,
+	})
+	return &resource.ReadResult{
+		ResourceType: req.ResourceType,
+		Properties:   string(propsJSON),
+	}, nil
+}
+
+// Update records the desired policy and prunes the directory.
+func (h *retentionPolicyHandler) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("label", req.Label, "nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	props, err := parseRetentionPolicyProperties(req.DesiredProperties)
+	var cfg *TargetConfig
+	if err == nil {
+		err = checkPath(req.TargetConfig, props.Path)
+	}
+	if err == nil {
+		cfg, err = checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	}
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, resource.OperationErrorCodeInvalidRequest, err)}, nil
+	}
+
+	current, removed, err := h.apply(ctx, log, req.TargetConfig, cfg, req.Label, props)
+	if err != nil {
+		return &resource.UpdateResult{ProgressResult: failureResult(resource.OperationUpdate, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	propsJSON, _ := json.Marshal(current)
+	return &resource.UpdateResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:          resource.OperationUpdate,
+			OperationStatus:    resource.OperationStatusSuccess,
+			StatusMessage:      removedMessage(removed),
+			NativeID:           req.NativeID,
+			ResourceProperties: propsJSON,
+		},
+	}, nil
+}
+
+// Delete forgets the policy and leaves the directory as it is.
+func (h *retentionPolicyHandler) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
+	log := plugin.LoggerFromContext(ctx).With("nativeID", req.NativeID, "resourceType", req.ResourceType)
+
+	cfg, err := checkPolicyTarget(req.TargetConfig, req.ResourceType)
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+
+	client, err := h.plugin.getClient(ctx, log, req.TargetConfig)
+	if err == nil {
+		err = updateManifest(client, cfg.ManifestPath, func(m *Manifest) {
+			delete(m.Retention, req.NativeID)
+		})
+	}
+	if err != nil {
+		return &resource.DeleteResult{ProgressResult: failureResult(resource.OperationDelete, req.NativeID, errorCode(err), err)}, nil
+	}
+	log.Info("retention policy removed", "path", req.NativeID)
+
+	return &resource.DeleteResult{
+		ProgressResult: &resource.ProgressResult{
+			Operation:       resource.OperationDelete,
+			OperationStatus: resource.OperationStatusSuccess,
+			NativeID:        req.NativeID,
+		},
+	}, nil
+}
@@ -0,0 +1,66 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetentionPolicy verifies a directory is pruned to its newest files,
+// the deleted files are reported by the sync and by later reads, and the
+// manifest, files other resources manage, their sidecars and staged writes
+// in the directory are never pruned.
+func TestRetentionPolicy(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+	// Files other resources manage, their sidecars and staged writes stay
+	managed := []string{"/upload/app.conf", "/upload/app.conf.sha256", "/upload/c.csv.tmp.0b6f1c2e-8a3d-4f5e-9c7b-2d1e0f3a4b5c"}
+	for _, name := range managed {
+		require.NoError(t, client.WriteFile(name, []byte("managed"), 0644))
+	}
+	require.NoError(t, recordManaged(client, "/upload/.formae.json", &asyncsftp.FileInfo{Path: "/upload/app.conf", Content: "managed"}, ManifestEntry{Label: "app", ResourceType: fileType}))
+	for _, name := range []string{"a.csv", "b.csv", "c.csv"} {
+		require.NoError(t, client.WriteFile("/upload/"+name, []byte(name), 0644))
+	}
+
+	created, err := p.Create(ctx, &resource.CreateRequest{
+		ResourceType: retentionPolicyType,
+		Properties:   json.RawMessage(`{"path":"/upload/","maxCount":1,"maxAge":"720h"}`),
+		TargetConfig: target,
+	})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	assert.Equal(t, "/upload", created.ProgressResult.NativeID)
+	assert.Equal(t, "removed 2 file(s): /upload/b.csv, /upload/a.csv", created.ProgressResult.StatusMessage)
+	files, err := client.ListFiles("/upload")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, append([]string{"/upload/.formae.json", "/upload/c.csv"}, managed...), files)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: retentionPolicyType, NativeID: "/upload", TargetConfig: target})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(created.ProgressResult.ResourceProperties), read.Properties)
+	assert.JSONEq(t, `{"path":"/upload","maxAge":"720h","maxCount":1,"deleted":["/upload/b.csv","/upload/a.csv"]}`, read.Properties)
+
+	deleted, err := p.Delete(ctx, &resource.DeleteRequest{ResourceType: retentionPolicyType, NativeID: "/upload", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationStatusSuccess, deleted.ProgressResult.OperationStatus)
+	read, err = p.Read(ctx, &resource.ReadRequest{ResourceType: retentionPolicyType, NativeID: "/upload", TargetConfig: target})
+	require.NoError(t, err)
+	assert.Equal(t, resource.OperationErrorCodeNotFound, read.ErrorCode)
+
+	_, err = parseRetentionPolicyProperties(json.RawMessage(`{"path":"/upload"}`))
+	assert.Error(t, err)
+}
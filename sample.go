@@ -0,0 +1,57 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+)
+
+// =============================================================================
+// Sampled Reads
+// =============================================================================
+
+// sampleSize is how many bytes sample reads take from each end of a file.
+const sampleSize = 64 << 10
+
+// sampleRanges returns the length of the head and the offset of the tail
+// sampled from a file of size bytes. They never overlap; files that fit in
+// the head have an empty tail.
+func sampleRanges(size int64) (headLen, tailOffset int64) {
+	return min(size, sampleSize), max(sampleSize, size-sampleSize)
+}
+
+// contentSample hashes the sampled ranges of content.
+func contentSample(content string) string {
+	headLen, tailOffset := sampleRanges(int64(len(content)))
+	return contentHash(content[:headLen] + content[min(tailOffset, int64(len(content))):])
+}
+
+// readSample hashes the sampled ranges of a regular file on the server,
+// downloading at most 2*sampleSize bytes of it.
+func readSample(client *asyncsftp.Client, info *asyncsftp.FileInfo) (string, error) {
+	headLen, tailOffset := sampleRanges(info.Size)
+	head, err := client.ReadRange(info.Path, 0, headLen)
+	if err != nil {
+		return "", err
+	}
+	tail, err := client.ReadRange(info.Path, tailOffset, max(info.Size-tailOffset, 0))
+	if err != nil {
+		return "", err
+	}
+	return contentHash(string(head) + string(tail)), nil
+}
+
+// withSample adds the sample hash that sample reads report to the state
+// returned after a write, so the next read compares equal. Appended files
+// are shared with other writers and not sampled.
+func withSample(targetConfig json.RawMessage, props *FileProperties, info *asyncsftp.FileInfo) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || cfg.ReadMode != readModeSample || info.Type != asyncsftp.FileTypeRegular || props.WriteMode == writeModeAppend {
+		return
+	}
+	props.SampleSHA256 = contentSample(info.Content)
+}
@@ -0,0 +1,53 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSampleRead verifies sample reads hash the same bytes as the state
+// recorded after a write, and see a change to the end of a large file.
+func TestSampleRead(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"readMode":"sample"`)
+
+	for _, size := range []int{0, 10, sampleSize, 2*sampleSize + 1, 5 * sampleSize} {
+		content := strings.Repeat("a", size/2) + strings.Repeat("b", size-size/2)
+		require.NoError(t, client.WriteFile("/upload/big.bin", []byte(content), 0644))
+		info, err := client.Stat("/upload/big.bin")
+		require.NoError(t, err)
+		sample, err := readSample(client, info)
+		require.NoError(t, err)
+		assert.Equal(t, contentSample(content), sample, "size %d", size)
+	}
+
+	read := func() FileProperties {
+		result, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/big.bin", TargetConfig: target})
+		require.NoError(t, err)
+		require.Empty(t, result.ErrorCode)
+		var props FileProperties
+		require.NoError(t, json.Unmarshal([]byte(result.Properties), &props))
+		return props
+	}
+	before := read()
+	assert.Empty(t, before.Content)
+	assert.NotEmpty(t, before.SampleSHA256)
+
+	content := strings.Repeat("a", 5*sampleSize/2) + strings.Repeat("c", 5*sampleSize-5*sampleSize/2)
+	require.NoError(t, client.WriteFile("/upload/big.bin", []byte(content), 0644))
+	assert.NotEqual(t, before.SampleSHA256, read().SampleSHA256)
+}
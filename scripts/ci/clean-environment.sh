@@ -26,6 +26,12 @@ SFTP_USERNAME="${SFTP_USERNAME:-}"
 SFTP_PASSWORD="${SFTP_PASSWORD:-}"
 SFTP_DIRECTORY="${SFTP_DIRECTORY:-/upload}"
 
+# In-memory targets vanish with the plugin process; nothing to clean
+if [[ "${SFTP_TEST_URL:-}" == memory://* ]]; then
+    echo "clean-environment.sh: in-memory target, nothing to clean"
+    exit 0
+fi
+
 echo "clean-environment.sh: Cleaning SFTP files with prefix '${TEST_PREFIX}'"
 
 # Check for required credentials
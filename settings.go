@@ -0,0 +1,325 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// =============================================================================
+// Plugin Settings
+// =============================================================================
+
+// settingsEnv names a settings file to load instead of the default one.
+const settingsEnv = "FORMAE_SFTP_SETTINGS"
+
+// settingsFile is the settings file looked for next to the plugin binary.
+const settingsFile = "settings.json"
+
+// Defaults for settings the file leaves unset.
+const (
+	defaultRequestsPerSecond    = 5
+	defaultConnectBackoff       = time.Second
+	defaultStatCacheTTL         = 2 * time.Second
+	defaultSupportBundleAfter   = 3
+	defaultMaxInlineContentSize = 1 << 20
+	defaultUploadBatchSize      = 16
+)
+
+// Settings are plugin-wide defaults that operators tune per agent host, as
+// opposed to TargetConfig, which travels with each stack. They are read
+// once at startup; every field is optional.
+type Settings struct {
+	// Workers is the number of operations run concurrently on each
+	// target's client, so every target gets this many of its own (default
+	// asyncsftp.DefaultWorkers).
+	Workers int `json:"workers,omitempty"`
+
+	// UploadBatchSize is how many queued small uploads to one target a
+	// worker writes together, pipelining their requests (default 16; 1
+	// writes each on its own).
+	UploadBatchSize int `json:"uploadBatchSize,omitempty"`
+
+	// ConnectTimeout is a Go duration bounding the connect and handshake
+	// (default "10s").
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+
+	// ConnectAttempts is how many times an unreachable target is dialed
+	// before the operation fails (default 1). Rejected credentials are
+	// never retried.
+	ConnectAttempts int `json:"connectAttempts,omitempty"`
+
+	// ConnectBackoff is a Go duration (default "1s") waited after the
+	// first failed attempt, doubling after each one.
+	ConnectBackoff string `json:"connectBackoff,omitempty"`
+
+	// RequestsPerSecond is the agent-side rate limit for the namespace
+	// (default 5).
+	RequestsPerSecond int `json:"requestsPerSecond,omitempty"`
+
+	// LogLevel is the least severe level logged: "debug" (default),
+	// "info", "warn" or "error".
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// AllowValidateCommand lets File resources run their validateCommand
+	// on this host. Off by default: the command comes from the stack, not
+	// from the operator.
+	AllowValidateCommand bool `json:"allowValidateCommand,omitempty"`
+
+	// OmitContent keeps file bodies out of formae's state store: File and
+	// FileContent properties report only the content's sha256 and size.
+	OmitContent bool `json:"omitContent,omitempty"`
+
+	// DownloadDir is the directory on this host under which Download
+	// resources may keep local copies. Unset, Download only reports
+	// content: the local path comes from the stack, not from the operator.
+	DownloadDir string `json:"downloadDir,omitempty"`
+
+	// OperationRetention is a Go duration (default "1h") for which Status
+	// still answers for a finished operation, including after the plugin
+	// reconnects to the target.
+	OperationRetention string `json:"operationRetention,omitempty"`
+
+	// StrictProperties rejects resources declaring properties their type
+	// does not have, e.g. a misspelled "permisions", instead of ignoring
+	// them.
+	StrictProperties bool `json:"strictProperties,omitempty"`
+
+	// StatCacheTTL is a Go duration (default "2s") for which a file's
+	// metadata is reused by existence checks, so applies touching many
+	// files in the same directories stat each less often. The plugin's own
+	// writes are always seen at once; "0" disables the cache.
+	StatCacheTTL string `json:"statCacheTTL,omitempty"`
+
+	// SyncCreateMaxSize is the size in bytes up to which Create waits for
+	// an upload and reports the created file at once, sparing the agent a
+	// Status round trip. Zero, the default, always answers asynchronously.
+	SyncCreateMaxSize int `json:"syncCreateMaxSize,omitempty"`
+
+	// SupportBundleDir, when set, is an absolute directory on the agent
+	// host that a support bundle is written to once SupportBundleAfter
+	// (default 3) requests in a row have failed: recent logs and
+	// operations, the session's protocol details and statistics, without
+	// credentials or file content.
+	SupportBundleDir   string `json:"supportBundleDir,omitempty"`
+	SupportBundleAfter int    `json:"supportBundleAfter,omitempty"`
+
+	// MaxInlineContentSize is the most content in bytes (default 1 MiB) a
+	// file may declare inline. Larger files are rejected with a pointer to
+	// file and url content parts, which keep them out of the requests
+	// between the agent and the plugin.
+	MaxInlineContentSize int `json:"maxInlineContentSize,omitempty"`
+
+	// ExpandEnvAllow names the environment variables expandEnv files may
+	// reference, e.g. "API_ENDPOINT", or by a prefix such as "DEPLOY_*".
+	// Variables not named here are never exposed to content.
+	ExpandEnvAllow []string `json:"expandEnvAllow,omitempty"`
+
+	// IntentDir, when set, is an absolute directory on the agent host
+	// where uploads are recorded until they finish. After a plugin crash,
+	// Status answers for them by checking whether the file on the server
+	// holds the uploaded content, rather than leaving the agent to retry
+	// blindly, which for appends would write twice.
+	IntentDir string `json:"intentDir,omitempty"`
+}
+
+// loadSettings reads the file named by FORMAE_SFTP_SETTINGS or, when that is
+// unset, settings.json next to the plugin binary. Only a missing default
+// file means defaults; a named file must exist.
+func loadSettings() (Settings, error) {
+	path, named := os.LookupEnv(settingsEnv)
+	if !named {
+		exe, err := os.Executable()
+		if err != nil {
+			return Settings{}, nil
+		}
+		path = filepath.Join(filepath.Dir(exe), settingsFile)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !named {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("read settings: %w", err)
+	}
+	settings, err := parseSettings(data)
+	if err != nil {
+		return Settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// parseSettings decodes and validates a settings file. Unknown fields are
+// rejected so a misspelled setting does not silently keep its default.
+func parseSettings(data []byte) (Settings, error) {
+	var s Settings
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings: %w", err)
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"connectTimeout", s.ConnectTimeout},
+		{"connectBackoff", s.ConnectBackoff},
+		{"operationRetention", s.OperationRetention},
+		{"statCacheTTL", s.StatCacheTTL},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(setting.value); err != nil || d < 0 {
+			return Settings{}, fmt.Errorf("invalid settings: '%s' must be a non-negative duration", setting.name)
+		}
+	}
+	if s.Workers < 0 || s.UploadBatchSize < 0 || s.ConnectAttempts < 0 || s.RequestsPerSecond < 0 || s.SyncCreateMaxSize < 0 || s.SupportBundleAfter < 0 || s.MaxInlineContentSize < 0 {
+		return Settings{}, fmt.Errorf("invalid settings: 'workers', 'uploadBatchSize', 'connectAttempts', 'requestsPerSecond', 'syncCreateMaxSize', 'supportBundleAfter' and 'maxInlineContentSize' must not be negative")
+	}
+	if s.DownloadDir != "" && !filepath.IsAbs(s.DownloadDir) {
+		return Settings{}, fmt.Errorf("invalid settings: 'downloadDir' must be an absolute path")
+	}
+	if s.SupportBundleDir != "" && !filepath.IsAbs(s.SupportBundleDir) {
+		return Settings{}, fmt.Errorf("invalid settings: 'supportBundleDir' must be an absolute path")
+	}
+	if s.IntentDir != "" && !filepath.IsAbs(s.IntentDir) {
+		return Settings{}, fmt.Errorf("invalid settings: 'intentDir' must be an absolute path")
+	}
+	for _, pattern := range s.ExpandEnvAllow {
+		if !envAllowPattern.MatchString(pattern) {
+			return Settings{}, fmt.Errorf("invalid settings: 'expandEnvAllow' entry %q must be a variable name, optionally ending in *", pattern)
+		}
+	}
+	if _, err := s.logLevel(); err != nil {
+		return Settings{}, err
+	}
+	return s, nil
+}
+
+// connectTimeout returns the configured connect timeout, or zero for the
+// client's default.
+func (s Settings) connectTimeout() time.Duration {
+	d, _ := time.ParseDuration(s.ConnectTimeout)
+	return d
+}
+
+// operationRetention returns how long finished operations stay answerable,
+// or zero for the client's default.
+func (s Settings) operationRetention() time.Duration {
+	d, _ := time.ParseDuration(s.OperationRetention)
+	return d
+}
+
+// statCacheTTL returns how long file metadata is reused, zero disabling
+// the cache.
+func (s Settings) statCacheTTL() time.Duration {
+	if s.StatCacheTTL == "" {
+		return defaultStatCacheTTL
+	}
+	d, _ := time.ParseDuration(s.StatCacheTTL)
+	return d
+}
+
+// connectRetry returns the number of connection attempts and the first
+// backoff between them.
+func (s Settings) connectRetry() (int, time.Duration) {
+	attempts, backoff := max(s.ConnectAttempts, 1), defaultConnectBackoff
+	if s.ConnectBackoff != "" {
+		backoff, _ = time.ParseDuration(s.ConnectBackoff)
+	}
+	return attempts, backoff
+}
+
+// supportBundleAfter returns how many failures in a row write a support
+// bundle.
+func (s Settings) supportBundleAfter() int {
+	if s.SupportBundleAfter == 0 {
+		return defaultSupportBundleAfter
+	}
+	return s.SupportBundleAfter
+}
+
+// uploadBatchSize returns how many small uploads are written together.
+func (s Settings) uploadBatchSize() int {
+	if s.UploadBatchSize == 0 {
+		return defaultUploadBatchSize
+	}
+	return s.UploadBatchSize
+}
+
+// maxInlineContentSize returns the most inline content a file may declare.
+func (s Settings) maxInlineContentSize() int {
+	if s.MaxInlineContentSize == 0 {
+		return defaultMaxInlineContentSize
+	}
+	return s.MaxInlineContentSize
+}
+
+// requestsPerSecond returns the configured rate limit.
+func (s Settings) requestsPerSecond() int {
+	if s.RequestsPerSecond == 0 {
+		return defaultRequestsPerSecond
+	}
+	return s.RequestsPerSecond
+}
+
+// logLevel returns the configured minimum log level.
+func (s Settings) logLevel() (slog.Level, error) {
+	var level slog.Level
+	if s.LogLevel == "" {
+		return slog.LevelDebug, nil
+	}
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		return 0, fmt.Errorf("invalid settings: 'logLevel' %q must be debug, info, warn or error", s.LogLevel)
+	}
+	return level, nil
+}
+
+// withLogLevel replaces ctx's logger with one that drops messages below the
+// configured level.
+func (s Settings) withLogLevel(ctx context.Context) context.Context {
+	level, _ := s.logLevel()
+	if level <= slog.LevelDebug {
+		return ctx
+	}
+	return plugin.WithLogger(ctx, levelLogger{Logger: plugin.LoggerFromContext(ctx), level: level})
+}
+
+// levelLogger filters a plugin.Logger by level.
+type levelLogger struct {
+	plugin.Logger
+	level slog.Level
+}
+
+func (l levelLogger) Debug(msg string, attrs ...any) {
+	if l.level <= slog.LevelDebug {
+		l.Logger.Debug(msg, attrs...)
+	}
+}
+
+func (l levelLogger) Info(msg string, attrs ...any) {
+	if l.level <= slog.LevelInfo {
+		l.Logger.Info(msg, attrs...)
+	}
+}
+
+func (l levelLogger) Warn(msg string, attrs ...any) {
+	if l.level <= slog.LevelWarn {
+		l.Logger.Warn(msg, attrs...)
+	}
+}
+
+func (l levelLogger) With(attrs ...any) plugin.Logger {
+	return levelLogger{Logger: l.Logger.With(attrs...), level: l.level}
+}
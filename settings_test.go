@@ -0,0 +1,75 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger collects the messages logged through it.
+type recordingLogger struct {
+	messages *[]string
+}
+
+func (l recordingLogger) Debug(msg string, _ ...any)  { *l.messages = append(*l.messages, msg) }
+func (l recordingLogger) Info(msg string, _ ...any)   { *l.messages = append(*l.messages, msg) }
+func (l recordingLogger) Warn(msg string, _ ...any)   { *l.messages = append(*l.messages, msg) }
+func (l recordingLogger) Error(msg string, _ ...any)  { *l.messages = append(*l.messages, msg) }
+func (l recordingLogger) With(_ ...any) plugin.Logger { return l }
+
+// TestSettings verifies settings files are validated and their values
+// replace the built-in defaults.
+func TestSettings(t *testing.T) {
+	defaults, err := parseSettings([]byte(`{}`))
+	require.NoError(t, err)
+	attempts, backoff := defaults.connectRetry()
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, time.Second, backoff)
+	assert.Equal(t, defaultRequestsPerSecond, defaults.requestsPerSecond())
+	assert.Zero(t, defaults.connectTimeout())
+
+	s, err := parseSettings([]byte(`{"workers":8,"connectTimeout":"30s","connectAttempts":3,"connectBackoff":"2s","requestsPerSecond":20,"logLevel":"warn"}`))
+	require.NoError(t, err)
+	attempts, backoff = s.connectRetry()
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2*time.Second, backoff)
+	assert.Equal(t, 30*time.Second, s.connectTimeout())
+	assert.Equal(t, 20, (&Plugin{settings: s}).RateLimit().MaxRequestsPerSecondForNamespace)
+
+	for _, bad := range []string{`{"workrs":8}`, `{"connectTimeout":"soon"}`, `{"connectAttempts":-1}`, `{"logLevel":"loud"}`, `[]`} {
+		_, err := parseSettings([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+
+	var messages []string
+	ctx := plugin.WithLogger(context.Background(), recordingLogger{&messages})
+	log := plugin.LoggerFromContext(s.withLogLevel(ctx)).With("k", "v")
+	log.Debug("debug")
+	log.Info("info")
+	log.Warn("warn")
+	log.Error("error")
+	assert.Equal(t, []string{"warn", "error"}, messages)
+
+	t.Setenv(settingsEnv, filepath.Join(t.TempDir(), "missing.json"))
+	_, err = loadSettings()
+	assert.Error(t, err, "a named settings file must exist")
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"workers":2}`), 0600))
+	t.Setenv(settingsEnv, path)
+	loaded, err := loadSettings()
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded.Workers)
+}
@@ -6,12 +6,20 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
 	"github.com/platform-engineering-labs/formae/pkg/plugin"
@@ -30,47 +38,562 @@ var ErrNotImplemented = errors.New("not implemented")
 // Contains only the deployment location, NOT credentials.
 // Credentials are provided via environment variables.
 type TargetConfig struct {
-	URL string `json:"url"` // sftp://host:port
+	URL string `json:"url"` // sftp://, scp:// or ftps://host:port, file:///dir or memory://name/dir
+
+	// Name is how logs and status messages refer to the target (e.g.
+	// "prod-edge-eu"), so output covering several targets is readable.
+	Name string `json:"name,omitempty"`
+
+	// InsecureSkipVerify accepts any TLS certificate on ftps:// targets,
+	// e.g. servers with self-signed certificates.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+	// RotateEndpoints re-resolves the host on each connection and rotates
+	// across all resolved addresses instead of pinning to the first one.
+	RotateEndpoints bool `json:"rotateEndpoints,omitempty"`
+
+	// SlowOperationThreshold is a Go duration string (e.g. "10s"). Operations
+	// running longer than this are logged with a per-phase timing breakdown.
+	// "0" disables the warning.
+	SlowOperationThreshold string `json:"slowOperationThreshold,omitempty"`
+
+	// AllowExec permits running remote commands over SSH exec for features
+	// SFTP cannot express (e.g. extended attributes). SFTP-only accounts
+	// must leave this off.
+	AllowExec bool `json:"allowExec,omitempty"`
+
+	// ManifestPath, when set, is a JSON file on the server listing every
+	// formae-managed file with its hash (e.g. /upload/.formae-manifest.json).
+	ManifestPath string `json:"manifestPath,omitempty"`
+
+	// Root confines every managed path to this directory. Paths resolving
+	// outside it (e.g. via "..") are rejected as invalid requests.
+	Root string `json:"root,omitempty"`
+
+	// ReadMode is "full" (default), "stat" or "sample". Stat reads skip
+	// downloading content and report metadata plus a server-side sha256,
+	// which keeps periodic syncs of large files cheap. Sample reads also
+	// hash the first and last sampleSize bytes, which catches most changes
+	// to a large binary without exec.
+	ReadMode string `json:"readMode,omitempty"`
+
+	// DryRun answers every change on the target, without making it, with
+	// what it would have done and a summary of the apply so far: bytes to
+	// upload, deletes and the largest files. Reads are unaffected.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// ProtectNewer sets protectNewer on every File of the target, for
+	// directories shared with people editing files by hand.
+	ProtectNewer bool `json:"protectNewer,omitempty"`
+
+	// FollowSymlinks makes reads report what a symlink finally points at,
+	// under the link's own path, instead of the link itself. Chains that
+	// loop fail, and so do targets outside Root.
+	FollowSymlinks bool `json:"followSymlinks,omitempty"`
+
+	// Banner, when set, is prepended as a comment to every uploaded file
+	// (e.g. "Managed by formae - do not edit") and stripped again on read.
+	Banner string `json:"banner,omitempty"`
+
+	// Probe is how file existence and metadata are checked: "stat"
+	// (default), "open" for accounts that can read but not stat a path, or
+	// "exec" to run stat over SSH (requires allowExec).
+	Probe string `json:"probe,omitempty"`
+
+	// ClockSkewThreshold is a Go duration (default "1m"). When the server's
+	// clock differs from the agent's by more, a warning is logged on
+	// connect, since modification times are then unreliable. "0" disables
+	// the check, which writes and removes a small file under root (or
+	// /upload).
+	ClockSkewThreshold string `json:"clockSkewThreshold,omitempty"`
+
+	// SSHAgent authenticates with the keys in the ssh-agent at
+	// SSH_AUTH_SOCK, including hardware-backed sk-ssh-ed25519 keys whose
+	// touch or PIN policy the agent enforces. SFTP_PASSWORD becomes optional.
+	SSHAgent bool `json:"sshAgent,omitempty"`
+
+	// Anonymous logs in to servers that require no credentials, as
+	// SFTP_USERNAME or "anonymous", with SFTP_PASSWORD or none.
+	Anonymous bool `json:"anonymous,omitempty"`
+
+	// AuthFailureLimit is the number of consecutive authentication failures
+	// (default 3) after which the plugin stops connecting for
+	// AuthLockoutPeriod and reports the target as locked out. 0 disables it.
+	AuthFailureLimit *int `json:"authFailureLimit,omitempty"`
+
+	// AuthLockoutPeriod is a Go duration (default "5m").
+	AuthLockoutPeriod string `json:"authLockoutPeriod,omitempty"`
+
+	// MaxQueuedOperations is the number of uploads and deletes (default 64)
+	// allowed to wait for a worker. Beyond it, requests fail with Throttling
+	// so the agent backs off instead of queuing for minutes. 0 disables it.
+	MaxQueuedOperations *int `json:"maxQueuedOperations,omitempty"`
+
+	// QuietHours are windows such as "Mon-Fri 01:00-04:00" during which
+	// creates, updates (drift corrections) and deletes are deferred with
+	// Throttling and only reads run, for partners that forbid changes during
+	// batch processing.
+	QuietHours []string `json:"quietHours,omitempty"`
+
+	// QuietHoursTimezone is the IANA time zone QuietHours are in (default
+	// "UTC").
+	QuietHoursTimezone string `json:"quietHoursTimezone,omitempty"`
+
+	// PausedResources are native IDs or path patterns such as "/etc/app/*"
+	// whose updates report the current state instead of correcting drift,
+	// and whose deletes fail with ResourceConflict until the pause is lifted.
+	PausedResources []string `json:"pausedResources,omitempty"`
+
+	// TimestampFormat is how modification times are reported: "rfc3339"
+	// (default), "rfc3339nano" or "epoch" (seconds). Always in UTC.
+	TimestampFormat string `json:"timestampFormat,omitempty"`
+
+	// MtimeProfile is how far the server's modification times can be
+	// trusted: "precise" (default), "seconds" for servers that store whole
+	// seconds, or "untrusted" for servers that report none or nonsense,
+	// which reports none and refuses features that need them.
+	MtimeProfile string `json:"mtimeProfile,omitempty"`
+
+	// SFTPVersion, when 3, restricts sftp:// sessions to the base SFTP
+	// version 3 protocol, ignoring the OpenSSH extensions (posix-rename,
+	// statvfs) a quirky server advertises but mishandles.
+	SFTPVersion *int `json:"sftpVersion,omitempty"`
+
+	// StableIDs gives files NativeIDs based on their canonical path, with
+	// symlinked parent directories resolved by the server, so the same file
+	// reached through different spellings is one resource.
+	StableIDs bool `json:"stableIds,omitempty"`
+
+	// TempFileMaxAge is a Go duration (default "1h"). Temporary files the
+	// plugin left behind when a previous run crashed (staged writes, clock
+	// probes) are removed from root (or /upload) on connect and every
+	// sweepInterval once they are older than this. "0" disables the sweep.
+	TempFileMaxAge string `json:"tempFileMaxAge,omitempty"`
+
+	// ACLType is the kind of ACL the server's filesystem uses for File
+	// acl entries: "posix" (default, getfacl/setfacl) or "nfs4"
+	// (nfs4_getfacl/nfs4_setfacl).
+	ACLType string `json:"aclType,omitempty"`
+
+	// QuarantineDir is an existing directory on the server (e.g.
+	// /upload/.quarantine). Uploads failing verifyUpload or
+	// remoteValidateCommand are moved into it for inspection instead of
+	// being removed.
+	QuarantineDir string `json:"quarantineDir,omitempty"`
+
+	// VerifyHash is the hash verifyUpload compares uploads by: "sha256"
+	// (default), "sha512", or "xxhash", which is much faster on huge
+	// artifacts. A File's own verifyHash takes precedence.
+	VerifyHash string `json:"verifyHash,omitempty"`
+
+	// CacheDir is an existing directory on the server (e.g. /srv/.formae-cache)
+	// keeping a copy of every upload of 1 MiB or more, named by its sha256.
+	// Uploads of identical content are then copied from it on the server
+	// instead of sent again. Copies run over SSH exec, so it requires
+	// allowExec.
+	CacheDir string `json:"cacheDir,omitempty"`
+
+	// WaitForTarget is a Go duration (e.g. "5m") for which connecting keeps
+	// retrying a server that refuses connections, polling every
+	// WaitForTargetInterval (default "5s"), so applies that provision the
+	// server can run before it has booted. By default an unreachable
+	// server is retried as the plugin's connectAttempts setting allows.
+	WaitForTarget         string `json:"waitForTarget,omitempty"`
+	WaitForTargetInterval string `json:"waitForTargetInterval,omitempty"`
+
+	// TCPKeepAlive is a Go duration between keepalive probes on idle
+	// connections (default "15s"); "0" disables them. TCPNoDelay, true by
+	// default, sends small writes at once rather than coalescing them.
+	// TCPBufferSize sets the socket send and receive buffers in bytes,
+	// which bound throughput to servers on high-latency links.
+	TCPKeepAlive  string `json:"tcpKeepAlive,omitempty"`
+	TCPNoDelay    *bool  `json:"tcpNoDelay,omitempty"`
+	TCPBufferSize int    `json:"tcpBufferSize,omitempty"`
+
+	// MaxPacketSize overrides the SFTP packet size in bytes, which is
+	// otherwise 32 KiB or, on OpenSSH servers advertising their limits,
+	// 255 KiB. Appliances that drop larger packets need less.
+	MaxPacketSize int `json:"maxPacketSize,omitempty"`
+
+	// MaxFileSize is the largest file in bytes the server accepts. Larger
+	// content fails with InvalidRequest before anything is uploaded.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+
+	// SFTPSubsystem is the SSH subsystem SFTP is served on, for servers
+	// exposing it under a name other than "sftp". SFTPServerCommand instead
+	// runs the SFTP server over exec (e.g. "/usr/libexec/sftp-server"), for
+	// servers that define no subsystem. At most one may be set.
+	SFTPSubsystem     string `json:"sftpSubsystem,omitempty"`
+	SFTPServerCommand string `json:"sftpServerCommand,omitempty"`
+}
+
+// Timestamp formats accepted by the target's timestampFormat.
+const (
+	timestampRFC3339     = "rfc3339"
+	timestampRFC3339Nano = "rfc3339nano"
+	timestampEpoch       = "epoch"
+)
+
+// formatTimestamp renders t in UTC in the given timestampFormat, so values
+// compare equal across targets and agents in different time zones.
+func formatTimestamp(t time.Time, format string) string {
+	t = t.UTC()
+	switch format {
+	case timestampRFC3339Nano:
+		return t.Format(time.RFC3339Nano)
+	case timestampEpoch:
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		return t.Format(time.RFC3339)
+	}
 }
 
+// readsContent reports whether Read downloads whole files, as opposed to
+// stat or sample reads that leave the content on the server.
+func (c *TargetConfig) readsContent() bool {
+	return c.ReadMode == "" || c.ReadMode == readModeFull
+}
+
+// targetName returns the target's name, or "" when it has none or the
+// config does not parse.
+func targetName(targetConfig json.RawMessage) string {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return ""
+	}
+	return cfg.Name
+}
+
+// timestampFormat returns the target's timestampFormat, or the default when
+// the config does not parse.
+func timestampFormat(targetConfig json.RawMessage) string {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return ""
+	}
+	return cfg.TimestampFormat
+}
+
+// Modification time profiles accepted by the target's mtimeProfile.
+const (
+	mtimePrecise   = "precise"
+	mtimeSeconds   = "seconds"
+	mtimeUntrusted = "untrusted"
+)
+
+// trustsMtime reports whether features that go by modification times,
+// such as removing files by age, may run against the target.
+func (c *TargetConfig) trustsMtime() bool {
+	return c.MtimeProfile != mtimeUntrusted
+}
+
+// Read modes accepted by the target's readMode.
+const (
+	readModeFull   = "full"
+	readModeStat   = "stat"
+	readModeSample = "sample"
+)
+
+// defaultSlowOperationThreshold applies when the target does not set one.
+const defaultSlowOperationThreshold = 10 * time.Second
+
+// defaultClockSkewThreshold applies when the target does not set one.
+const defaultClockSkewThreshold = time.Minute
+
+// defaultTempFileMaxAge applies when the target does not set one. It is far
+// beyond how long a staged write or clock probe stays on the server.
+const defaultTempFileMaxAge = time.Hour
+
+// queueReportThreshold is how long an operation must have waited for a
+// worker before its result says so. Shorter waits are ordinary scheduling.
+const queueReportThreshold = time.Second
+
+// minPacketSize is the smallest maxPacketSize accepted. Smaller packets
+// would make every transfer crawl, and no server needs them.
+const minPacketSize = 1 << 10
+
+// defaultMaxQueuedOperations applies when the target does not set one.
+const defaultMaxQueuedOperations = 64
+
 // parseTargetConfig extracts SFTP target settings from the request.
 func parseTargetConfig(data json.RawMessage) (*TargetConfig, error) {
 	var cfg TargetConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("invalid target config: %w", err)
+		return nil, fmt.Errorf("%w: %w", errInvalidTargetConfig, err)
 	}
 	if cfg.URL == "" {
-		return nil, fmt.Errorf("target config missing 'url'")
+		return nil, fmt.Errorf("%w: missing 'url'", errInvalidTargetConfig)
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"slowOperationThreshold", cfg.SlowOperationThreshold},
+		{"authLockoutPeriod", cfg.AuthLockoutPeriod},
+		{"clockSkewThreshold", cfg.ClockSkewThreshold},
+		{"tempFileMaxAge", cfg.TempFileMaxAge},
+		{"waitForTarget", cfg.WaitForTarget},
+		{"waitForTargetInterval", cfg.WaitForTargetInterval},
+		{"tcpKeepAlive", cfg.TCPKeepAlive},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(setting.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid '%s': %w", errInvalidTargetConfig, setting.name, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("%w: '%s' must not be negative", errInvalidTargetConfig, setting.name)
+		}
+	}
+	if cfg.AuthFailureLimit != nil && *cfg.AuthFailureLimit < 0 {
+		return nil, fmt.Errorf("%w: 'authFailureLimit' must not be negative", errInvalidTargetConfig)
+	}
+	if cfg.MaxPacketSize != 0 && cfg.MaxPacketSize < minPacketSize {
+		return nil, fmt.Errorf("%w: 'maxPacketSize' must be at least %d", errInvalidTargetConfig, minPacketSize)
+	}
+	if cfg.SFTPSubsystem != "" && cfg.SFTPServerCommand != "" {
+		return nil, fmt.Errorf("%w: set only one of 'sftpSubsystem' and 'sftpServerCommand'", errInvalidTargetConfig)
+	}
+	if cfg.Anonymous && cfg.SSHAgent {
+		return nil, fmt.Errorf("%w: set only one of 'anonymous' and 'sshAgent'", errInvalidTargetConfig)
+	}
+	if cfg.TCPBufferSize < 0 {
+		return nil, fmt.Errorf("%w: 'tcpBufferSize' must not be negative", errInvalidTargetConfig)
+	}
+	if cfg.MaxFileSize < 0 {
+		return nil, fmt.Errorf("%w: 'maxFileSize' must not be negative", errInvalidTargetConfig)
+	}
+	if cfg.MaxQueuedOperations != nil && *cfg.MaxQueuedOperations < 0 {
+		return nil, fmt.Errorf("%w: 'maxQueuedOperations' must not be negative", errInvalidTargetConfig)
+	}
+	for _, spec := range cfg.QuietHours {
+		if _, err := parseQuietWindow(spec); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidTargetConfig, err)
+		}
+	}
+	if _, err := time.LoadLocation(cfg.quietHoursTimezone()); err != nil {
+		return nil, fmt.Errorf("%w: invalid 'quietHoursTimezone': %w", errInvalidTargetConfig, err)
+	}
+	switch cfg.MtimeProfile {
+	case "", mtimePrecise, mtimeSeconds, mtimeUntrusted:
+	default:
+		return nil, fmt.Errorf("%w: invalid 'mtimeProfile' %q: must be precise, seconds or untrusted", errInvalidTargetConfig, cfg.MtimeProfile)
+	}
+	for _, pattern := range cfg.PausedResources {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: invalid 'pausedResources' entry %q: %w", errInvalidTargetConfig, pattern, err)
+		}
+	}
+	switch cfg.TimestampFormat {
+	case "", timestampRFC3339, timestampRFC3339Nano, timestampEpoch:
+	default:
+		return nil, fmt.Errorf("%w: invalid 'timestampFormat' %q: must be rfc3339, rfc3339nano or epoch", errInvalidTargetConfig, cfg.TimestampFormat)
+	}
+	if cfg.SFTPVersion != nil && *cfg.SFTPVersion != asyncsftp.SFTPVersion3 {
+		return nil, fmt.Errorf("%w: invalid 'sftpVersion' %d: only 3 is supported", errInvalidTargetConfig, *cfg.SFTPVersion)
+	}
+	switch cfg.ReadMode {
+	case "", readModeFull, readModeStat, readModeSample:
+	default:
+		return nil, fmt.Errorf("%w: invalid 'readMode' %q: must be full, stat or sample", errInvalidTargetConfig, cfg.ReadMode)
+	}
+	if cfg.QuarantineDir != "" {
+		if err := validatePath(cfg.QuarantineDir, cfg.Root); err != nil {
+			return nil, fmt.Errorf("%w: invalid 'quarantineDir': %w", errInvalidTargetConfig, err)
+		}
+	}
+	if !verifyHashes[cfg.VerifyHash] {
+		return nil, fmt.Errorf("%w: invalid 'verifyHash' %q: must be sha256, sha512 or xxhash", errInvalidTargetConfig, cfg.VerifyHash)
+	}
+	if cfg.CacheDir != "" {
+		if err := validatePath(cfg.CacheDir, cfg.Root); err != nil {
+			return nil, fmt.Errorf("%w: invalid 'cacheDir': %w", errInvalidTargetConfig, err)
+		}
+		if !cfg.AllowExec {
+			return nil, fmt.Errorf("%w: 'cacheDir' requires 'allowExec'", errInvalidTargetConfig)
+		}
+	}
+	switch cfg.ACLType {
+	case "", asyncsftp.ACLTypePOSIX, asyncsftp.ACLTypeNFS4:
+	default:
+		return nil, fmt.Errorf("%w: invalid 'aclType' %q: must be posix or nfs4", errInvalidTargetConfig, cfg.ACLType)
+	}
+	switch asyncsftp.Probe(cfg.Probe) {
+	case "", asyncsftp.ProbeStat, asyncsftp.ProbeOpen:
+	case asyncsftp.ProbeExec:
+		if !cfg.AllowExec {
+			return nil, fmt.Errorf("%w: 'probe' exec requires 'allowExec'", errInvalidTargetConfig)
+		}
+	default:
+		return nil, fmt.Errorf("%w: invalid 'probe' %q: must be stat, open or exec", errInvalidTargetConfig, cfg.Probe)
 	}
 	return &cfg, nil
 }
 
-// parseURL extracts host and port from an SFTP URL.
-// Expected format: sftp://host:port or sftp://host (defaults to port 22)
-func parseURL(sftpURL string) (host string, port string, err error) {
-	u, err := url.Parse(sftpURL)
+// slowThreshold returns the configured slow-operation threshold.
+func (c *TargetConfig) slowThreshold() time.Duration {
+	if c.SlowOperationThreshold == "" {
+		return defaultSlowOperationThreshold
+	}
+	d, _ := time.ParseDuration(c.SlowOperationThreshold)
+	return d
+}
+
+// waitForTarget returns how long to wait for an unreachable server and how
+// often to try it meanwhile, zero leaving the client's defaults.
+func (c *TargetConfig) waitForTarget() (time.Duration, time.Duration) {
+	maxWait, _ := time.ParseDuration(c.WaitForTarget)
+	interval, _ := time.ParseDuration(c.WaitForTargetInterval)
+	return maxWait, interval
+}
+
+// tcpOptions returns the tuning of the target's TCP connections.
+func (c *TargetConfig) tcpOptions() asyncsftp.TCPOptions {
+	opts := asyncsftp.TCPOptions{NoDelay: c.TCPNoDelay, ReadBuffer: c.TCPBufferSize, WriteBuffer: c.TCPBufferSize}
+	if c.TCPKeepAlive != "" {
+		opts.KeepAlive, _ = time.ParseDuration(c.TCPKeepAlive)
+		if opts.KeepAlive == 0 {
+			opts.KeepAlive = -1
+		}
+	}
+	return opts
+}
+
+// authLockout returns the configured failure limit and lockout period.
+func (c *TargetConfig) authLockout() (int, time.Duration) {
+	limit, period := defaultAuthFailureLimit, defaultAuthLockoutPeriod
+	if c.AuthFailureLimit != nil {
+		limit = *c.AuthFailureLimit
+	}
+	if c.AuthLockoutPeriod != "" {
+		period, _ = time.ParseDuration(c.AuthLockoutPeriod)
+	}
+	return limit, period
+}
+
+// clockSkewThreshold returns the configured clock skew threshold.
+func (c *TargetConfig) clockSkewThreshold() time.Duration {
+	if c.ClockSkewThreshold == "" {
+		return defaultClockSkewThreshold
+	}
+	d, _ := time.ParseDuration(c.ClockSkewThreshold)
+	return d
+}
+
+// tempFileMaxAge returns the configured age beyond which leftover
+// temporary files are swept.
+func (c *TargetConfig) tempFileMaxAge() time.Duration {
+	if c.TempFileMaxAge == "" {
+		return defaultTempFileMaxAge
+	}
+	d, _ := time.ParseDuration(c.TempFileMaxAge)
+	return d
+}
+
+// workDir returns the directory the plugin writes its own scratch files
+// to: root, or /upload.
+func (c *TargetConfig) workDir() string {
+	if c.Root != "" {
+		return c.Root
+	}
+	return "/upload"
+}
+
+// maxQueued returns the configured queue limit.
+func (c *TargetConfig) maxQueued() int {
+	if c.MaxQueuedOperations == nil {
+		return defaultMaxQueuedOperations
+	}
+	return *c.MaxQueuedOperations
+}
+
+// quietHoursTimezone returns the configured quiet hours time zone.
+func (c *TargetConfig) quietHoursTimezone() string {
+	if c.QuietHoursTimezone == "" {
+		return "UTC"
+	}
+	return c.QuietHoursTimezone
+}
+
+// defaultPorts maps each supported URL scheme to its default port.
+var defaultPorts = map[asyncsftp.Protocol]string{
+	asyncsftp.ProtocolSFTP: "22",
+	asyncsftp.ProtocolSCP:  "22",
+	asyncsftp.ProtocolFTPS: "21",
+}
+
+// parseURL extracts the protocol and address from a target URL.
+// Expected format: sftp://host:port or sftp://host (defaults to port 22),
+// scp://host:port (SCP over SSH exec, defaults to port 22),
+// ftps://host:port or ftps://host (explicit FTPS, defaults to port 21),
+// file:///dir (the agent host's filesystem beneath dir), or
+// memory://name/dir (an in-process store for tests, with dir created)
+func parseURL(targetURL string) (asyncsftp.Config, error) {
+	u, err := url.Parse(targetURL)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid URL: %w", err)
+		return asyncsftp.Config{}, fmt.Errorf("invalid URL: %w", err)
 	}
-	if u.Scheme != "sftp" {
-		return "", "", fmt.Errorf("expected sftp:// URL, got %s://", u.Scheme)
+	protocol := asyncsftp.Protocol(u.Scheme)
+	if protocol == asyncsftp.ProtocolMemory {
+		return asyncsftp.Config{Protocol: protocol, Host: u.Host, LocalDir: u.Path}, nil
 	}
-	host = u.Hostname()
-	port = u.Port()
+	if protocol == asyncsftp.ProtocolFile {
+		if u.Host != "" && u.Host != "localhost" {
+			return asyncsftp.Config{}, fmt.Errorf("file:// URL must not name a remote host, got %s", u.Host)
+		}
+		dir := u.Path
+		if dir == "" {
+			dir = "/"
+		}
+		return asyncsftp.Config{Protocol: protocol, LocalDir: dir}, nil
+	}
+
+	defaultPort, ok := defaultPorts[protocol]
+	if !ok {
+		return asyncsftp.Config{}, fmt.Errorf("expected sftp://, scp://, ftps://, file:// or memory:// URL, got %s://", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return asyncsftp.Config{}, fmt.Errorf("%s:// URL is missing a host", u.Scheme)
+	}
+	port := u.Port()
 	if port == "" {
-		port = "22"
+		port = defaultPort
 	}
-	return host, port, nil
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return asyncsftp.Config{}, fmt.Errorf("invalid port %q", port)
+	}
+	return asyncsftp.Config{Protocol: protocol, Host: u.Hostname(), Port: port}, nil
 }
 
-// getCredentials reads SFTP credentials from environment variables.
-func getCredentials() (username, password string, err error) {
-	username = os.Getenv("SFTP_USERNAME")
-	password = os.Getenv("SFTP_PASSWORD")
-	if username == "" || password == "" {
-		return "", "", fmt.Errorf("SFTP_USERNAME and SFTP_PASSWORD must be set")
+// Prefixes of the environment variables holding credentials: the target's
+// own (SFTP_USERNAME, SFTP_PASSWORD) and those of a Copy's source target
+// (SFTP_SOURCE_USERNAME, SFTP_SOURCE_PASSWORD).
+const (
+	credentialsEnvPrefix       = "SFTP"
+	sourceCredentialsEnvPrefix = "SFTP_SOURCE"
+)
+
+// getCredentials reads SFTP credentials from the environment variables
+// starting with prefix. With sshAgent, keys come from the agent at
+// SSH_AUTH_SOCK and the password is optional; anonymous targets need
+// neither username nor password.
+func getCredentials(prefix string, sshAgent, anonymous bool) (username, password, agentSocket string, err error) {
+	username = os.Getenv(prefix + "_USERNAME")
+	password = os.Getenv(prefix + "_PASSWORD")
+	if anonymous {
+		return username, password, "", nil
+	}
+	if !sshAgent {
+		if username == "" || password == "" {
+			return "", "", "", errMissingCredentials
+		}
+		return username, password, "", nil
 	}
-	return username, password, nil
+
+	agentSocket = os.Getenv("SSH_AUTH_SOCK")
+	if username == "" || agentSocket == "" {
+		return "", "", "", errMissingAgent
+	}
+	return username, password, agentSocket, nil
 }
 
 // =============================================================================
@@ -84,6 +607,257 @@ type FileProperties struct {
 	Permissions string `json:"permissions"`
 	Size        int64  `json:"size,omitempty"`
 	ModifiedAt  string `json:"modifiedAt,omitempty"`
+
+	// FileType is "regular", "symlink", "directory" or "other" (read-only).
+	FileType string `json:"fileType,omitempty"`
+	// LinkTarget is the symlink target when FileType is "symlink" (read-only).
+	LinkTarget string `json:"linkTarget,omitempty"`
+	// SHA256 is the hex-encoded content hash of a regular file (read-only).
+	SHA256 string `json:"sha256,omitempty"`
+	// SampleSHA256 is the hex-encoded hash of the file's first and last
+	// sampleSize bytes, reported in sample read mode (read-only).
+	SampleSHA256 string `json:"sampleSha256,omitempty"`
+	// CanonicalPath is the path with symlinks in its parent directories
+	// resolved by the server, and URL locates the file on the target, e.g.
+	// "sftp://files.example.com/upload/app.tar.gz" (read-only). Both are
+	// outputs for other resources to reference.
+	CanonicalPath string `json:"canonicalPath,omitempty"`
+	URL           string `json:"url,omitempty"`
+
+	// Priority overrides the queue priority derived from the upload size.
+	// One of "low", "normal", "high".
+	Priority string `json:"priority,omitempty"`
+
+	// Xattrs are user.* extended attributes, e.g. build IDs or provenance.
+	// Managed over SSH exec, so the target must set allowExec.
+	Xattrs map[string]string `json:"xattrs,omitempty"`
+
+	// ACL are access control entries beyond the permission bits, written
+	// as getfacl or nfs4_getfacl prints them (e.g. "user:deploy:rw-").
+	// Managed over SSH exec, so the target must set allowExec.
+	ACL []string `json:"acl,omitempty"`
+
+	// SELinuxContext is the file's security context, e.g.
+	// "system_u:object_r:httpd_sys_content_t:s0". Set with chcon over SSH
+	// exec, so the target must set allowExec. Unset leaves it to the server.
+	SELinuxContext string `json:"selinuxContext,omitempty"`
+
+	// ContentParts, when set, are concatenated into Content before upload,
+	// e.g. shared snippets followed by a per-host fragment.
+	ContentParts []ContentPart `json:"contentParts,omitempty"`
+
+	// ContentFormat is "text" (default), "json", "yaml" or "xml". Content is
+	// syntax-checked before upload, and json and yaml are compared
+	// semantically, so reformatting or reordering keys on the server is not
+	// drift.
+	ContentFormat string `json:"contentFormat,omitempty"`
+
+	// BannerComment is the comment syntax for the target's banner: "#"
+	// (default), "//", "--", ";", "<!--", "/*", or "none" to leave it out.
+	BannerComment string `json:"bannerComment,omitempty"`
+
+	// ValidateCommand is a shell command run on the agent host against the
+	// rendered content before upload; a non-zero exit rejects the content.
+	// Requires allowValidateCommand in the plugin settings.
+	ValidateCommand string `json:"validateCommand,omitempty"`
+
+	// RemoteValidateCommand is a shell command run over SSH exec against
+	// the staged upload, named by $FORMAE_FILE; the file is only renamed
+	// into place if it exits zero. Requires allowExec on the target.
+	RemoteValidateCommand string `json:"remoteValidateCommand,omitempty"`
+
+	// VerifyUpload stages the content and reads it back before renaming it
+	// into place, so a transfer that altered or truncated it never replaces
+	// the live file.
+	VerifyUpload bool `json:"verifyUpload,omitempty"`
+
+	// VerifyHash is the hash verifyUpload compares by, overriding the
+	// target's: "sha256", "sha512" or "xxhash".
+	VerifyHash string `json:"verifyHash,omitempty"`
+
+	// ChecksumFile writes a checksum file next to the file with every
+	// upload, named after the algorithm: "sha256" (<name>.sha256),
+	// "sha512" or "md5".
+	ChecksumFile string `json:"checksumFile,omitempty"`
+
+	// ChecksumFileFormat is the checksum file's layout: "gnu" (default,
+	// "<hash>  <name>"), "bsd" ("SHA256 (<name>) = <hash>") or "hash".
+	ChecksumFileFormat string `json:"checksumFileFormat,omitempty"`
+
+	// ProtectNewer makes Update refuse to overwrite the file when it was
+	// changed on the server after formae last wrote it, failing with a
+	// conflict instead.
+	ProtectNewer bool `json:"protectNewer,omitempty"`
+
+	// Adopt lets Create take over a file the deployment manifest records
+	// as another resource's, e.g. one moving between stacks, instead of
+	// failing with a conflict.
+	Adopt bool `json:"adopt,omitempty"`
+
+	// WriteMode is "replace" (default) or "append". Appended content is
+	// added to the end of the file unless the file already contains it,
+	// and the rest of the file is left to other writers.
+	WriteMode string `json:"writeMode,omitempty"`
+
+	// Compression is "gzip" to store the content compressed; it is still
+	// declared and read back uncompressed. Requires manifestPath and full
+	// reads on the target.
+	Compression string `json:"compression,omitempty"`
+
+	// MaxBytesPerSecond limits the upload's bandwidth, after compression.
+	// Zero means unlimited.
+	MaxBytesPerSecond int64 `json:"maxBytesPerSecond,omitempty"`
+
+	// CreateParents creates missing directories above the file when it is
+	// created, with ParentPermissions (octal, default "0755") and, when
+	// set, ParentOwner ("uid:gid"). CreatedDirectories reports those
+	// created (read-only), e.g. to adopt them as resources.
+	CreateParents      bool     `json:"createParents,omitempty"`
+	ParentPermissions  string   `json:"parentPermissions,omitempty"`
+	ParentOwner        string   `json:"parentOwner,omitempty"`
+	CreatedDirectories []string `json:"createdDirectories,omitempty"`
+
+	// ExpandEnv substitutes ${NAME} references in the content with the
+	// plugin's environment variables the expandEnvAllow setting names.
+	// Requires manifestPath on the target and plain text content.
+	ExpandEnv bool `json:"expandEnv,omitempty"`
+
+	// template is the content as declared, before ExpandEnv expanded it.
+	template string
+}
+
+// priorities maps the priority property to queue priorities.
+var priorities = map[string]asyncsftp.Priority{
+	"low":    asyncsftp.PriorityLow,
+	"normal": asyncsftp.PriorityNormal,
+	"high":   asyncsftp.PriorityHigh,
+}
+
+// Write modes accepted by writeMode.
+const (
+	writeModeReplace = "replace"
+	writeModeAppend  = "append"
+)
+
+// fileMode parses the octal permissions string, defaulting to 0644.
+func (props *FileProperties) fileMode() os.FileMode {
+	var perm os.FileMode = 0644
+	if props.Permissions != "" {
+		_, _ = fmt.Sscanf(props.Permissions, "%o", &perm)
+	}
+	return perm
+}
+
+// parseMode parses the octal mode in the permissions property named
+// property, e.g. "0644"; the setuid, setgid and sticky bits may be set.
+// example is a typical value, for the error.
+func parseMode(property, value, example string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return 0, fmt.Errorf("invalid '%s' %q: must be an octal mode such as %s", property, value, example)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parentOwner parses parentOwner, which is nil when unset.
+func (props *FileProperties) parentOwner() (*asyncsftp.FileOwner, error) {
+	if props.ParentOwner == "" {
+		return nil, nil
+	}
+	uid, gid, ok := strings.Cut(props.ParentOwner, ":")
+	owner := &asyncsftp.FileOwner{}
+	var uidErr, gidErr error
+	owner.UID, uidErr = strconv.Atoi(uid)
+	owner.GID, gidErr = strconv.Atoi(gid)
+	if !ok || uidErr != nil || gidErr != nil || owner.UID < 0 || owner.GID < 0 {
+		return nil, fmt.Errorf("invalid 'parentOwner' %q: must be numeric uid:gid such as 1000:1000", props.ParentOwner)
+	}
+	return owner, nil
+}
+
+// uploadOptions returns the asyncsftp options for uploading these properties
+// to the target.
+func (props *FileProperties) uploadOptions(targetConfig json.RawMessage) asyncsftp.UploadOptions {
+	opts := asyncsftp.UploadOptions{
+		Permissions:     props.fileMode(),
+		Priority:        priorities[props.Priority],
+		Xattrs:          props.Xattrs,
+		ACL:             props.ACL,
+		ACLType:         aclType(targetConfig),
+		SELinuxContext:  props.SELinuxContext,
+		ValidateCommand: props.RemoteValidateCommand,
+		Verify:          props.VerifyUpload,
+		VerifyHash:      asyncsftp.HashAlgorithm(props.VerifyHash),
+		Sidecar:         props.sidecar(),
+		Append:          props.WriteMode == writeModeAppend,
+		Middleware:      props.middleware(),
+		CreateParents:   props.CreateParents,
+	}
+	if props.ParentPermissions != "" {
+		_, _ = fmt.Sscanf(props.ParentPermissions, "%o", &opts.ParentPermissions)
+	}
+	opts.ParentOwner, _ = props.parentOwner()
+	if cfg, err := parseTargetConfig(targetConfig); err == nil {
+		opts.QuarantineDir = cfg.QuarantineDir
+		opts.CacheDir = cfg.CacheDir
+		if opts.VerifyHash == "" {
+			opts.VerifyHash = asyncsftp.HashAlgorithm(cfg.VerifyHash)
+		}
+	}
+	return opts
+}
+
+// filePropertiesFromInfo converts remote file metadata into resource
+// properties, with the modification time in the target's timestampFormat.
+func filePropertiesFromInfo(info *asyncsftp.FileInfo, timestampFormat string) FileProperties {
+	props := FileProperties{
+		Path:        info.Path,
+		Content:     info.Content,
+		Permissions: info.Permissions,
+		Size:        info.Size,
+		FileType:    string(info.Type),
+		LinkTarget:  info.LinkTarget,
+	}
+	// Open probes cannot see the modification time
+	if !info.ModifiedAt.IsZero() {
+		props.ModifiedAt = formatTimestamp(info.ModifiedAt, timestampFormat)
+	}
+	if info.Type == asyncsftp.FileTypeRegular {
+		props.SHA256 = contentHash(info.Content)
+	}
+	return props
+}
+
+// statProperties is the Read output in stat mode: the file's properties with
+// content omitted rather than reported as empty.
+type statProperties struct {
+	FileProperties
+	Content *string `json:"content,omitempty"`
+}
+
+// redactedFileContent is a FileContent data source with content omitted.
+type redactedFileContent struct {
+	FileContentProperties
+	Content *string `json:"content,omitempty"`
+}
+
+// redact leaves the content out of reported properties when the omitContent
+// setting is on. A File's sha256 is then the hash of the content it would
+// have reported, so it still changes exactly when that content does.
+func (p *Plugin) redact(props any) any {
+	if !p.settings.OmitContent {
+		return props
+	}
+	switch props := props.(type) {
+	case FileProperties:
+		if props.Content != "" {
+			props.SHA256 = contentHash(props.Content)
+		}
+		return statProperties{FileProperties: props}
+	case FileContentProperties:
+		return redactedFileContent{FileContentProperties: props}
+	}
+	return props
 }
 
 // parseFileProperties extracts file properties from a JSON request.
@@ -98,6 +872,75 @@ func parseFileProperties(data json.RawMessage) (*FileProperties, error) {
 	if props.Permissions == "" {
 		props.Permissions = "0644" // Default permissions
 	}
+	if _, err := parseMode("permissions", props.Permissions, "0644"); err != nil {
+		return nil, err
+	}
+	if _, ok := priorities[props.Priority]; props.Priority != "" && !ok {
+		return nil, fmt.Errorf("invalid 'priority' %q: must be low, normal or high", props.Priority)
+	}
+	if err := checkACLEntries(props.ACL); err != nil {
+		return nil, err
+	}
+	if props.SELinuxContext != "" && !selinuxContext.MatchString(props.SELinuxContext) {
+		return nil, fmt.Errorf("invalid 'selinuxContext' %q: must be user:role:type with an optional level, e.g. system_u:object_r:httpd_sys_content_t:s0", props.SELinuxContext)
+	}
+	for name := range props.Xattrs {
+		if !strings.HasPrefix(name, asyncsftp.XattrPrefix) || name == asyncsftp.XattrPrefix {
+			return nil, fmt.Errorf("invalid xattr %q: only the %s* namespace is supported", name, asyncsftp.XattrPrefix)
+		}
+	}
+	switch props.ContentFormat {
+	case "", contentFormatText, contentFormatJSON, contentFormatYAML, contentFormatXML:
+	default:
+		return nil, fmt.Errorf("invalid 'contentFormat' %q: must be text, json, yaml or xml", props.ContentFormat)
+	}
+	if _, ok := bannerComments[props.BannerComment]; props.BannerComment != "" && props.BannerComment != bannerNone && !ok {
+		return nil, fmt.Errorf("invalid 'bannerComment' %q: must be #, //, --, ;, <!--, /* or none", props.BannerComment)
+	}
+	switch props.WriteMode {
+	case "", writeModeReplace:
+	case writeModeAppend:
+		if err := checkAppend(&props); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid 'writeMode' %q: must be replace or append", props.WriteMode)
+	}
+	if !verifyHashes[props.VerifyHash] {
+		return nil, fmt.Errorf("invalid 'verifyHash' %q: must be sha256, sha512 or xxhash", props.VerifyHash)
+	}
+	if err := checkChecksumFile(&props); err != nil {
+		return nil, err
+	}
+	if props.Compression != "" && props.Compression != compressionGzip {
+		return nil, fmt.Errorf("invalid 'compression' %q: must be gzip", props.Compression)
+	}
+	if props.MaxBytesPerSecond < 0 {
+		return nil, fmt.Errorf("invalid 'maxBytesPerSecond' %d: must not be negative", props.MaxBytesPerSecond)
+	}
+	if (props.ParentPermissions != "" || props.ParentOwner != "") && !props.CreateParents {
+		return nil, fmt.Errorf("'parentPermissions' and 'parentOwner' require 'createParents'")
+	}
+	if props.ParentPermissions != "" {
+		if _, err := parseMode("parentPermissions", props.ParentPermissions, "0755"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := props.parentOwner(); err != nil {
+		return nil, err
+	}
+	props.CreatedDirectories = nil
+	if len(props.ContentParts) > 0 && props.Content != "" {
+		return nil, fmt.Errorf("'content' and 'contentParts' are mutually exclusive")
+	}
+	if props.ExpandEnv && (props.WriteMode == writeModeAppend || (props.ContentFormat != "" && props.ContentFormat != contentFormatText)) {
+		return nil, fmt.Errorf("'expandEnv' requires plain text content in replace mode")
+	}
+	for i := range props.ContentParts {
+		if err := props.ContentParts[i].validate(); err != nil {
+			return nil, err
+		}
+	}
 	return &props, nil
 }
 
@@ -109,54 +952,502 @@ func parseFileProperties(data json.RawMessage) (*FileProperties, error) {
 // The SDK automatically provides identity methods (Name, Version, Namespace)
 // by reading formae-plugin.pkl at startup.
 type Plugin struct {
-	mu     sync.Mutex
-	client *asyncsftp.Client
+	settings    Settings
+	mu          sync.Mutex
+	clients     map[string]*targetClient // by clientKey
+	journal     *asyncsftp.Journal       // finished operations, kept across clients
+	authLockout authLockout
+	support     supportState // failure streak and logs for support bundles
+	dryRunState dryRunState  // changes answered on a dryRun target
+	imports     importState  // documents prepared by import Lists
+	listGaps    listGaps     // subtrees recursive Lists could not read
+}
+
+// Compile-time check: Plugin must satisfy ResourcePlugin interface.
+var _ plugin.ResourcePlugin = &Plugin{}
+
+// targetClient is the connection to one target and its temp-file sweeper.
+// It is registered before dialing, so concurrent requests for the target
+// wait for that dial instead of starting their own.
+type targetClient struct {
+	ready     chan struct{} // closed once dialing finished
+	client    *asyncsftp.Client
+	err       error
+	stopSweep func()
+}
+
+// open returns the client once dialed, or nil while dialing or after it
+// failed.
+func (tc *targetClient) open() *asyncsftp.Client {
+	select {
+	case <-tc.ready:
+		return tc.client
+	default:
+		return nil
+	}
+}
+
+// clientKey identifies the client a target needs: the server and every
+// setting the connection is made or swept with, so targets differing in
+// any of them each get their own.
+func clientKey(clientCfg asyncsftp.Config, cfg *TargetConfig) string {
+	noDelay := "default"
+	if clientCfg.TCP.NoDelay != nil {
+		noDelay = strconv.FormatBool(*clientCfg.TCP.NoDelay)
+	}
+	clientCfg.TCP.NoDelay = nil
+	clientCfg.Clock, clientCfg.Journal, clientCfg.OnDialRetry = nil, nil, nil
+
+	// Hashed so the credentials are not kept in the clear a second time
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%#v|%s|%s|%s", clientCfg, noDelay, cfg.workDir(), cfg.tempFileMaxAge())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getClient returns the SFTP client for the target, creating it if
+// necessary. Each target's client is created lazily on first use and
+// reused for later requests with the same connection settings until its
+// connection is lost. Dialing happens outside p.mu, so a target that is
+// slow to answer only holds up requests for that target, and only until
+// ctx is done.
+func (p *Plugin) getClient(ctx context.Context, log plugin.Logger, targetConfig json.RawMessage) (*asyncsftp.Client, error) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	clientCfg, err := p.clientConfig(cfg, credentialsEnvPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return p.sharedClient(ctx, log, clientKey(clientCfg, cfg), clientCfg, cfg, true)
+}
+
+// sharedClient returns the open client registered under key, or dials one
+// with clientCfg and registers it, sweeping cfg's work directory when sweep
+// is set. Every dial is subject to the authentication lockout.
+func (p *Plugin) sharedClient(ctx context.Context, log plugin.Logger, key string, clientCfg asyncsftp.Config, cfg *TargetConfig, sweep bool) (*asyncsftp.Client, error) {
+	for {
+		p.mu.Lock()
+		tc, ok := p.clients[key]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+
+		// Another request is dialing the target; share its outcome
+		select {
+		case <-tc.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if tc.err != nil {
+			// Its dial gave up because that request was cancelled, not
+			// because the target failed; dial again for this one
+			if ctx.Err() == nil && (errors.Is(tc.err, context.Canceled) || errors.Is(tc.err, context.DeadlineExceeded)) {
+				continue
+			}
+			return nil, tc.err
+		}
+		if !tc.client.Disconnected() {
+			return tc.client, nil
+		}
+		p.mu.Lock()
+		if p.clients[key] == tc {
+			// Dial afresh, which with rotateEndpoints resolves the host again
+			log.Warn("sftp connection lost; reconnecting", "endpoint", tc.client.Endpoint())
+			tc.stopSweep()
+			_ = tc.client.Close()
+			delete(p.clients, key)
+		}
+		p.mu.Unlock()
+	}
+
+	// p.mu is held and no client is open or being dialed for the target
+	if err := p.authLockout.check(time.Now()); err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	if p.journal == nil {
+		p.journal = asyncsftp.NewJournal(p.settings.operationRetention(), nil)
+	}
+	clientCfg.Journal = p.journal
+	clientCfg.OperationTTL = p.settings.operationRetention()
+	if p.clients == nil {
+		p.clients = make(map[string]*targetClient)
+	}
+	tc := &targetClient{ready: make(chan struct{})}
+	p.clients[key] = tc
+	p.mu.Unlock()
+
+	tc.client, tc.err = p.dial(ctx, log, clientCfg, cfg)
+
+	p.mu.Lock()
+	switch {
+	case tc.err != nil:
+		delete(p.clients, key)
+	case sweep:
+		tc.stopSweep = startSweeper(p.backgroundLogger(cfg), tc.client, cfg)
+	default:
+		tc.stopSweep = func() {}
+	}
+	p.mu.Unlock()
+	close(tc.ready)
+	return tc.client, tc.err
+}
+
+// dial connects to the target, recording the outcome against the
+// authentication lockout, and logs the session it opened.
+func (p *Plugin) dial(ctx context.Context, log plugin.Logger, clientCfg asyncsftp.Config, cfg *TargetConfig) (*asyncsftp.Client, error) {
+	client, err := p.connect(ctx, log, clientCfg)
+	limit, period := cfg.authLockout()
+	p.mu.Lock()
+	lockedOut := p.authLockout.record(err, time.Now(), limit, period)
+	p.mu.Unlock()
+	if lockedOut {
+		log.Warn("target rejected repeated logins; pausing connection attempts",
+			"failures", limit, "lockoutPeriod", period)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+
+	info, session := client.ProtocolInfo(), client.Session()
+	log.Info("sftp session opened",
+		"endpoint", client.Endpoint(),
+		"protocol", info.Protocol,
+		"sftpVersion", info.Version,
+		"extensions", info.Extensions,
+		"maxPacket", client.Limits().MaxPacket,
+		"user", session.User,
+		"home", session.Home,
+		"chrooted", session.Chrooted,
+	)
+	for _, w := range client.Warnings() {
+		log.Warn("target has a known limitation", "endpoint", client.Endpoint(), "code", w.Code, "warning", w.Message)
+	}
+	checkClockSkew(log, client, cfg)
+	return client, nil
+}
+
+// cachedClient returns the target's client if one is open, without
+// connecting or waiting for a dial in progress.
+func (p *Plugin) cachedClient(targetConfig json.RawMessage) *asyncsftp.Client {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return nil
+	}
+	clientCfg, err := p.clientConfig(cfg, credentialsEnvPrefix)
+	if err != nil {
+		return nil
+	}
+	key := clientKey(clientCfg, cfg)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tc, ok := p.clients[key]; ok {
+		return tc.open()
+	}
+	return nil
+}
+
+// journaled returns the finished operation answering requestID from the
+// journal the target's clients share.
+func (p *Plugin) journaled(requestID string) (*asyncsftp.Operation, error) {
+	p.mu.Lock()
+	journal := p.journal
+	p.mu.Unlock()
+
+	if journal != nil {
+		if op, ok := journal.Lookup(requestID); ok {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("operation not found: %s", requestID)
+}
+
+// clientConfig returns the client configuration for a target, with the
+// credentials from the environment variables starting with envPrefix.
+func (p *Plugin) clientConfig(cfg *TargetConfig, envPrefix string) (asyncsftp.Config, error) {
+	// Parse URL to get protocol, host and port
+	clientCfg, err := parseURL(cfg.URL)
+	if err != nil {
+		return asyncsftp.Config{}, fmt.Errorf("%w: %w", errInvalidTargetConfig, err)
+	}
+	clientCfg.RotateEndpoints = cfg.RotateEndpoints
+	clientCfg.InsecureSkipVerify = cfg.InsecureSkipVerify
+	clientCfg.Anonymous = cfg.Anonymous
+	switch cfg.MtimeProfile {
+	case mtimeSeconds:
+		clientCfg.MtimePrecision = time.Second
+	case mtimeUntrusted:
+		clientCfg.IgnoreMtime = true
+	}
+	clientCfg.Probe = asyncsftp.Probe(cfg.Probe)
+	clientCfg.MaxQueued = cfg.maxQueued()
+	clientCfg.Workers = p.settings.Workers
+	clientCfg.UploadBatchSize = p.settings.uploadBatchSize()
+	clientCfg.DialTimeout = p.settings.connectTimeout()
+	clientCfg.StatCacheTTL = p.settings.statCacheTTL()
+	clientCfg.IntentDir = p.settings.IntentDir
+	clientCfg.WaitFor, clientCfg.WaitInterval = cfg.waitForTarget()
+	clientCfg.TCP = cfg.tcpOptions()
+	clientCfg.MaxPacket, clientCfg.MaxFileSize = cfg.MaxPacketSize, cfg.MaxFileSize
+	clientCfg.SFTPSubsystem, clientCfg.SFTPServerCommand = cfg.SFTPSubsystem, cfg.SFTPServerCommand
+	if cfg.SFTPVersion != nil {
+		clientCfg.SFTPVersion = *cfg.SFTPVersion
+	}
+
+	// Get credentials from environment; local and in-memory targets need none
+	if clientCfg.Protocol != asyncsftp.ProtocolFile && clientCfg.Protocol != asyncsftp.ProtocolMemory {
+		// FTPS has no key authentication, so sshAgent only applies over SSH
+		sshAgent := cfg.SSHAgent && clientCfg.Protocol != asyncsftp.ProtocolFTPS
+		clientCfg.Username, clientCfg.Password, clientCfg.AgentSocket, err = getCredentials(envPrefix, sshAgent, cfg.Anonymous)
+		if err != nil {
+			return asyncsftp.Config{}, err
+		}
+	}
+	return clientCfg, nil
+}
+
+// connect dials the target, retrying an unreachable one as the plugin
+// settings allow until ctx is done. Rejected credentials are never retried.
+func (p *Plugin) connect(ctx context.Context, log plugin.Logger, cfg asyncsftp.Config) (*asyncsftp.Client, error) {
+	cfg.DialAttempts, cfg.DialBackoff = p.settings.connectRetry()
+	cfg.OnDialRetry = func(attempt int, backoff time.Duration, err error) {
+		if cfg.WaitFor > 0 {
+			log.Warn("target unreachable; waiting for it", "attempt", attempt, "waitForTarget", cfg.WaitFor, "interval", backoff, "error", err)
+			return
+		}
+		log.Warn("target unreachable; retrying", "attempt", attempt, "attempts", cfg.DialAttempts, "backoff", backoff, "error", err)
+	}
+	return asyncsftp.Connect(ctx, cfg)
+}
+
+// Close logs each session's statistics and disconnects from the targets.
+func (p *Plugin) Close(log plugin.Logger) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for key, tc := range p.clients {
+		if tc.open() == nil {
+			continue // still dialing
+		}
+		stats := tc.client.Stats()
+		log.Info("sftp session closed",
+			"endpoint", tc.client.Endpoint(),
+			"operations", stats.Operations,
+			"failures", stats.Failures,
+			"bytesUploaded", stats.BytesUploaded,
+			"bytesDownloaded", stats.BytesDownloaded,
+			"averageLatency", stats.AverageLatency,
+		)
+		tc.stopSweep()
+		errs = append(errs, tc.client.Close())
+		delete(p.clients, key)
+	}
+	return errors.Join(errs...)
+}
+
+// checkClockSkew warns when the server's clock is far enough from the
+// agent's that modification times cannot be trusted.
+func checkClockSkew(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig) {
+	threshold := cfg.clockSkewThreshold()
+	if threshold <= 0 || !cfg.trustsMtime() {
+		return
+	}
+	dir := cfg.workDir()
+	skew, err := client.MeasureClockSkew(dir)
+	if err != nil {
+		log.Debug("could not measure clock skew", "directory", dir, "error", err)
+		return
+	}
+	if skew.Abs() > threshold {
+		log.Warn("server clock differs from agent clock; modification times are unreliable",
+			"endpoint", client.Endpoint(), "skew", skew, "threshold", threshold)
+	}
+}
+
+// sweepInterval is how often leftover temporary files are swept after the
+// pass on connect.
+const sweepInterval = 15 * time.Minute
+
+// startSweeper removes temporary files that crashed runs left in the
+// target's work directory, now and every sweepInterval until the returned
+// function is called. The sweeper outlives the request that connected the
+// target, so log should carry none of that request's attributes.
+func startSweeper(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig) func() {
+	minAge := cfg.tempFileMaxAge()
+	if minAge <= 0 {
+		return func() {}
+	}
+	dir := cfg.workDir()
+	sweep := func() {
+		removed, err := client.SweepTemporary(dir, minAge)
+		if len(removed) > 0 {
+			log.Info("removed leftover temporary files", "directory", dir, "files", removed)
+		}
+		if err != nil {
+			log.Warn("could not sweep temporary files", "directory", dir, "error", err)
+		}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		sweep()
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return sync.OnceFunc(func() { close(stop) })
 }
 
-// Compile-time check: Plugin must satisfy ResourcePlugin interface.
-var _ plugin.ResourcePlugin = &Plugin{}
-
-// getClient returns the SFTP client, creating it if necessary.
-// The client is created lazily on first use and reused for subsequent calls.
-func (p *Plugin) getClient(targetConfig json.RawMessage) (*asyncsftp.Client, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// Operation metadata keys carrying resource identity on asyncsftp operation
+// records, so Status can correlate logs and manifest entries with the
+// resource that started the operation.
+const (
+	metaLabel         = "label"
+	metaResourceType  = "resourceType"
+	metaContentFormat = "contentFormat"
+	metaWriteMode     = "writeMode"
+	metaCompression   = "compression"
+	metaChecksumFile  = "checksumFile"
+	metaChecksumFmt   = "checksumFileFormat"
+	metaExpandEnv     = "expandEnv" // the content as declared
+)
 
-	if p.client != nil {
-		return p.client, nil
+// operationMetadata builds the correlation metadata for an operation.
+func operationMetadata(label, resourceType string) map[string]string {
+	return map[string]string{
+		metaLabel:        label,
+		metaResourceType: resourceType,
 	}
+}
 
-	// Parse target config
+// warnIfSlow logs a timing breakdown when a completed operation exceeded the
+// target's slow-operation threshold, once per operation however often its
+// status is polled. Dial and auth come from the connection the operation
+// ran on.
+func warnIfSlow(log plugin.Logger, targetConfig json.RawMessage, client *asyncsftp.Client, op *asyncsftp.Operation) {
 	cfg, err := parseTargetConfig(targetConfig)
 	if err != nil {
-		return nil, err
+		return
 	}
+	threshold := cfg.slowThreshold()
+	if threshold <= 0 || op.Duration() < threshold || !client.MarkSlowWarned(op.ID) {
+		return
+	}
+
+	// Time spent waiting for a worker is the plugin throttling itself, not
+	// the server being slow; say which one dominated.
+	msg := "slow SFTP operation"
+	if op.Timings.Queued*2 >= op.Duration() {
+		msg = "operation delayed in the worker queue"
+	}
+	connect := client.ConnectTimings()
+	log.Warn(msg,
+		"operation", op.Type,
+		"path", op.Path,
+		"duration", op.Duration(),
+		"threshold", threshold,
+		"queued", op.Timings.Queued,
+		"dial", connect.Dial,
+		"auth", connect.Auth,
+		"open", op.Timings.Open,
+		"write", op.Timings.Write,
+		"chmod", op.Timings.Chmod,
+		"stat", op.Timings.Stat,
+	)
+}
 
-	// Parse URL to get host and port
-	host, port, err := parseURL(cfg.URL)
+// countThrottled records a request the plugin turned away with Throttling,
+// so dashboards can tell the plugin holding back from a slow server.
+func countThrottled(ctx context.Context, reason string) {
+	plugin.MetricsFromContext(ctx).Counter("sftp.throttled", 1, attribute.String("reason", reason))
+}
+
+// checkExecAllowed rejects properties that need SSH exec on a target that
+// does not allow it.
+func checkExecAllowed(targetConfig json.RawMessage, props *FileProperties) error {
+	var property string
+	switch {
+	case len(props.Xattrs) > 0:
+		property = "xattrs"
+	case len(props.ACL) > 0:
+		property = "acl"
+	case props.SELinuxContext != "":
+		property = "selinuxContext"
+	case props.RemoteValidateCommand != "":
+		property = "remoteValidateCommand"
+	default:
+		return nil
+	}
+	cfg, err := parseTargetConfig(targetConfig)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !cfg.AllowExec {
+		return fmt.Errorf("'%s' requires 'allowExec' on the target", property)
 	}
+	return nil
+}
 
-	// Get credentials from environment
-	username, password, err := getCredentials()
+// selinuxContext matches an SELinux security context: user, role and type,
+// then an optional MLS/MCS level such as s0 or s0-s0:c0.c1023.
+var selinuxContext = regexp.MustCompile(`^\w+:\w+:\w+(:[\w.,:-]+)?$`)
+
+// readSELinuxContext fills in the file's security context when the target
+// allows exec. Servers without SELinux labels report none.
+func readSELinuxContext(log plugin.Logger, client *asyncsftp.Client, targetConfig json.RawMessage, props *FileProperties) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || !cfg.AllowExec || props.FileType != string(asyncsftp.FileTypeRegular) {
+		return
+	}
+	label, err := client.GetSELinuxContext(props.Path)
 	if err != nil {
-		return nil, err
+		log.Debug("could not read selinux context", "path", props.Path, "error", err)
+		return
 	}
+	props.SELinuxContext = label
+}
 
-	// Create client
-	client, err := asyncsftp.NewClient(asyncsftp.Config{
-		Host:     host,
-		Port:     port,
-		Username: username,
-		Password: password,
-	})
+// readXattrs fills in the file's user.* xattrs when the target allows exec.
+// Servers without getfattr are treated as having none.
+func readXattrs(log plugin.Logger, client *asyncsftp.Client, targetConfig json.RawMessage, props *FileProperties) {
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil || !cfg.AllowExec || props.FileType != string(asyncsftp.FileTypeRegular) {
+		return
+	}
+	xattrs, err := client.GetXattrs(props.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+		log.Debug("could not read xattrs", "path", props.Path, "error", err)
+		return
 	}
+	if len(xattrs) > 0 {
+		props.Xattrs = xattrs
+	}
+}
 
-	p.client = client
-	return p.client, nil
+// remoteChecksum hashes a regular file on the server when the target allows
+// exec. Otherwise, or if sha256sum is unavailable, no hash is reported.
+func remoteChecksum(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, info *asyncsftp.FileInfo) string {
+	if !cfg.AllowExec || info.Type != asyncsftp.FileTypeRegular {
+		return ""
+	}
+	sum, err := client.Checksum(info.Path)
+	if err != nil {
+		log.Debug("could not checksum file", "path", info.Path, "error", err)
+		return ""
+	}
+	return sum
 }
 
 // =============================================================================
@@ -169,7 +1460,7 @@ func (p *Plugin) getClient(targetConfig json.RawMessage) (*asyncsftp.Client, err
 func (p *Plugin) RateLimit() plugin.RateLimitConfig {
 	return plugin.RateLimitConfig{
 		Scope:                            plugin.RateLimitScopeNamespace,
-		MaxRequestsPerSecondForNamespace: 5,
+		MaxRequestsPerSecondForNamespace: p.settings.requestsPerSecond(),
 	}
 }
 
@@ -188,336 +1479,88 @@ func (p *Plugin) LabelConfig() plugin.LabelConfig {
 	}
 }
 
-// =============================================================================
-// CRUD Operations
-// =============================================================================
-
-// Create provisions a new resource.
-// Returns InProgress with a RequestID - poll Status() for completion.
-func (p *Plugin) Create(ctx context.Context, req *resource.CreateRequest) (*resource.CreateResult, error) {
-	// Get observability from context
-	log := plugin.LoggerFromContext(ctx)
-	metrics := plugin.MetricsFromContext(ctx)
-
-	log.Info("creating file resource", "label", req.Label)
-
-	// Parse file properties from request
-	props, err := parseFileProperties(req.Properties)
-	if err != nil {
-		return &resource.CreateResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationCreate,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
-	}
-
-	// Get SFTP client
-	client, err := p.getClient(req.TargetConfig)
-	if err != nil {
-		return &resource.CreateResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationCreate,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
-	}
-
-	// Parse permissions string to os.FileMode
-	var perm os.FileMode = 0644
-	if props.Permissions != "" {
-		_, _ = fmt.Sscanf(props.Permissions, "%o", &perm)
+// Status checks the progress of an async operation.
+// Called when Create/Update/Delete return InProgress status.
+func (p *Plugin) Status(ctx context.Context, req *resource.StatusRequest) (*resource.StatusResult, error) {
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	result, err := p.status(ctx, req)
+	if result != nil {
+		p.finish(ctx, req.TargetConfig, result.ProgressResult)
 	}
-
-	// Start async upload - returns immediately with operation ID
-	requestID := client.StartUpload(props.Path, props.Content, perm)
-
-	// Record metric for uploads started
-	metrics.Counter("sftp.uploads_started", 1,
-		attribute.String("path", props.Path))
-
-	log.Debug("upload started", "requestID", requestID, "path", props.Path)
-
-	return &resource.CreateResult{
-		ProgressResult: &resource.ProgressResult{
-			Operation:       resource.OperationCreate,
-			OperationStatus: resource.OperationStatusInProgress,
-			RequestID:       requestID,
-			NativeID:        props.Path, // File path is the native identifier
-		},
-	}, nil
+	return result, err
 }
 
-// Read retrieves the current state of a resource.
-// Returns NotFound error code (not an error) if the file doesn't exist.
-func (p *Plugin) Read(ctx context.Context, req *resource.ReadRequest) (*resource.ReadResult, error) {
-	// Get SFTP client
-	client, err := p.getClient(req.TargetConfig)
-	if err != nil {
-		return &resource.ReadResult{
-			ResourceType: req.ResourceType,
-			ErrorCode:    resource.OperationErrorCodeInternalFailure,
-		}, nil
-	}
-
-	// Read file from SFTP server
-	fileInfo, err := client.ReadFile(req.NativeID)
-	if err != nil {
-		// NotFound is not an error - return result with ErrorCode
-		if errors.Is(err, asyncsftp.ErrNotFound) {
-			return &resource.ReadResult{
-				ResourceType: req.ResourceType,
-				ErrorCode:    resource.OperationErrorCodeNotFound,
+// status answers Status for a prepared context.
+func (p *Plugin) status(ctx context.Context, req *resource.StatusRequest) (*resource.StatusResult, error) {
+	// Status rarely dials: the client that started the operation answers
+	// for it, or once that client is closed, the journal. Only an upload an
+	// earlier process started and recorded an intent for needs a new
+	// connection, to check what reached the server, as does a finished
+	// upload the journal answers for while a manifest is configured, to
+	// record or release its claim.
+	client := p.cachedClient(req.TargetConfig)
+	op, err := p.journaled(req.RequestID)
+	if client == nil && err != nil && p.settings.IntentDir != "" {
+		client, err = p.getClient(ctx, plugin.LoggerFromContext(ctx), req.TargetConfig)
+		if err != nil {
+			return &resource.StatusResult{
+				ProgressResult: &resource.ProgressResult{
+					Operation:       resource.OperationCheckStatus,
+					OperationStatus: resource.OperationStatusFailure,
+					ErrorCode:       errorCode(err),
+					StatusMessage:   err.Error(),
+				},
 			}, nil
 		}
-		return &resource.ReadResult{
-			ResourceType: req.ResourceType,
-			ErrorCode:    resource.OperationErrorCodeInternalFailure,
-		}, nil
 	}
-
-	// Convert to JSON properties
-	props := FileProperties{
-		Path:        fileInfo.Path,
-		Content:     fileInfo.Content,
-		Permissions: fileInfo.Permissions,
-		Size:        fileInfo.Size,
-		ModifiedAt:  fileInfo.ModifiedAt.Format("2006-01-02T15:04:05Z07:00"),
+	if client != nil {
+		op, err = client.GetStatus(req.RequestID)
 	}
-	propsJSON, _ := json.Marshal(props)
-
-	return &resource.ReadResult{
-		ResourceType: req.ResourceType,
-		Properties:   string(propsJSON),
-	}, nil
-}
-
-// Update modifies an existing resource.
-// Updates are synchronous - we update content and/or permissions directly.
-func (p *Plugin) Update(ctx context.Context, req *resource.UpdateRequest) (*resource.UpdateResult, error) {
-	// Get SFTP client
-	client, err := p.getClient(req.TargetConfig)
 	if err != nil {
-		return &resource.UpdateResult{
+		return &resource.StatusResult{
 			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationUpdate,
+				Operation:       resource.OperationCheckStatus,
 				OperationStatus: resource.OperationStatusFailure,
 				ErrorCode:       resource.OperationErrorCodeInternalFailure,
 				StatusMessage:   err.Error(),
 			},
 		}, nil
 	}
-
-	// Parse desired properties
-	desiredProps, err := parseFileProperties(req.DesiredProperties)
-	if err != nil {
-		return &resource.UpdateResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationUpdate,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInvalidRequest,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
-	}
-
-	// Parse prior properties to detect changes
-	priorProps, _ := parseFileProperties(req.PriorProperties)
-
-	// Check if content changed - need to rewrite file
-	if priorProps == nil || priorProps.Content != desiredProps.Content {
-		var perm os.FileMode = 0644
-		if desiredProps.Permissions != "" {
-			_, _ = fmt.Sscanf(desiredProps.Permissions, "%o", &perm)
-		}
-
-		// Use sync upload for update (blocking)
-		opID := client.StartUpload(req.NativeID, desiredProps.Content, perm)
-
-		// Wait for completion
-		for {
-			op, err := client.GetStatus(opID)
-			if err != nil {
-				return &resource.UpdateResult{
+	if client == nil && op.Type == asyncsftp.OperationTypeUpload && op.State != asyncsftp.StateInProgress {
+		if cfg, cfgErr := parseTargetConfig(req.TargetConfig); cfgErr == nil && cfg.ManifestPath != "" {
+			if client, err = p.getClient(ctx, plugin.LoggerFromContext(ctx), req.TargetConfig); err != nil {
+				return &resource.StatusResult{
 					ProgressResult: &resource.ProgressResult{
-						Operation:       resource.OperationUpdate,
+						Operation:       resource.OperationCheckStatus,
 						OperationStatus: resource.OperationStatusFailure,
-						ErrorCode:       resource.OperationErrorCodeInternalFailure,
+						ErrorCode:       errorCode(err),
 						StatusMessage:   err.Error(),
 					},
 				}, nil
 			}
-			if op.State == asyncsftp.StateCompleted {
-				break
-			}
-			if op.State == asyncsftp.StateFailure {
-				return &resource.UpdateResult{
-					ProgressResult: &resource.ProgressResult{
-						Operation:       resource.OperationUpdate,
-						OperationStatus: resource.OperationStatusFailure,
-						ErrorCode:       resource.OperationErrorCodeInternalFailure,
-						StatusMessage:   op.Error,
-					},
-				}, nil
-			}
-		}
-	} else if priorProps.Permissions != desiredProps.Permissions {
-		// Only permissions changed
-		var perm os.FileMode = 0644
-		_, _ = fmt.Sscanf(desiredProps.Permissions, "%o", &perm)
-
-		if err := client.SetPermissions(req.NativeID, perm); err != nil {
-			return &resource.UpdateResult{
-				ProgressResult: &resource.ProgressResult{
-					Operation:       resource.OperationUpdate,
-					OperationStatus: resource.OperationStatusFailure,
-					ErrorCode:       resource.OperationErrorCodeInternalFailure,
-					StatusMessage:   err.Error(),
-				},
-			}, nil
-		}
-	}
-
-	// Read back the updated file to return current state
-	fileInfo, err := client.ReadFile(req.NativeID)
-	if err != nil {
-		return &resource.UpdateResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationUpdate,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
-	}
-
-	resourceProps, _ := json.Marshal(FileProperties{
-		Path:        fileInfo.Path,
-		Content:     fileInfo.Content,
-		Permissions: fileInfo.Permissions,
-		Size:        fileInfo.Size,
-		ModifiedAt:  fileInfo.ModifiedAt.Format("2006-01-02T15:04:05Z07:00"),
-	})
-
-	return &resource.UpdateResult{
-		ProgressResult: &resource.ProgressResult{
-			Operation:          resource.OperationUpdate,
-			OperationStatus:    resource.OperationStatusSuccess,
-			NativeID:           req.NativeID,
-			ResourceProperties: resourceProps,
-		},
-	}, nil
-}
-
-// Delete removes a resource.
-// Returns Failure with NotFound error code if file doesn't exist (agent treats this as success).
-func (p *Plugin) Delete(ctx context.Context, req *resource.DeleteRequest) (*resource.DeleteResult, error) {
-	// Get SFTP client
-	client, err := p.getClient(req.TargetConfig)
-	if err != nil {
-		return &resource.DeleteResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationDelete,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
-	}
-
-	// Check if file exists first
-	_, err = client.ReadFile(req.NativeID)
-	if err != nil {
-		if errors.Is(err, asyncsftp.ErrNotFound) {
-			// File doesn't exist - return Failure with NotFound
-			// The agent treats NotFound on Delete as success
-			return &resource.DeleteResult{
-				ProgressResult: &resource.ProgressResult{
-					Operation:       resource.OperationDelete,
-					OperationStatus: resource.OperationStatusFailure,
-					ErrorCode:       resource.OperationErrorCodeNotFound,
-					NativeID:        req.NativeID,
-				},
-			}, nil
 		}
-		return &resource.DeleteResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationDelete,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
 	}
 
-	// Start delete operation
-	opID := client.StartDelete(req.NativeID)
+	// Correlate with the resource that started the operation
+	log := plugin.LoggerFromContext(ctx).With(
+		"requestID", req.RequestID,
+		"label", op.Metadata[metaLabel],
+		"resourceType", op.Metadata[metaResourceType],
+		"path", op.Path,
+	)
 
-	// Wait for completion (delete is fast, we wait synchronously)
-	for {
-		op, err := client.GetStatus(opID)
-		if err != nil {
-			return &resource.DeleteResult{
-				ProgressResult: &resource.ProgressResult{
-					Operation:       resource.OperationDelete,
-					OperationStatus: resource.OperationStatusFailure,
-					ErrorCode:       resource.OperationErrorCodeInternalFailure,
-					StatusMessage:   err.Error(),
-				},
-			}, nil
-		}
-		if op.State == asyncsftp.StateCompleted {
-			return &resource.DeleteResult{
-				ProgressResult: &resource.ProgressResult{
-					Operation:       resource.OperationDelete,
-					OperationStatus: resource.OperationStatusSuccess,
-					NativeID:        req.NativeID,
-				},
-			}, nil
-		}
-		if op.State == asyncsftp.StateFailure {
-			return &resource.DeleteResult{
-				ProgressResult: &resource.ProgressResult{
-					Operation:       resource.OperationDelete,
-					OperationStatus: resource.OperationStatusFailure,
-					ErrorCode:       resource.OperationErrorCodeInternalFailure,
-					StatusMessage:   op.Error,
-				},
-			}, nil
+	if op.State != asyncsftp.StateInProgress {
+		if client != nil {
+			warnIfSlow(log, req.TargetConfig, client, op)
 		}
+		plugin.MetricsFromContext(ctx).Gauge("sftp.queue_wait_seconds", op.Timings.Queued.Seconds(),
+			attribute.String("operation", string(op.Type)))
 	}
-}
-
-// Status checks the progress of an async operation.
-// Called when Create/Update/Delete return InProgress status.
-func (p *Plugin) Status(ctx context.Context, req *resource.StatusRequest) (*resource.StatusResult, error) {
-	// Client must exist if we have a RequestID from a previous operation
-	if p.client == nil {
-		return &resource.StatusResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationCheckStatus,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   "no client available",
-			},
-		}, nil
+	if op.State == asyncsftp.StateFailure {
+		log.Error("operation failed", "operation", op.Type, "error", op.Error, "serverMessage", asyncsftp.ServerMessage(op.Err))
 	}
-
-	// Get operation status from asyncsftp
-	op, err := p.client.GetStatus(req.RequestID)
-	if err != nil {
-		return &resource.StatusResult{
-			ProgressResult: &resource.ProgressResult{
-				Operation:       resource.OperationCheckStatus,
-				OperationStatus: resource.OperationStatusFailure,
-				ErrorCode:       resource.OperationErrorCodeInternalFailure,
-				StatusMessage:   err.Error(),
-			},
-		}, nil
+	if len(op.RequestIDs) > 1 {
+		log.Debug("operation deduplicated", "attempts", len(op.RequestIDs), "requestIDs", op.RequestIDs)
 	}
 
 	// Map asyncsftp state to resource.OperationStatus
@@ -532,17 +1575,50 @@ func (p *Plugin) Status(ctx context.Context, req *resource.StatusRequest) (*reso
 		status = resource.OperationStatusSuccess
 		// Include resource properties on success
 		if op.Result != nil {
-			resourceProps, _ = json.Marshal(FileProperties{
-				Path:        op.Result.Path,
-				Content:     op.Result.Content,
-				Permissions: op.Result.Permissions,
-				Size:        op.Result.Size,
-				ModifiedAt:  op.Result.ModifiedAt.Format("2006-01-02T15:04:05Z07:00"),
-			})
+			props := filePropertiesFromInfo(op.Result, timestampFormat(req.TargetConfig))
+			stripBanner(req.TargetConfig, &props)
+			props.WriteMode = op.Metadata[metaWriteMode]
+			props.Compression = op.Metadata[metaCompression]
+			props.ChecksumFile, props.ChecksumFileFormat = op.Metadata[metaChecksumFile], op.Metadata[metaChecksumFmt]
+			props.CreatedDirectories = op.CreatedDirs
+			if template, ok := op.Metadata[metaExpandEnv]; ok {
+				reportExpanded(&props, template, p.settings.ExpandEnvAllow)
+			}
+			withSample(req.TargetConfig, &props, op.Result)
+			withLocation(log, client, req.TargetConfig, &props)
+			resourceProps, _ = json.Marshal(p.redact(props))
+			// A configured manifest always has a client by now: the one
+			// that ran the upload, or one dialed above for the journal
+			if op.Type == asyncsftp.OperationTypeUpload && client != nil {
+				withManifest(log, req.TargetConfig, func(manifestPath string) error {
+					return recordManaged(client, manifestPath, op.Result, ManifestEntry{
+						Label:         op.Metadata[metaLabel],
+						ResourceType:  op.Metadata[metaResourceType],
+						ContentFormat: op.Metadata[metaContentFormat],
+						WriteMode:     op.Metadata[metaWriteMode],
+						Compression:   op.Metadata[metaCompression],
+						ExpandEnv:     props.ExpandEnv,
+						Declared:      props.Content,
+					})
+				})
+			}
 		}
 	case asyncsftp.StateFailure:
 		status = resource.OperationStatusFailure
-		errorCode = resource.OperationErrorCodeInternalFailure
+		errorCode = failureCode(op)
+		if op.Type == asyncsftp.OperationTypeUpload && client != nil {
+			withManifest(log, req.TargetConfig, func(manifestPath string) error {
+				return releaseClaim(client, manifestPath, op.Path, op.Metadata[metaLabel])
+			})
+		}
+	}
+
+	message := statusMessage(op)
+	if op.State != asyncsftp.StateInProgress && client != nil {
+		message = withWarnings(message, client)
+	}
+	if client != nil && (errors.Is(op.Err, asyncsftp.ErrNotFound) || errors.Is(op.Err, os.ErrNotExist)) {
+		message = withSession(message, client)
 	}
 
 	return &resource.StatusResult{
@@ -553,44 +1629,206 @@ func (p *Plugin) Status(ctx context.Context, req *resource.StatusRequest) (*reso
 			NativeID:           op.Path,
 			ResourceProperties: resourceProps,
 			ErrorCode:          errorCode,
-			StatusMessage:      op.Error,
+			StatusMessage:      message,
 		},
 	}, nil
 }
 
+// statusMessage returns op's error or, while an upload runs, how much of it
+// has been written, followed by a deduplication report when retried applies
+// were attached to op instead of uploading again.
+func statusMessage(op *asyncsftp.Operation) string {
+	var parts []string
+	if op.Error != "" {
+		parts = append(parts, op.Error)
+	}
+	if op.State == asyncsftp.StateInProgress && op.BytesWritten > 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes written", op.BytesWritten))
+	}
+	if op.State != asyncsftp.StateInProgress && op.Timings.Queued >= queueReportThreshold {
+		parts = append(parts, fmt.Sprintf("waited %s for a free worker before starting", op.Timings.Queued.Round(time.Millisecond)))
+	}
+	if op.CacheHit {
+		parts = append(parts, "copied from the server-side artifact cache")
+	}
+	if len(op.CreatedDirs) > 0 {
+		parts = append(parts, "created directories "+strings.Join(op.CreatedDirs, ", "))
+	}
+	if len(op.RequestIDs) > 1 {
+		parts = append(parts, fmt.Sprintf("deduplicated: %d apply attempts satisfied by one upload (requests %s)",
+			len(op.RequestIDs), strings.Join(op.RequestIDs, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withWarnings appends the target's known limitations to a result message,
+// so users learn about them at apply time rather than from a failure.
+func withWarnings(message string, client *asyncsftp.Client) string {
+	var parts []string
+	if message != "" {
+		parts = append(parts, message)
+	}
+	for _, w := range client.Warnings() {
+		parts = append(parts, fmt.Sprintf("warning [%s]: %s", w.Code, w.Message))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withSession appends who and where the client is logged in to a result
+// message, for paths that turn out to be missing: on servers that chroot
+// the session or land it elsewhere than expected, the path is rarely the
+// whole story.
+func withSession(message string, client *asyncsftp.Client) string {
+	return message + "; session: " + client.Session().String()
+}
+
 // List returns all resource identifiers of a given type.
 // Called during discovery to find unmanaged resources.
 func (p *Plugin) List(ctx context.Context, req *resource.ListRequest) (*resource.ListResult, error) {
-	// Get SFTP client
-	client, err := p.getClient(req.TargetConfig)
+	ctx = p.requestContext(ctx, req.TargetConfig)
+	log := plugin.LoggerFromContext(ctx)
+	metrics := plugin.MetricsFromContext(ctx)
+
+	// Get SFTP client. ListResult carries no error code, so failures are
+	// returned as errors: an empty list would tell the agent that every
+	// previously discovered file is gone.
+	client, err := p.getClient(ctx, log, req.TargetConfig)
 	if err != nil {
-		return &resource.ListResult{
-			NativeIDs: []string{},
-		}, nil
+		return nil, fmt.Errorf("list: %w", err)
 	}
 
-	// List files in the upload directory
-	// In a real plugin, you might use AdditionalProperties to specify the directory
+	// List files in the upload directory. AdditionalProperties may override
+	// the directory and set recursive=true to descend into subdirectories.
 	dir := "/upload"
 	if d, ok := req.AdditionalProperties["directory"]; ok {
 		dir = d
 	}
+	if err := checkPath(req.TargetConfig, dir); err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+	if dir, err = resolvePath(client, req.TargetConfig, dir); err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	offset, err := parsePageToken(req.PageToken)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+	limit := int(req.PageSize)
+
+	// Files are filtered as they are listed, so pages are cut from the files
+	// List reports and page tokens count only those.
+	stable, err := stablePaths(client, req.TargetConfig)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+	owned, err := filterOwnership(client, req.TargetConfig, req.AdditionalProperties["ownership"])
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", dir, err)
+	}
+	var paths []string
+	var reported int
+	var more bool
+	var resolveErr error
+	visit := func(info *asyncsftp.FileInfo) bool {
+		if !listable(log, info.Path) {
+			return true
+		}
+		path, ok, err := stable(info.Path)
+		if err != nil {
+			resolveErr = err
+			return false
+		}
+		if !ok || !owned(path) {
+			return true
+		}
+		if reported++; reported <= offset {
+			return true
+		}
+		if limit > 0 && len(paths) == limit {
+			more = true
+			return false
+		}
+		paths = append(paths, path)
+		return true
+	}
 
-	paths, err := client.ListFiles(dir)
+	if req.AdditionalProperties["recursive"] == "true" {
+		var skipped []*asyncsftp.ListError
+		skipped, err = client.WalkTree(dir, visit)
+		// Subtrees we could not read do not hide the files that were listed
+		// successfully; later results on the target report them. A walk
+		// that stopped at the end of a page only saw part of the tree, so it
+		// does not clear an earlier gap.
+		if err == nil && resolveErr == nil && (!more || len(skipped) > 0) {
+			p.listGaps.record(req.TargetConfig, dir, skipped)
+		}
+		for _, s := range skipped {
+			log.Warn("skipping inaccessible subtree during list",
+				"directory", s.Path, "errorCode", errorCode(s.Err), "error", s.Err)
+			metrics.Counter("sftp.list_skipped_subtrees", 1,
+				attribute.String("directory", dir))
+		}
+		if len(skipped) > 0 {
+			log.Warn("list incomplete", "directory", dir, "listed", len(paths), "gap", describeGap(dir, skipped))
+		}
+	} else {
+		err = client.WalkFiles(dir, visit)
+	}
+	if err == nil {
+		err = resolveErr
+	}
 	if err != nil {
-		// If directory doesn't exist, return empty list
+		// If directory doesn't exist, there is genuinely nothing to discover
 		if errors.Is(err, asyncsftp.ErrNotFound) {
 			return &resource.ListResult{
 				NativeIDs: []string{},
 			}, nil
 		}
-		return &resource.ListResult{
-			NativeIDs: []string{},
-		}, nil
+		metrics.Counter("sftp.list_failures", 1,
+			attribute.String("directory", dir))
+		return nil, fmt.Errorf("list %s: %w", dir, err)
 	}
 
-	return &resource.ListResult{
-		NativeIDs:     paths,
-		NextPageToken: nil, // No pagination for this simple implementation
-	}, nil
+	if req.AdditionalProperties["import"] == "true" && req.ResourceType == fileType {
+		p.prepareImport(ctx, req.TargetConfig, paths)
+	}
+
+	result := &resource.ListResult{NativeIDs: paths}
+	if more {
+		next := strconv.Itoa(offset + limit)
+		result.NextPageToken = &next
+	}
+	return result, nil
+}
+
+// parsePageToken returns the number of files earlier List pages reported.
+// Tokens are offsets into the server's listing order, counting only files
+// List reports, so files created or removed between pages may be missed or
+// repeated until the next discovery.
+func parsePageToken(token *string) (int, error) {
+	if token == nil || *token == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(*token)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid page token %q", *token)
+	}
+	return offset, nil
+}
+
+// listable reports whether a listed path can be returned as a NativeID.
+// NativeIDs travel as JSON, which replaces invalid UTF-8 with U+FFFD, and
+// names with control characters fail path validation; neither would resolve
+// back to the file, so they are skipped loudly.
+func listable(log plugin.Logger, path string) bool {
+	if !utf8.ValidString(path) {
+		log.Warn("skipping file whose name is not valid UTF-8", "path", fmt.Sprintf("%q", path))
+		return false
+	}
+	if err := validatePath(path, ""); err != nil {
+		log.Warn("skipping file with unsupported name", "error", err)
+		return false
+	}
+	return true
 }
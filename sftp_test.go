@@ -486,3 +486,89 @@ func TestList(t *testing.T) {
 		_ = client.StartDelete(filePath)
 	}
 }
+
+// =============================================================================
+// Path Handling Tests
+// =============================================================================
+
+// TestSpecialCharacterPaths verifies the full lifecycle for file names with
+// spaces and non-ASCII characters (control characters such as newlines are
+// rejected by path validation, see TestValidatePath):
+// 1. Create/Status writes the file under the exact name
+// 2. Read returns the path unchanged after a JSON round trip
+// 3. List discovers the file under the same NativeID
+// 4. Delete removes it
+func TestSpecialCharacterPaths(t *testing.T) {
+	if os.Getenv("SFTP_USERNAME") == "" || os.Getenv("SFTP_PASSWORD") == "" {
+		t.Skip("SFTP_USERNAME and SFTP_PASSWORD must be set")
+	}
+
+	ctx := context.Background()
+	plugin := &Plugin{}
+
+	paths := []string{
+		"/upload/test special spaces.txt",
+		"/upload/test-données-ü.txt",
+		"/upload/test-日本語.txt",
+	}
+
+	for _, filePath := range paths {
+		t.Run(filePath, func(t *testing.T) {
+			propertiesJSON, err := json.Marshal(map[string]any{
+				"path":    filePath,
+				"content": "special",
+			})
+			require.NoError(t, err)
+
+			// --- Create ---
+			result, err := plugin.Create(ctx, &resource.CreateRequest{
+				ResourceType: "SFTP::Files::File",
+				Label:        "test-special",
+				Properties:   propertiesJSON,
+				TargetConfig: testTargetConfig(),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, filePath, result.ProgressResult.NativeID)
+
+			require.Eventually(t, func() bool {
+				statusResult, err := plugin.Status(ctx, &resource.StatusRequest{
+					RequestID:    result.ProgressResult.RequestID,
+					ResourceType: "SFTP::Files::File",
+					TargetConfig: testTargetConfig(),
+				})
+				return err == nil && statusResult.ProgressResult.OperationStatus == resource.OperationStatusSuccess
+			}, 10*time.Second, 100*time.Millisecond, "Create should complete")
+
+			// --- Read: path survives the JSON round trip ---
+			readResult, err := plugin.Read(ctx, &resource.ReadRequest{
+				NativeID:     filePath,
+				ResourceType: "SFTP::Files::File",
+				TargetConfig: testTargetConfig(),
+			})
+			require.NoError(t, err)
+			require.Empty(t, readResult.ErrorCode)
+
+			var props FileProperties
+			require.NoError(t, json.Unmarshal([]byte(readResult.Properties), &props))
+			assert.Equal(t, filePath, props.Path)
+			assert.Equal(t, "special", props.Content)
+
+			// --- List: discovered under the same NativeID ---
+			listResult, err := plugin.List(ctx, &resource.ListRequest{
+				ResourceType: "SFTP::Files::File",
+				TargetConfig: testTargetConfig(),
+			})
+			require.NoError(t, err)
+			assert.Contains(t, listResult.NativeIDs, filePath)
+
+			// --- Delete ---
+			deleteResult, err := plugin.Delete(ctx, &resource.DeleteRequest{
+				NativeID:     filePath,
+				ResourceType: "SFTP::Files::File",
+				TargetConfig: testTargetConfig(),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, resource.OperationStatusSuccess, deleteResult.ProgressResult.OperationStatus)
+		})
+	}
+}
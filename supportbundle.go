@@ -0,0 +1,278 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+)
+
+// =============================================================================
+// Support Bundles
+// =============================================================================
+
+// supportBundleLogs is how many recent log lines a support bundle holds.
+const supportBundleLogs = 200
+
+// supportBundleOperations is how many recent operations a support bundle
+// holds.
+const supportBundleOperations = 50
+
+// supportState tracks consecutive failures and recent log lines so the
+// plugin can write a support bundle once failures repeat.
+type supportState struct {
+	mu       sync.Mutex
+	failures int // consecutive failed requests
+	logs     logRing
+}
+
+// recordOutcome counts result towards the current failure streak and
+// writes a support bundle when the streak reaches supportBundleAfter. A
+// success ends the streak; results still in progress leave it as it is.
+func (p *Plugin) recordOutcome(ctx context.Context, targetConfig json.RawMessage, result *resource.ProgressResult) {
+	if p.settings.SupportBundleDir == "" || result == nil {
+		return
+	}
+	// Every change fails on a dry-run target, by design
+	if cfg, err := parseTargetConfig(targetConfig); err == nil && cfg.DryRun {
+		return
+	}
+	p.support.mu.Lock()
+	switch result.OperationStatus {
+	case resource.OperationStatusSuccess:
+		p.support.failures = 0
+	case resource.OperationStatusFailure:
+		p.support.failures++
+	}
+	failures := p.support.failures
+	p.support.mu.Unlock()
+
+	if failures != p.settings.supportBundleAfter() {
+		return
+	}
+	log := plugin.LoggerFromContext(ctx)
+	path, err := p.writeSupportBundle(targetConfig, failures)
+	if err != nil {
+		log.Error("could not write support bundle", "error", err)
+		return
+	}
+	log.Warn("repeated failures; wrote support bundle", "failures", failures, "path", path)
+}
+
+// supportBundle is what a support bundle file holds. It carries no
+// credentials and no file content.
+type supportBundle struct {
+	CreatedAt        time.Time               `json:"createdAt"`
+	Failures         int                     `json:"consecutiveFailures"`
+	Target           *TargetConfig           `json:"target,omitempty"`
+	Connection       *bundleConnection       `json:"connection,omitempty"`
+	RecentOperations []bundleOperation       `json:"recentOperations"`
+	RecentLogs       []logLine               `json:"recentLogs"`
+	Settings         Settings                `json:"settings"`
+	Stats            *asyncsftp.SessionStats `json:"stats,omitempty"`
+}
+
+// bundleConnection describes the open session.
+type bundleConnection struct {
+	Endpoint       string                 `json:"endpoint"`
+	Protocol       asyncsftp.ProtocolInfo `json:"protocol"`
+	Limits         asyncsftp.Limits       `json:"limits"`
+	ConnectTimings asyncsftp.Timings      `json:"connectTimings"`
+	Warnings       []asyncsftp.Warning    `json:"warnings,omitempty"`
+	Session        asyncsftp.Session      `json:"session"`
+}
+
+// bundleOperation is an operation record without its result, which may
+// hold file content.
+type bundleOperation struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	Path         string    `json:"path"`
+	Label        string    `json:"label,omitempty"`
+	ResourceType string    `json:"resourceType,omitempty"`
+	State        string    `json:"state"`
+	Error        string    `json:"error,omitempty"`
+	StartedAt    time.Time `json:"startedAt"`
+	CompletedAt  time.Time `json:"completedAt"`
+	Duration     string    `json:"duration"`
+}
+
+// writeSupportBundle writes what is known about the target and recent work
+// to a new file in the support bundle directory and returns its path.
+func (p *Plugin) writeSupportBundle(targetConfig json.RawMessage, failures int) (string, error) {
+	now := time.Now().UTC()
+	bundle := supportBundle{
+		CreatedAt:  now,
+		Failures:   failures,
+		RecentLogs: p.support.logs.lines(),
+		Settings:   p.settings,
+	}
+	name := "target"
+	if cfg, err := parseTargetConfig(targetConfig); err == nil {
+		cfg.URL = redactURL(cfg.URL)
+		bundle.Target = cfg
+		name = cmp.Or(cfg.Name, hostOf(cfg.URL), name)
+	}
+
+	client := p.cachedClient(targetConfig)
+	p.mu.Lock()
+	journal := p.journal
+	p.mu.Unlock()
+	if client != nil {
+		stats := client.Stats()
+		bundle.Stats = &stats
+		bundle.Connection = &bundleConnection{
+			Endpoint:       client.Endpoint(),
+			Protocol:       client.ProtocolInfo(),
+			Limits:         client.Limits(),
+			ConnectTimings: client.ConnectTimings(),
+			Warnings:       client.Warnings(),
+			Session:        client.Session(),
+		}
+	}
+	if journal != nil {
+		for _, op := range journal.Recent(supportBundleOperations) {
+			bundle.RecentOperations = append(bundle.RecentOperations, bundleOperation{
+				ID:           op.ID,
+				Type:         string(op.Type),
+				Path:         op.Path,
+				Label:        op.Metadata[metaLabel],
+				ResourceType: op.Metadata[metaResourceType],
+				State:        string(op.State),
+				Error:        op.Error,
+				StartedAt:    op.StartedAt,
+				CompletedAt:  op.CompletedAt,
+				Duration:     op.Duration().String(),
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	file := fmt.Sprintf("formae-sftp-support-%s-%s.json", unsafeFileChars.ReplaceAllString(name, "_"), now.Format("20060102T150405Z"))
+	path := filepath.Join(p.settings.SupportBundleDir, file)
+	if err := os.MkdirAll(p.settings.SupportBundleDir, 0700); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// unsafeFileChars are replaced in the target name a bundle's file is named
+// after.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// redactURL removes a password from a target URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	return u.String()
+}
+
+// hostOf returns the host of a target URL, or "" when it has none.
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// =============================================================================
+// Recent Logs
+// =============================================================================
+
+// logLine is one log record kept for support bundles.
+type logLine struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Attrs   []string  `json:"attrs,omitempty"`
+}
+
+// logRing keeps the most recent supportBundleLogs log lines.
+type logRing struct {
+	mu    sync.Mutex
+	buf   []logLine
+	next  int
+	count int
+}
+
+func (r *logRing) add(level, msg string, attrs []any) {
+	line := logLine{Time: time.Now().UTC(), Level: level, Message: msg}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		line.Attrs = append(line.Attrs, fmt.Sprintf("%v=%v", attrs[i], attrs[i+1]))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buf == nil {
+		r.buf = make([]logLine, supportBundleLogs)
+	}
+	r.buf[r.next] = line
+	r.next = (r.next + 1) % len(r.buf)
+	r.count = min(r.count+1, len(r.buf))
+}
+
+// lines returns the kept lines, oldest first.
+func (r *logRing) lines() []logLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines := make([]logLine, 0, r.count)
+	for i := range r.count {
+		lines = append(lines, r.buf[(r.next-r.count+i+len(r.buf))%len(r.buf)])
+	}
+	return lines
+}
+
+// logger returns log, keeping its info, warning and error lines in r.
+func (r *logRing) logger(log plugin.Logger) plugin.Logger {
+	return ringLogger{Logger: log, ring: r}
+}
+
+// ringLogger copies the lines it logs into a logRing.
+type ringLogger struct {
+	plugin.Logger
+	ring  *logRing
+	attrs []any // from With, which the ring cannot ask the logger for
+}
+
+func (l ringLogger) Info(msg string, attrs ...any) {
+	l.ring.add("INFO", msg, append(l.attrs[:len(l.attrs):len(l.attrs)], attrs...))
+	l.Logger.Info(msg, attrs...)
+}
+
+func (l ringLogger) Warn(msg string, attrs ...any) {
+	l.ring.add("WARN", msg, append(l.attrs[:len(l.attrs):len(l.attrs)], attrs...))
+	l.Logger.Warn(msg, attrs...)
+}
+
+func (l ringLogger) Error(msg string, attrs ...any) {
+	l.ring.add("ERROR", msg, append(l.attrs[:len(l.attrs):len(l.attrs)], attrs...))
+	l.Logger.Error(msg, attrs...)
+}
+
+func (l ringLogger) With(attrs ...any) plugin.Logger {
+	return ringLogger{Logger: l.Logger.With(attrs...), ring: l.ring, attrs: append(l.attrs[:len(l.attrs):len(l.attrs)], attrs...)}
+}
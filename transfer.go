@@ -0,0 +1,74 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+)
+
+// =============================================================================
+// Transfer Middleware
+// =============================================================================
+
+// Compressions accepted by compression.
+const compressionGzip = "gzip"
+
+// middleware returns the transfer stages an upload of these properties
+// passes through: compression first, so a bandwidth limit applies to the
+// bytes on the wire.
+func (props *FileProperties) middleware() []asyncsftp.Middleware {
+	mws := compressionStages(props.Compression)
+	if props.MaxBytesPerSecond > 0 {
+		mws = append(mws, asyncsftp.Throttle(props.MaxBytesPerSecond))
+	}
+	return mws
+}
+
+// compressionStages returns the stages that undo compression on read.
+func compressionStages(compression string) []asyncsftp.Middleware {
+	if compression == compressionGzip {
+		return []asyncsftp.Middleware{asyncsftp.Gzip()}
+	}
+	return nil
+}
+
+// checkCompressionTarget requires a deployment manifest and full reads for
+// compressed files. Reads only see a path, so the manifest is what tells
+// them to decompress, and stat or sample reads would see the compressed
+// bytes rather than the content.
+func checkCompressionTarget(targetConfig json.RawMessage, props *FileProperties) error {
+	if props.Compression == "" {
+		return nil
+	}
+	cfg, err := parseTargetConfig(targetConfig)
+	if err != nil {
+		return err
+	}
+	if cfg.ManifestPath == "" {
+		return fmt.Errorf("'compression' requires 'manifestPath' on the target")
+	}
+	if !cfg.readsContent() {
+		return fmt.Errorf("'compression' requires 'readMode' full on the target")
+	}
+	return nil
+}
+
+// managedCompression returns the compression the manifest records for the
+// file at path, or "" when there is none or it cannot be read.
+func managedCompression(log plugin.Logger, client *asyncsftp.Client, cfg *TargetConfig, path string) string {
+	if cfg.ManifestPath == "" {
+		return ""
+	}
+	m, err := readManifest(client, cfg.ManifestPath)
+	if err != nil {
+		log.Debug("could not read deployment manifest", "manifest", cfg.ManifestPath, "error", err)
+		return ""
+	}
+	return m.Files[path].Compression
+}
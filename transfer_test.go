@@ -0,0 +1,68 @@
+// © 2025 Platform Engineering Labs Inc.
+//
+// SPDX-License-Identifier: FSL-1.1-ALv2
+
+//go:build unit
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform-engineering-labs/formae-plugin-sftp/pkg/asyncsftp"
+	"github.com/platform-engineering-labs/formae/pkg/plugin"
+	"github.com/platform-engineering-labs/formae/pkg/plugin/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompression verifies a compressed file is stored gzipped, read back
+// as declared, and rewritten when its compression changes.
+func TestCompression(t *testing.T) {
+	ctx := context.Background()
+	p := &Plugin{}
+	target, client := testMemoryTarget(t, p, "/upload", `"manifestPath":"/upload/.formae.json"`)
+
+	props := json.RawMessage(`{"path":"/upload/app.conf","content":"key=value\n","compression":"gzip","maxBytesPerSecond":1048576}`)
+	created, err := p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: props, TargetConfig: target})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusInProgress, created.ProgressResult.OperationStatus, created.ProgressResult.StatusMessage)
+	require.Eventually(t, func() bool {
+		status, err := p.Status(ctx, &resource.StatusRequest{RequestID: created.ProgressResult.RequestID, TargetConfig: target})
+		require.NoError(t, err)
+		return status.ProgressResult.OperationStatus == resource.OperationStatusSuccess
+	}, time.Second, time.Millisecond)
+
+	raw, err := client.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.NotEqual(t, "key=value\n", raw.Content)
+	info, err := client.ReadFileThrough("/upload/app.conf", asyncsftp.Gzip())
+	require.NoError(t, err)
+	assert.Equal(t, "key=value\n", info.Content)
+
+	read, err := p.Read(ctx, &resource.ReadRequest{ResourceType: fileType, NativeID: "/upload/app.conf", TargetConfig: target})
+	require.NoError(t, err)
+	var got FileProperties
+	require.NoError(t, json.Unmarshal([]byte(read.Properties), &got))
+	assert.Equal(t, "key=value\n", got.Content)
+	assert.Equal(t, compressionGzip, got.Compression)
+
+	updated, err := p.Update(ctx, &resource.UpdateRequest{ResourceType: fileType, NativeID: "/upload/app.conf", TargetConfig: target,
+		PriorProperties: json.RawMessage(read.Properties), DesiredProperties: json.RawMessage(`{"path":"/upload/app.conf","content":"key=value\n"}`)})
+	require.NoError(t, err)
+	require.Equal(t, resource.OperationStatusSuccess, updated.ProgressResult.OperationStatus, updated.ProgressResult.StatusMessage)
+	assert.Contains(t, updated.ProgressResult.StatusMessage, "compression gzip -> none")
+	raw, err = client.ReadFile("/upload/app.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "key=value\n", raw.Content)
+
+	noManifest := json.RawMessage(`{"url":"memory://` + t.Name() + `/upload"}`)
+	created, err = p.Create(ctx, &resource.CreateRequest{ResourceType: fileType, Properties: props, TargetConfig: noManifest})
+	require.NoError(t, err)
+	assert.Contains(t, created.ProgressResult.StatusMessage, "manifestPath")
+	_, err = parseFileProperties(json.RawMessage(`{"path":"/a","content":"x","compression":"zstd"}`))
+	assert.Error(t, err)
+}